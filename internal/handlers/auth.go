@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/lehigh-university-libraries/hocr-edit/internal/services/auth"
+	"github.com/lehigh-university-libraries/hocr-edit/internal/utils"
+)
+
+// HandleAuthMe reports who the caller is signed in as and which providers they can
+// sign in with, answering 401 with the providers when they are not signed in
+func (h *Handler) HandleAuthMe(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	response := map[string]any{"providers": h.auth.Providers()}
+	user, err := h.auth.Authenticate(r)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		response["error"] = err.Error()
+	} else {
+		response["user"] = user
+	}
+
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		slog.Error("Unable to encode user", "err", err)
+	}
+}
+
+// HandleAuthLogin signs a local user in with {"username", "password"}
+func (h *Handler) HandleAuthLogin(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var request struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		utils.RespondWithError(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	user, err := h.auth.Login(request.Username, request.Password)
+	if err != nil {
+		slog.Warn("Failed login", "username", request.Username, "remote_addr", r.RemoteAddr)
+		utils.RespondWithError(w, "Invalid username or password", http.StatusUnauthorized)
+		return
+	}
+	if err := h.auth.SetSession(w, r, user); err != nil {
+		utils.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	slog.Info("User signed in", "user", user.ID, "provider", user.Provider)
+	if err := json.NewEncoder(w).Encode(map[string]any{"user": user}); err != nil {
+		slog.Error("Unable to encode user", "err", err)
+	}
+}
+
+// HandleAuthLogout signs the browser out
+func (h *Handler) HandleAuthLogout(w http.ResponseWriter, r *http.Request) {
+	h.auth.ClearSession(w)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleOIDCLogin sends the browser to the identity provider
+func (h *Handler) HandleOIDCLogin(w http.ResponseWriter, r *http.Request) {
+	loginURL, err := h.auth.OIDCLoginURL(w, r, localPath(r.URL.Query().Get("next")))
+	if err != nil {
+		slog.Error("Unable to start OIDC login", "err", err)
+		utils.RespondWithError(w, "Unable to sign in: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	http.Redirect(w, r, loginURL, http.StatusFound)
+}
+
+// HandleOIDCCallback is where the identity provider sends the browser back to
+func (h *Handler) HandleOIDCCallback(w http.ResponseWriter, r *http.Request) {
+	user, next, err := h.auth.OIDCCallback(w, r)
+	if err != nil {
+		slog.Warn("Failed OIDC login", "err", err, "remote_addr", r.RemoteAddr)
+		utils.RespondWithError(w, "Unable to sign in: "+err.Error(), http.StatusUnauthorized)
+		return
+	}
+	if err := h.auth.SetSession(w, r, user); err != nil {
+		utils.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	slog.Info("User signed in", "user", user.ID, "provider", user.Provider)
+	http.Redirect(w, r, localPath(next), http.StatusFound)
+}
+
+// canAccessSession reports whether the caller may use a session. Sessions the
+// caller cannot reach are reported as not found, so their IDs are not confirmed.
+func (h *Handler) canAccessSession(r *http.Request, sessionID string) bool {
+	session, exists := h.sessionStore.Get(sessionID)
+	if !exists {
+		return true
+	}
+	return auth.UserFromContext(r.Context()).CanAccess(session.CreatedBy)
+}
+
+// requestAuthor is who to record as the author of a change: the signed in user, or
+// when authentication is disabled whoever the client claims to be
+func requestAuthor(r *http.Request, claimed string) string {
+	user := auth.UserFromContext(r.Context())
+	if user.Anonymous() {
+		return claimed
+	}
+	return user.ID
+}
+
+// localPath keeps redirects after login on this site
+func localPath(next string) string {
+	if !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") || strings.HasPrefix(next, "/\\") {
+		return "/"
+	}
+	return next
+}
@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/lehigh-university-libraries/hocr-edit/internal/models"
+	"github.com/lehigh-university-libraries/hocr-edit/internal/services/auth"
+	"github.com/lehigh-university-libraries/hocr-edit/internal/storage"
+)
+
+func TestSessionOwnership(t *testing.T) {
+	h := &Handler{sessionStore: storage.NewMemory()}
+	for _, session := range []*models.CorrectionSession{
+		{ID: "alice_1", CreatedBy: "alice"},
+		{ID: "alice_2", CreatedBy: "alice"},
+		{ID: "bob_1", CreatedBy: "bob"},
+		{ID: "legacy"},
+	} {
+		h.sessionStore.Set(session.ID, session)
+	}
+
+	users := map[string]*auth.User{
+		"alice": {ID: "alice"},
+		"bob":   {ID: "bob"},
+		"admin": {ID: "admin", Admin: true},
+	}
+	request := func(method, path, user string) *http.Request {
+		req := httptest.NewRequest(method, path, nil)
+		return req.WithContext(auth.WithUser(req.Context(), users[user]))
+	}
+
+	tests := []struct {
+		user     string
+		sessions int
+	}{
+		{"alice", 2},
+		{"bob", 1},
+		{"admin", 4},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		h.HandleSessions(rec, request("GET", "/api/sessions", tt.user))
+
+		var sessions []models.CorrectionSession
+		if err := json.NewDecoder(rec.Body).Decode(&sessions); err != nil {
+			t.Fatalf("Error decoding sessions: %v", err)
+		}
+		if len(sessions) != tt.sessions {
+			t.Errorf("Expected %s to see %d sessions, got %d", tt.user, tt.sessions, len(sessions))
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	h.Routes().ServeHTTP(rec, request("GET", "/api/sessions/alice_1", "bob"))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Expected another user's session to be not found, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	h.Routes().ServeHTTP(rec, request("GET", "/api/sessions/alice_1", "alice"))
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected the owner to get their session, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,405 @@
+package handlers
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lehigh-university-libraries/hocr-edit/internal/models"
+	"github.com/lehigh-university-libraries/hocr-edit/internal/services/auth"
+	"github.com/lehigh-university-libraries/hocr-edit/internal/services/jobs"
+	"github.com/lehigh-university-libraries/hocr-edit/internal/services/ocr"
+	"github.com/lehigh-university-libraries/hocr-edit/internal/utils"
+)
+
+// drupalCollectionMember is one row of the DRUPAL_COLLECTION_URL view.
+// json.Number accepts the nid whether the view renders it as a number or a string.
+type drupalCollectionMember struct {
+	NID json.Number `json:"nid"`
+}
+
+// HandleBatches queues every node of an Islandora collection, or a list of node IDs,
+// into one new session. Nodes are fetched and OCR'd in the background; progress is
+// reported on the session's batch field and at /api/sessions/{id}/batch.
+func (h *Handler) HandleBatches(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var request struct {
+		Collection string   `json:"collection"`
+		Nids       []string `json:"nids"`
+		CSV        string   `json:"csv"`
+		Engine     string   `json:"engine"`
+		ReOCR      bool     `json:"reocr"`
+	}
+
+	if strings.Contains(r.Header.Get("Content-Type"), "application/json") {
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			utils.RespondWithError(w, "Invalid JSON: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	} else {
+		request.Collection = r.FormValue("collection")
+		request.Engine = r.FormValue("engine")
+		request.ReOCR = r.FormValue("reocr") == "true"
+		if file, _, err := r.FormFile("file"); err == nil {
+			data, err := io.ReadAll(file)
+			file.Close()
+			if err != nil {
+				utils.RespondWithError(w, "Failed to read file: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+			request.CSV = string(data)
+		}
+	}
+
+	engine, err := h.ocrEngine(request.Engine)
+	if err != nil {
+		utils.RespondWithError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	nids := request.Nids
+	if request.CSV != "" {
+		csvNids, err := parseNidCSV(strings.NewReader(request.CSV))
+		if err != nil {
+			utils.RespondWithError(w, "Invalid CSV: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		nids = append(nids, csvNids...)
+	}
+	if request.Collection != "" {
+		request.Collection, err = drupalID(request.Collection)
+		if err != nil {
+			utils.RespondWithError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		members, err := h.fetchCollectionNids(r.Context(), request.Collection)
+		if err != nil {
+			utils.RespondWithError(w, "Failed to list collection: "+err.Error(), http.StatusBadGateway)
+			return
+		}
+		nids = append(nids, members...)
+	}
+
+	nids, err = uniqueNids(nids)
+	if err != nil {
+		utils.RespondWithError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(nids) == 0 {
+		utils.RespondWithError(w, "collection, nids or csv is required", http.StatusBadRequest)
+		return
+	}
+
+	sessionID, jobID, err := h.queueDrupalBatch(request.Collection, nids, engine, request.ReOCR, auth.UserFromContext(r.Context()).ID)
+	if err != nil {
+		utils.RespondWithError(w, err.Error(), jobErrorStatus(err))
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+	response := map[string]any{
+		"session_id": sessionID,
+		"job_id":     jobID,
+		"message":    fmt.Sprintf("Queued %d Drupal nodes", len(nids)),
+		"nodes":      len(nids),
+	}
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		slog.Error("Unable to encode response data", "err", err)
+	}
+}
+
+// queueDrupalBatch stores an empty session for the batch and starts ingesting it.
+// The collection and nids must have been checked by drupalID.
+func (h *Handler) queueDrupalBatch(collection string, nids []string, engine ocr.Engine, reocr bool, createdBy string) (string, string, error) {
+	name := collection
+	if name == "" {
+		name = fmt.Sprintf("%d_nodes", len(nids))
+	}
+	sessionID := fmt.Sprintf("drupal_batch_%s_%d", name, time.Now().Unix())
+
+	session := &models.CorrectionSession{
+		ID:        sessionID,
+		Images:    []models.ImageItem{},
+		Current:   0,
+		CreatedAt: time.Now(),
+		CreatedBy: createdBy,
+		Config: models.EvalConfig{
+			Model:       engine.Name(),
+			Prompt:      fmt.Sprintf("Drupal batch of %d nodes - %s", len(nids), engine.Description()),
+			Temperature: 0.0,
+			Timestamp:   time.Now().Format("2006-01-02_15-04-05"),
+		},
+		Batch: &models.BatchProgress{
+			Status:     models.BatchQueued,
+			Collection: collection,
+			Nids:       nids,
+			Skipped:    []string{},
+			Failed:     map[string]string{},
+			ReOCR:      reocr,
+			StartedAt:  time.Now(),
+		},
+	}
+
+	if err := h.sessionStore.Set(sessionID, session); err != nil {
+		return "", "", fmt.Errorf("failed to save session: %w", err)
+	}
+
+	job, err := h.jobQueue.Submit("drupal_batch", sessionID, func(ctx context.Context, progress jobs.ProgressFunc) error {
+		h.runDrupalBatch(ctx, sessionID, nids, engine, reocr, progress)
+		return ctx.Err()
+	})
+	if err != nil {
+		if deleteErr := h.sessionStore.Delete(sessionID); deleteErr != nil {
+			slog.Warn("Unable to remove session for unqueued batch", "session_id", sessionID, "err", deleteErr)
+		}
+		return "", "", err
+	}
+
+	if err := h.updateSession(sessionID, func(session *models.CorrectionSession) {
+		session.JobID = job.ID
+	}); err != nil {
+		return "", "", err
+	}
+
+	slog.Info("Drupal batch queued", "session_id", sessionID, "collection", collection, "nodes", len(nids), "job_id", job.ID)
+	return sessionID, job.ID, nil
+}
+
+// runDrupalBatch ingests the nodes one at a time, saving the session after each so
+// pages can be corrected while the rest of the batch is still being OCR'd. Failed
+// nodes are recorded on the batch rather than failing, and so retrying, the whole job.
+// Canceling ctx stops the batch before its next node and marks it canceled.
+func (h *Handler) runDrupalBatch(ctx context.Context, sessionID string, nids []string, engine ocr.Engine, reocr bool, progress jobs.ProgressFunc) {
+	h.updateBatch(sessionID, func(session *models.CorrectionSession) {
+		session.Batch.Status = models.BatchRunning
+	})
+
+	for i, nid := range nids {
+		if ctx.Err() != nil {
+			break
+		}
+
+		image, skipped, err := h.drupalBatchImage(ctx, nid, engine, reocr)
+		if ctx.Err() != nil {
+			// the node was cut short, so it is neither ingested nor failed
+			break
+		}
+		if err != nil {
+			slog.Warn("Failed to ingest Drupal node", "session_id", sessionID, "nid", nid, "err", err)
+		}
+
+		h.updateBatch(sessionID, func(session *models.CorrectionSession) {
+			session.Batch.Processed++
+			switch {
+			case err != nil:
+				session.Batch.Failed[nid] = err.Error()
+			case skipped:
+				session.Batch.Skipped = append(session.Batch.Skipped, nid)
+			default:
+				appendImage(session, image)
+			}
+		})
+		progress(i+1, len(nids))
+	}
+
+	if ctx.Err() != nil {
+		h.finishBatch(sessionID, models.BatchCanceled)
+		slog.Info("Drupal batch canceled", "session_id", sessionID, "nodes", len(nids))
+		return
+	}
+
+	h.finishBatch(sessionID, models.BatchCompleted)
+	slog.Info("Drupal batch finished", "session_id", sessionID, "nodes", len(nids))
+}
+
+// drupalBatchImage OCRs the service file of a node. Nodes that already have hOCR
+// in Drupal are skipped unless reocr is set.
+func (h *Handler) drupalBatchImage(ctx context.Context, nid string, engine ocr.Engine, reocr bool) (models.ImageItem, bool, error) {
+	node, err := h.fetchDrupalNode(ctx, nid)
+	if err != nil {
+		return models.ImageItem{}, false, err
+	}
+
+	if node.hasExistingHOCR() && !reocr {
+		slog.Info("Skipping Drupal node with existing hOCR", "nid", nid, "hocr_uri", node.HOCRFile.URI)
+		return models.ImageItem{}, true, nil
+	}
+
+	image, err := h.imageItemFromURL(ctx, node.ImageURL, "", engine)
+	if err != nil {
+		return models.ImageItem{}, false, err
+	}
+	image.DrupalUploadURL = node.HOCRUploadURL
+	image.DrupalNid = nid
+
+	return image, false, nil
+}
+
+func (h *Handler) updateBatch(sessionID string, update func(session *models.CorrectionSession)) {
+	err := h.updateSession(sessionID, func(session *models.CorrectionSession) {
+		if session.Batch == nil {
+			session.Batch = &models.BatchProgress{Failed: map[string]string{}}
+		}
+		update(session)
+	})
+	if err != nil {
+		slog.Error("Unable to save batch progress", "session_id", sessionID, "err", err)
+	}
+}
+
+// finishBatch records that the batch of a session has stopped, unless it already had
+func (h *Handler) finishBatch(sessionID, status string) {
+	err := h.updateSession(sessionID, func(session *models.CorrectionSession) {
+		if session.Batch == nil || session.Batch.FinishedAt != nil {
+			return
+		}
+		finishedAt := time.Now()
+		session.Batch.Status = status
+		session.Batch.FinishedAt = &finishedAt
+	})
+	if err != nil {
+		slog.Error("Unable to save batch progress", "session_id", sessionID, "err", err)
+	}
+}
+
+// handleBatchProgress reports how far the background ingestion of a session has got
+func (h *Handler) handleBatchProgress(w http.ResponseWriter, _ *http.Request, sessionID string) {
+	w.Header().Set("Content-Type", "application/json")
+
+	session, exists := h.sessionStore.Get(sessionID)
+	if !exists {
+		utils.RespondWithError(w, "Session not found", http.StatusNotFound)
+		return
+	}
+	if session.Batch == nil {
+		utils.RespondWithError(w, "Session was not created from a batch", http.StatusNotFound)
+		return
+	}
+
+	response := struct {
+		*models.BatchProgress
+		Total  int `json:"total"`
+		Images int `json:"images"`
+	}{
+		BatchProgress: session.Batch,
+		Total:         len(session.Batch.Nids),
+		Images:        len(session.Images),
+	}
+
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		slog.Error("Unable to encode batch progress", "err", err)
+	}
+}
+
+// fetchCollectionNids lists the members of a collection via DRUPAL_COLLECTION_URL,
+// a view URL with a %s placeholder for the collection node ID
+func (h *Handler) fetchCollectionNids(ctx context.Context, collection string) ([]string, error) {
+	collectionURL := os.Getenv("DRUPAL_COLLECTION_URL")
+	if collectionURL == "" {
+		return nil, fmt.Errorf("DRUPAL_COLLECTION_URL environment variable not set")
+	}
+	collection, err := drupalID(collection)
+	if err != nil {
+		return nil, err
+	}
+
+	requestURL := fmt.Sprintf(collectionURL, collection)
+	slog.Info("Fetching Drupal collection", "collection", collection, "url", requestURL)
+
+	data, _, err := h.fetcher.Get(ctx, requestURL, jsonContentTypes...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch collection: %w", err)
+	}
+
+	var members []drupalCollectionMember
+	if err := json.Unmarshal(data, &members); err != nil {
+		return nil, fmt.Errorf("failed to parse collection JSON: %w", err)
+	}
+
+	nids := make([]string, 0, len(members))
+	for _, member := range members {
+		if member.NID != "" {
+			nids = append(nids, member.NID.String())
+		}
+	}
+
+	return nids, nil
+}
+
+// parseNidCSV reads node IDs from the "nid" column of a CSV with a header row,
+// or from the first column when there is no such header
+func parseNidCSV(r io.Reader) ([]string, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+
+	column := 0
+	if index := slices.IndexFunc(records[0], func(field string) bool {
+		return strings.EqualFold(strings.TrimSpace(field), "nid")
+	}); index >= 0 {
+		column = index
+		records = records[1:]
+	}
+
+	var nids []string
+	for _, record := range records {
+		if column >= len(record) {
+			continue
+		}
+		if nid := strings.TrimSpace(record[column]); nid != "" {
+			nids = append(nids, nid)
+		}
+	}
+
+	return nids, nil
+}
+
+// uniqueNids drops blank and repeated node IDs, failing on any that is not a node number
+func uniqueNids(nids []string) ([]string, error) {
+	seen := make(map[string]bool, len(nids))
+	unique := make([]string, 0, len(nids))
+	for _, nid := range nids {
+		if strings.TrimSpace(nid) == "" {
+			continue
+		}
+		nid, err := drupalID(nid)
+		if err != nil {
+			return nil, err
+		}
+		if seen[nid] {
+			continue
+		}
+		seen[nid] = true
+		unique = append(unique, nid)
+	}
+	return unique, nil
+}
+
+// drupalID checks that a node or collection ID is a node number, as it goes into
+// Drupal URLs and session IDs, and returns it without padding or sign
+func drupalID(id string) (string, error) {
+	id = strings.TrimSpace(id)
+	n, err := strconv.Atoi(id)
+	if err != nil || n < 1 {
+		return "", fmt.Errorf("invalid Drupal node ID %q", id)
+	}
+	return strconv.Itoa(n), nil
+}
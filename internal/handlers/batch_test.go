@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/lehigh-university-libraries/hocr-edit/internal/models"
+	"github.com/lehigh-university-libraries/hocr-edit/internal/storage"
+)
+
+func TestParseNidCSV(t *testing.T) {
+	tests := []struct {
+		name string
+		csv  string
+		want []string
+	}{
+		{"single column", "12\n13\n\n14\n", []string{"12", "13", "14"}},
+		{"nid header", "title,nid\nLetter,12\n\"Diary, vol 2\",13\n", []string{"12", "13"}},
+		{"first column without header", "12,Letter\n13,Diary\n", []string{"12", "13"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseNidCSV(strings.NewReader(tt.csv))
+			if err != nil {
+				t.Fatalf("Error parsing CSV: %v", err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestUniqueNids(t *testing.T) {
+	got, err := uniqueNids([]string{"12", " 13", "12", "", "13 ", "013"})
+	if err != nil || !slices.Equal(got, []string{"12", "13"}) {
+		t.Errorf("Expected [12 13], got %v, %v", got, err)
+	}
+
+	for _, nid := range []string{"12/../../admin", "12?_format=json", "-3", "0", "abc"} {
+		if _, err := uniqueNids([]string{"12", nid}); err == nil {
+			t.Errorf("Expected %q to be refused", nid)
+		}
+	}
+}
+
+func TestDrupalBatchCanceled(t *testing.T) {
+	h := &Handler{sessionStore: storage.NewMemory()}
+	err := h.sessionStore.Set("batch", &models.CorrectionSession{
+		ID:    "batch",
+		Batch: &models.BatchProgress{Status: models.BatchQueued, Nids: []string{"12", "13"}, Failed: map[string]string{}},
+	})
+	if err != nil {
+		t.Fatalf("Error storing session: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	h.runDrupalBatch(ctx, "batch", []string{"12", "13"}, nil, false, func(int, int) {
+		t.Error("Expected no node to be processed after cancellation")
+	})
+
+	session, _ := h.sessionStore.Get("batch")
+	if session.Batch.Status != models.BatchCanceled || session.Batch.FinishedAt == nil || session.Batch.Processed != 0 {
+		t.Errorf("Expected the batch to stop as canceled, got %+v", session.Batch)
+	}
+}
+
+func TestDrupalBatchImageIDsAfterDelete(t *testing.T) {
+	h := &Handler{sessionStore: storage.NewMemory()}
+	err := h.sessionStore.Set("batch", &models.CorrectionSession{
+		ID:     "batch",
+		Images: []models.ImageItem{{ID: "img_1"}, {ID: "img_2"}},
+		Batch:  &models.BatchProgress{Status: models.BatchRunning, Nids: []string{"12", "13", "14"}, Failed: map[string]string{}},
+	})
+	if err != nil {
+		t.Fatalf("Error storing session: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	h.Routes().ServeHTTP(rec, newRequest("DELETE", "/api/sessions/batch/images/img_1", nil))
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("Expected the page to be deleted, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	// the batch saves its next node as runDrupalBatch does
+	h.updateBatch("batch", func(session *models.CorrectionSession) {
+		session.Batch.Processed++
+		appendImage(session, models.ImageItem{DrupalNid: "14"})
+	})
+
+	session, _ := h.sessionStore.Get("batch")
+	ids := make([]string, 0, len(session.Images))
+	for _, image := range session.Images {
+		ids = append(ids, image.ID)
+	}
+	if !slices.Equal(ids, []string{"img_2", "img_3"}) {
+		t.Errorf("Expected the new page to get a fresh ID, got %v", ids)
+	}
+}
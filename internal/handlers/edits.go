@@ -0,0 +1,277 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/lehigh-university-libraries/hocr-edit/internal/models"
+	"github.com/lehigh-university-libraries/hocr-edit/internal/services/auth"
+	"github.com/lehigh-university-libraries/hocr-edit/internal/services/hocr"
+	"github.com/lehigh-university-libraries/hocr-edit/internal/utils"
+	"github.com/lehigh-university-libraries/hocr-edit/pkg/hocr/parser"
+)
+
+// errInvalidEdit marks edits that are well formed but do not make a valid document
+var errInvalidEdit = errors.New("invalid edit")
+
+// editFunc applies an operation to the parsed pages of an image and returns what it
+// created or changed, for the response, and the IDs to record in the operation log
+type editFunc func(pages []models.HOCRPage) (any, []string, error)
+
+// editBuilder decodes an edit request into the operation it performs. decode reads
+// the JSON body and fails when there is none.
+type editBuilder func(r *http.Request, decode func(v any) error) (models.Operation, editFunc, error)
+
+// imageEdit serves one edit of the word and line edit API under
+// /api/sessions/{id}/images/{imageId}/:
+//
+//	PATCH  words/{wordId}        change text, bbox or confidence
+//	DELETE words/{wordId}        delete a word
+//	POST   words/{wordId}/split  split a word into {"texts": [...]}
+//	POST   words/{wordId}/merge  merge with the neighbouring word {"with": id}
+//	POST   lines/{lineId}/words  insert a word {"text", "bbox", "confidence"}
+//	POST   lines                 insert a line {"text", "bbox", "confidence", "after"}
+//	PATCH  lines/{lineId}        replace the text of a line {"text"}
+//	DELETE lines/{lineId}        delete a line
+//
+// Each edit is applied to the image's current hOCR, validated, stored and logged.
+// With If-Match the edit is only applied to that version of the session.
+func (h *Handler) imageEdit(build editBuilder) func(w http.ResponseWriter, r *http.Request, sessionID, imageID string) {
+	return func(w http.ResponseWriter, r *http.Request, sessionID, imageID string) {
+		w.Header().Set("Content-Type", "application/json")
+
+		params, err := io.ReadAll(r.Body)
+		if err != nil {
+			utils.RespondWithError(w, "Failed to read request: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if len(strings.TrimSpace(string(params))) == 0 {
+			params = nil
+		}
+
+		expected, err := expectedVersion(r, nil)
+		if err != nil {
+			utils.RespondWithError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		operation, apply, err := build(r, func(v any) error {
+			if params == nil {
+				return fmt.Errorf("request body is required")
+			}
+			if err := json.Unmarshal(params, v); err != nil {
+				return fmt.Errorf("invalid JSON: %w", err)
+			}
+			return nil
+		})
+		if err != nil {
+			utils.RespondWithError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		operation.ImageID = imageID
+		if json.Valid(params) {
+			operation.Params = params
+		}
+		result, session, err := h.applyEdit(sessionID, expected, auth.UserFromContext(r.Context()), &operation, apply)
+		if err != nil {
+			status := sessionWriteStatus(err)
+			switch {
+			case errors.Is(err, hocr.ErrNotFound):
+				status = http.StatusNotFound
+			case errors.Is(err, errInvalidEdit):
+				status = http.StatusUnprocessableEntity
+			}
+			utils.RespondWithError(w, err.Error(), status)
+			return
+		}
+
+		index := slices.IndexFunc(session.Images, func(image models.ImageItem) bool {
+			return image.ID == imageID
+		})
+		w.Header().Set("ETag", sessionETag(session))
+		response := map[string]any{
+			"operation": operation,
+			"result":    result,
+			"hocr":      session.Images[index].CorrectedHOCR,
+			"version":   session.Version,
+		}
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			slog.Error("Unable to encode edit result", "err", err)
+		}
+	}
+}
+
+func editWord(r *http.Request, decode func(v any) error) (models.Operation, editFunc, error) {
+	wordID := r.PathValue("wordId")
+	var edit hocr.WordEdit
+	if err := decode(&edit); err != nil {
+		return models.Operation{}, nil, err
+	}
+	return models.Operation{Type: "edit_word", Target: wordID}, func(pages []models.HOCRPage) (any, []string, error) {
+		word, err := hocr.EditWord(pages, wordID, edit)
+		return word, []string{word.ID}, err
+	}, nil
+}
+
+func deleteWord(r *http.Request, _ func(v any) error) (models.Operation, editFunc, error) {
+	wordID := r.PathValue("wordId")
+	return models.Operation{Type: "delete_word", Target: wordID}, func(pages []models.HOCRPage) (any, []string, error) {
+		return nil, nil, hocr.DeleteWord(pages, wordID)
+	}, nil
+}
+
+func splitWord(r *http.Request, decode func(v any) error) (models.Operation, editFunc, error) {
+	wordID := r.PathValue("wordId")
+	var request struct {
+		Texts []string `json:"texts"`
+	}
+	if err := decode(&request); err != nil {
+		return models.Operation{}, nil, err
+	}
+	return models.Operation{Type: "split_word", Target: wordID}, func(pages []models.HOCRPage) (any, []string, error) {
+		words, err := hocr.SplitWord(pages, wordID, request.Texts)
+		return words, wordIDs(words), err
+	}, nil
+}
+
+func mergeWords(r *http.Request, decode func(v any) error) (models.Operation, editFunc, error) {
+	wordID := r.PathValue("wordId")
+	var request struct {
+		With string `json:"with"`
+	}
+	if err := decode(&request); err != nil {
+		return models.Operation{}, nil, err
+	}
+	return models.Operation{Type: "merge_words", Target: wordID}, func(pages []models.HOCRPage) (any, []string, error) {
+		word, err := hocr.MergeWords(pages, wordID, request.With)
+		return word, []string{word.ID}, err
+	}, nil
+}
+
+func insertWord(r *http.Request, decode func(v any) error) (models.Operation, editFunc, error) {
+	lineID := r.PathValue("lineId")
+	var word models.HOCRWord
+	if err := decode(&word); err != nil {
+		return models.Operation{}, nil, err
+	}
+	return models.Operation{Type: "insert_word", Target: lineID}, func(pages []models.HOCRPage) (any, []string, error) {
+		inserted, err := hocr.InsertWord(pages, lineID, word)
+		return inserted, []string{inserted.ID}, err
+	}, nil
+}
+
+func insertLine(_ *http.Request, decode func(v any) error) (models.Operation, editFunc, error) {
+	var request struct {
+		Text       string      `json:"text"`
+		BBox       models.BBox `json:"bbox"`
+		Confidence *float64    `json:"confidence"`
+		After      string      `json:"after"`
+	}
+	if err := decode(&request); err != nil {
+		return models.Operation{}, nil, err
+	}
+	confidence := 100.0
+	if request.Confidence != nil {
+		confidence = *request.Confidence
+	}
+	return models.Operation{Type: "insert_line", Target: request.After}, func(pages []models.HOCRPage) (any, []string, error) {
+		line, err := hocr.InsertLine(pages, request.Text, request.BBox, confidence, request.After)
+		return line, append([]string{line.ID}, wordIDs(line.Words)...), err
+	}, nil
+}
+
+func editLine(r *http.Request, decode func(v any) error) (models.Operation, editFunc, error) {
+	lineID := r.PathValue("lineId")
+	var request struct {
+		Text string `json:"text"`
+	}
+	if err := decode(&request); err != nil {
+		return models.Operation{}, nil, err
+	}
+	return models.Operation{Type: "edit_line", Target: lineID}, func(pages []models.HOCRPage) (any, []string, error) {
+		line, err := hocr.EditLine(pages, lineID, request.Text)
+		return line, wordIDs(line.Words), err
+	}, nil
+}
+
+func deleteLine(r *http.Request, _ func(v any) error) (models.Operation, editFunc, error) {
+	lineID := r.PathValue("lineId")
+	return models.Operation{Type: "delete_line", Target: lineID}, func(pages []models.HOCRPage) (any, []string, error) {
+		return nil, nil, hocr.DeleteLine(pages, lineID)
+	}, nil
+}
+
+// applyEdit runs an edit against the current hOCR of an image inside a store
+// update, so concurrent edits apply one after the other instead of overwriting each
+// other. The operation is appended to the session's log and the saved session returned.
+func (h *Handler) applyEdit(sessionID string, expected *int, user *auth.User, operation *models.Operation, apply editFunc) (any, *models.CorrectionSession, error) {
+	var result any
+	session, err := h.sessionStore.Update(sessionID, func(session *models.CorrectionSession) error {
+		if err := checkExpectedVersion(session, expected); err != nil {
+			return err
+		}
+
+		index := slices.IndexFunc(session.Images, func(image models.ImageItem) bool {
+			return image.ID == operation.ImageID
+		})
+		if index < 0 {
+			return fmt.Errorf("image %s: %w", operation.ImageID, hocr.ErrNotFound)
+		}
+		image := &session.Images[index]
+		if err := checkEditable(image, user); err != nil {
+			return err
+		}
+
+		hocrXML := image.CorrectedHOCR
+		if hocrXML == "" {
+			hocrXML = image.OriginalHOCR
+		}
+		pages, err := parser.ParseHOCRPages(hocrXML)
+		if err != nil {
+			return fmt.Errorf("failed to parse hOCR: %w", err)
+		}
+
+		var ids []string
+		result, ids, err = apply(pages)
+		if err != nil {
+			if errors.Is(err, hocr.ErrNotFound) {
+				return err
+			}
+			return fmt.Errorf("%w: %w", errInvalidEdit, err)
+		}
+
+		pages, err = hocr.CanonicalPages(pages)
+		if err != nil {
+			return fmt.Errorf("%w: %w", errInvalidEdit, err)
+		}
+
+		image.CorrectedHOCR = sessionConverter(session).ConvertHOCRPagesToXML(pages)
+		operation.ID = fmt.Sprintf("op_%d", len(session.Operations)+1)
+		operation.Result = ids
+		operation.At = time.Now()
+		session.Operations = append(session.Operations, *operation)
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	slog.Info("Applied hOCR edit", "session_id", sessionID, "image_id", operation.ImageID, "operation", operation.Type, "target", operation.Target)
+	return result, session, nil
+}
+
+func wordIDs(words []models.HOCRWord) []string {
+	ids := make([]string, len(words))
+	for i, word := range words {
+		ids[i] = word.ID
+	}
+	return ids
+}
@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/lehigh-university-libraries/hocr-edit/internal/models"
+	"github.com/lehigh-university-libraries/hocr-edit/internal/services/auth"
+	"github.com/lehigh-university-libraries/hocr-edit/internal/storage"
+	"github.com/lehigh-university-libraries/hocr-edit/pkg/hocr/parser"
+)
+
+const editTestHOCR = `<?xml version="1.0" encoding="UTF-8"?>
+<html xmlns="http://www.w3.org/1999/xhtml"><body>
+<div class='ocr_page' id='page_1' title='bbox 0 0 500 400'>
+<span class='ocr_line' id='line_1' title='bbox 10 10 200 30'><span class='ocrx_word' id='word_1' title='bbox 10 10 90 30; x_wconf 80'>Dearsir</span> <span class='ocrx_word' id='word_2' title='bbox 100 10 140 30; x_wconf 70'>yo</span> <span class='ocrx_word' id='word_3' title='bbox 145 10 200 30; x_wconf 90'>urs</span></span>
+</div>
+</body></html>`
+
+func newEditTestHandler(t *testing.T) *Handler {
+	t.Helper()
+	store := storage.NewMemory()
+	err := store.Set("s1", &models.CorrectionSession{
+		ID:     "s1",
+		Config: models.EvalConfig{Model: "tesseract"},
+		Images: []models.ImageItem{{ID: "img_1", OriginalHOCR: editTestHOCR, ImageWidth: 500, ImageHeight: 400}},
+	})
+	if err != nil {
+		t.Fatalf("Error storing session: %v", err)
+	}
+	return &Handler{sessionStore: store}
+}
+
+// newRequest is a request as the auth middleware passes it on when authentication
+// is disabled
+func newRequest(method, target string, body io.Reader) *http.Request {
+	req := httptest.NewRequest(method, target, body)
+	return req.WithContext(auth.WithUser(req.Context(), auth.Anonymous))
+}
+
+func doEdit(t *testing.T, h *Handler, method, path, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := newRequest(method, "/api/sessions/s1/images/img_1/"+path, strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	h.Routes().ServeHTTP(rec, req)
+	return rec
+}
+
+func editedWords(t *testing.T, h *Handler) []models.HOCRWord {
+	t.Helper()
+	session, _ := h.sessionStore.Get("s1")
+	words, err := parser.ParseHOCRWords(session.Images[0].CorrectedHOCR)
+	if err != nil {
+		t.Fatalf("Error parsing corrected hOCR: %v", err)
+	}
+	return words
+}
+
+func TestImageEdits(t *testing.T) {
+	h := newEditTestHandler(t)
+
+	rec := doEdit(t, h, "POST", "words/word_1/split", `{"texts": ["Dear", "sir"]}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected split to succeed, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	rec = doEdit(t, h, "POST", "words/word_2/merge", `{"with": "word_3"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected merge to succeed, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	rec = doEdit(t, h, "PATCH", "words/word_4", `{"text": "Sir"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected edit to succeed, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	words := editedWords(t, h)
+	var texts []string
+	for _, word := range words {
+		texts = append(texts, word.ID+"="+word.Text)
+	}
+	if got := strings.Join(texts, " "); got != "word_1=Dear word_4=Sir word_2=yours" {
+		t.Errorf("Unexpected words after edits: %s", got)
+	}
+	if words[0].BBox.X2 != 56 || words[1].BBox.X1 != 56 {
+		t.Errorf("Expected the split to divide the bbox 4:3, got %+v and %+v", words[0].BBox, words[1].BBox)
+	}
+	if words[2].Confidence != 70 || words[2].BBox.X2 != 200 {
+		t.Errorf("Expected the merged word to cover both and keep the lower confidence, got %+v", words[2])
+	}
+
+	session, _ := h.sessionStore.Get("s1")
+	if !strings.Contains(session.Images[0].CorrectedHOCR, "content='tesseract'") {
+		t.Error("Expected the session's engine as the ocr-system")
+	}
+	if len(session.Operations) != 3 || session.Operations[0].Type != "split_word" || session.Operations[2].ID != "op_3" {
+		t.Fatalf("Unexpected operation log %+v", session.Operations)
+	}
+	if strings.Join(session.Operations[0].Result, ",") != "word_1,word_4" {
+		t.Errorf("Expected the split to log the words it made, got %v", session.Operations[0].Result)
+	}
+}
+
+func TestSessionHOCRExport(t *testing.T) {
+	h := newEditTestHandler(t)
+
+	rec := httptest.NewRecorder()
+	h.Routes().ServeHTTP(rec, newRequest("GET", "/api/sessions/s1/hocr", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if !strings.Contains(rec.Body.String(), "<meta name='ocr-system' content='tesseract'") {
+		t.Errorf("Expected the session's engine as the ocr-system, got:\n%s", rec.Body.String())
+	}
+}
+
+func TestImageLineEdits(t *testing.T) {
+	h := newEditTestHandler(t)
+
+	rec := doEdit(t, h, "POST", "lines", `{"text": "new line", "bbox": {"x1": 10, "y1": 50, "x2": 90, "y2": 70}, "after": "line_1"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected insert to succeed, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var response struct {
+		Result models.HOCRLine `json:"result"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+		t.Fatalf("Error decoding response: %v", err)
+	}
+	if response.Result.ID != "line_2" || len(response.Result.Words) != 2 {
+		t.Errorf("Unexpected inserted line %+v", response.Result)
+	}
+
+	if rec := doEdit(t, h, "DELETE", "lines/line_1", ""); rec.Code != http.StatusOK {
+		t.Fatalf("Expected delete to succeed, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	words := editedWords(t, h)
+	if len(words) != 2 || words[0].Text != "new" || words[0].LineID != "line_2" {
+		t.Errorf("Unexpected words after line edits %+v", words)
+	}
+}
+
+func TestImageEditErrors(t *testing.T) {
+	h := newEditTestHandler(t)
+
+	tests := []struct {
+		method, path, body string
+		want               int
+	}{
+		{"PATCH", "words/missing", `{"text": "x"}`, http.StatusNotFound},
+		{"PATCH", "words/word_1", `{"text": " "}`, http.StatusUnprocessableEntity},
+		{"POST", "words/word_1/merge", `{"with": "word_3"}`, http.StatusUnprocessableEntity},
+		{"POST", "words/word_1/split", `{"texts": ["only"]}`, http.StatusUnprocessableEntity},
+		{"PATCH", "words/word_1", `not json`, http.StatusBadRequest},
+		{"GET", "words/word_1", ``, http.StatusMethodNotAllowed},
+	}
+
+	for _, tt := range tests {
+		if rec := doEdit(t, h, tt.method, tt.path, tt.body); rec.Code != tt.want {
+			t.Errorf("%s %s: expected %d, got %d: %s", tt.method, tt.path, tt.want, rec.Code, rec.Body.String())
+		}
+	}
+
+	session, _ := h.sessionStore.Get("s1")
+	if session.Images[0].CorrectedHOCR != "" || len(session.Operations) != 0 {
+		t.Error("Expected failed edits to leave the session unchanged")
+	}
+}
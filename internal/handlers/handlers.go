@@ -1,163 +1,768 @@
 package handlers
 
 import (
+	"archive/zip"
 	"bytes"
+	"context"
 	"crypto/md5"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
 	"net/http"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"time"
 
 	"github.com/lehigh-university-libraries/hocr-edit/internal/models"
+	"github.com/lehigh-university-libraries/hocr-edit/internal/services/auth"
+	"github.com/lehigh-university-libraries/hocr-edit/internal/services/drupal"
+	"github.com/lehigh-university-libraries/hocr-edit/internal/services/fetch"
 	"github.com/lehigh-university-libraries/hocr-edit/internal/services/hocr"
+	"github.com/lehigh-university-libraries/hocr-edit/internal/services/jobs"
 	"github.com/lehigh-university-libraries/hocr-edit/internal/services/ocr"
 	"github.com/lehigh-university-libraries/hocr-edit/internal/storage"
 	"github.com/lehigh-university-libraries/hocr-edit/internal/utils"
+	"github.com/lehigh-university-libraries/hocr-edit/pkg/hocr/alto"
+	"github.com/lehigh-university-libraries/hocr-edit/pkg/hocr/pagexml"
 	"github.com/lehigh-university-libraries/hocr-edit/pkg/hocr/parser"
+	"github.com/lehigh-university-libraries/hocr-edit/pkg/hocr/pdf"
 	"github.com/lehigh-university-libraries/hocr-edit/pkg/metrics"
+	"golang.org/x/text/language"
 )
 
 type Handler struct {
-	sessionStore *storage.SessionStore
-	ocrService   *ocr.Service
+	sessionStore    storage.SessionStore
+	ocrEngines      map[string]ocr.Engine
+	drupalPublisher *drupal.Publisher
+	jobQueue        *jobs.Queue
+	auth            *auth.Service
+	fetcher         *fetch.Fetcher
 }
 
-func New() *Handler {
+func New(sessionStore storage.SessionStore, authService *auth.Service, fetcher *fetch.Fetcher) *Handler {
+	engines := make(map[string]ocr.Engine)
+	for _, name := range ocr.EngineNames() {
+		engine, err := ocr.NewEngine(name)
+		if err != nil {
+			slog.Warn("Unable to initialize OCR engine", "engine", name, "err", err)
+			continue
+		}
+		engines[name] = engine
+	}
+
+	publisher, err := drupal.NewPublisher()
+	if err != nil {
+		slog.Warn("Unable to configure Drupal publishing", "err", err)
+	}
+
 	return &Handler{
-		sessionStore: storage.New(),
-		ocrService:   ocr.New(),
+		sessionStore:    sessionStore,
+		ocrEngines:      engines,
+		drupalPublisher: publisher,
+		jobQueue:        jobs.New(),
+		auth:            authService,
+		fetcher:         fetcher,
+	}
+}
+
+// HandleEngines lists the OCR engines a session can be created with
+func (h *Handler) HandleEngines(w http.ResponseWriter, r *http.Request) {
+	type engineInfo struct {
+		Name        string `json:"name"`
+		Description string `json:"description"`
+		Default     bool   `json:"default"`
+	}
+
+	defaultEngine := ocr.DefaultEngineName()
+	engines := make([]engineInfo, 0, len(h.ocrEngines))
+	for _, name := range ocr.EngineNames() {
+		engine, ok := h.ocrEngines[name]
+		if !ok {
+			continue
+		}
+		engines = append(engines, engineInfo{
+			Name:        engine.Name(),
+			Description: engine.Description(),
+			Default:     engine.Name() == defaultEngine,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(engines); err != nil {
+		slog.Error("Unable to encode engines", "err", err)
+		utils.RespondWithError(w, "Invalid JSON", http.StatusInternalServerError)
+	}
+}
+
+// ocrEngine resolves the engine a request asked for, falling back to the default
+func (h *Handler) ocrEngine(name string) (ocr.Engine, error) {
+	if name == "" {
+		name = ocr.DefaultEngineName()
+	}
+
+	engine, ok := h.ocrEngines[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown OCR engine %q", name)
+	}
+
+	return engine, nil
+}
+
+// hocrCacheFilename keeps Google Cloud Vision output at the historical <md5>.xml
+// path so existing caches stay valid, and namespaces every other engine.
+func hocrCacheFilename(md5Hash string, engine ocr.Engine) string {
+	if engine.Name() == ocr.EngineGoogleCloudVision {
+		return md5Hash + ".xml"
 	}
+	return md5Hash + "_" + engine.Name() + ".xml"
 }
 
+// HandleSessions lists the sessions the caller created, or every session for admins
 func (h *Handler) HandleSessions(w http.ResponseWriter, r *http.Request) {
-	switch r.Method {
-	case "GET":
-		sessions := h.sessionStore.GetAll()
-		sessionList := make([]*models.CorrectionSession, 0, len(sessions))
-		for _, session := range sessions {
+	w.Header().Set("Content-Type", "application/json")
+
+	user := auth.UserFromContext(r.Context())
+	sessions := h.sessionStore.GetAll()
+	sessionList := make([]*models.CorrectionSession, 0, len(sessions))
+	for _, session := range sessions {
+		if user.CanAccess(session.CreatedBy) {
 			sessionList = append(sessionList, session)
 		}
-		if err := json.NewEncoder(w).Encode(sessionList); err != nil {
-			http.Error(w, "Failed to encode response", http.StatusInternalServerError)
-			return
+	}
+	if err := json.NewEncoder(w).Encode(sessionList); err != nil {
+		slog.Error("Unable to encode sessions", "err", err)
+	}
+}
+
+func (h *Handler) getSession(w http.ResponseWriter, _ *http.Request, sessionID string) {
+	w.Header().Set("Content-Type", "application/json")
+
+	session, exists := h.sessionStore.Get(sessionID)
+	if !exists {
+		utils.RespondWithError(w, "Session not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("ETag", sessionETag(session))
+	if err := json.NewEncoder(w).Encode(session); err != nil {
+		slog.Error("Unable to encode session data", "err", err)
+	}
+}
+
+// putSession replaces the whole session, so it must be made against the current
+// version, given by If-Match or else by the version in the body
+func (h *Handler) putSession(w http.ResponseWriter, r *http.Request, sessionID string) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var updatedSession models.CorrectionSession
+	if err := json.NewDecoder(r.Body).Decode(&updatedSession); err != nil {
+		slog.Error("Unable to decode session data", "err", err)
+		utils.RespondWithError(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	expected, err := expectedVersion(r, &updatedSession.Version)
+	if err != nil {
+		utils.RespondWithError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	saved, err := h.sessionStore.Update(sessionID, func(session *models.CorrectionSession) error {
+		if err := checkExpectedVersion(session, expected); err != nil {
+			return err
 		}
-	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		language, err := sessionLanguage(updatedSession.Language)
+		if err != nil {
+			return err
+		}
+		updatedSession.ID = session.ID
+		updatedSession.Version = session.Version
+		updatedSession.CreatedBy = session.CreatedBy
+		updatedSession.Language = language
+		keepReviewState(session, &updatedSession)
+		keepHistory(session, &updatedSession)
+		keepDrupalLinks(session, &updatedSession)
+		if err := checkLockedPages(session, &updatedSession, auth.UserFromContext(r.Context())); err != nil {
+			return err
+		}
+		*session = updatedSession
+		return nil
+	})
+	if err != nil {
+		slog.Error("Unable to save session", "session_id", sessionID, "err", err)
+		utils.RespondWithError(w, "Failed to save session: "+err.Error(), sessionWriteStatus(err))
+		return
+	}
+
+	w.Header().Set("ETag", sessionETag(saved))
+	if err := json.NewEncoder(w).Encode(saved); err != nil {
+		slog.Error("Unable to encode session data", "err", err)
 	}
 }
 
-func (h *Handler) HandleSessionDetail(w http.ResponseWriter, r *http.Request) {
-	sessionID := strings.TrimPrefix(r.URL.Path, "/api/sessions/")
+// keepDrupalLinks stops a PUT of the whole session from pointing its pages at other
+// Drupal nodes, as the publisher posts to DrupalUploadURL with its credentials.
+// Only the server sets them, when it creates a page from a node.
+func keepDrupalLinks(stored, updated *models.CorrectionSession) {
+	for i := range updated.Images {
+		index := slices.IndexFunc(stored.Images, func(image models.ImageItem) bool {
+			return image.ID == updated.Images[i].ID
+		})
+		if index < 0 {
+			updated.Images[i].DrupalUploadURL = ""
+			updated.Images[i].DrupalNid = ""
+			continue
+		}
+		updated.Images[i].DrupalUploadURL = stored.Images[index].DrupalUploadURL
+		updated.Images[i].DrupalNid = stored.Images[index].DrupalNid
+	}
+}
 
-	if strings.HasSuffix(sessionID, "/metrics") {
-		sessionID = strings.TrimSuffix(sessionID, "/metrics")
-		if r.Method == "POST" {
-			h.handleMetrics(w, r, sessionID)
-			return
+// patchSession changes the fields of a session that are not managed by other
+// routes: the image the editor is on, the OCR settings it was made with and the
+// language its hOCR is tagged with, where an empty language keeps the detected ones
+func (h *Handler) patchSession(w http.ResponseWriter, r *http.Request, sessionID string) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var patch struct {
+		Current  *int               `json:"current"`
+		Config   *models.EvalConfig `json:"config"`
+		Language *string            `json:"language"`
+		Version  *int               `json:"version"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+		utils.RespondWithError(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	expected, err := expectedVersion(r, patch.Version)
+	if err != nil {
+		utils.RespondWithError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	saved, err := h.sessionStore.Update(sessionID, func(session *models.CorrectionSession) error {
+		if err := checkExpectedVersion(session, expected); err != nil {
+			return err
+		}
+		if patch.Current != nil {
+			if *patch.Current < 0 || *patch.Current > len(session.Images) {
+				return fmt.Errorf("%w: current must be between 0 and %d", errInvalidEdit, len(session.Images))
+			}
+			session.Current = *patch.Current
 		}
+		if patch.Config != nil {
+			session.Config = *patch.Config
+		}
+		if patch.Language != nil {
+			tag, err := sessionLanguage(*patch.Language)
+			if err != nil {
+				return err
+			}
+			session.Language = tag
+		}
+		return nil
+	})
+	if err != nil {
+		utils.RespondWithError(w, err.Error(), sessionWriteStatus(err))
+		return
+	}
+
+	w.Header().Set("ETag", sessionETag(saved))
+	if err := json.NewEncoder(w).Encode(saved); err != nil {
+		slog.Error("Unable to encode session data", "err", err)
+	}
+}
+
+// sessionLanguage checks a session language is a BCP 47 tag and returns it in its
+// canonical form, e.g. en-US for en_us
+func sessionLanguage(value string) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+	tag, err := language.Parse(value)
+	if err != nil {
+		return "", fmt.Errorf("%w: language %q is not a BCP 47 tag", errInvalidEdit, value)
 	}
+	return tag.String(), nil
+}
 
+// deleteSession removes a session and cancels the OCR job still filling it in, if any.
+// Images and cached hOCR on disk are kept, as other sessions may share them.
+func (h *Handler) deleteSession(w http.ResponseWriter, r *http.Request, sessionID string) {
 	session, exists := h.sessionStore.Get(sessionID)
 	if !exists {
-		http.Error(w, "Session not found", http.StatusNotFound)
+		utils.RespondWithError(w, "Session not found", http.StatusNotFound)
+		return
+	}
+	expected, err := expectedVersion(r, nil)
+	if err != nil {
+		utils.RespondWithError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := checkExpectedVersion(session, expected); err != nil {
+		utils.RespondWithError(w, err.Error(), http.StatusConflict)
 		return
 	}
 
-	switch r.Method {
-	case "GET":
-		if err := json.NewEncoder(w).Encode(session); err != nil {
-			http.Error(w, "Failed to encode response", http.StatusInternalServerError)
-			return
-		}
-	case "PUT":
-		var updatedSession models.CorrectionSession
-		if err := json.NewDecoder(r.Body).Decode(&updatedSession); err != nil {
-			slog.Error("Unable to decode session data", "err", err)
-			http.Error(w, "Invalid JSON", http.StatusBadRequest)
-			return
-		}
-		h.sessionStore.Set(sessionID, &updatedSession)
-		if err := json.NewEncoder(w).Encode(updatedSession); err != nil {
-			slog.Error("Unable to encode session data", "err", err)
-			http.Error(w, "Invalid JSON", http.StatusInternalServerError)
-			return
+	if session.JobID != "" {
+		if _, err := h.jobQueue.Cancel(session.JobID); err != nil && !errors.Is(err, jobs.ErrFinished) && !errors.Is(err, jobs.ErrNotFound) {
+			slog.Warn("Unable to cancel job of deleted session", "session_id", sessionID, "job_id", session.JobID, "err", err)
 		}
-	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 	}
+	if err := h.sessionStore.Delete(sessionID); err != nil {
+		utils.RespondWithError(w, "Failed to delete session: "+err.Error(), sessionWriteStatus(err))
+		return
+	}
+
+	slog.Info("Deleted session", "session_id", sessionID, "user", auth.UserFromContext(r.Context()).ID)
+	w.WriteHeader(http.StatusNoContent)
 }
 
+// handleMetrics compares the original and corrected text. The top level fields use the
+// default normalization; each entry in profiles is also reported under its own name.
 func (h *Handler) handleMetrics(w http.ResponseWriter, r *http.Request, _ string) {
 	var request struct {
-		Original  string `json:"original"`
-		Corrected string `json:"corrected"`
+		Original  string   `json:"original"`
+		Corrected string   `json:"corrected"`
+		Profiles  []string `json:"profiles"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
 		slog.Error("Unable to decode metrics data", "err", err)
-		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		utils.RespondWithError(w, "Invalid JSON", http.StatusBadRequest)
 		return
 	}
 
-	metricsResult := metrics.CalculateAccuracyMetrics(request.Original, request.Corrected)
-	if err := json.NewEncoder(w).Encode(metricsResult); err != nil {
+	response := struct {
+		metrics.EvalResult
+		Profiles map[string]metrics.EvalResult `json:"profiles,omitempty"`
+	}{
+		EvalResult: metrics.CalculateAccuracyMetrics(request.Original, request.Corrected),
+	}
+
+	if len(request.Profiles) > 0 {
+		response.Profiles = make(map[string]metrics.EvalResult, len(request.Profiles))
+		for _, profile := range request.Profiles {
+			result, err := metrics.CalculateAccuracyMetricsWithProfile(request.Original, request.Corrected, profile)
+			if err != nil {
+				utils.RespondWithError(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			response.Profiles[profile] = result
+		}
+	}
+
+	if err := json.NewEncoder(w).Encode(response); err != nil {
 		slog.Error("Unable to encode metrics data", "err", err)
-		http.Error(w, "Invalid JSON", http.StatusInternalServerError)
+		utils.RespondWithError(w, "Invalid JSON", http.StatusInternalServerError)
 	}
 }
 
-func (h *Handler) HandleHOCRUpdate(w http.ResponseWriter, r *http.Request) {
-	if r.Method != "POST" {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+// handleSessionHOCR combines the pages of a session into a single multi-page hOCR document.
+// The optional document query parameter limits the output to the pages of one document.
+func (h *Handler) handleSessionHOCR(w http.ResponseWriter, r *http.Request, sessionID string) {
+	pages, ok := h.sessionPagesOrError(w, r, sessionID)
+	if !ok {
 		return
 	}
 
-	var request struct {
-		SessionID string `json:"session_id"`
-		ImageID   string `json:"image_id"`
-		HOCR      string `json:"hocr"`
+	converter := hocr.NewConverter()
+	if session, exists := h.sessionStore.Get(sessionID); exists {
+		converter = sessionConverter(session)
 	}
 
-	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
-		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+	w.Header().Set("Content-Type", "text/vnd.hocr+html; charset=utf-8")
+	if _, err := w.Write([]byte(converter.ConvertHOCRPagesToXML(pages))); err != nil {
+		slog.Error("Unable to write hOCR", "err", err)
+	}
+}
+
+// handleSessionALTO exports the same pages as handleSessionHOCR as one ALTO v4 document
+func (h *Handler) handleSessionALTO(w http.ResponseWriter, r *http.Request, sessionID string) {
+	pages, ok := h.sessionPagesOrError(w, r, sessionID)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", sessionID+".alto.xml"))
+	if _, err := w.Write([]byte(alto.Write(pages))); err != nil {
+		slog.Error("Unable to write ALTO", "err", err)
+	}
+}
+
+// handleSessionPageXML exports the pages of a session as PAGE XML. PAGE describes a
+// single image, so a session with more than one page is sent as a zip of one file per page.
+func (h *Handler) handleSessionPageXML(w http.ResponseWriter, r *http.Request, sessionID string) {
+	pages, ok := h.sessionPagesOrError(w, r, sessionID)
+	if !ok {
+		return
+	}
+
+	if len(pages) == 1 {
+		w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", sessionID+".page.xml"))
+		if _, err := w.Write([]byte(pagexml.Write(pages[0]))); err != nil {
+			slog.Error("Unable to write PAGE XML", "err", err)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", sessionID+".page.zip"))
+
+	archive := zip.NewWriter(w)
+	names := make(map[string]bool, len(pages))
+	for i, page := range pages {
+		// Transkribus matches PAGE files to images by name
+		name := strings.TrimSuffix(page.Image, filepath.Ext(page.Image)) + ".xml"
+		if page.Image == "" || names[name] {
+			name = fmt.Sprintf("page_%04d.xml", i+1)
+		}
+		names[name] = true
+
+		file, err := archive.Create(name)
+		if err == nil {
+			_, err = file.Write([]byte(pagexml.Write(page)))
+		}
+		if err != nil {
+			slog.Error("Unable to write PAGE XML", "session_id", sessionID, "page", name, "err", err)
+			return
+		}
+	}
+
+	if err := archive.Close(); err != nil {
+		slog.Error("Unable to finish PAGE XML archive", "session_id", sessionID, "err", err)
+	}
+}
+
+// handleSessionPDF combines every image in a session, with its corrected hOCR as
+// an invisible text layer, into one searchable PDF
+func (h *Handler) handleSessionPDF(w http.ResponseWriter, r *http.Request, sessionID string) {
+	pages, ok := h.sessionPagesOrError(w, r, sessionID)
+	if !ok {
+		return
+	}
+
+	pdfPages := make([]pdf.Page, 0, len(pages))
+	for _, page := range pages {
+		imageData, err := pdfImageData(filepath.Join("uploads", page.Image))
+		if err != nil {
+			slog.Error("Unable to read page image", "session_id", sessionID, "image", page.Image, "err", err)
+			utils.RespondWithError(w, "Failed to read image "+page.Image, http.StatusInternalServerError)
+			return
+		}
+		pdfPages = append(pdfPages, pdf.Page{Image: imageData, HOCR: page})
+	}
+
+	var document bytes.Buffer
+	if err := pdf.Write(&document, pdfPages); err != nil {
+		slog.Error("Unable to build PDF", "session_id", sessionID, "err", err)
+		utils.RespondWithError(w, "Failed to build PDF: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	session, exists := h.sessionStore.Get(request.SessionID)
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", sessionID+".pdf"))
+	if _, err := w.Write(document.Bytes()); err != nil {
+		slog.Error("Unable to write PDF", "err", err)
+	}
+}
+
+// pdfImageData reads an image the PDF writer can embed, converting other formats to JPEG
+func pdfImageData(imagePath string) ([]byte, error) {
+	switch strings.ToLower(filepath.Ext(imagePath)) {
+	case ".jpg", ".jpeg", ".png", ".gif":
+		return os.ReadFile(imagePath)
+	}
+	return utils.ConvertToJPEG(imagePath)
+}
+
+// sessionPagesOrError parses the corrected, or else original, hOCR of every image in a
+// session into renumbered pages, writing an error response when that is not possible
+func (h *Handler) sessionPagesOrError(w http.ResponseWriter, r *http.Request, sessionID string) ([]models.HOCRPage, bool) {
+	session, exists := h.sessionStore.Get(sessionID)
 	if !exists {
-		http.Error(w, "Session not found", http.StatusNotFound)
+		utils.RespondWithError(w, "Session not found", http.StatusNotFound)
+		return nil, false
+	}
+
+	documentID := r.URL.Query().Get("document")
+
+	var pages []models.HOCRPage
+	for _, image := range session.Images {
+		if documentID != "" && image.DocumentID != documentID {
+			continue
+		}
+
+		hocrXML := image.CorrectedHOCR
+		if hocrXML == "" {
+			hocrXML = image.OriginalHOCR
+		}
+
+		imagePages, err := parser.ParseHOCRPages(hocrXML)
+		if err != nil {
+			slog.Error("Unable to parse hOCR", "session_id", sessionID, "image_id", image.ID, "err", err)
+			utils.RespondWithError(w, "Failed to parse hOCR for image "+image.ID, http.StatusInternalServerError)
+			return nil, false
+		}
+
+		for _, page := range imagePages {
+			page.Image = image.ImagePath
+			if page.BBox == (models.BBox{}) {
+				page.BBox = models.BBox{X2: image.ImageWidth, Y2: image.ImageHeight}
+			}
+			pages = append(pages, page)
+		}
+	}
+
+	if len(pages) == 0 {
+		utils.RespondWithError(w, "No pages found", http.StatusNotFound)
+		return nil, false
+	}
+
+	hocr.RenumberPages(pages)
+	return pages, true
+}
+
+// handlePublish uploads the saved hOCR for one image to its Drupal node and records
+// the outcome on the image, whether or not the upload succeeded
+func (h *Handler) handlePublish(w http.ResponseWriter, _ *http.Request, sessionID, imageID string) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if h.drupalPublisher == nil {
+		utils.RespondWithError(w, "Drupal publishing is not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	session, exists := h.sessionStore.Get(sessionID)
+	if !exists {
+		utils.RespondWithError(w, "Session not found", http.StatusNotFound)
 		return
 	}
 
+	index := -1
 	for i, image := range session.Images {
-		if image.ID == request.ImageID {
-			session.Images[i].CorrectedHOCR = request.HOCR
-			session.Images[i].Completed = true
+		if image.ID == imageID {
+			index = i
 			break
 		}
 	}
+	if index == -1 {
+		utils.RespondWithError(w, "Image not found", http.StatusNotFound)
+		return
+	}
 
-	h.sessionStore.Set(request.SessionID, session)
+	image := &session.Images[index]
+	if image.DrupalUploadURL == "" {
+		utils.RespondWithError(w, "Image was not created from a Drupal node", http.StatusBadRequest)
+		return
+	}
+
+	hocrXML := image.CorrectedHOCR
+	if hocrXML == "" {
+		hocrXML = image.OriginalHOCR
+	}
+
+	destination := drupal.Destination{Nid: image.DrupalNid, SessionID: sessionID, ImageID: imageID}
+	result, publishErr := h.drupalPublisher.Publish(image.DrupalUploadURL, destination, hocrXML)
+	image.LastPublish = &result
+
+	err := h.updateSession(sessionID, func(session *models.CorrectionSession) {
+		for i := range session.Images {
+			if session.Images[i].ID == imageID {
+				session.Images[i].LastPublish = &result
+			}
+		}
+	})
+	if err != nil {
+		slog.Error("Unable to save publish result", "session_id", sessionID, "image_id", imageID, "err", err)
+	}
 
+	if publishErr != nil {
+		slog.Error("Unable to publish hOCR to Drupal", "session_id", sessionID, "image_id", imageID, "err", publishErr)
+		if errors.Is(publishErr, drupal.ErrUntrustedURL) {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+		} else {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}
+
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		slog.Error("Unable to encode publish result", "err", err)
+	}
+}
+
+// hocrEdit is what the editor sends for one image: the words as edited and the page
+// structure they were parsed from. A complete hOCR document is also accepted in place
+// of the words; either way the stored hOCR is written by the server. Author names
+// the person saving for the revision history when authentication is disabled, and Version, when set, the session
+// version the edit was made against.
+type hocrEdit struct {
+	SessionID string            `json:"session_id"`
+	ImageID   string            `json:"image_id"`
+	HOCR      string            `json:"hocr"`
+	Words     []models.HOCRWord `json:"words"`
+	Pages     []models.HOCRPage `json:"pages"`
+	Author    string            `json:"author"`
+	Version   *int              `json:"version"`
+}
+
+// HandleHOCRUpdate validates an edit, stores its canonical hOCR as the image's
+// CorrectedHOCR, records it as a new revision and returns the stored document
+func (h *Handler) HandleHOCRUpdate(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(map[string]string{"status": "success"}); err != nil {
+
+	var edit hocrEdit
+	if err := json.NewDecoder(r.Body).Decode(&edit); err != nil {
+		utils.RespondWithError(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if !h.canAccessSession(r, edit.SessionID) {
+		utils.RespondWithError(w, "session not found", http.StatusNotFound)
+		return
+	}
+
+	expected, err := expectedVersion(r, edit.Version)
+	if err != nil {
+		utils.RespondWithError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	hocrXML, status, err := h.canonicalHOCR(edit)
+	if err != nil {
+		utils.RespondWithError(w, err.Error(), status)
+		return
+	}
+
+	var revision models.Revision
+	saved, err := h.sessionStore.Update(edit.SessionID, func(session *models.CorrectionSession) error {
+		if err := checkExpectedVersion(session, expected); err != nil {
+			return err
+		}
+		index, err := imageIndex(session, edit.ImageID)
+		if err != nil {
+			return err
+		}
+		image := &session.Images[index]
+		if err := checkEditable(image, auth.UserFromContext(r.Context())); err != nil {
+			return err
+		}
+		image.CorrectedHOCR = hocrXML
+		image.Completed = true
+		revision = addRevision(image, hocrXML, requestAuthor(r, edit.Author), models.RevisionSave, nil)
+		session.Operations = append(session.Operations, models.Operation{
+			ID:      fmt.Sprintf("op_%d", len(session.Operations)+1),
+			ImageID: edit.ImageID,
+			Type:    "replace_hocr",
+			Result:  []string{fmt.Sprintf("revision_%d", revision.Number)},
+			At:      time.Now(),
+		})
+		return nil
+	})
+	if err != nil {
+		slog.Error("Unable to save session", "session_id", edit.SessionID, "err", err)
+		status := sessionWriteStatus(err)
+		if errors.Is(err, hocr.ErrNotFound) {
+			status = http.StatusNotFound
+		}
+		utils.RespondWithError(w, "Failed to save session: "+err.Error(), status)
+		return
+	}
+
+	w.Header().Set("ETag", sessionETag(saved))
+	response := map[string]any{"status": "success", "hocr": hocrXML, "revision": revision.Number, "version": saved.Version}
+	if err := json.NewEncoder(w).Encode(response); err != nil {
 		slog.Error("Unable to encode success", "err", err)
-		http.Error(w, "Invalid JSON", http.StatusInternalServerError)
 	}
 }
 
-func (h *Handler) HandleUpload(w http.ResponseWriter, r *http.Request) {
-	if r.Method != "POST" {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+// HandleHOCRRender returns the canonical hOCR for an edit without storing it
+func (h *Handler) HandleHOCRRender(w http.ResponseWriter, r *http.Request) {
+	var edit hocrEdit
+	if err := json.NewDecoder(r.Body).Decode(&edit); err != nil {
+		utils.RespondWithError(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if !h.canAccessSession(r, edit.SessionID) {
+		utils.RespondWithError(w, "session not found", http.StatusNotFound)
+		return
+	}
+
+	hocrXML, status, err := h.canonicalHOCR(edit)
+	if err != nil {
+		utils.RespondWithError(w, err.Error(), status)
 		return
 	}
 
+	w.Header().Set("Content-Type", "text/vnd.hocr+html; charset=utf-8")
+	if _, err := w.Write([]byte(hocrXML)); err != nil {
+		slog.Error("Unable to write hOCR", "err", err)
+	}
+}
+
+// canonicalHOCR writes an edit of a session image as hOCR with the session's OCR
+// engine as its ocr-system, returning the HTTP status to report when it cannot
+func (h *Handler) canonicalHOCR(edit hocrEdit) (string, int, error) {
+	session, exists := h.sessionStore.Get(edit.SessionID)
+	if !exists {
+		return "", http.StatusNotFound, fmt.Errorf("session not found")
+	}
+
+	index := slices.IndexFunc(session.Images, func(image models.ImageItem) bool {
+		return image.ID == edit.ImageID
+	})
+	if index < 0 {
+		return "", http.StatusNotFound, fmt.Errorf("image not found")
+	}
+	image := session.Images[index]
+
+	converter := sessionConverter(session)
+
+	switch {
+	case edit.Words != nil:
+		var structure models.HOCRPage
+		if len(edit.Pages) > 0 {
+			structure = edit.Pages[0]
+		}
+		page, err := hocr.CanonicalPage(structure, edit.Words, image.ImageWidth, image.ImageHeight)
+		if err != nil {
+			return "", http.StatusUnprocessableEntity, fmt.Errorf("invalid hOCR edit: %w", err)
+		}
+		return converter.ConvertHOCRPageToXML(page), http.StatusOK, nil
+	case edit.HOCR != "":
+		pages, err := parser.ParseHOCRPages(edit.HOCR)
+		if err != nil {
+			return "", http.StatusBadRequest, fmt.Errorf("failed to parse hOCR: %w", err)
+		}
+		if len(pages) == 0 {
+			return "", http.StatusUnprocessableEntity, fmt.Errorf("invalid hOCR: no ocr_page found")
+		}
+		pages, err = hocr.CanonicalPages(pages)
+		if err != nil {
+			return "", http.StatusUnprocessableEntity, fmt.Errorf("invalid hOCR: %w", err)
+		}
+		return converter.ConvertHOCRPagesToXML(pages), http.StatusOK, nil
+	default:
+		return "", http.StatusBadRequest, fmt.Errorf("words or hocr is required")
+	}
+}
+
+// sessionConverter writes hOCR naming the session's OCR engine as its ocr-system,
+// in the session's language when it has one
+func sessionConverter(session *models.CorrectionSession) *hocr.Converter {
+	if session.Config.Model == "" {
+		return hocr.NewConverter().WithLanguage(session.Language)
+	}
+	return hocr.NewConverterForSystem(strings.ReplaceAll(session.Config.Model, "_", "-")).WithLanguage(session.Language)
+}
+
+// HandleUpload saves the upload and queues it for OCR. It responds as soon as the
+// job is queued; the session's images are filled in when the job finishes.
+func (h *Handler) HandleUpload(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
 	// Check if this is a JSON request with image URL
@@ -165,6 +770,7 @@ func (h *Handler) HandleUpload(w http.ResponseWriter, r *http.Request) {
 	if strings.Contains(contentType, "application/json") {
 		var request struct {
 			ImageURL string `json:"image_url"`
+			Engine   string `json:"engine"`
 		}
 
 		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
@@ -177,23 +783,30 @@ func (h *Handler) HandleUpload(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		sessionID, err := h.createSessionFromURL(request.ImageURL)
+		engine, err := h.ocrEngine(request.Engine)
+		if err != nil {
+			utils.RespondWithError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		sessionID, job, err := h.queueSessionFromURL(request.ImageURL, engine, auth.UserFromContext(r.Context()).ID)
 		if err != nil {
-			utils.RespondWithError(w, "Failed to process image URL: "+err.Error(), http.StatusBadRequest)
+			utils.RespondWithError(w, "Failed to queue image URL: "+err.Error(), jobErrorStatus(err))
 			return
 		}
 
+		w.WriteHeader(http.StatusAccepted)
 		response := map[string]any{
 			"session_id": sessionID,
-			"message":    "Successfully processed image from URL",
-			"images":     1,
+			"job_id":     job.ID,
+			"status":     job.Status,
+			"message":    "Image URL queued for OCR",
 			"cache_used": false,
 			"source":     "url",
 		}
 
 		if err := json.NewEncoder(w).Encode(response); err != nil {
 			slog.Error("Unable to encode response data", "err", err)
-			http.Error(w, "Invalid JSON", http.StatusInternalServerError)
 		}
 		return
 	}
@@ -209,21 +822,15 @@ func (h *Handler) HandleUpload(w http.ResponseWriter, r *http.Request) {
 	}
 	defer file.Close()
 
+	engine, err := h.ocrEngine(r.FormValue("engine"))
+	if err != nil {
+		utils.RespondWithError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	// Use filename (without extension) as session name, with timestamp for uniqueness
 	baseFilename := strings.TrimSuffix(header.Filename, filepath.Ext(header.Filename))
 	sessionID := fmt.Sprintf("%s_%d", baseFilename, time.Now().Unix())
-	session := &models.CorrectionSession{
-		ID:        sessionID,
-		Images:    []models.ImageItem{},
-		Current:   0,
-		CreatedAt: time.Now(),
-		Config: models.EvalConfig{
-			Model:       "google_cloud_vision",
-			Prompt:      "Google Cloud Vision OCR with hOCR conversion",
-			Temperature: 0.0,
-			Timestamp:   time.Now().Format("2006-01-02_15-04-05"),
-		},
-	}
 
 	uploadsDir := "uploads"
 	if err := os.MkdirAll(uploadsDir, 0755); err != nil {
@@ -242,7 +849,7 @@ func (h *Handler) HandleUpload(w http.ResponseWriter, r *http.Request) {
 	ext := filepath.Ext(header.Filename)
 
 	imageFilename := md5Hash + ext
-	hocrFilename := md5Hash + ".xml"
+	hocrFilename := hocrCacheFilename(md5Hash, engine)
 
 	imageFilePath := filepath.Join(uploadsDir, imageFilename)
 	hocrFilePath := filepath.Join(uploadsDir, hocrFilename)
@@ -254,234 +861,399 @@ func (h *Handler) HandleUpload(w http.ResponseWriter, r *http.Request) {
 
 	slog.Info("Image saved", "filename", imageFilename, "md5", md5Hash)
 
-	width, height := utils.GetImageDimensions(imageFilePath)
+	// An ALTO or PAGE XML file uploaded alongside the image is used as the OCR, so no engine is called
+	if page, format, found, err := uploadedLayout(r); found {
+		if err != nil {
+			utils.RespondWithError(w, "Invalid "+format+": "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		h.createSessionFromLayout(w, page, format, sessionID, imageFilePath, md5Hash, auth.UserFromContext(r.Context()).ID)
+		return
+	}
 
-	var hocrXML string
+	multiPage := isMultiPageFormat(header.Header.Get("Content-Type"), header.Filename)
+	_, statErr := os.Stat(hocrFilePath)
+	cacheUsed := !multiPage && statErr == nil
 
-	if _, err := os.Stat(hocrFilePath); err == nil {
-		hocrData, err := os.ReadFile(hocrFilePath)
-		if err != nil {
-			slog.Warn("Failed to read existing hOCR file", "error", err, "path", hocrFilePath)
-			hocrXML, err = h.getOCRForImage(imageFilePath)
+	job, err := h.queueSession(sessionID, engine, auth.UserFromContext(r.Context()).ID, "upload", func(_ context.Context, progress jobs.ProgressFunc) ([]models.ImageItem, error) {
+		if multiPage {
+			images, err := h.createDocumentImages(imageFilePath, md5Hash, engine)
 			if err != nil {
-				slog.Warn("Failed to get hOCR from Google Cloud Vision", "error", err)
-				utils.RespondWithError(w, "Failed to process image", http.StatusInternalServerError)
-				return
-			}
-			if err := os.WriteFile(hocrFilePath, []byte(hocrXML), 0644); err != nil {
-				slog.Warn("Failed to save hOCR file", "error", err)
+				return nil, fmt.Errorf("failed to process document: %w", err)
 			}
-		} else {
-			hocrXML = string(hocrData)
-			slog.Info("Using cached hOCR", "filename", hocrFilename)
+			return images, nil
 		}
-	} else {
-		slog.Info("Generating new hOCR via Google Cloud Vision", "filename", imageFilename)
-		hocrXML, err = h.getOCRForImage(imageFilePath)
+
+		width, height := utils.GetImageDimensions(imageFilePath)
+		hocrXML, _, err := h.hocrForImage(imageFilePath, hocrFilePath, engine)
 		if err != nil {
-			slog.Warn("Failed to get hOCR from Google Cloud Vision", "error", err)
-			utils.RespondWithError(w, "Failed to process image", http.StatusInternalServerError)
-			return
+			return nil, fmt.Errorf("failed to process image: %w", err)
 		}
 
-		if err := os.WriteFile(hocrFilePath, []byte(hocrXML), 0644); err != nil {
-			slog.Warn("Failed to save hOCR file", "error", err)
-		} else {
-			slog.Info("hOCR cached", "filename", hocrFilename)
-		}
+		return []models.ImageItem{{
+			ID:            "img_1",
+			ImagePath:     imageFilename,
+			ImageURL:      "/static/uploads/" + imageFilename,
+			OriginalHOCR:  hocrXML,
+			CorrectedHOCR: "",
+			Completed:     false,
+			ImageWidth:    width,
+			ImageHeight:   height,
+		}}, nil
+	})
+	if err != nil {
+		utils.RespondWithError(w, "Failed to queue upload: "+err.Error(), jobErrorStatus(err))
+		return
 	}
 
-	imageItem := models.ImageItem{
-		ID:            "img_1",
-		ImagePath:     imageFilename,
-		ImageURL:      "/static/uploads/" + imageFilename,
-		OriginalHOCR:  hocrXML,
-		CorrectedHOCR: "",
-		Completed:     false,
-		ImageWidth:    width,
-		ImageHeight:   height,
+	w.WriteHeader(http.StatusAccepted)
+	response := map[string]any{
+		"session_id": sessionID,
+		"job_id":     job.ID,
+		"status":     job.Status,
+		"message":    "File queued for OCR",
+		"cache_used": cacheUsed,
+		"md5_hash":   md5Hash,
 	}
 
-	session.Images = []models.ImageItem{imageItem}
-	h.sessionStore.Set(sessionID, session)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		slog.Error("Unable to encode response data", "err", err)
+	}
+}
+
+// uploadedLayout parses an ALTO or PAGE XML file sent alongside an upload in the
+// alto or pagexml form field. found is false when there is neither.
+func uploadedLayout(r *http.Request) (page models.HOCRPage, format string, found bool, err error) {
+	for _, format = range []string{"alto", "pagexml"} {
+		file, _, formErr := r.FormFile(format)
+		if formErr != nil {
+			continue
+		}
+		defer file.Close()
 
-	_, cacheErr := os.Stat(hocrFilePath)
-	cacheUsed := cacheErr == nil
+		data, err := io.ReadAll(file)
+		if err != nil {
+			return page, format, true, fmt.Errorf("failed to read %s file: %w", format, err)
+		}
+
+		if format == "pagexml" {
+			page, err = pagexml.Parse(string(data))
+			return page, format, true, err
+		}
+
+		pages, err := alto.Parse(string(data))
+		if err != nil {
+			return page, format, true, err
+		}
+		if len(pages) != 1 {
+			return page, format, true, fmt.Errorf("ALTO file must describe exactly one page, found %d", len(pages))
+		}
+		return pages[0], format, true, nil
+	}
+
+	return page, "", false, nil
+}
+
+// createSessionFromLayout builds a session for an uploaded image from its ALTO or
+// PAGE XML, converting it to hOCR so the editor can work on it as usual
+func (h *Handler) createSessionFromLayout(w http.ResponseWriter, page models.HOCRPage, format, sessionID, imageFilePath, md5Hash, createdBy string) {
+	imageFilename := filepath.Base(imageFilePath)
+	width, height := utils.GetImageDimensions(imageFilePath)
+	page.Image = imageFilename
+	if page.BBox == (models.BBox{}) {
+		page.BBox = models.BBox{X2: width, Y2: height}
+	}
+
+	session := &models.CorrectionSession{
+		ID:        sessionID,
+		Current:   0,
+		CreatedAt: time.Now(),
+		CreatedBy: createdBy,
+		Config: models.EvalConfig{
+			Model:       format,
+			Prompt:      "Using uploaded " + format,
+			Temperature: 0.0,
+			Timestamp:   time.Now().Format("2006-01-02_15-04-05"),
+		},
+		Images: []models.ImageItem{{
+			ID:            "img_1",
+			ImagePath:     imageFilename,
+			ImageURL:      "/static/uploads/" + imageFilename,
+			OriginalHOCR:  hocr.NewConverterForSystem(format).ConvertHOCRPageToXML(page),
+			CorrectedHOCR: "",
+			Completed:     false,
+			ImageWidth:    width,
+			ImageHeight:   height,
+		}},
+	}
+
+	if err := h.sessionStore.Set(sessionID, session); err != nil {
+		utils.RespondWithError(w, "Failed to save session: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
 
 	response := map[string]any{
 		"session_id": sessionID,
-		"message":    "Successfully processed 1 file",
+		"message":    "Successfully loaded " + format,
 		"images":     1,
-		"cache_used": cacheUsed,
 		"md5_hash":   md5Hash,
+		"source":     format,
 	}
 
 	if err := json.NewEncoder(w).Encode(response); err != nil {
 		slog.Error("Unable to encode response data", "err", err)
-		http.Error(w, "Invalid JSON", http.StatusInternalServerError)
 	}
 }
 
-func (h *Handler) createSessionFromURL(imageURL string) (string, error) {
-	// Download image from URL
-	resp, err := http.Get(imageURL)
+// queueSessionFromURL creates an empty session and queues the download and OCR of imageURL into it
+func (h *Handler) queueSessionFromURL(imageURL string, engine ocr.Engine, createdBy string) (string, jobs.Job, error) {
+	sessionID := fmt.Sprintf("%s_%d", filenameFromURL(imageURL, "image"), time.Now().Unix())
+
+	job, err := h.queueSession(sessionID, engine, createdBy, "url", func(ctx context.Context, progress jobs.ProgressFunc) ([]models.ImageItem, error) {
+		return h.imagesFromURL(ctx, imageURL, engine)
+	})
+	if err != nil {
+		return "", jobs.Job{}, err
+	}
+
+	slog.Info("Session queued from URL", "session_id", sessionID, "url", imageURL, "job_id", job.ID)
+	return sessionID, job, nil
+}
+
+// queueSession stores an empty session and queues a job whose images are attached to it
+// when the job succeeds. The session records the job ID so clients can follow its progress.
+func (h *Handler) queueSession(sessionID string, engine ocr.Engine, createdBy, jobType string, process func(ctx context.Context, progress jobs.ProgressFunc) ([]models.ImageItem, error)) (jobs.Job, error) {
+	session := &models.CorrectionSession{
+		ID:        sessionID,
+		Images:    []models.ImageItem{},
+		Current:   0,
+		CreatedAt: time.Now(),
+		CreatedBy: createdBy,
+		Config: models.EvalConfig{
+			Model:       engine.Name(),
+			Prompt:      engine.Description(),
+			Temperature: 0.0,
+			Timestamp:   time.Now().Format("2006-01-02_15-04-05"),
+		},
+	}
+	if err := h.sessionStore.Set(sessionID, session); err != nil {
+		return jobs.Job{}, fmt.Errorf("failed to save session: %w", err)
+	}
+
+	job, err := h.jobQueue.Submit(jobType, sessionID, func(ctx context.Context, progress jobs.ProgressFunc) error {
+		images, err := process(ctx, progress)
+		if err == nil {
+			err = ctx.Err()
+		}
+		if err != nil {
+			h.failJob(sessionID, err)
+			return err
+		}
+
+		return h.updateSession(sessionID, func(session *models.CorrectionSession) {
+			session.Images = images
+			session.JobError = ""
+		})
+	})
+	if err != nil {
+		if deleteErr := h.sessionStore.Delete(sessionID); deleteErr != nil {
+			slog.Warn("Unable to remove session for unqueued job", "session_id", sessionID, "err", deleteErr)
+		}
+		return jobs.Job{}, err
+	}
+
+	if err := h.updateSession(sessionID, func(session *models.CorrectionSession) {
+		session.JobID = job.ID
+	}); err != nil {
+		return jobs.Job{}, err
+	}
+
+	return job, nil
+}
+
+// failJob records on a session why the job filling it in failed or was canceled, as
+// the session would otherwise be left empty with no reason once the job is forgotten.
+// A job that is retried records the error of its latest attempt.
+func (h *Handler) failJob(sessionID string, err error) {
+	message := err.Error()
+	if errors.Is(err, context.Canceled) {
+		message = string(jobs.StatusCanceled)
+	}
+	if err := h.updateSession(sessionID, func(session *models.CorrectionSession) {
+		session.JobError = message
+	}); err != nil {
+		slog.Error("Unable to record job failure", "session_id", sessionID, "err", err)
+	}
+}
+
+// updateSession applies change to the stored session and saves it. The store's
+// Update keeps background jobs and editors from losing each other's changes.
+func (h *Handler) updateSession(sessionID string, change func(session *models.CorrectionSession)) error {
+	_, err := h.sessionStore.Update(sessionID, func(session *models.CorrectionSession) error {
+		change(session)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save session %s: %w", sessionID, err)
+	}
+
+	return nil
+}
+
+func jobErrorStatus(err error) int {
+	if errors.Is(err, jobs.ErrQueueFull) {
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
+
+// imagesFromURL downloads a URL and OCRs it, splitting PDFs and multi-page TIFFs into one image per page
+func (h *Handler) imagesFromURL(ctx context.Context, imageURL string, engine ocr.Engine) ([]models.ImageItem, error) {
+	imageData, contentType, err := h.downloadURL(ctx, imageURL)
+	if err != nil {
+		return nil, err
+	}
+
+	// PDFs and multi-page TIFFs are split into one image per page locally
+	if isMultiPageFormat(contentType, imageURL) {
+		images, ok, err := h.documentImagesFromData(imageURL, imageData, contentType, engine)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			return images, nil
+		}
+	}
+
+	image, err := h.imageItemFromData(imageURL, imageData, contentType, "img_1", engine)
+	if err != nil {
+		return nil, err
+	}
+
+	return []models.ImageItem{image}, nil
+}
+
+// Content types accepted from the URLs sessions are created from
+var (
+	imageContentTypes = []string{"image/", "application/pdf"}
+	hocrContentTypes  = []string{"text/html", "application/xhtml+xml", "text/vnd.hocr+html", "text/xml", "application/xml", "text/plain"}
+	jsonContentTypes  = []string{"application/json", "application/vnd.api+json"}
+)
+
+// downloadURL fetches an image or PDF and returns its body and content type
+func (h *Handler) downloadURL(ctx context.Context, imageURL string) ([]byte, string, error) {
+	imageData, contentType, err := h.fetcher.Get(ctx, imageURL, imageContentTypes...)
 	if err != nil {
-		return "", fmt.Errorf("failed to download image: %w", err)
+		return nil, "", fmt.Errorf("failed to download image: %w", err)
 	}
-	defer resp.Body.Close()
+	return imageData, contentType, nil
+}
+
+func (h *Handler) getOCRForImage(imagePath string, engine ocr.Engine) (string, error) {
+	return engine.ProcessImage(imagePath)
+}
 
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("failed to download image: HTTP %d", resp.StatusCode)
+// hocrForImage returns the cached hOCR for an image, or runs the OCR engine and caches its output.
+// The boolean reports whether the cache was used.
+func (h *Handler) hocrForImage(imageFilePath, hocrFilePath string, engine ocr.Engine) (string, bool, error) {
+	if hocrData, err := os.ReadFile(hocrFilePath); err == nil {
+		slog.Info("Using cached hOCR", "filename", filepath.Base(hocrFilePath))
+		return string(hocrData), true, nil
+	} else if !os.IsNotExist(err) {
+		slog.Warn("Failed to read existing hOCR file", "error", err, "path", hocrFilePath)
 	}
 
-	// Read image data
-	imageData, err := io.ReadAll(resp.Body)
+	slog.Info("Generating new hOCR", "engine", engine.Name(), "filename", filepath.Base(imageFilePath))
+	hocrXML, err := h.getOCRForImage(imageFilePath, engine)
 	if err != nil {
-		return "", fmt.Errorf("failed to read image data: %w", err)
+		return "", false, err
 	}
 
-	// Get content type from response
-	contentType := resp.Header.Get("Content-Type")
-
-	// Convert JP2/TIFF images using Houdini if needed
-	originalImageData := imageData
-	if needsHoudiniConversion(contentType, imageURL) {
-		slog.Info("Image requires Houdini conversion", "content_type", contentType, "url", imageURL)
-		convertedData, err := h.convertImageViaHoudini(imageData, contentType)
-		if err != nil {
-			return "", fmt.Errorf("failed to convert image via Houdini: %w", err)
-		}
-		imageData = convertedData
-		contentType = "image/jpeg"
+	if err := os.WriteFile(hocrFilePath, []byte(hocrXML), 0644); err != nil {
+		slog.Warn("Failed to save hOCR file", "error", err)
+	} else {
+		slog.Info("hOCR cached", "filename", filepath.Base(hocrFilePath))
 	}
 
-	// Calculate MD5 hash of the original image data for consistent caching
-	md5Hash := utils.CalculateDataMD5(originalImageData)
+	return hocrXML, false, nil
+}
 
-	// Extract filename from URL or use md5 hash
-	filename := md5Hash
-	if urlParts := strings.Split(imageURL, "/"); len(urlParts) > 0 {
-		lastPart := urlParts[len(urlParts)-1]
-		if lastPart != "" && strings.Contains(lastPart, ".") {
-			filename = strings.TrimSuffix(lastPart, filepath.Ext(lastPart))
-		}
+// createDocumentImages splits a multi-page TIFF or PDF into one image per page and
+// OCRs each page on its own, so every page can be edited and saved separately
+func (h *Handler) createDocumentImages(documentPath, md5Hash string, engine ocr.Engine) ([]models.ImageItem, error) {
+	uploadsDir := filepath.Dir(documentPath)
+	pagePaths, err := utils.SplitPages(documentPath, filepath.Join(uploadsDir, md5Hash))
+	if err != nil {
+		return nil, err
 	}
 
-	// Create session ID using filename and timestamp
-	sessionID := fmt.Sprintf("%s_%d", filename, time.Now().Unix())
+	images := make([]models.ImageItem, 0, len(pagePaths))
+	for i, pagePath := range pagePaths {
+		pageFilename := filepath.Base(pagePath)
+		pageKey := strings.TrimSuffix(pageFilename, filepath.Ext(pageFilename))
+		hocrFilePath := filepath.Join(uploadsDir, hocrCacheFilename(pageKey, engine))
 
-	// Determine file extension from content type (which may have been updated by Houdini conversion)
-	ext := ".jpg" // default
-	switch contentType {
-	case "image/png":
-		ext = ".png"
-	case "image/gif":
-		ext = ".gif"
-	case "image/webp":
-		ext = ".webp"
-	default:
-		// Try to get extension from URL
-		if urlExt := filepath.Ext(imageURL); urlExt != "" {
-			ext = urlExt
+		hocrXML, _, err := h.hocrForImage(pagePath, hocrFilePath, engine)
+		if err != nil {
+			return nil, fmt.Errorf("failed to process page %d: %w", i+1, err)
 		}
-	}
 
+		width, height := utils.GetImageDimensions(pagePath)
+		images = append(images, models.ImageItem{
+			ID:           fmt.Sprintf("img_%d", i+1),
+			ImagePath:    pageFilename,
+			ImageURL:     "/static/uploads/" + pageFilename,
+			OriginalHOCR: hocrXML,
+			ImageWidth:   width,
+			ImageHeight:  height,
+			DocumentID:   md5Hash,
+			PageNumber:   i + 1,
+			PageCount:    len(pagePaths),
+		})
+	}
+
+	slog.Info("Document split into pages", "md5", md5Hash, "pages", len(images))
+	return images, nil
+}
+
+// documentImagesFromData OCRs each page of a downloaded PDF or multi-page TIFF.
+// It reports false when the TIFF only has one page so the caller can fall back
+// to the single image flow.
+func (h *Handler) documentImagesFromData(documentURL string, documentData []byte, contentType string, engine ocr.Engine) ([]models.ImageItem, bool, error) {
 	uploadsDir := "uploads"
 	if err := os.MkdirAll(uploadsDir, 0755); err != nil {
-		return "", fmt.Errorf("failed to create uploads directory: %w", err)
+		return nil, false, fmt.Errorf("failed to create uploads directory: %w", err)
 	}
 
-	imageFilename := md5Hash + ext
-	hocrFilename := md5Hash + ".xml"
-	imageFilePath := filepath.Join(uploadsDir, imageFilename)
-	hocrFilePath := filepath.Join(uploadsDir, hocrFilename)
-
-	// Save image file
-	if err := os.WriteFile(imageFilePath, imageData, 0644); err != nil {
-		return "", fmt.Errorf("failed to save image: %w", err)
+	md5Hash := utils.CalculateDataMD5(documentData)
+	ext := ".tif"
+	if isPDF(contentType, documentURL) {
+		ext = ".pdf"
 	}
 
-	slog.Info("Image downloaded and saved", "filename", imageFilename, "md5", md5Hash, "url", imageURL)
-
-	// Get image dimensions
-	width, height := utils.GetImageDimensions(imageFilePath)
-
-	// Process hOCR (check cache first)
-	var hocrXML string
-	if _, err := os.Stat(hocrFilePath); err == nil {
-		hocrData, err := os.ReadFile(hocrFilePath)
-		if err != nil {
-			slog.Warn("Failed to read existing hOCR file", "error", err, "path", hocrFilePath)
-			hocrXML, err = h.getOCRForImage(imageFilePath)
-			if err != nil {
-				return "", fmt.Errorf("failed to process image with OCR: %w", err)
-			}
-			if err := os.WriteFile(hocrFilePath, []byte(hocrXML), 0644); err != nil {
-				slog.Warn("Failed to save hOCR file", "error", err)
-			}
-		} else {
-			hocrXML = string(hocrData)
-			slog.Info("Using cached hOCR", "filename", hocrFilename)
-		}
-	} else {
-		slog.Info("Generating new hOCR via Google Cloud Vision", "filename", imageFilename)
-		hocrXML, err = h.getOCRForImage(imageFilePath)
-		if err != nil {
-			return "", fmt.Errorf("failed to process image with OCR: %w", err)
-		}
-
-		if err := os.WriteFile(hocrFilePath, []byte(hocrXML), 0644); err != nil {
-			slog.Warn("Failed to save hOCR file", "error", err)
-		} else {
-			slog.Info("hOCR cached", "filename", hocrFilename)
-		}
+	documentPath := filepath.Join(uploadsDir, md5Hash+ext)
+	if err := os.WriteFile(documentPath, documentData, 0644); err != nil {
+		return nil, false, fmt.Errorf("failed to save document: %w", err)
 	}
 
-	// Create session
-	session := &models.CorrectionSession{
-		ID:        sessionID,
-		Images:    []models.ImageItem{},
-		Current:   0,
-		CreatedAt: time.Now(),
-		Config: models.EvalConfig{
-			Model:       "google_cloud_vision",
-			Prompt:      "Google Cloud Vision OCR with hOCR conversion",
-			Temperature: 0.0,
-			Timestamp:   time.Now().Format("2006-01-02_15-04-05"),
-		},
+	if ext == ".tif" && utils.CountPages(documentPath) < 2 {
+		return nil, false, nil
 	}
 
-	imageItem := models.ImageItem{
-		ID:            "img_1",
-		ImagePath:     imageFilename,
-		ImageURL:      "/static/uploads/" + imageFilename,
-		OriginalHOCR:  hocrXML,
-		CorrectedHOCR: "",
-		Completed:     false,
-		ImageWidth:    width,
-		ImageHeight:   height,
+	images, err := h.createDocumentImages(documentPath, md5Hash, engine)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to process document: %w", err)
 	}
 
-	session.Images = []models.ImageItem{imageItem}
-	h.sessionStore.Set(sessionID, session)
-
-	slog.Info("Session created from URL", "session_id", sessionID, "url", imageURL)
-	return sessionID, nil
+	slog.Info("Document downloaded and split", "url", documentURL, "pages", len(images))
+	return images, true, nil
 }
 
-func (h *Handler) getOCRForImage(imagePath string) (string, error) {
-	gcvResponse, err := h.ocrService.ProcessImage(imagePath)
-	if err != nil {
-		return "", err
-	}
-
-	converter := hocr.NewConverter()
-	hocr, err := converter.ConvertToHOCR(gcvResponse)
-	if err != nil {
-		return "", fmt.Errorf("failed to convert to hOCR: %w", err)
+// HandleHealthcheck answers OK while the server is up
+func (h *Handler) HandleHealthcheck(w http.ResponseWriter, _ *http.Request) {
+	if _, err := w.Write([]byte("OK")); err != nil {
+		slog.Error("Unable to write healthcheck", "err", err)
 	}
-
-	return hocr, nil
 }
 
 func (h *Handler) HandleStatic(w http.ResponseWriter, r *http.Request) {
@@ -497,18 +1269,30 @@ func (h *Handler) HandleStatic(w http.ResponseWriter, r *http.Request) {
 		filepath = "index.html"
 	}
 
+	// Only signed in users create sessions, even if the middleware let the request by
+	if slices.ContainsFunc(auth.SessionQueries, r.URL.Query().Has) && auth.UserFromContext(r.Context()) == auth.Nobody {
+		http.Error(w, auth.ErrUnauthenticated.Error(), http.StatusUnauthorized)
+		return
+	}
+
 	// Check if image URL parameter is provided
 	imageURL := r.URL.Query().Get("image")
 	if imageURL != "" {
-		// Create session from image URL
-		sessionID, err := h.createSessionFromURL(imageURL)
+		engine, err := h.ocrEngine(r.URL.Query().Get("engine"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		// Queue a session for the image URL
+		sessionID, _, err := h.queueSessionFromURL(imageURL, engine, auth.UserFromContext(r.Context()).ID)
 		if err != nil {
-			slog.Error("Failed to create session from URL", "url", imageURL, "error", err)
-			http.Error(w, "Failed to process image URL: "+err.Error(), http.StatusBadRequest)
+			slog.Error("Failed to queue session from URL", "url", imageURL, "error", err)
+			http.Error(w, "Failed to queue image URL: "+err.Error(), jobErrorStatus(err))
 			return
 		}
 
-		// Redirect to the session
+		// Redirect to the session, which fills in once the OCR job finishes
 		http.Redirect(w, r, "?session="+sessionID, http.StatusFound)
 		return
 	}
@@ -516,15 +1300,67 @@ func (h *Handler) HandleStatic(w http.ResponseWriter, r *http.Request) {
 	// Check if Drupal node ID parameter is provided
 	nid := r.URL.Query().Get("nid")
 	if nid != "" {
-		// Create session from Drupal node
-		sessionID, err := h.createSessionFromDrupalNode(nid)
+		nid, err := drupalID(nid)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		engine, err := h.ocrEngine(r.URL.Query().Get("engine"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		// Queue a session for the node, as for an image URL
+		sessionID, _, err := h.queueSessionFromDrupalNode(nid, engine, auth.UserFromContext(r.Context()).ID)
+		if err != nil {
+			slog.Error("Failed to queue session from Drupal node", "nid", nid, "error", err)
+			http.Error(w, "Failed to queue Drupal node: "+err.Error(), jobErrorStatus(err))
+			return
+		}
+
+		// Redirect to the session, which fills in once the job finishes
+		http.Redirect(w, r, "?session="+sessionID, http.StatusFound)
+		return
+	}
+
+	// Check if a Drupal collection is to be queued as one batch session
+	collection := r.URL.Query().Get("collection")
+	if collection != "" {
+		collection, err := drupalID(collection)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		engine, err := h.ocrEngine(r.URL.Query().Get("engine"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		nids, err := h.fetchCollectionNids(r.Context(), collection)
+		if err != nil {
+			slog.Error("Failed to list Drupal collection", "collection", collection, "error", err)
+			http.Error(w, "Failed to list Drupal collection: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		nids, err = uniqueNids(nids)
 		if err != nil {
-			slog.Error("Failed to create session from Drupal node", "nid", nid, "error", err)
-			http.Error(w, "Failed to process Drupal node: "+err.Error(), http.StatusBadRequest)
+			http.Error(w, "Drupal collection lists "+err.Error(), http.StatusBadGateway)
+			return
+		}
+		if len(nids) == 0 {
+			http.Error(w, "Drupal collection has no members", http.StatusBadRequest)
+			return
+		}
+
+		sessionID, _, err := h.queueDrupalBatch(collection, nids, engine, r.URL.Query().Get("reocr") == "true", auth.UserFromContext(r.Context()).ID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 
-		// Redirect to the session
+		// Redirect to the session, which fills in as the batch runs
 		http.Redirect(w, r, "?session="+sessionID, http.StatusFound)
 		return
 	}
@@ -551,36 +1387,40 @@ func (h *Handler) HandleStatic(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *Handler) HandleHOCRParse(w http.ResponseWriter, r *http.Request) {
-	if r.Method != "POST" {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-
 	var request struct {
 		HOCR string `json:"hocr"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
-		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		utils.RespondWithError(w, "Invalid JSON", http.StatusBadRequest)
 		return
 	}
 
 	words, err := parser.ParseHOCRWords(request.HOCR)
 	if err != nil {
 		slog.Error("Unable to parse hocr", "hocr", request.HOCR, "err", err)
-		http.Error(w, "Failed to parse hOCR", http.StatusBadRequest)
+		utils.RespondWithError(w, "Failed to parse hOCR", http.StatusBadRequest)
+		return
+	}
+
+	pages, err := parser.ParseHOCRPages(request.HOCR)
+	if err != nil {
+		slog.Error("Unable to parse hocr structure", "err", err)
+		utils.RespondWithError(w, "Failed to parse hOCR", http.StatusBadRequest)
 		return
 	}
 
 	response := struct {
 		Words []models.HOCRWord `json:"words"`
+		Pages []models.HOCRPage `json:"pages"`
 	}{
 		Words: words,
+		Pages: pages,
 	}
 
 	if err := json.NewEncoder(w).Encode(response); err != nil {
 		slog.Error("Unable to encode response data", "err", err)
-		http.Error(w, "Invalid JSON", http.StatusInternalServerError)
+		utils.RespondWithError(w, "Invalid JSON", http.StatusInternalServerError)
 	}
 }
 
@@ -620,22 +1460,10 @@ func (h *Handler) convertImageViaHoudini(imageData []byte, contentType string) (
 	req.Header.Set("Content-Type", contentType)
 	req.Header.Set("Accept", "image/jpeg")
 
-	client := &http.Client{Timeout: 30 * time.Second}
-	resp, err := client.Do(req)
+	convertedData, _, err := h.fetcher.Do(req, "image/jpeg")
 	if err != nil {
 		return nil, fmt.Errorf("houdini request failed: %w", err)
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("houdini returned HTTP %d", resp.StatusCode)
-	}
-
-	// Read converted image
-	convertedData, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read Houdini response: %w", err)
-	}
 
 	// Cache the converted image
 	if err := os.WriteFile(cachePath, convertedData, 0644); err != nil {
@@ -647,6 +1475,29 @@ func (h *Handler) convertImageViaHoudini(imageData []byte, contentType string) (
 	return convertedData, nil
 }
 
+// isMultiPageFormat checks if the file may hold more than one page
+func isMultiPageFormat(contentType, filename string) bool {
+	if isPDF(contentType, filename) {
+		return true
+	}
+
+	switch contentType {
+	case "image/tiff", "image/tif":
+		return true
+	}
+
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".tiff", ".tif":
+		return true
+	}
+
+	return false
+}
+
+func isPDF(contentType, filename string) bool {
+	return contentType == "application/pdf" || strings.ToLower(filepath.Ext(filename)) == ".pdf"
+}
+
 // needsHoudiniConversion checks if the image format requires Houdini conversion
 func needsHoudiniConversion(contentType, url string) bool {
 	// Check content type first
@@ -677,11 +1528,29 @@ type DrupalFileObject struct {
 // DrupalHOCRData represents the JSON response from Drupal HOCR endpoint (array of file objects)
 type DrupalHOCRData []DrupalFileObject
 
-// createSessionFromDrupalNode creates a session from a Drupal node ID
-func (h *Handler) createSessionFromDrupalNode(nid string) (string, error) {
+// drupalNode holds the Islandora files a session needs for one node
+type drupalNode struct {
+	Nid           string
+	ImageURL      string
+	HOCRURL       string
+	HOCRUploadURL string
+	HOCRFile      DrupalFileObject
+}
+
+// hasExistingHOCR reports whether Drupal already holds hOCR produced by Google Cloud Vision
+func (n drupalNode) hasExistingHOCR() bool {
+	return strings.Contains(n.HOCRFile.URI, "gcloud")
+}
+
+// fetchDrupalNode looks up the service file and hOCR media of a node via DRUPAL_HOCR_URL
+func (h *Handler) fetchDrupalNode(ctx context.Context, nid string) (drupalNode, error) {
 	drupalURL := os.Getenv("DRUPAL_HOCR_URL")
 	if drupalURL == "" {
-		return "", fmt.Errorf("DRUPAL_HOCR_URL environment variable not set")
+		return drupalNode{}, fmt.Errorf("DRUPAL_HOCR_URL environment variable not set")
+	}
+	nid, err := drupalID(nid)
+	if err != nil {
+		return drupalNode{}, err
 	}
 
 	// Format the URL with the node ID
@@ -689,24 +1558,19 @@ func (h *Handler) createSessionFromDrupalNode(nid string) (string, error) {
 	slog.Info("Fetching Drupal HOCR data", "nid", nid, "url", requestURL)
 
 	// Make request to Drupal
-	resp, err := http.Get(requestURL)
+	data, _, err := h.fetcher.Get(ctx, requestURL, jsonContentTypes...)
 	if err != nil {
-		return "", fmt.Errorf("failed to fetch Drupal data: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("drupal returned HTTP %d", resp.StatusCode)
+		return drupalNode{}, fmt.Errorf("failed to fetch Drupal data: %w", err)
 	}
 
 	// Parse JSON response
 	var drupalData DrupalHOCRData
-	if err := json.NewDecoder(resp.Body).Decode(&drupalData); err != nil {
-		return "", fmt.Errorf("failed to parse Drupal JSON: %w", err)
+	if err := json.Unmarshal(data, &drupalData); err != nil {
+		return drupalNode{}, fmt.Errorf("failed to parse Drupal JSON: %w", err)
 	}
 
 	if len(drupalData) == 0 {
-		return "", fmt.Errorf("no file objects provided by Drupal")
+		return drupalNode{}, fmt.Errorf("no file objects provided by Drupal")
 	}
 
 	// Find service file and hOCR file
@@ -721,79 +1585,86 @@ func (h *Handler) createSessionFromDrupalNode(nid string) (string, error) {
 	}
 
 	if serviceFile == nil {
-		return "", fmt.Errorf("no Service File found in Drupal response")
+		return drupalNode{}, fmt.Errorf("no Service File found in Drupal response")
 	}
 
 	if hocrFile == nil {
-		return "", fmt.Errorf("no hOCR file found in Drupal response")
+		return drupalNode{}, fmt.Errorf("no hOCR file found in Drupal response")
 	}
 
 	baseUrl := strings.Replace(drupalURL, "/node/%s/hocr", "", 1)
-	// Construct image URL from service file
-	imageURL := baseUrl + serviceFile.ViewNode + serviceFile.URI
-
-	// Construct hOCR upload URL
-	hocrUploadURL := fmt.Sprintf("%s/node/%s%s/media/file/%s", baseUrl, nid, serviceFile.ViewNode, hocrFile.TID)
-
-	slog.Info("Retrieved Drupal data", "nid", nid, "image_url", imageURL, "hocr_upload", hocrUploadURL)
-
-	// Check if we should use existing hOCR (if URI contains "gcloud") or generate new hOCR
-	var sessionID string
-	var sessionErr error
+	node := drupalNode{
+		Nid:      nid,
+		HOCRFile: *hocrFile,
+		// Construct image URL from service file
+		ImageURL: baseUrl + serviceFile.ViewNode + serviceFile.URI,
+		HOCRURL:  hocrFile.ViewNode + hocrFile.URI,
+		// Construct hOCR upload URL
+		HOCRUploadURL: fmt.Sprintf("%s/node/%s%s/media/file/%s", baseUrl, nid, serviceFile.ViewNode, hocrFile.TID),
+	}
+
+	slog.Info("Retrieved Drupal data", "nid", nid, "image_url", node.ImageURL, "hocr_upload", node.HOCRUploadURL)
+	return node, nil
+}
 
-	if strings.Contains(hocrFile.URI, "gcloud") {
-		// Download and use existing hOCR instead of calling Google Cloud Vision
-		slog.Info("Using existing hOCR from Drupal", "nid", nid, "hocr_uri", hocrFile.URI)
-		sessionID, sessionErr = h.createSessionFromDrupalWithExistingHOCR(imageURL, hocrFile.ViewNode+hocrFile.URI, nid)
-	} else {
-		// Generate new hOCR using Google Cloud Vision API (same as normal image upload)
-		slog.Info("Generating new hOCR via Google Cloud Vision", "nid", nid, "hocr_uri", hocrFile.URI)
-		sessionID, sessionErr = h.createSessionFromDrupalWithNewHOCR(imageURL, nid)
-	}
+// queueSessionFromDrupalNode creates an empty session and queues the download of a
+// node's service file into it
+func (h *Handler) queueSessionFromDrupalNode(nid string, engine ocr.Engine, createdBy string) (string, jobs.Job, error) {
+	sessionID := fmt.Sprintf("drupal_%s_%d", nid, time.Now().Unix())
 
-	if sessionErr != nil {
-		return "", fmt.Errorf("failed to create session from Drupal: %w", sessionErr)
+	job, err := h.queueSession(sessionID, engine, createdBy, "drupal", func(ctx context.Context, progress jobs.ProgressFunc) ([]models.ImageItem, error) {
+		image, err := h.drupalNodeImage(ctx, nid, engine)
+		if err != nil {
+			return nil, err
+		}
+		return []models.ImageItem{image}, nil
+	})
+	if err != nil {
+		return "", jobs.Job{}, err
 	}
 
-	// Store the Drupal upload URL in the session for later use
-	session, exists := h.sessionStore.Get(sessionID)
-	if exists {
-		// Add Drupal metadata to session
+	if err := h.updateSession(sessionID, func(session *models.CorrectionSession) {
 		session.Config.Prompt = fmt.Sprintf("Drupal Node %s - %s", nid, session.Config.Prompt)
-
-		// Store upload URL in the new dedicated field
-		if len(session.Images) > 0 {
-			session.Images[0].DrupalUploadURL = hocrUploadURL
-			session.Images[0].DrupalNid = nid
-		}
-
-		h.sessionStore.Set(sessionID, session)
+	}); err != nil {
+		return "", jobs.Job{}, err
 	}
 
-	return sessionID, nil
+	slog.Info("Session queued from Drupal node", "session_id", sessionID, "nid", nid, "job_id", job.ID)
+	return sessionID, job, nil
 }
 
-// createSessionFromDrupalWithExistingHOCR creates a session using existing hOCR from Drupal
-func (h *Handler) createSessionFromDrupalWithExistingHOCR(imageURL, hocrURL, nid string) (string, error) {
-	// Download image from URL (similar to createSessionFromURL but use existing hOCR)
-	resp, err := http.Get(imageURL)
+// drupalNodeImage downloads the service file of a node. Its existing hOCR is used
+// if it came from Google Cloud Vision; otherwise the image is OCR'd with engine.
+func (h *Handler) drupalNodeImage(ctx context.Context, nid string, engine ocr.Engine) (models.ImageItem, error) {
+	node, err := h.fetchDrupalNode(ctx, nid)
 	if err != nil {
-		return "", fmt.Errorf("failed to download image: %w", err)
+		return models.ImageItem{}, err
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("failed to download image: HTTP %d", resp.StatusCode)
+	var image models.ImageItem
+	if node.hasExistingHOCR() {
+		slog.Info("Using existing hOCR from Drupal", "nid", node.Nid, "hocr_uri", node.HOCRFile.URI)
+		image, err = h.imageItemWithHOCR(ctx, node.ImageURL, node.HOCRURL)
+	} else {
+		slog.Info("Generating new hOCR", "engine", engine.Name(), "nid", node.Nid, "hocr_uri", node.HOCRFile.URI)
+		image, err = h.imageItemFromURL(ctx, node.ImageURL, "img_1", engine)
 	}
-
-	// Read image data
-	imageData, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return "", fmt.Errorf("failed to read image data: %w", err)
+		return models.ImageItem{}, fmt.Errorf("failed to create session from Drupal: %w", err)
 	}
 
-	// Get content type from response
-	contentType := resp.Header.Get("Content-Type")
+	image.DrupalUploadURL = node.HOCRUploadURL
+	image.DrupalNid = node.Nid
+	return image, nil
+}
+
+// imageItemWithHOCR downloads an image, converting it via Houdini if needed, and pairs
+// it with hOCR that was already made for it
+func (h *Handler) imageItemWithHOCR(ctx context.Context, imageURL, hocrURL string) (models.ImageItem, error) {
+	imageData, contentType, err := h.downloadURL(ctx, imageURL)
+	if err != nil {
+		return models.ImageItem{}, err
+	}
 
 	// Convert JP2/TIFF images using Houdini if needed
 	originalImageData := imageData
@@ -801,7 +1672,7 @@ func (h *Handler) createSessionFromDrupalWithExistingHOCR(imageURL, hocrURL, nid
 		slog.Info("Image requires Houdini conversion", "content_type", contentType, "url", imageURL)
 		convertedData, err := h.convertImageViaHoudini(imageData, contentType)
 		if err != nil {
-			return "", fmt.Errorf("failed to convert image via Houdini: %w", err)
+			return models.ImageItem{}, fmt.Errorf("failed to convert image via Houdini: %w", err)
 		}
 		imageData = convertedData
 		contentType = "image/jpeg" // Houdini converts to JPEG
@@ -810,18 +1681,6 @@ func (h *Handler) createSessionFromDrupalWithExistingHOCR(imageURL, hocrURL, nid
 	// Calculate MD5 hash of the original image data for consistent caching
 	md5Hash := utils.CalculateDataMD5(originalImageData)
 
-	// Extract filename from URL or use md5 hash
-	filename := md5Hash
-	if urlParts := strings.Split(imageURL, "/"); len(urlParts) > 0 {
-		lastPart := urlParts[len(urlParts)-1]
-		if lastPart != "" && strings.Contains(lastPart, ".") {
-			filename = strings.TrimSuffix(lastPart, filepath.Ext(lastPart))
-		}
-	}
-
-	// Use NID in session name for Drupal sessions
-	sessionID := fmt.Sprintf("drupal_%s_%s_%d", nid, filename, time.Now().Unix())
-
 	// Determine file extension from content type (which may have been updated by Houdini conversion)
 	ext := ".jpg" // default
 	switch contentType {
@@ -840,7 +1699,7 @@ func (h *Handler) createSessionFromDrupalWithExistingHOCR(imageURL, hocrURL, nid
 
 	uploadsDir := "uploads"
 	if err := os.MkdirAll(uploadsDir, 0755); err != nil {
-		return "", fmt.Errorf("failed to create uploads directory: %w", err)
+		return models.ImageItem{}, fmt.Errorf("failed to create uploads directory: %w", err)
 	}
 
 	imageFilename := md5Hash + ext
@@ -848,7 +1707,7 @@ func (h *Handler) createSessionFromDrupalWithExistingHOCR(imageURL, hocrURL, nid
 
 	// Save image file
 	if err := os.WriteFile(imageFilePath, imageData, 0644); err != nil {
-		return "", fmt.Errorf("failed to save image: %w", err)
+		return models.ImageItem{}, fmt.Errorf("failed to save image: %w", err)
 	}
 
 	slog.Info("Image downloaded and saved", "filename", imageFilename, "md5", md5Hash, "url", imageURL)
@@ -857,85 +1716,40 @@ func (h *Handler) createSessionFromDrupalWithExistingHOCR(imageURL, hocrURL, nid
 	width, height := utils.GetImageDimensions(imageFilePath)
 
 	// Download existing hOCR
-	hocrResp, err := http.Get(hocrURL)
-	if err != nil {
-		return "", fmt.Errorf("failed to download existing hOCR: %w", err)
-	}
-	defer hocrResp.Body.Close()
-
-	if hocrResp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("failed to download hOCR: HTTP %d", hocrResp.StatusCode)
-	}
-
-	hocrData, err := io.ReadAll(hocrResp.Body)
+	hocrData, _, err := h.fetcher.Get(ctx, hocrURL, hocrContentTypes...)
 	if err != nil {
-		return "", fmt.Errorf("failed to read hOCR data: %w", err)
-	}
-
-	hocrXML := string(hocrData)
-	slog.Info("Using existing hOCR from Drupal", "nid", nid, "hocr_url", hocrURL)
-
-	// Create session
-	session := &models.CorrectionSession{
-		ID:        sessionID,
-		Images:    []models.ImageItem{},
-		Current:   0,
-		CreatedAt: time.Now(),
-		Config: models.EvalConfig{
-			Model:       "drupal_existing_hocr",
-			Prompt:      "Using existing hOCR from Drupal",
-			Temperature: 0.0,
-			Timestamp:   time.Now().Format("2006-01-02_15-04-05"),
-		},
-	}
-
-	imageItem := models.ImageItem{
-		ID:            "img_1",
-		ImagePath:     imageFilename,
-		ImageURL:      "/static/uploads/" + imageFilename,
-		OriginalHOCR:  hocrXML,
-		CorrectedHOCR: "",
-		Completed:     false,
-		ImageWidth:    width,
-		ImageHeight:   height,
+		return models.ImageItem{}, fmt.Errorf("failed to download existing hOCR: %w", err)
 	}
 
-	session.Images = []models.ImageItem{imageItem}
-	h.sessionStore.Set(sessionID, session)
-
-	slog.Info("Session created from Drupal with existing hOCR", "session_id", sessionID, "nid", nid)
-	return sessionID, nil
+	return models.ImageItem{
+		ID:           "img_1",
+		ImagePath:    imageFilename,
+		ImageURL:     "/static/uploads/" + imageFilename,
+		OriginalHOCR: string(hocrData),
+		ImageWidth:   width,
+		ImageHeight:  height,
+	}, nil
 }
 
-// createSessionFromDrupalWithNewHOCR creates a session and generates new hOCR via Google Cloud Vision
-func (h *Handler) createSessionFromDrupalWithNewHOCR(imageURL, nid string) (string, error) {
-	// Download image from URL (similar to createSessionFromURL)
-	resp, err := http.Get(imageURL)
-	if err != nil {
-		return "", fmt.Errorf("failed to download image: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("failed to download image: HTTP %d", resp.StatusCode)
-	}
-
-	// Read image data
-	imageData, err := io.ReadAll(resp.Body)
+// imageItemFromURL downloads an image, converting it via Houdini if needed, and OCRs it
+func (h *Handler) imageItemFromURL(ctx context.Context, imageURL, imageID string, engine ocr.Engine) (models.ImageItem, error) {
+	imageData, contentType, err := h.downloadURL(ctx, imageURL)
 	if err != nil {
-		return "", fmt.Errorf("failed to read image data: %w", err)
+		return models.ImageItem{}, err
 	}
 
-	// Get content type from response
-	contentType := resp.Header.Get("Content-Type")
+	return h.imageItemFromData(imageURL, imageData, contentType, imageID, engine)
+}
 
+// imageItemFromData saves a downloaded image, converting it via Houdini if needed, and OCRs it
+func (h *Handler) imageItemFromData(imageURL string, imageData []byte, contentType, imageID string, engine ocr.Engine) (models.ImageItem, error) {
 	// Convert JP2/TIFF images using Houdini if needed
 	originalImageData := imageData
 	if needsHoudiniConversion(contentType, imageURL) {
 		slog.Info("Image requires Houdini conversion", "content_type", contentType, "url", imageURL)
 		convertedData, err := h.convertImageViaHoudini(imageData, contentType)
 		if err != nil {
-			return "", fmt.Errorf("failed to convert image via Houdini: %w", err)
+			return models.ImageItem{}, fmt.Errorf("failed to convert image via Houdini: %w", err)
 		}
 		imageData = convertedData
 		contentType = "image/jpeg" // Houdini converts to JPEG
@@ -944,18 +1758,6 @@ func (h *Handler) createSessionFromDrupalWithNewHOCR(imageURL, nid string) (stri
 	// Calculate MD5 hash of the original image data for consistent caching
 	md5Hash := utils.CalculateDataMD5(originalImageData)
 
-	// Extract filename from URL or use md5 hash
-	filename := md5Hash
-	if urlParts := strings.Split(imageURL, "/"); len(urlParts) > 0 {
-		lastPart := urlParts[len(urlParts)-1]
-		if lastPart != "" && strings.Contains(lastPart, ".") {
-			filename = strings.TrimSuffix(lastPart, filepath.Ext(lastPart))
-		}
-	}
-
-	// Use NID in session name for Drupal sessions
-	sessionID := fmt.Sprintf("drupal_%s_%s_%d", nid, filename, time.Now().Unix())
-
 	// Determine file extension from content type (which may have been updated by Houdini conversion)
 	ext := ".jpg" // default
 	switch contentType {
@@ -974,17 +1776,17 @@ func (h *Handler) createSessionFromDrupalWithNewHOCR(imageURL, nid string) (stri
 
 	uploadsDir := "uploads"
 	if err := os.MkdirAll(uploadsDir, 0755); err != nil {
-		return "", fmt.Errorf("failed to create uploads directory: %w", err)
+		return models.ImageItem{}, fmt.Errorf("failed to create uploads directory: %w", err)
 	}
 
 	imageFilename := md5Hash + ext
-	hocrFilename := md5Hash + ".xml"
+	hocrFilename := hocrCacheFilename(md5Hash, engine)
 	imageFilePath := filepath.Join(uploadsDir, imageFilename)
 	hocrFilePath := filepath.Join(uploadsDir, hocrFilename)
 
 	// Save image file
 	if err := os.WriteFile(imageFilePath, imageData, 0644); err != nil {
-		return "", fmt.Errorf("failed to save image: %w", err)
+		return models.ImageItem{}, fmt.Errorf("failed to save image: %w", err)
 	}
 
 	slog.Info("Image downloaded and saved", "filename", imageFilename, "md5", md5Hash, "url", imageURL)
@@ -992,53 +1794,14 @@ func (h *Handler) createSessionFromDrupalWithNewHOCR(imageURL, nid string) (stri
 	// Get image dimensions
 	width, height := utils.GetImageDimensions(imageFilePath)
 
-	// Process hOCR (check cache first, then generate via Google Cloud Vision)
-	var hocrXML string
-	if _, err := os.Stat(hocrFilePath); err == nil {
-		hocrData, err := os.ReadFile(hocrFilePath)
-		if err != nil {
-			slog.Warn("Failed to read existing hOCR file", "error", err, "path", hocrFilePath)
-			hocrXML, err = h.getOCRForImage(imageFilePath)
-			if err != nil {
-				return "", fmt.Errorf("failed to process image with OCR: %w", err)
-			}
-			if err := os.WriteFile(hocrFilePath, []byte(hocrXML), 0644); err != nil {
-				slog.Warn("Failed to save hOCR file", "error", err)
-			}
-		} else {
-			hocrXML = string(hocrData)
-			slog.Info("Using cached hOCR", "filename", hocrFilename)
-		}
-	} else {
-		slog.Info("Generating new hOCR via Google Cloud Vision", "filename", imageFilename)
-		hocrXML, err = h.getOCRForImage(imageFilePath)
-		if err != nil {
-			return "", fmt.Errorf("failed to process image with OCR: %w", err)
-		}
-
-		if err := os.WriteFile(hocrFilePath, []byte(hocrXML), 0644); err != nil {
-			slog.Warn("Failed to save hOCR file", "error", err)
-		} else {
-			slog.Info("hOCR cached", "filename", hocrFilename)
-		}
-	}
-
-	// Create session
-	session := &models.CorrectionSession{
-		ID:        sessionID,
-		Images:    []models.ImageItem{},
-		Current:   0,
-		CreatedAt: time.Now(),
-		Config: models.EvalConfig{
-			Model:       "google_cloud_vision",
-			Prompt:      "Google Cloud Vision OCR with hOCR conversion for Drupal",
-			Temperature: 0.0,
-			Timestamp:   time.Now().Format("2006-01-02_15-04-05"),
-		},
+	// Process hOCR (check cache first, then generate via the OCR engine)
+	hocrXML, _, err := h.hocrForImage(imageFilePath, hocrFilePath, engine)
+	if err != nil {
+		return models.ImageItem{}, fmt.Errorf("failed to process image with OCR: %w", err)
 	}
 
-	imageItem := models.ImageItem{
-		ID:            "img_1",
+	return models.ImageItem{
+		ID:            imageID,
 		ImagePath:     imageFilename,
 		ImageURL:      "/static/uploads/" + imageFilename,
 		OriginalHOCR:  hocrXML,
@@ -1046,11 +1809,17 @@ func (h *Handler) createSessionFromDrupalWithNewHOCR(imageURL, nid string) (stri
 		Completed:     false,
 		ImageWidth:    width,
 		ImageHeight:   height,
-	}
-
-	session.Images = []models.ImageItem{imageItem}
-	h.sessionStore.Set(sessionID, session)
+	}, nil
+}
 
-	slog.Info("Session created from Drupal with new hOCR", "session_id", sessionID, "nid", nid)
-	return sessionID, nil
+// filenameFromURL returns the last path segment of a URL without its extension,
+// or fallback when the URL does not end in a file name
+func filenameFromURL(rawURL, fallback string) string {
+	if urlParts := strings.Split(rawURL, "/"); len(urlParts) > 0 {
+		lastPart := urlParts[len(urlParts)-1]
+		if lastPart != "" && strings.Contains(lastPart, ".") {
+			return strings.TrimSuffix(lastPart, filepath.Ext(lastPart))
+		}
+	}
+	return fallback
 }
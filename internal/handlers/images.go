@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/lehigh-university-libraries/hocr-edit/internal/models"
+	"github.com/lehigh-university-libraries/hocr-edit/internal/services/auth"
+	"github.com/lehigh-university-libraries/hocr-edit/internal/services/hocr"
+	"github.com/lehigh-university-libraries/hocr-edit/internal/services/review"
+	"github.com/lehigh-university-libraries/hocr-edit/internal/utils"
+)
+
+func (h *Handler) getImage(w http.ResponseWriter, _ *http.Request, sessionID, imageID string) {
+	w.Header().Set("Content-Type", "application/json")
+
+	image, ok := h.sessionImageOrError(w, sessionID, imageID)
+	if !ok {
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(image); err != nil {
+		slog.Error("Unable to encode image", "err", err)
+	}
+}
+
+// patchImage changes the ground truth and completed flag of a page. The hOCR is
+// changed through the edit API, so every change to it is logged.
+func (h *Handler) patchImage(w http.ResponseWriter, r *http.Request, sessionID, imageID string) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var patch struct {
+		GroundTruth *string `json:"ground_truth"`
+		Completed   *bool   `json:"completed"`
+		Version     *int    `json:"version"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+		utils.RespondWithError(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	expected, err := expectedVersion(r, patch.Version)
+	if err != nil {
+		utils.RespondWithError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var image models.ImageItem
+	saved, err := h.sessionStore.Update(sessionID, func(session *models.CorrectionSession) error {
+		if err := checkExpectedVersion(session, expected); err != nil {
+			return err
+		}
+		index, err := imageIndex(session, imageID)
+		if err != nil {
+			return err
+		}
+		if err := checkEditable(&session.Images[index], auth.UserFromContext(r.Context())); err != nil {
+			return err
+		}
+
+		if patch.GroundTruth != nil {
+			session.Images[index].GroundTruth = *patch.GroundTruth
+		}
+		if patch.Completed != nil {
+			session.Images[index].Completed = *patch.Completed
+		}
+		image = session.Images[index]
+		return nil
+	})
+	if err != nil {
+		utils.RespondWithError(w, err.Error(), reviewWriteStatus(err))
+		return
+	}
+
+	w.Header().Set("ETag", sessionETag(saved))
+	if err := json.NewEncoder(w).Encode(image); err != nil {
+		slog.Error("Unable to encode image", "err", err)
+	}
+}
+
+// deleteImage removes a page from its session. Pages the caller may not edit in
+// their review status cannot be removed either.
+func (h *Handler) deleteImage(w http.ResponseWriter, r *http.Request, sessionID, imageID string) {
+	expected, err := expectedVersion(r, nil)
+	if err != nil {
+		utils.RespondWithError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	saved, err := h.sessionStore.Update(sessionID, func(session *models.CorrectionSession) error {
+		if err := checkExpectedVersion(session, expected); err != nil {
+			return err
+		}
+		index, err := imageIndex(session, imageID)
+		if err != nil {
+			return err
+		}
+		if err := checkEditable(&session.Images[index], auth.UserFromContext(r.Context())); err != nil {
+			return err
+		}
+
+		session.Images = slices.Delete(session.Images, index, index+1)
+		session.Current = min(session.Current, len(session.Images))
+		session.Status = review.SessionStatus(session.Images)
+		return nil
+	})
+	if err != nil {
+		utils.RespondWithError(w, err.Error(), reviewWriteStatus(err))
+		return
+	}
+
+	slog.Info("Deleted image", "session_id", sessionID, "image_id", imageID, "user", auth.UserFromContext(r.Context()).ID)
+	w.Header().Set("ETag", sessionETag(saved))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// imageIndex finds an image of a session, failing with hocr.ErrNotFound
+func imageIndex(session *models.CorrectionSession, imageID string) (int, error) {
+	index := slices.IndexFunc(session.Images, func(image models.ImageItem) bool {
+		return image.ID == imageID
+	})
+	if index < 0 {
+		return -1, fmt.Errorf("image %s: %w", imageID, hocr.ErrNotFound)
+	}
+	return index, nil
+}
+
+// appendImage adds an image to the end of a session under the next free img_N ID.
+// Pages can be deleted while a batch is still adding to the session, so the ID comes
+// from the highest one in use rather than the number of images.
+func appendImage(session *models.CorrectionSession, image models.ImageItem) {
+	last := 0
+	for _, existing := range session.Images {
+		if n, err := strconv.Atoi(strings.TrimPrefix(existing.ID, "img_")); err == nil {
+			last = max(last, n)
+		}
+	}
+	image.ID = fmt.Sprintf("img_%d", last+1)
+	session.Images = append(session.Images, image)
+}
@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/lehigh-university-libraries/hocr-edit/internal/models"
+	"github.com/lehigh-university-libraries/hocr-edit/internal/services/jobs"
+	"github.com/lehigh-university-libraries/hocr-edit/internal/utils"
+)
+
+// HandleJobs reports the status of a background OCR job at /api/jobs/{id}
+func (h *Handler) HandleJobs(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	job, exists := h.jobQueue.Get(r.PathValue("id"))
+	if !exists || !h.canAccessSession(r, job.SessionID) {
+		utils.RespondWithError(w, "Job not found", http.StatusNotFound)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(job); err != nil {
+		slog.Error("Unable to encode job", "err", err)
+	}
+}
+
+// HandleJobEvents streams every change to a job as server-sent events at /api/jobs/{id}/events
+func (h *Handler) HandleJobEvents(w http.ResponseWriter, r *http.Request) {
+	h.handleJobEvents(w, r, r.PathValue("id"))
+}
+
+// HandleJobCancel cancels a queued or running job at DELETE /api/jobs/{id}.
+// Jobs that already finished cannot be canceled.
+func (h *Handler) HandleJobCancel(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	jobID := r.PathValue("id")
+	job, exists := h.jobQueue.Get(jobID)
+	if !exists || !h.canAccessSession(r, job.SessionID) {
+		utils.RespondWithError(w, "Job not found", http.StatusNotFound)
+		return
+	}
+
+	job, err := h.jobQueue.Cancel(jobID)
+	switch {
+	case errors.Is(err, jobs.ErrNotFound):
+		utils.RespondWithError(w, "Job not found", http.StatusNotFound)
+		return
+	case errors.Is(err, jobs.ErrFinished):
+		utils.RespondWithError(w, err.Error(), http.StatusConflict)
+		return
+	case err != nil:
+		utils.RespondWithError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if job.Status == jobs.StatusCanceled {
+		// a job canceled before it ran never gets to record that on its session
+		h.finishBatch(job.SessionID, models.BatchCanceled)
+		h.failJob(job.SessionID, context.Canceled)
+	}
+
+	slog.Info("Canceled job", "job_id", jobID, "session_id", job.SessionID)
+	if err := json.NewEncoder(w).Encode(job); err != nil {
+		slog.Error("Unable to encode job", "err", err)
+	}
+}
+
+// handleJobEvents sends the current state of the job, then each update, closing
+// the stream once the job has succeeded or failed
+func (h *Handler) handleJobEvents(w http.ResponseWriter, r *http.Request, jobID string) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		utils.RespondWithError(w, "Streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	job, exists := h.jobQueue.Get(jobID)
+	if !exists || !h.canAccessSession(r, job.SessionID) {
+		utils.RespondWithError(w, "Job not found", http.StatusNotFound)
+		return
+	}
+
+	updates, cancel := h.jobQueue.Subscribe(jobID)
+	defer cancel()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	if !writeJobEvent(w, job) {
+		return
+	}
+	flusher.Flush()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case update, open := <-updates:
+			if !open {
+				// the final snapshot may have been dropped for a slow reader, so send it again
+				if job, exists := h.jobQueue.Get(jobID); exists && job.Finished() {
+					writeJobEvent(w, job)
+					flusher.Flush()
+				}
+				return
+			}
+			if !writeJobEvent(w, update) {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+func writeJobEvent(w http.ResponseWriter, job jobs.Job) bool {
+	data, err := json.Marshal(job)
+	if err != nil {
+		slog.Error("Unable to encode job", "err", err)
+		return false
+	}
+
+	if _, err := fmt.Fprintf(w, "data: %s\n\n", data); err != nil {
+		slog.Warn("Unable to write job event", "job_id", job.ID, "err", err)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/lehigh-university-libraries/hocr-edit/internal/models"
+	"github.com/lehigh-university-libraries/hocr-edit/internal/services/jobs"
+	"github.com/lehigh-university-libraries/hocr-edit/internal/services/ocr"
+	"github.com/lehigh-university-libraries/hocr-edit/internal/storage"
+)
+
+// waitForJob blocks until a job has finished
+func waitForJob(t *testing.T, queue *jobs.Queue, jobID string) jobs.Job {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if job, _ := queue.Get(jobID); job.Finished() {
+			return job
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("Job %s did not finish", jobID)
+	return jobs.Job{}
+}
+
+func TestQueuedSessionRecordsFailure(t *testing.T) {
+	h := &Handler{sessionStore: storage.NewMemory(), jobQueue: jobs.NewQueue(1, 10, 1, 0, time.Hour)}
+
+	job, err := h.queueSession("failed", ocr.NewTesseract(), "jo", "url", func(context.Context, jobs.ProgressFunc) ([]models.ImageItem, error) {
+		return nil, errors.New("failed to download image: HTTP 404")
+	})
+	if err != nil {
+		t.Fatalf("Error queueing session: %v", err)
+	}
+	if job := waitForJob(t, h.jobQueue, job.ID); job.Status != jobs.StatusFailed {
+		t.Fatalf("Expected the job to fail, got %s", job.Status)
+	}
+	if session, _ := h.sessionStore.Get("failed"); session.JobError != "failed to download image: HTTP 404" {
+		t.Errorf("Expected the failure on the session, got %q", session.JobError)
+	}
+
+	started := make(chan struct{})
+	job, err = h.queueSession("canceled", ocr.NewTesseract(), "jo", "url", func(ctx context.Context, _ jobs.ProgressFunc) ([]models.ImageItem, error) {
+		close(started)
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	if err != nil {
+		t.Fatalf("Error queueing session: %v", err)
+	}
+	<-started
+	if _, err := h.jobQueue.Cancel(job.ID); err != nil {
+		t.Fatalf("Error canceling job: %v", err)
+	}
+	if job := waitForJob(t, h.jobQueue, job.ID); job.Status != jobs.StatusCanceled {
+		t.Fatalf("Expected the job to be canceled, got %s", job.Status)
+	}
+	if session, _ := h.sessionStore.Get("canceled"); session.JobError != "canceled" || len(session.Images) != 0 {
+		t.Errorf("Expected the cancellation on the session, got %q with %d images", session.JobError, len(session.Images))
+	}
+}
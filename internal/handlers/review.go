@@ -0,0 +1,257 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/lehigh-university-libraries/hocr-edit/internal/models"
+	"github.com/lehigh-university-libraries/hocr-edit/internal/services/auth"
+	"github.com/lehigh-university-libraries/hocr-edit/internal/services/hocr"
+	"github.com/lehigh-university-libraries/hocr-edit/internal/services/review"
+	"github.com/lehigh-university-libraries/hocr-edit/internal/utils"
+)
+
+// errPageLocked is returned for edits to a page its review status does not let the user change
+var errPageLocked = errors.New("page is locked for review")
+
+type reviewRequest struct {
+	Action  string `json:"action"`
+	Comment string `json:"comment"`
+}
+
+// handleReview moves pages through the review workflow with {"action", "comment"}.
+// With an imageID only that page moves; without one, every page of the session for
+// which the action is valid does, and the request fails if there are none.
+func (h *Handler) handleReview(w http.ResponseWriter, r *http.Request, sessionID, imageID string) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var request reviewRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		utils.RespondWithError(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	expected, err := expectedVersion(r, nil)
+	if err != nil {
+		utils.RespondWithError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	user := auth.UserFromContext(r.Context())
+	events := map[string]models.ReviewEvent{}
+	saved, err := h.sessionStore.Update(sessionID, func(session *models.CorrectionSession) error {
+		if err := checkExpectedVersion(session, expected); err != nil {
+			return err
+		}
+
+		if imageID != "" {
+			index := slices.IndexFunc(session.Images, func(image models.ImageItem) bool {
+				return image.ID == imageID
+			})
+			if index < 0 {
+				return fmt.Errorf("image %s: %w", imageID, hocr.ErrNotFound)
+			}
+			event, err := review.Apply(&session.Images[index], request.Action, user, request.Comment)
+			if err != nil {
+				return err
+			}
+			events[imageID] = event
+		} else {
+			var lastErr error
+			for i := range session.Images {
+				event, err := review.Apply(&session.Images[i], request.Action, user, request.Comment)
+				switch {
+				case errors.Is(err, review.ErrInvalidTransition):
+					lastErr = err
+					continue
+				case err != nil:
+					return err
+				}
+				events[session.Images[i].ID] = event
+			}
+			if len(events) == 0 {
+				if lastErr == nil {
+					lastErr = fmt.Errorf("%w: session has no pages", review.ErrInvalidTransition)
+				}
+				return lastErr
+			}
+		}
+
+		session.Status = review.SessionStatus(session.Images)
+		return nil
+	})
+	if err != nil {
+		utils.RespondWithError(w, err.Error(), reviewWriteStatus(err))
+		return
+	}
+
+	slog.Info("Reviewed pages", "session_id", sessionID, "action", request.Action, "user", user.ID, "pages", len(events))
+	w.Header().Set("ETag", sessionETag(saved))
+	response := map[string]any{
+		"status":  saved.Status,
+		"pages":   events,
+		"version": saved.Version,
+	}
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		slog.Error("Unable to encode review", "err", err)
+	}
+}
+
+// handleSessionReview applies a review action to every page of a session it is valid for
+func (h *Handler) handleSessionReview(w http.ResponseWriter, r *http.Request, sessionID string) {
+	h.handleReview(w, r, sessionID, "")
+}
+
+// queueItem is a page in the review queue. Since is when the page reached its
+// status and Comment the last comment left on it.
+type queueItem struct {
+	SessionID  string    `json:"session_id"`
+	ImageID    string    `json:"image_id"`
+	ImageURL   string    `json:"image_url"`
+	PageNumber int       `json:"page_number,omitempty"`
+	Status     string    `json:"status"`
+	Awaiting   string    `json:"awaiting"`
+	Since      time.Time `json:"since"`
+	Comment    string    `json:"comment,omitempty"`
+}
+
+// HandleReviewQueue lists the pages waiting for one of the caller's roles, longest
+// waiting first. The role query parameter limits it to one role. Transcribers only
+// see the pages of sessions they created.
+func (h *Handler) HandleReviewQueue(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	user := auth.UserFromContext(r.Context())
+	roles := slices.DeleteFunc(slices.Clone(auth.Roles), func(role string) bool {
+		return !user.HasRole(role)
+	})
+	if role := r.URL.Query().Get("role"); role != "" {
+		if !slices.Contains(auth.Roles, role) {
+			utils.RespondWithError(w, "unknown role "+role, http.StatusBadRequest)
+			return
+		}
+		if !user.HasRole(role) {
+			utils.RespondWithError(w, "you do not have the "+role+" role", http.StatusForbidden)
+			return
+		}
+		roles = []string{role}
+	}
+
+	queue := []queueItem{}
+	for _, session := range h.sessionStore.GetAll() {
+		if !user.CanAccess(session.CreatedBy) {
+			continue
+		}
+		for i := range session.Images {
+			image := &session.Images[i]
+			status := review.Status(image)
+			awaiting := review.Awaiting(status)
+			if !slices.Contains(roles, awaiting) {
+				continue
+			}
+			if awaiting == auth.RoleTranscriber && !user.Admin && session.CreatedBy != user.ID {
+				continue
+			}
+
+			item := queueItem{
+				SessionID:  session.ID,
+				ImageID:    image.ID,
+				ImageURL:   image.ImageURL,
+				PageNumber: image.PageNumber,
+				Status:     status,
+				Awaiting:   awaiting,
+				Since:      session.CreatedAt,
+			}
+			if len(image.Reviews) > 0 {
+				last := image.Reviews[len(image.Reviews)-1]
+				item.Since = last.At
+				item.Comment = last.Comment
+			}
+			queue = append(queue, item)
+		}
+	}
+
+	slices.SortStableFunc(queue, func(a, b queueItem) int {
+		return a.Since.Compare(b.Since)
+	})
+
+	if err := json.NewEncoder(w).Encode(queue); err != nil {
+		slog.Error("Unable to encode review queue", "err", err)
+	}
+}
+
+// checkEditable fails with errPageLocked when user may not change the page's hOCR
+func checkEditable(image *models.ImageItem, user *auth.User) error {
+	if !review.CanEdit(image, user) {
+		return fmt.Errorf("%w: image %s is %s", errPageLocked, image.ID, review.Status(image))
+	}
+	return nil
+}
+
+// keepReviewState stops a PUT of the whole session from changing where its pages are
+// in review
+func keepReviewState(stored, updated *models.CorrectionSession) {
+	for i := range updated.Images {
+		index := slices.IndexFunc(stored.Images, func(image models.ImageItem) bool {
+			return image.ID == updated.Images[i].ID
+		})
+		if index < 0 {
+			updated.Images[i].Status = ""
+			updated.Images[i].Reviews = nil
+			continue
+		}
+		image := &stored.Images[index]
+		updated.Images[i].Status = image.Status
+		updated.Images[i].Reviews = image.Reviews
+	}
+	updated.Status = review.SessionStatus(updated.Images)
+}
+
+// checkLockedPages fails with errPageLocked when a PUT of the whole session leaves out
+// or changes a page user may not edit, as deleteImage and the edit routes would refuse
+// it. It runs after the review state and history of the pages have been carried over.
+func checkLockedPages(stored, updated *models.CorrectionSession, user *auth.User) error {
+	for i := range stored.Images {
+		image := &stored.Images[i]
+		if review.CanEdit(image, user) {
+			continue
+		}
+		index := slices.IndexFunc(updated.Images, func(updated models.ImageItem) bool {
+			return updated.ID == image.ID
+		})
+		if index < 0 {
+			return fmt.Errorf("%w: image %s is %s and cannot be removed", errPageLocked, image.ID, review.Status(image))
+		}
+		before, err := json.Marshal(image)
+		if err != nil {
+			return err
+		}
+		after, err := json.Marshal(&updated.Images[index])
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(before, after) {
+			return fmt.Errorf("%w: image %s is %s", errPageLocked, image.ID, review.Status(image))
+		}
+	}
+	return nil
+}
+
+// reviewWriteStatus is sessionWriteStatus for errors of the review workflow
+func reviewWriteStatus(err error) int {
+	switch {
+	case errors.Is(err, hocr.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, review.ErrForbidden):
+		return http.StatusForbidden
+	case errors.Is(err, review.ErrInvalidTransition):
+		return http.StatusConflict
+	}
+	return sessionWriteStatus(err)
+}
@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/lehigh-university-libraries/hocr-edit/internal/models"
+	"github.com/lehigh-university-libraries/hocr-edit/internal/services/auth"
+)
+
+func TestReviewWorkflow(t *testing.T) {
+	h := newEditTestHandler(t)
+	if _, err := h.sessionStore.Update("s1", func(session *models.CorrectionSession) error {
+		session.CreatedBy = "jo"
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	users := map[string]*auth.User{
+		"jo":  {ID: "jo"},
+		"rey": {ID: "rey", Roles: []string{auth.RoleReviewer}},
+		"abe": {ID: "abe", Roles: []string{auth.RoleApprover}},
+	}
+	do := func(user, method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req = req.WithContext(auth.WithUser(req.Context(), users[user]))
+		rec := httptest.NewRecorder()
+		h.Routes().ServeHTTP(rec, req)
+		return rec
+	}
+	queue := func(user string) []queueItem {
+		rec := do(user, "GET", "/api/review/queue", "")
+		var items []queueItem
+		if err := json.NewDecoder(rec.Body).Decode(&items); err != nil {
+			t.Fatalf("Error decoding queue: %v", err)
+		}
+		return items
+	}
+	const image = "/api/sessions/s1/images/img_1/"
+
+	if items := queue("jo"); len(items) != 1 || items[0].Status != models.ReviewDraft {
+		t.Errorf("Expected the draft in the transcriber's queue, got %+v", items)
+	}
+	if items := queue("rey"); len(items) != 0 {
+		t.Errorf("Expected an empty reviewer queue, got %+v", items)
+	}
+
+	if rec := do("jo", "POST", image+"review", `{"action": "submit"}`); rec.Code != http.StatusOK {
+		t.Fatalf("Expected submit to succeed, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec := do("jo", "PATCH", image+"words/word_1", `{"text": "Dear"}`); rec.Code != http.StatusForbidden {
+		t.Errorf("Expected the submitted page to be locked for the transcriber, got %d", rec.Code)
+	}
+	if rec := do("jo", "POST", image+"review", `{"action": "review"}`); rec.Code != http.StatusForbidden {
+		t.Errorf("Expected the transcriber to be refused the review, got %d", rec.Code)
+	}
+
+	if items := queue("rey"); len(items) != 1 || items[0].Awaiting != auth.RoleReviewer {
+		t.Errorf("Expected the submitted page in the reviewer's queue, got %+v", items)
+	}
+	if rec := do("rey", "PATCH", image+"words/word_1", `{"text": "Dear"}`); rec.Code != http.StatusOK {
+		t.Errorf("Expected the reviewer to correct the page, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec := do("rey", "POST", image+"review", `{"action": "reject", "comment": "check the signature"}`); rec.Code != http.StatusOK {
+		t.Fatalf("Expected reject to succeed, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if items := queue("jo"); len(items) != 1 || items[0].Comment != "check the signature" {
+		t.Errorf("Expected the rejected page back with its comment, got %+v", items)
+	}
+
+	if rec := do("jo", "POST", "/api/sessions/s1/review", `{"action": "submit"}`); rec.Code != http.StatusOK {
+		t.Fatalf("Expected the session to be submitted, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec := do("rey", "POST", image+"review", `{"action": "review"}`); rec.Code != http.StatusOK {
+		t.Fatalf("Expected review to succeed, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec := do("rey", "POST", image+"review", `{"action": "approve"}`); rec.Code != http.StatusForbidden {
+		t.Errorf("Expected the reviewer to be refused the approval, got %d", rec.Code)
+	}
+	if items := queue("abe"); len(items) != 1 {
+		t.Errorf("Expected the reviewed page in the approver's queue, got %+v", items)
+	}
+	rec := do("abe", "POST", image+"review", `{"action": "approve"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected approve to succeed, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	// a PUT cannot move pages back, nor drop or change an approved page
+	var approved models.CorrectionSession
+	if err := json.NewDecoder(rec.Body).Decode(&approved); err != nil {
+		t.Fatal(err)
+	}
+	stored, _ := h.sessionStore.Get("s1")
+	put := func(change func(image map[string]any) []any) *httptest.ResponseRecorder {
+		var image map[string]any
+		encoded, _ := json.Marshal(stored.Images[0])
+		if err := json.Unmarshal(encoded, &image); err != nil {
+			t.Fatal(err)
+		}
+		body, _ := json.Marshal(map[string]any{"id": "s1", "version": approved.Version, "images": change(image)})
+		return do("jo", "PUT", "/api/sessions/s1", string(body))
+	}
+	if rec := put(func(map[string]any) []any { return []any{} }); rec.Code != http.StatusForbidden {
+		t.Errorf("Expected a PUT dropping the approved page to be refused, got %d", rec.Code)
+	}
+	if rec := put(func(image map[string]any) []any {
+		image["ground_truth"] = "Dear sir"
+		return []any{image}
+	}); rec.Code != http.StatusForbidden {
+		t.Errorf("Expected a PUT changing the approved page's ground truth to be refused, got %d", rec.Code)
+	}
+	if rec := put(func(image map[string]any) []any {
+		image["status"] = models.ReviewDraft
+		image["corrected_hocr"] = "<html/>"
+		return []any{image}
+	}); rec.Code != http.StatusOK {
+		t.Fatalf("Expected the PUT to succeed, got %d: %s", rec.Code, rec.Body.String())
+	}
+	session, _ := h.sessionStore.Get("s1")
+	if session.Status != models.ReviewApproved || session.Images[0].Status != models.ReviewApproved {
+		t.Errorf("Expected the session to stay approved, got %s/%s", session.Status, session.Images[0].Status)
+	}
+	if session.Images[0].CorrectedHOCR == "<html/>" {
+		t.Error("Expected the approved page's hOCR to be kept")
+	}
+	if len(session.Images[0].Reviews) != 5 {
+		t.Errorf("Expected 5 review events, got %d", len(session.Images[0].Reviews))
+	}
+}
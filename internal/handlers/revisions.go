@@ -0,0 +1,271 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/lehigh-university-libraries/hocr-edit/internal/models"
+	"github.com/lehigh-university-libraries/hocr-edit/internal/services/auth"
+	"github.com/lehigh-university-libraries/hocr-edit/internal/services/hocr"
+	"github.com/lehigh-university-libraries/hocr-edit/internal/utils"
+	"github.com/lehigh-university-libraries/hocr-edit/pkg/hocr/parser"
+)
+
+// handleRevisionList lists the revisions of an image. The revision history is served
+// under /api/sessions/{id}/images/{imageId}/:
+//
+//	GET  revisions                      list revisions, oldest first
+//	GET  revisions/{n}                  one revision with its hOCR
+//	GET  revisions/diff?from=a&to=b     word level diff of two revisions
+//	POST revisions/{n}/restore          make revision n current
+//
+// In from, to and restore, 0 or "original" is the original OCR output and
+// "current" is the hOCR the image has now.
+func (h *Handler) handleRevisionList(w http.ResponseWriter, _ *http.Request, sessionID, imageID string) {
+	w.Header().Set("Content-Type", "application/json")
+
+	image, ok := h.sessionImageOrError(w, sessionID, imageID)
+	if !ok {
+		return
+	}
+
+	type revisionInfo struct {
+		models.Revision
+		HOCR  string `json:"hocr,omitempty"`
+		Words int    `json:"words"`
+	}
+
+	revisions := make([]revisionInfo, len(image.Revisions))
+	for i, revision := range image.Revisions {
+		words, err := parser.ParseHOCRWords(revision.HOCR)
+		if err != nil {
+			slog.Warn("Unable to parse revision", "session_id", sessionID, "image_id", imageID, "revision", revision.Number, "err", err)
+		}
+		revisions[i] = revisionInfo{Revision: revision, Words: len(words)}
+	}
+
+	if err := json.NewEncoder(w).Encode(revisions); err != nil {
+		slog.Error("Unable to encode revisions", "err", err)
+	}
+}
+
+func (h *Handler) handleRevision(w http.ResponseWriter, r *http.Request, sessionID, imageID string) {
+	w.Header().Set("Content-Type", "application/json")
+
+	ref := r.PathValue("n")
+	image, ok := h.sessionImageOrError(w, sessionID, imageID)
+	if !ok {
+		return
+	}
+
+	number, err := strconv.Atoi(ref)
+	if err != nil || number < 1 || number > len(image.Revisions) {
+		utils.RespondWithError(w, "revision "+ref+" not found", http.StatusNotFound)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(image.Revisions[number-1]); err != nil {
+		slog.Error("Unable to encode revision", "err", err)
+	}
+}
+
+func (h *Handler) handleRevisionDiff(w http.ResponseWriter, r *http.Request, sessionID, imageID string) {
+	w.Header().Set("Content-Type", "application/json")
+
+	image, ok := h.sessionImageOrError(w, sessionID, imageID)
+	if !ok {
+		return
+	}
+
+	from, to := r.URL.Query().Get("from"), r.URL.Query().Get("to")
+	if from == "" || to == "" {
+		utils.RespondWithError(w, "from and to are required", http.StatusBadRequest)
+		return
+	}
+
+	var words [2][]models.HOCRWord
+	for i, ref := range []string{from, to} {
+		hocrXML, _, err := revisionHOCR(image, ref)
+		if err != nil {
+			utils.RespondWithError(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		words[i], err = parser.ParseHOCRWords(hocrXML)
+		if err != nil {
+			utils.RespondWithError(w, fmt.Sprintf("Failed to parse revision %s: %v", ref, err), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	changes, summary := hocr.DiffWords(words[0], words[1])
+	response := map[string]any{
+		"from":    from,
+		"to":      to,
+		"changes": changes,
+		"summary": summary,
+	}
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		slog.Error("Unable to encode revision diff", "err", err)
+	}
+}
+
+// handleRevisionRestore copies an earlier revision to the image's CorrectedHOCR as a
+// new revision, so the history is never rewritten and a restore can itself be undone
+func (h *Handler) handleRevisionRestore(w http.ResponseWriter, r *http.Request, sessionID, imageID string) {
+	w.Header().Set("Content-Type", "application/json")
+
+	ref := r.PathValue("n")
+	var request struct {
+		Author string `json:"author"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			utils.RespondWithError(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+	}
+
+	expected, err := expectedVersion(r, nil)
+	if err != nil {
+		utils.RespondWithError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var revision models.Revision
+	saved, err := h.sessionStore.Update(sessionID, func(session *models.CorrectionSession) error {
+		if err := checkExpectedVersion(session, expected); err != nil {
+			return err
+		}
+
+		index := slices.IndexFunc(session.Images, func(image models.ImageItem) bool {
+			return image.ID == imageID
+		})
+		if index < 0 {
+			return fmt.Errorf("image %s: %w", imageID, hocr.ErrNotFound)
+		}
+		image := &session.Images[index]
+		if err := checkEditable(image, auth.UserFromContext(r.Context())); err != nil {
+			return err
+		}
+
+		hocrXML, number, err := revisionHOCR(image, ref)
+		if err != nil {
+			return err
+		}
+
+		image.CorrectedHOCR = hocrXML
+		revision = addRevision(image, hocrXML, requestAuthor(r, request.Author), models.RevisionRestore, &number)
+		session.Operations = append(session.Operations, models.Operation{
+			ID:      fmt.Sprintf("op_%d", len(session.Operations)+1),
+			ImageID: imageID,
+			Type:    "restore_revision",
+			Target:  fmt.Sprintf("revision_%d", number),
+			Result:  []string{fmt.Sprintf("revision_%d", revision.Number)},
+			At:      revision.CreatedAt,
+		})
+		return nil
+	})
+	if err != nil {
+		status := sessionWriteStatus(err)
+		if errors.Is(err, hocr.ErrNotFound) {
+			status = http.StatusNotFound
+		}
+		utils.RespondWithError(w, err.Error(), status)
+		return
+	}
+
+	w.Header().Set("ETag", sessionETag(saved))
+	slog.Info("Restored hOCR revision", "session_id", sessionID, "image_id", imageID, "from", ref, "revision", revision.Number)
+	if err := json.NewEncoder(w).Encode(revision); err != nil {
+		slog.Error("Unable to encode revision", "err", err)
+	}
+}
+
+// addRevision appends hocrXML to the image's history and returns the new revision
+func addRevision(image *models.ImageItem, hocrXML, author, source string, restoredFrom *int) models.Revision {
+	if author == "" {
+		author = auth.Anonymous.ID
+	}
+	revision := models.Revision{
+		Number:       len(image.Revisions) + 1,
+		HOCR:         hocrXML,
+		Author:       author,
+		Source:       source,
+		RestoredFrom: restoredFrom,
+		CreatedAt:    time.Now(),
+	}
+	image.Revisions = append(image.Revisions, revision)
+	return revision
+}
+
+// keepHistory stops a PUT of the whole session from rewriting its history. The original
+// hOCR is revision 0, corrected hOCR only changes through the hOCR update, edit and
+// restore routes, which record a revision or operation for it, and the batch fields
+// belong to the job filling the session.
+func keepHistory(stored, updated *models.CorrectionSession) {
+	for i := range updated.Images {
+		index := slices.IndexFunc(stored.Images, func(image models.ImageItem) bool {
+			return image.ID == updated.Images[i].ID
+		})
+		if index < 0 {
+			updated.Images[i].CorrectedHOCR = ""
+			updated.Images[i].Revisions = nil
+			continue
+		}
+		updated.Images[i].OriginalHOCR = stored.Images[index].OriginalHOCR
+		updated.Images[i].CorrectedHOCR = stored.Images[index].CorrectedHOCR
+		updated.Images[i].Revisions = stored.Images[index].Revisions
+	}
+	updated.Operations = stored.Operations
+	updated.Batch = stored.Batch
+	updated.JobID = stored.JobID
+	updated.JobError = stored.JobError
+}
+
+// revisionHOCR resolves a revision reference to its hOCR and revision number, with
+// the original OCR output as revision 0
+func revisionHOCR(image *models.ImageItem, ref string) (string, int, error) {
+	switch ref {
+	case "original":
+		return image.OriginalHOCR, 0, nil
+	case "current":
+		if image.CorrectedHOCR == "" {
+			return image.OriginalHOCR, 0, nil
+		}
+		return image.CorrectedHOCR, len(image.Revisions), nil
+	}
+
+	number, err := strconv.Atoi(ref)
+	switch {
+	case err != nil || number < 0 || number > len(image.Revisions):
+		return "", 0, fmt.Errorf("revision %s: %w", ref, hocr.ErrNotFound)
+	case number == 0:
+		return image.OriginalHOCR, 0, nil
+	}
+	return image.Revisions[number-1].HOCR, number, nil
+}
+
+// sessionImageOrError looks up an image of a session, responding with 404 when
+// either does not exist
+func (h *Handler) sessionImageOrError(w http.ResponseWriter, sessionID, imageID string) (*models.ImageItem, bool) {
+	session, exists := h.sessionStore.Get(sessionID)
+	if !exists {
+		utils.RespondWithError(w, "Session not found", http.StatusNotFound)
+		return nil, false
+	}
+
+	index := slices.IndexFunc(session.Images, func(image models.ImageItem) bool {
+		return image.ID == imageID
+	})
+	if index < 0 {
+		utils.RespondWithError(w, "Image not found", http.StatusNotFound)
+		return nil, false
+	}
+	return &session.Images[index], true
+}
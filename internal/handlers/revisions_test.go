@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/lehigh-university-libraries/hocr-edit/internal/models"
+	"github.com/lehigh-university-libraries/hocr-edit/internal/services/hocr"
+	"github.com/lehigh-university-libraries/hocr-edit/internal/storage"
+)
+
+func saveHOCR(t *testing.T, h *Handler, author, hocrXML string) {
+	t.Helper()
+	body, _ := json.Marshal(hocrEdit{SessionID: "s1", ImageID: "img_1", HOCR: hocrXML, Author: author})
+	rec := httptest.NewRecorder()
+	h.HandleHOCRUpdate(rec, newRequest("POST", "/api/hocr", strings.NewReader(string(body))))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected save to succeed, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestRevisions(t *testing.T) {
+	h := newEditTestHandler(t)
+
+	first := strings.Replace(editTestHOCR, ">Dearsir<", ">Dear sir<", 1)
+	saveHOCR(t, h, "student", first)
+	saveHOCR(t, h, "", strings.Replace(first, ">yo<", ">you<", 1))
+
+	rec := doEdit(t, h, "GET", "revisions", "")
+	var revisions []struct {
+		models.Revision
+		Words int `json:"words"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&revisions); err != nil {
+		t.Fatalf("Error decoding revisions: %v", err)
+	}
+	if len(revisions) != 2 || revisions[0].Author != "student" || revisions[1].Author != "anonymous" {
+		t.Fatalf("Unexpected revisions %+v", revisions)
+	}
+	if revisions[1].Number != 2 || revisions[1].HOCR != "" || revisions[1].Words != 3 {
+		t.Errorf("Expected the list to number revisions and count words without the hOCR, got %+v", revisions[1])
+	}
+
+	rec = doEdit(t, h, "GET", "revisions/diff?from=1&to=2", "")
+	var diff struct {
+		Changes []hocr.WordChange `json:"changes"`
+		Summary hocr.DiffSummary  `json:"summary"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&diff); err != nil {
+		t.Fatalf("Error decoding diff: %v", err)
+	}
+	if len(diff.Changes) != 1 || diff.Changes[0].From.Text != "yo" || diff.Changes[0].To.Text != "you" {
+		t.Errorf("Expected a single substitution, got %+v", diff.Changes)
+	}
+	if diff.Summary.Unchanged != 2 || diff.Summary.Substitutions != 1 {
+		t.Errorf("Unexpected diff summary %+v", diff.Summary)
+	}
+
+	rec = doEdit(t, h, "POST", "revisions/1/restore", `{"author": "supervisor"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected restore to succeed, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	session, _ := h.sessionStore.Get("s1")
+	image := session.Images[0]
+	if len(image.Revisions) != 3 || image.CorrectedHOCR != image.Revisions[0].HOCR {
+		t.Fatalf("Expected the restore to add revision 3 with revision 1's hOCR, got %d revisions", len(image.Revisions))
+	}
+	restored := image.Revisions[2]
+	if restored.Source != models.RevisionRestore || restored.RestoredFrom == nil || *restored.RestoredFrom != 1 || restored.Author != "supervisor" {
+		t.Errorf("Unexpected restored revision %+v", restored)
+	}
+	if last := session.Operations[len(session.Operations)-1]; last.Type != "restore_revision" || last.Target != "revision_1" {
+		t.Errorf("Expected the restore in the operation log, got %+v", last)
+	}
+}
+
+func TestRevisionErrors(t *testing.T) {
+	h := newEditTestHandler(t)
+	saveHOCR(t, h, "student", editTestHOCR)
+
+	tests := []struct {
+		method, path string
+		want         int
+	}{
+		{"GET", "revisions/2", http.StatusNotFound},
+		{"GET", "revisions/diff?from=original", http.StatusBadRequest},
+		{"GET", "revisions/diff?from=original&to=7", http.StatusNotFound},
+		{"GET", "revisions/diff?from=original&to=current", http.StatusOK},
+		{"POST", "revisions/9/restore", http.StatusNotFound},
+		{"DELETE", "revisions/1", http.StatusMethodNotAllowed},
+	}
+
+	for _, tt := range tests {
+		if rec := doEdit(t, h, tt.method, tt.path, ""); rec.Code != tt.want {
+			t.Errorf("%s %s: expected %d, got %d: %s", tt.method, tt.path, tt.want, rec.Code, rec.Body.String())
+		}
+	}
+}
+
+func TestSessionPutKeepsHistory(t *testing.T) {
+	h := newEditTestHandler(t)
+	saveHOCR(t, h, "student", strings.Replace(editTestHOCR, ">Dearsir<", ">Dear sir<", 1))
+	if rec := doEdit(t, h, "PATCH", "words/word_2", `{"text": "you"}`); rec.Code != http.StatusOK {
+		t.Fatalf("Expected the edit to succeed, got %d: %s", rec.Code, rec.Body.String())
+	}
+	stored, _ := h.sessionStore.Get("s1")
+
+	body := `{"id": "s1", "version": ` + strconv.Itoa(stored.Version) + `, "job_id": "job_x", "operations": [],
+		"batch": {"status": "completed", "nids": []},
+		"images": [{"id": "img_1", "original_hocr": "<html/>", "corrected_hocr": "<html/>", "revisions": []}, {"id": "img_2", "corrected_hocr": "<html/>"}]}`
+	rec := httptest.NewRecorder()
+	h.Routes().ServeHTTP(rec, newRequest("PUT", "/api/sessions/s1", strings.NewReader(body)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	session, _ := h.sessionStore.Get("s1")
+	if session.Images[0].CorrectedHOCR != stored.Images[0].CorrectedHOCR || len(session.Images[0].Revisions) != 1 {
+		t.Errorf("Expected the corrected hOCR and its revisions to be kept, got %d revisions", len(session.Images[0].Revisions))
+	}
+	if session.Images[0].OriginalHOCR != stored.Images[0].OriginalHOCR {
+		t.Errorf("Expected the original hOCR to be kept, got %q", session.Images[0].OriginalHOCR)
+	}
+	if session.Images[1].CorrectedHOCR != "" {
+		t.Errorf("Expected a new image to start without corrected hOCR, got %q", session.Images[1].CorrectedHOCR)
+	}
+	if len(session.Operations) != len(stored.Operations) || session.JobID != "" || session.Batch != nil {
+		t.Errorf("Expected the operations and batch fields to be kept, got %d operations, job %q, batch %+v", len(session.Operations), session.JobID, session.Batch)
+	}
+}
+
+// deletingStore removes every image of a session just before an update, as a
+// concurrent delete of the page would
+type deletingStore struct {
+	storage.SessionStore
+}
+
+func (s deletingStore) Update(sessionID string, change func(session *models.CorrectionSession) error) (*models.CorrectionSession, error) {
+	return s.SessionStore.Update(sessionID, func(session *models.CorrectionSession) error {
+		session.Images = nil
+		return change(session)
+	})
+}
+
+func TestHOCRUpdateOfDeletedImage(t *testing.T) {
+	h := newEditTestHandler(t)
+	store := h.sessionStore
+	h.sessionStore = deletingStore{store}
+
+	body, _ := json.Marshal(hocrEdit{SessionID: "s1", ImageID: "img_1", HOCR: editTestHOCR})
+	rec := httptest.NewRecorder()
+	h.HandleHOCRUpdate(rec, newRequest("POST", "/api/hocr/update", strings.NewReader(string(body))))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for an image deleted during the save, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	session, _ := store.Get("s1")
+	if len(session.Operations) != 0 || len(session.Images) != 1 {
+		t.Errorf("Expected nothing to be written, got %d operations and %d images", len(session.Operations), len(session.Images))
+	}
+}
@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/lehigh-university-libraries/hocr-edit/internal/utils"
+)
+
+// routeMethods are the methods probed to tell a path that exists for other methods
+// from a path that does not exist
+var routeMethods = []string{"GET", "POST", "PUT", "PATCH", "DELETE"}
+
+// Routes registers the API, the editor's pages and the health check on a new mux
+func (h *Handler) Routes() *http.ServeMux {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /api/sessions", h.HandleSessions)
+	mux.HandleFunc("GET /api/sessions/{id}", h.withSession(h.getSession))
+	mux.HandleFunc("PUT /api/sessions/{id}", h.withSession(h.putSession))
+	mux.HandleFunc("PATCH /api/sessions/{id}", h.withSession(h.patchSession))
+	mux.HandleFunc("DELETE /api/sessions/{id}", h.withSession(h.deleteSession))
+	mux.HandleFunc("POST /api/sessions/{id}/metrics", h.withSession(h.handleMetrics))
+	mux.HandleFunc("GET /api/sessions/{id}/hocr", h.withSession(h.handleSessionHOCR))
+	mux.HandleFunc("GET /api/sessions/{id}/alto", h.withSession(h.handleSessionALTO))
+	mux.HandleFunc("GET /api/sessions/{id}/pagexml", h.withSession(h.handleSessionPageXML))
+	mux.HandleFunc("GET /api/sessions/{id}/export.pdf", h.withSession(h.handleSessionPDF))
+	mux.HandleFunc("GET /api/sessions/{id}/batch", h.withSession(h.handleBatchProgress))
+	mux.HandleFunc("POST /api/sessions/{id}/review", h.withSession(h.handleSessionReview))
+
+	mux.HandleFunc("GET /api/sessions/{id}/images/{imageId}", h.withImage(h.getImage))
+	mux.HandleFunc("PATCH /api/sessions/{id}/images/{imageId}", h.withImage(h.patchImage))
+	mux.HandleFunc("DELETE /api/sessions/{id}/images/{imageId}", h.withImage(h.deleteImage))
+	mux.HandleFunc("POST /api/sessions/{id}/images/{imageId}/publish", h.withImage(h.handlePublish))
+	mux.HandleFunc("POST /api/sessions/{id}/images/{imageId}/review", h.withImage(h.handleReview))
+	mux.HandleFunc("GET /api/sessions/{id}/images/{imageId}/revisions", h.withImage(h.handleRevisionList))
+	mux.HandleFunc("GET /api/sessions/{id}/images/{imageId}/revisions/diff", h.withImage(h.handleRevisionDiff))
+	mux.HandleFunc("GET /api/sessions/{id}/images/{imageId}/revisions/{n}", h.withImage(h.handleRevision))
+	mux.HandleFunc("POST /api/sessions/{id}/images/{imageId}/revisions/{n}/restore", h.withImage(h.handleRevisionRestore))
+	mux.HandleFunc("PATCH /api/sessions/{id}/images/{imageId}/words/{wordId}", h.withImage(h.imageEdit(editWord)))
+	mux.HandleFunc("DELETE /api/sessions/{id}/images/{imageId}/words/{wordId}", h.withImage(h.imageEdit(deleteWord)))
+	mux.HandleFunc("POST /api/sessions/{id}/images/{imageId}/words/{wordId}/split", h.withImage(h.imageEdit(splitWord)))
+	mux.HandleFunc("POST /api/sessions/{id}/images/{imageId}/words/{wordId}/merge", h.withImage(h.imageEdit(mergeWords)))
+	mux.HandleFunc("POST /api/sessions/{id}/images/{imageId}/lines", h.withImage(h.imageEdit(insertLine)))
+	mux.HandleFunc("PATCH /api/sessions/{id}/images/{imageId}/lines/{lineId}", h.withImage(h.imageEdit(editLine)))
+	mux.HandleFunc("DELETE /api/sessions/{id}/images/{imageId}/lines/{lineId}", h.withImage(h.imageEdit(deleteLine)))
+	mux.HandleFunc("POST /api/sessions/{id}/images/{imageId}/lines/{lineId}/words", h.withImage(h.imageEdit(insertWord)))
+
+	mux.HandleFunc("POST /api/upload", h.HandleUpload)
+	mux.HandleFunc("POST /api/batches", h.HandleBatches)
+	mux.HandleFunc("GET /api/jobs/{id}", h.HandleJobs)
+	mux.HandleFunc("DELETE /api/jobs/{id}", h.HandleJobCancel)
+	mux.HandleFunc("GET /api/jobs/{id}/events", h.HandleJobEvents)
+	mux.HandleFunc("GET /api/engines", h.HandleEngines)
+	mux.HandleFunc("POST /api/hocr/parse", h.HandleHOCRParse)
+	mux.HandleFunc("POST /api/hocr/update", h.HandleHOCRUpdate)
+	mux.HandleFunc("POST /api/hocr/render", h.HandleHOCRRender)
+	mux.HandleFunc("GET /api/review/queue", h.HandleReviewQueue)
+	mux.HandleFunc("GET /api/auth/me", h.HandleAuthMe)
+	mux.HandleFunc("POST /api/auth/login", h.HandleAuthLogin)
+	mux.HandleFunc("POST /api/auth/logout", h.HandleAuthLogout)
+	mux.HandleFunc("GET /api/auth/oidc/login", h.HandleOIDCLogin)
+	mux.HandleFunc("GET /api/auth/oidc/callback", h.HandleOIDCCallback)
+	mux.HandleFunc("/api/", apiFallback(mux))
+
+	mux.HandleFunc("GET /healthcheck", h.HandleHealthcheck)
+	mux.HandleFunc("/", h.HandleStatic)
+
+	return mux
+}
+
+// apiFallback answers API requests no route matched with a JSON 405, listing the
+// methods the path does have, or a JSON 404
+func apiFallback(mux *http.ServeMux) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var allowed []string
+		for _, method := range routeMethods {
+			probe := r.Clone(r.Context())
+			probe.Method = method
+			if _, pattern := mux.Handler(probe); pattern != "" && pattern != "/api/" {
+				allowed = append(allowed, method)
+			}
+		}
+
+		if len(allowed) == 0 {
+			utils.RespondWithError(w, "Not found", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Allow", strings.Join(allowed, ", "))
+		utils.RespondWithError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// withSession passes the session named by the id path value to next, answering 404
+// for sessions the caller cannot access
+func (h *Handler) withSession(next func(w http.ResponseWriter, r *http.Request, sessionID string)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sessionID := r.PathValue("id")
+		if !h.canAccessSession(r, sessionID) {
+			utils.RespondWithError(w, "Session not found", http.StatusNotFound)
+			return
+		}
+		next(w, r, sessionID)
+	}
+}
+
+// withImage is withSession for routes that also name an image
+func (h *Handler) withImage(next func(w http.ResponseWriter, r *http.Request, sessionID, imageID string)) http.HandlerFunc {
+	return h.withSession(func(w http.ResponseWriter, r *http.Request, sessionID string) {
+		next(w, r, sessionID, r.PathValue("imageId"))
+	})
+}
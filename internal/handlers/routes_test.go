@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRoutes(t *testing.T) {
+	h := newEditTestHandler(t)
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		status int
+		allow  string
+	}{
+		{"session", "GET", "/api/sessions/s1", http.StatusOK, ""},
+		{"unknown session", "GET", "/api/sessions/nope", http.StatusNotFound, ""},
+		{"wrong method on session", "POST", "/api/sessions/s1", http.StatusMethodNotAllowed, "GET, PUT, PATCH, DELETE"},
+		{"sub-resource is not a session id", "GET", "/api/sessions/s1/metrics", http.StatusMethodNotAllowed, "POST"},
+		{"wrong method on word", "GET", "/api/sessions/s1/images/img_1/words/word_1", http.StatusMethodNotAllowed, "PATCH, DELETE"},
+		{"unknown image path", "GET", "/api/sessions/s1/images/img_1/nope", http.StatusNotFound, ""},
+		{"unknown API path", "GET", "/api/nope", http.StatusNotFound, ""},
+		{"wrong method on engines", "DELETE", "/api/engines", http.StatusMethodNotAllowed, "GET"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			h.Routes().ServeHTTP(rec, newRequest(tt.method, tt.path, nil))
+			if rec.Code != tt.status {
+				t.Fatalf("Expected %d, got %d: %s", tt.status, rec.Code, rec.Body.String())
+			}
+			if allow := rec.Header().Get("Allow"); allow != tt.allow {
+				t.Errorf("Expected Allow %q, got %q", tt.allow, allow)
+			}
+			if contentType := rec.Header().Get("Content-Type"); contentType != "application/json" {
+				t.Errorf("Expected a JSON response, got %s", contentType)
+			}
+			if rec.Code != http.StatusOK {
+				var body map[string]string
+				if err := json.NewDecoder(rec.Body).Decode(&body); err != nil || body["error"] == "" {
+					t.Errorf("Expected a JSON error, got %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestSessionPatchAndDelete(t *testing.T) {
+	h := newEditTestHandler(t)
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		h.Routes().ServeHTTP(rec, newRequest(method, path, strings.NewReader(body)))
+		return rec
+	}
+
+	if rec := do("PATCH", "/api/sessions/s1", `{"current": 5}`); rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected an out of range current to be rejected, got %d", rec.Code)
+	}
+	if rec := do("PATCH", "/api/sessions/s1", `{"language": "not a language"}`); rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected an invalid language to be rejected, got %d", rec.Code)
+	}
+	if rec := do("PATCH", "/api/sessions/s1", `{"current": 1, "language": "en_us", "version": 1}`); rec.Code != http.StatusOK {
+		t.Fatalf("Expected the session patch to succeed, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	rec := do("PATCH", "/api/sessions/s1/images/img_1", `{"ground_truth": "Dear sir yours"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected the image patch to succeed, got %d: %s", rec.Code, rec.Body.String())
+	}
+	session, _ := h.sessionStore.Get("s1")
+	if session.Current != 1 || session.Images[0].GroundTruth != "Dear sir yours" || session.Version != 3 {
+		t.Errorf("Expected both patches to apply, got current %d, ground truth %q at version %d", session.Current, session.Images[0].GroundTruth, session.Version)
+	}
+	if session.Language != "en-US" {
+		t.Errorf("Expected the language in canonical form, got %q", session.Language)
+	}
+
+	if rec := do("DELETE", "/api/sessions/s1/images/img_1", ""); rec.Code != http.StatusNoContent {
+		t.Fatalf("Expected the image to be deleted, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec := do("GET", "/api/sessions/s1/images/img_1", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected the deleted image to be gone, got %d", rec.Code)
+	}
+
+	req := newRequest("DELETE", "/api/sessions/s1", nil)
+	req.Header.Set("If-Match", `"1"`)
+	rec = httptest.NewRecorder()
+	h.Routes().ServeHTTP(rec, req)
+	if rec.Code != http.StatusConflict {
+		t.Errorf("Expected a stale delete to conflict, got %d", rec.Code)
+	}
+	if rec := do("DELETE", "/api/sessions/s1", ""); rec.Code != http.StatusNoContent {
+		t.Fatalf("Expected the session to be deleted, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if _, exists := h.sessionStore.Get("s1"); exists {
+		t.Error("Expected the session to be removed from the store")
+	}
+}
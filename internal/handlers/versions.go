@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/lehigh-university-libraries/hocr-edit/internal/models"
+	"github.com/lehigh-university-libraries/hocr-edit/internal/storage"
+)
+
+// sessionETag is the ETag of a session, which changes on every write
+func sessionETag(session *models.CorrectionSession) string {
+	return strconv.Quote(strconv.Itoa(session.Version))
+}
+
+// ifMatchVersion reads the session version a request was made against from its
+// If-Match header. ok is false when there is no header or it is "*".
+func ifMatchVersion(r *http.Request) (version int, ok bool, err error) {
+	header := strings.TrimSpace(r.Header.Get("If-Match"))
+	if header == "" || header == "*" {
+		return 0, false, nil
+	}
+
+	tag := strings.TrimPrefix(header, "W/")
+	unquoted, err := strconv.Unquote(tag)
+	if err != nil {
+		unquoted = tag
+	}
+	version, err = strconv.Atoi(unquoted)
+	if err != nil {
+		return 0, false, fmt.Errorf("invalid If-Match %q: expected the ETag of a session", header)
+	}
+	return version, true, nil
+}
+
+// expectedVersion returns the version a write must find the session at, taken from
+// If-Match or else from the version the client sent with its body, if any
+func expectedVersion(r *http.Request, bodyVersion *int) (*int, error) {
+	version, ok, err := ifMatchVersion(r)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		return &version, nil
+	}
+	return bodyVersion, nil
+}
+
+// checkExpectedVersion is CheckVersion for an optional expected version
+func checkExpectedVersion(session *models.CorrectionSession, expected *int) error {
+	if expected == nil {
+		return nil
+	}
+	return storage.CheckVersion(session, *expected)
+}
+
+// sessionWriteStatus is the status to report for an error from a session update
+func sessionWriteStatus(err error) int {
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, storage.ErrConflict):
+		return http.StatusConflict
+	case errors.Is(err, errPageLocked):
+		return http.StatusForbidden
+	case errors.Is(err, errInvalidEdit):
+		return http.StatusUnprocessableEntity
+	}
+	return http.StatusInternalServerError
+}
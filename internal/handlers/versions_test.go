@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/lehigh-university-libraries/hocr-edit/internal/models"
+)
+
+func TestSessionPutConflict(t *testing.T) {
+	h := newEditTestHandler(t)
+
+	get := httptest.NewRecorder()
+	h.Routes().ServeHTTP(get, newRequest("GET", "/api/sessions/s1", nil))
+	etag := get.Header().Get("ETag")
+	if etag != `"1"` {
+		t.Fatalf("Expected ETag \"1\", got %s", etag)
+	}
+
+	put := func(ifMatch, body string) *httptest.ResponseRecorder {
+		req := newRequest("PUT", "/api/sessions/s1", strings.NewReader(body))
+		if ifMatch != "" {
+			req.Header.Set("If-Match", ifMatch)
+		}
+		rec := httptest.NewRecorder()
+		h.Routes().ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := put(etag, `{"id": "s1", "current": 1}`)
+	if rec.Code != http.StatusOK || rec.Header().Get("ETag") != `"2"` {
+		t.Fatalf("Expected the first write to succeed with ETag \"2\", got %d %s: %s", rec.Code, rec.Header().Get("ETag"), rec.Body.String())
+	}
+
+	if rec := put(etag, `{"id": "s1", "current": 2}`); rec.Code != http.StatusConflict {
+		t.Errorf("Expected a stale If-Match to conflict, got %d", rec.Code)
+	}
+	if rec := put("", `{"id": "s1", "current": 2, "version": 1}`); rec.Code != http.StatusConflict {
+		t.Errorf("Expected a stale body version to conflict, got %d", rec.Code)
+	}
+	if rec := put("", `{"id": "s1", "current": 2, "version": 2}`); rec.Code != http.StatusOK {
+		t.Errorf("Expected the current body version to be accepted, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	session, _ := h.sessionStore.Get("s1")
+	if session.Current != 2 || session.Version != 3 {
+		t.Errorf("Expected only the current writes to apply, got current %d at version %d", session.Current, session.Version)
+	}
+}
+
+func TestEditConflict(t *testing.T) {
+	h := newEditTestHandler(t)
+
+	req := newRequest("PATCH", "/api/sessions/s1/images/img_1/words/word_1", strings.NewReader(`{"text": "Dear"}`))
+	req.Header.Set("If-Match", `"1"`)
+	rec := httptest.NewRecorder()
+	h.Routes().ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected the edit to succeed, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	req = newRequest("PATCH", "/api/sessions/s1/images/img_1/words/word_2", strings.NewReader(`{"text": "your"}`))
+	req.Header.Set("If-Match", `"1"`)
+	rec = httptest.NewRecorder()
+	h.Routes().ServeHTTP(rec, req)
+	if rec.Code != http.StatusConflict {
+		t.Errorf("Expected an edit against a stale version to conflict, got %d", rec.Code)
+	}
+
+	version := 1
+	body, _ := json.Marshal(hocrEdit{SessionID: "s1", ImageID: "img_1", HOCR: editTestHOCR, Version: &version})
+	rec = httptest.NewRecorder()
+	h.HandleHOCRUpdate(rec, newRequest("POST", "/api/hocr/update", strings.NewReader(string(body))))
+	if rec.Code != http.StatusConflict {
+		t.Errorf("Expected a save against a stale version to conflict, got %d", rec.Code)
+	}
+
+	session, _ := h.sessionStore.Get("s1")
+	if session.Version != 2 || len(session.Operations) != 1 {
+		t.Errorf("Expected only the first edit to apply, got version %d with %d operations", session.Version, len(session.Operations))
+	}
+}
+
+func TestSessionPutKeepsDrupalLinks(t *testing.T) {
+	h := newEditTestHandler(t)
+	_, err := h.sessionStore.Update("s1", func(session *models.CorrectionSession) error {
+		session.Images[0].DrupalUploadURL = "https://drupal.example.edu/node/42/media/file/7"
+		session.Images[0].DrupalNid = "42"
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Error updating session: %v", err)
+	}
+
+	body := `{"id": "s1", "version": 2, "images": [
+		{"id": "img_1", "drupal_upload_url": "http://169.254.169.254/latest", "drupal_nid": "1"},
+		{"id": "img_2", "drupal_upload_url": "http://169.254.169.254/latest", "drupal_nid": "1"}
+	]}`
+	rec := httptest.NewRecorder()
+	h.Routes().ServeHTTP(rec, newRequest("PUT", "/api/sessions/s1", strings.NewReader(body)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	session, _ := h.sessionStore.Get("s1")
+	if image := session.Images[0]; image.DrupalUploadURL != "https://drupal.example.edu/node/42/media/file/7" || image.DrupalNid != "42" {
+		t.Errorf("Expected the stored Drupal link to be kept, got %q %q", image.DrupalUploadURL, image.DrupalNid)
+	}
+	if image := session.Images[1]; image.DrupalUploadURL != "" || image.DrupalNid != "" {
+		t.Errorf("Expected a new image to have no Drupal link, got %q %q", image.DrupalUploadURL, image.DrupalNid)
+	}
+}
@@ -29,26 +29,32 @@ type Page struct {
 }
 
 type Block struct {
+	Property    *Property    `json:"property"`
 	BoundingBox BoundingPoly `json:"boundingBox"`
 	Paragraphs  []Paragraph  `json:"paragraphs"`
 	BlockType   string       `json:"blockType"`
 }
 
 type Paragraph struct {
+	Property    *Property    `json:"property"`
 	BoundingBox BoundingPoly `json:"boundingBox"`
 	Words       []Word       `json:"words"`
 }
 
+// Word is a recognized word. Confidence runs from 0 to 1, with 0 when GCV did not
+// report one, as it is then left out of the JSON.
 type Word struct {
 	Property    *Property    `json:"property"`
 	BoundingBox BoundingPoly `json:"boundingBox"`
 	Symbols     []Symbol     `json:"symbols"`
+	Confidence  float64      `json:"confidence,omitempty"`
 }
 
 type Symbol struct {
 	Property    *Property    `json:"property"`
 	BoundingBox BoundingPoly `json:"boundingBox"`
 	Text        string       `json:"text"`
+	Confidence  float64      `json:"confidence,omitempty"`
 }
 
 type Property struct {
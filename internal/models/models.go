@@ -1,6 +1,9 @@
 package models
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+)
 
 type EvalConfig struct {
 	Model       string  `json:"model"`
@@ -18,6 +21,7 @@ type EvalResult struct {
 	Public                bool    `json:"public"`
 	OpenAIResponse        string  `json:"openai_response"`
 	CharacterSimilarity   float64 `json:"character_similarity"`
+	CharacterErrorRate    float64 `json:"character_error_rate"`
 	WordSimilarity        float64 `json:"word_similarity"`
 	WordAccuracy          float64 `json:"word_accuracy"`
 	WordErrorRate         float64 `json:"word_error_rate"`
@@ -57,34 +61,206 @@ type CorrectionSession struct {
 	Results   []EvalResult `json:"results"`
 	Config    EvalConfig   `json:"config"`
 	CreatedAt time.Time    `json:"created_at"`
+	// CreatedBy is the ID of the user that created the session, who owns it
+	CreatedBy string `json:"created_by,omitempty"`
+	// JobID is the background job that fills in Images when the session was queued for OCR
+	JobID string `json:"job_id,omitempty"`
+	// JobError is why the job filling in Images failed or was canceled
+	JobError string `json:"job_error,omitempty"`
+	// Batch is set on sessions whose images are being ingested in the background
+	Batch *BatchProgress `json:"batch,omitempty"`
+	// Operations logs every edit applied to the session's hOCR, oldest first
+	Operations []Operation `json:"operations,omitempty"`
+	// Version is incremented by the session store on every write and is the session's ETag
+	Version int `json:"version"`
+	// Status is the review status of the session's least advanced page
+	Status string `json:"status,omitempty"`
+	// Language is a BCP 47 tag that replaces the languages OCR detected when the
+	// session's hOCR is written
+	Language string `json:"language,omitempty"`
+}
+
+// Operation is one edit to the hOCR of an image. Target is the word or line the
+// edit was addressed to and Result lists the IDs it created or changed.
+type Operation struct {
+	ID      string          `json:"id"`
+	ImageID string          `json:"image_id"`
+	Type    string          `json:"type"`
+	Target  string          `json:"target,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  []string        `json:"result,omitempty"`
+	At      time.Time       `json:"at"`
+}
+
+const (
+	BatchQueued    = "queued"
+	BatchRunning   = "running"
+	BatchCompleted = "completed"
+	BatchCanceled  = "canceled"
+)
+
+// BatchProgress tracks the Drupal nodes queued into a session. Skipped holds nodes
+// that already had hOCR in Drupal and Failed maps a node ID to its error.
+type BatchProgress struct {
+	Status     string            `json:"status"`
+	Collection string            `json:"collection,omitempty"`
+	Nids       []string          `json:"nids"`
+	Processed  int               `json:"processed"`
+	Skipped    []string          `json:"skipped"`
+	Failed     map[string]string `json:"failed"`
+	ReOCR      bool              `json:"reocr"`
+	StartedAt  time.Time         `json:"started_at"`
+	FinishedAt *time.Time        `json:"finished_at,omitempty"`
 }
 
 type ImageItem struct {
-	ID              string `json:"id"`
-	ImagePath       string `json:"image_path"`
-	ImageURL        string `json:"image_url"`
-	OriginalHOCR    string `json:"original_hocr"`
-	CorrectedHOCR   string `json:"corrected_hocr"`
-	GroundTruth     string `json:"ground_truth"`
+	ID            string `json:"id"`
+	ImagePath     string `json:"image_path"`
+	ImageURL      string `json:"image_url"`
+	OriginalHOCR  string `json:"original_hocr"`
+	CorrectedHOCR string `json:"corrected_hocr"`
+	GroundTruth   string `json:"ground_truth"`
+	// Completed is set once the page has been corrected; Status is where it is in review
 	Completed       bool   `json:"completed"`
+	Status          string `json:"status,omitempty"`
 	ImageWidth      int    `json:"image_width"`
 	ImageHeight     int    `json:"image_height"`
 	DrupalUploadURL string `json:"drupal_upload_url,omitempty"`
 	DrupalNid       string `json:"drupal_nid,omitempty"`
+	// DocumentID groups the pages of a multi-page TIFF or PDF.
+	// PageNumber is 1-based and PageCount is the number of pages in the document.
+	DocumentID string `json:"document_id,omitempty"`
+	PageNumber int    `json:"page_number,omitempty"`
+	PageCount  int    `json:"page_count,omitempty"`
+	// LastPublish is the outcome of the most recent upload of this page's hOCR to Drupal
+	LastPublish *PublishResult `json:"last_publish,omitempty"`
+	// Revisions holds every saved CorrectedHOCR, oldest first. Revision N is Revisions[N-1].
+	Revisions []Revision `json:"revisions,omitempty"`
+	// Reviews logs every review transition of the page, oldest first
+	Reviews []ReviewEvent `json:"reviews,omitempty"`
 }
 
-type HOCRLine struct {
+// Review states of a page. A page without a status is a draft.
+const (
+	ReviewDraft     = "draft"
+	ReviewSubmitted = "submitted"
+	ReviewReviewed  = "reviewed"
+	ReviewApproved  = "approved"
+	ReviewRejected  = "rejected"
+)
+
+// ReviewEvent is one transition of a page's review status
+type ReviewEvent struct {
+	Action  string    `json:"action"`
+	From    string    `json:"from"`
+	To      string    `json:"to"`
+	User    string    `json:"user"`
+	Comment string    `json:"comment,omitempty"`
+	At      time.Time `json:"at"`
+}
+
+const (
+	RevisionSave    = "save"
+	RevisionRestore = "restore"
+)
+
+// Revision is one saved version of an image's corrected hOCR. RestoredFrom is the
+// revision a restore copied, where 0 is the original OCR output.
+type Revision struct {
+	Number       int       `json:"number"`
+	HOCR         string    `json:"hocr"`
+	Author       string    `json:"author"`
+	Source       string    `json:"source"`
+	RestoredFrom *int      `json:"restored_from,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// PublishResult records a server side upload of corrected hOCR to Drupal
+type PublishResult struct {
+	Success     bool      `json:"success"`
+	StatusCode  int       `json:"status_code,omitempty"`
+	Destination string    `json:"destination"`
+	Attempts    int       `json:"attempts"`
+	Error       string    `json:"error,omitempty"`
+	PublishedAt time.Time `json:"published_at"`
+}
+
+// HOCRPage is an ocr_page with its content areas in reading order.
+// Image and PageNumber are the hOCR image and ppageno (0-based) properties.
+// Lang, here and on paragraphs and words, is the element's lang attribute and
+// is empty when the element has the language of the one containing it.
+type HOCRPage struct {
+	ID         string     `json:"id"`
+	BBox       BBox       `json:"bbox"`
+	Image      string     `json:"image,omitempty"`
+	PageNumber int        `json:"ppageno"`
+	Lang       string     `json:"lang,omitempty"`
+	Areas      []HOCRArea `json:"areas"`
+}
+
+// HOCRArea is an ocr_carea. Areas with an empty ID were not present in the
+// source document and are only there to hold stray paragraphs or lines.
+// Poly, here and on paragraphs, lines and words, is the hOCR poly property: the
+// outline of a slanted or irregular element, which its bbox only bounds. It is
+// empty when the bbox is the outline.
+type HOCRArea struct {
+	ID         string          `json:"id"`
+	BBox       BBox            `json:"bbox"`
+	Poly       []Point         `json:"poly,omitempty"`
+	Paragraphs []HOCRParagraph `json:"paragraphs"`
+}
+
+// HOCRParagraph is an ocr_par. As with HOCRArea an empty ID marks an implicit paragraph.
+type HOCRParagraph struct {
 	ID    string     `json:"id"`
 	BBox  BBox       `json:"bbox"`
-	Words []HOCRWord `json:"words"`
+	Poly  []Point    `json:"poly,omitempty"`
+	Lang  string     `json:"lang,omitempty"`
+	Lines []HOCRLine `json:"lines"`
+}
+
+// HOCRLine is an ocr_line. PageID is only set when the line was parsed
+// from a document that wraps its lines in ocr_page elements.
+// TextAngle is how far the line is turned counterclockwise, in degrees, and its
+// baseline and sizes are measured along it: XSize is the height of the line,
+// XDescenders how far letters reach below the baseline and XAscenders how far
+// they reach above the x-height. Zero sizes are unknown.
+type HOCRLine struct {
+	ID          string     `json:"id"`
+	PageID      string     `json:"page_id,omitempty"`
+	BBox        BBox       `json:"bbox"`
+	Poly        []Point    `json:"poly,omitempty"`
+	TextAngle   float64    `json:"textangle,omitempty"`
+	Baseline    *Baseline  `json:"baseline,omitempty"`
+	XSize       float64    `json:"x_size,omitempty"`
+	XDescenders float64    `json:"x_descenders,omitempty"`
+	XAscenders  float64    `json:"x_ascenders,omitempty"`
+	Words       []HOCRWord `json:"words"`
+}
+
+// Baseline is the hOCR baseline of a line, y = Slope*x + Offset, with x and y
+// relative to the bottom left corner of the line's bbox. Offset is negative
+// when the baseline is above the bottom of the bbox.
+type Baseline struct {
+	Slope  float64 `json:"slope"`
+	Offset float64 `json:"offset"`
 }
 
+// HOCRWord is an ocrx_word. Confidence is its x_wconf and CharConfidences its
+// x_confs, one per character of Text, both from 0 to 100. CharBoxes are its
+// x_bboxes, one per character; they are stale once the text is edited to a
+// different length, as they no longer line up with its characters.
 type HOCRWord struct {
-	ID         string  `json:"id"`
-	Text       string  `json:"text"`
-	BBox       BBox    `json:"bbox"`
-	Confidence float64 `json:"confidence"`
-	LineID     string  `json:"line_id"`
+	ID              string    `json:"id"`
+	Text            string    `json:"text"`
+	BBox            BBox      `json:"bbox"`
+	Poly            []Point   `json:"poly,omitempty"`
+	Confidence      float64   `json:"confidence"`
+	CharConfidences []float64 `json:"char_confidences,omitempty"`
+	CharBoxes       []BBox    `json:"char_boxes,omitempty"`
+	CharBoxesStale  bool      `json:"char_boxes_stale,omitempty"`
+	Lang            string    `json:"lang,omitempty"`
+	LineID          string    `json:"line_id"`
 }
 
 type BBox struct {
@@ -93,3 +269,9 @@ type BBox struct {
 	X2 int `json:"x2"`
 	Y2 int `json:"y2"`
 }
+
+// Point is a vertex of a Poly, in image pixels
+type Point struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+}
@@ -0,0 +1,350 @@
+package auth
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/lehigh-university-libraries/hocr-edit/internal/utils"
+)
+
+const (
+	ProviderLocal = "local"
+	ProviderToken = "token"
+	ProviderOIDC  = "oidc"
+
+	// RoleTranscriber corrects pages and submits them for review. Every user has it.
+	RoleTranscriber = "transcriber"
+	// RoleReviewer checks submitted pages and sends them on or back
+	RoleReviewer = "reviewer"
+	// RoleApprover makes the final decision on reviewed pages
+	RoleApprover = "approver"
+
+	// SessionCookie holds the signed login of a browser user
+	SessionCookie = "hocr_session"
+	// SessionTTL is how long a login lasts before the user has to sign in again
+	SessionTTL = 12 * time.Hour
+)
+
+// ErrUnauthenticated is returned for credentials that are missing or not valid
+var ErrUnauthenticated = errors.New("authentication required")
+
+// Anonymous is the user of every request when authentication is disabled
+var Anonymous = &User{ID: "anonymous", Name: "Anonymous", Admin: true, Provider: "none"}
+
+// Nobody is the user of requests that were not authenticated while authentication is
+// enabled. It owns nothing and has no roles beyond transcriber, so it can reach no session.
+var Nobody = &User{Name: "Nobody", Provider: "none"}
+
+// SessionQueries are the query parameters the editor's page creates a session from
+var SessionQueries = []string{"image", "nid", "collection"}
+
+// Roles lists every role, in the order a page passes through them
+var Roles = []string{RoleTranscriber, RoleReviewer, RoleApprover}
+
+// User is the caller of a request. ID is what sessions record as their owner.
+type User struct {
+	ID       string   `json:"id"`
+	Name     string   `json:"name"`
+	Admin    bool     `json:"admin"`
+	Roles    []string `json:"roles,omitempty"`
+	Provider string   `json:"provider"`
+}
+
+// Anonymous reports whether the user stands in for a caller that was never identified
+func (u *User) Anonymous() bool {
+	return u == Anonymous
+}
+
+// HasRole reports whether the user may act as role. Admins have every role.
+func (u *User) HasRole(role string) bool {
+	return u.Admin || role == RoleTranscriber || slices.Contains(u.Roles, role)
+}
+
+// CanAccess reports whether the user may see and change something owned by owner.
+// Reviewers and approvers work on everyone's pages, so they can reach every session.
+// Sessions from before there were owners can only be reached by admins and them.
+func (u *User) CanAccess(owner string) bool {
+	if u.HasRole(RoleReviewer) || u.HasRole(RoleApprover) {
+		return true
+	}
+	return owner != "" && owner == u.ID
+}
+
+type contextKey struct{}
+
+// WithUser returns a context carrying user
+func WithUser(ctx context.Context, user *User) context.Context {
+	return context.WithValue(ctx, contextKey{}, user)
+}
+
+// UserFromContext returns the user the middleware authenticated. Requests that did
+// not pass through the middleware are Nobody, so a route that misses it fails closed.
+func UserFromContext(ctx context.Context) *User {
+	if user, ok := ctx.Value(contextKey{}).(*User); ok {
+		return user
+	}
+	return Nobody
+}
+
+// Service authenticates requests with the providers listed in AUTH_PROVIDERS
+type Service struct {
+	providers []string
+	secret    []byte
+	users     map[string]localUser
+	tokens    map[string]string
+	admins    []string
+	roles     map[string][]string
+	oidc      *oidcProvider
+}
+
+// New reads its configuration from the environment:
+// AUTH_PROVIDERS is a comma separated list of local, token and oidc, and leaves
+// authentication disabled when empty; AUTH_USERS_FILE lists the local users and their
+// API tokens; AUTH_SECRET signs login cookies; AUTH_ADMINS names users that are admins
+// whichever provider they sign in with; AUTH_REVIEWERS and AUTH_APPROVERS do the same
+// for those roles. OIDC is configured by the OIDC_* variables.
+func New() (*Service, error) {
+	s := &Service{
+		secret: []byte(os.Getenv("AUTH_SECRET")),
+		users:  map[string]localUser{},
+		tokens: map[string]string{},
+		roles:  map[string][]string{},
+	}
+
+	for _, provider := range strings.Split(os.Getenv("AUTH_PROVIDERS"), ",") {
+		provider = strings.TrimSpace(provider)
+		switch provider {
+		case "":
+			continue
+		case ProviderLocal, ProviderToken, ProviderOIDC:
+			s.providers = append(s.providers, provider)
+		default:
+			return nil, fmt.Errorf("unknown auth provider %q", provider)
+		}
+	}
+	if len(s.providers) == 0 {
+		slog.Warn("Authentication is disabled, set AUTH_PROVIDERS to require users to sign in")
+		return s, nil
+	}
+
+	s.admins = splitList(os.Getenv("AUTH_ADMINS"))
+	s.roles[RoleReviewer] = splitList(os.Getenv("AUTH_REVIEWERS"))
+	s.roles[RoleApprover] = splitList(os.Getenv("AUTH_APPROVERS"))
+
+	if (s.Enabled(ProviderLocal) || s.Enabled(ProviderOIDC)) && len(s.secret) < 32 {
+		return nil, fmt.Errorf("AUTH_SECRET of at least 32 bytes is required to sign logins")
+	}
+
+	if s.Enabled(ProviderLocal) || s.Enabled(ProviderToken) {
+		path := os.Getenv("AUTH_USERS_FILE")
+		if path == "" {
+			return nil, fmt.Errorf("AUTH_USERS_FILE is required for local users and API tokens")
+		}
+		if err := s.loadUsers(path); err != nil {
+			return nil, err
+		}
+	}
+
+	if s.Enabled(ProviderOIDC) {
+		provider, err := newOIDCProvider()
+		if err != nil {
+			return nil, err
+		}
+		s.oidc = provider
+	}
+
+	slog.Info("Authentication enabled", "providers", s.providers, "users", len(s.users))
+	return s, nil
+}
+
+// Enabled reports whether provider is one of the configured providers
+func (s *Service) Enabled(provider string) bool {
+	return slices.Contains(s.providers, provider)
+}
+
+// Providers lists the configured providers, which is empty when authentication is disabled
+func (s *Service) Providers() []string {
+	return slices.Clone(s.providers)
+}
+
+// Authenticate identifies the caller of a request from an API token, HTTP basic
+// credentials of a local user or a login cookie
+func (s *Service) Authenticate(r *http.Request) (*User, error) {
+	if len(s.providers) == 0 {
+		return Anonymous, nil
+	}
+
+	if header := r.Header.Get("Authorization"); header != "" {
+		if token, ok := strings.CutPrefix(header, "Bearer "); ok && s.Enabled(ProviderToken) {
+			return s.tokenUser(token)
+		}
+		if username, password, ok := r.BasicAuth(); ok && s.Enabled(ProviderLocal) {
+			return s.Login(username, password)
+		}
+		return nil, ErrUnauthenticated
+	}
+
+	cookie, err := r.Cookie(SessionCookie)
+	if err != nil {
+		return nil, ErrUnauthenticated
+	}
+	var user User
+	if err := s.verify(cookie.Value, &user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// Middleware authenticates every request that reaches the API, uploaded images or
+// creates a session from the page's query string. API calls without a valid user get
+// 401; page loads are sent to the start page to sign in. Other requests carry
+// Anonymous when authentication is disabled and Nobody otherwise.
+func (s *Service) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !protected(r) {
+			user := Nobody
+			if len(s.providers) == 0 {
+				user = Anonymous
+			}
+			next.ServeHTTP(w, r.WithContext(WithUser(r.Context(), user)))
+			return
+		}
+
+		user, err := s.Authenticate(r)
+		if err != nil {
+			if strings.HasPrefix(r.URL.Path, "/api/") {
+				w.Header().Set("Content-Type", "application/json")
+				utils.RespondWithError(w, err.Error(), http.StatusUnauthorized)
+				return
+			}
+			http.Redirect(w, r, "/?next="+url.QueryEscape(r.URL.RequestURI()), http.StatusFound)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(WithUser(r.Context(), user)))
+	})
+}
+
+// protected reports whether a request needs a user. The login endpoints, health
+// check and the editor's own files stay open so a browser can get to the login form.
+func protected(r *http.Request) bool {
+	switch {
+	case r.URL.Path == "/healthcheck", strings.HasPrefix(r.URL.Path, "/api/auth/"):
+		return false
+	case strings.HasPrefix(r.URL.Path, "/api/"), strings.HasPrefix(r.URL.Path, "/static/uploads/"):
+		return true
+	}
+	return slices.ContainsFunc(SessionQueries, r.URL.Query().Has)
+}
+
+// SetSession signs the user into the browser that made the request
+func (s *Service) SetSession(w http.ResponseWriter, r *http.Request, user *User) error {
+	value, err := s.sign(user, SessionTTL)
+	if err != nil {
+		return err
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     SessionCookie,
+		Value:    value,
+		Path:     "/",
+		MaxAge:   int(SessionTTL.Seconds()),
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+	return nil
+}
+
+// ClearSession signs the browser out
+func (s *Service) ClearSession(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{Name: SessionCookie, Value: "", Path: "/", MaxAge: -1, HttpOnly: true})
+}
+
+func (s *Service) isAdmin(id string) bool {
+	return slices.Contains(s.admins, id)
+}
+
+// userRoles adds the roles AUTH_REVIEWERS and AUTH_APPROVERS give id to roles
+func (s *Service) userRoles(id string, roles []string) []string {
+	var all []string
+	for _, role := range Roles {
+		if slices.Contains(roles, role) || slices.Contains(s.roles[role], id) {
+			all = append(all, role)
+		}
+	}
+	return all
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// signed is the payload of a cookie; Data is the signed value and Expires its Unix expiry
+type signed struct {
+	Data    json.RawMessage `json:"d"`
+	Expires int64           `json:"e"`
+}
+
+// sign encodes v with an expiry and an HMAC-SHA256 of both
+func (s *Service) sign(v any, ttl time.Duration) (string, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode cookie: %w", err)
+	}
+	payload, err := json.Marshal(signed{Data: data, Expires: time.Now().Add(ttl).Unix()})
+	if err != nil {
+		return "", fmt.Errorf("failed to encode cookie: %w", err)
+	}
+
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(s.mac(encoded)), nil
+}
+
+// verify checks the signature and expiry of a value made by sign and decodes it into v
+func (s *Service) verify(value string, v any) error {
+	encoded, signature, found := strings.Cut(value, ".")
+	if !found {
+		return ErrUnauthenticated
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || !hmac.Equal(mac, s.mac(encoded)) {
+		return ErrUnauthenticated
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return ErrUnauthenticated
+	}
+	var contents signed
+	if err := json.Unmarshal(payload, &contents); err != nil || time.Now().Unix() > contents.Expires {
+		return ErrUnauthenticated
+	}
+	if err := json.Unmarshal(contents.Data, v); err != nil {
+		return ErrUnauthenticated
+	}
+	return nil
+}
+
+func (s *Service) mac(value string) []byte {
+	h := hmac.New(sha256.New, s.secret)
+	h.Write([]byte(value))
+	return h.Sum(nil)
+}
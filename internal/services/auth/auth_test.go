@@ -0,0 +1,339 @@
+package auth_test
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/lehigh-university-libraries/hocr-edit/internal/services/auth"
+)
+
+const testSecret = "0123456789abcdef0123456789abcdef"
+
+func writeUsers(t *testing.T) string {
+	t.Helper()
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token := sha256.Sum256([]byte("api-token"))
+	users := []map[string]any{
+		{"username": "student", "password_hash": string(hash), "roles": []string{"reviewer"}, "tokens": []string{hex.EncodeToString(token[:])}},
+		{"username": "supervisor", "name": "Super Visor", "password_hash": string(hash), "admin": true},
+	}
+	data, _ := json.Marshal(users)
+	path := filepath.Join(t.TempDir(), "users.json")
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func newLocalService(t *testing.T) *auth.Service {
+	t.Helper()
+	t.Setenv("AUTH_PROVIDERS", "local,token")
+	t.Setenv("AUTH_USERS_FILE", writeUsers(t))
+	t.Setenv("AUTH_SECRET", testSecret)
+	t.Setenv("AUTH_APPROVERS", "supervisor")
+	service, err := auth.New()
+	if err != nil {
+		t.Fatalf("Error configuring auth: %v", err)
+	}
+	return service
+}
+
+func TestLocalUsersAndTokens(t *testing.T) {
+	service := newLocalService(t)
+
+	if _, err := service.Login("student", "wrong"); err == nil {
+		t.Error("Expected a wrong password to fail")
+	}
+	if _, err := service.Login("nobody", "secret"); err == nil {
+		t.Error("Expected an unknown user to fail")
+	}
+
+	user, err := service.Login("supervisor", "secret")
+	if err != nil {
+		t.Fatalf("Error logging in: %v", err)
+	}
+	if user.ID != "supervisor" || user.Name != "Super Visor" || !user.Admin || !slices.Contains(user.Roles, auth.RoleApprover) {
+		t.Errorf("Unexpected user %+v", user)
+	}
+
+	rec := httptest.NewRecorder()
+	if err := service.SetSession(rec, httptest.NewRequest("POST", "/api/auth/login", nil), user); err != nil {
+		t.Fatalf("Error setting session: %v", err)
+	}
+	req := httptest.NewRequest("GET", "/api/sessions", nil)
+	for _, cookie := range rec.Result().Cookies() {
+		req.AddCookie(cookie)
+	}
+	if got, err := service.Authenticate(req); err != nil || got.ID != "supervisor" {
+		t.Errorf("Expected the cookie to sign the supervisor in, got %+v, %v", got, err)
+	}
+
+	req = httptest.NewRequest("GET", "/api/sessions", nil)
+	req.AddCookie(&http.Cookie{Name: auth.SessionCookie, Value: strings.Replace(rec.Result().Cookies()[0].Value, "e", "f", 1)})
+	if _, err := service.Authenticate(req); err == nil {
+		t.Error("Expected a tampered cookie to be rejected")
+	}
+
+	req = httptest.NewRequest("GET", "/api/sessions", nil)
+	req.Header.Set("Authorization", "Bearer api-token")
+	got, err := service.Authenticate(req)
+	if err != nil || got.ID != "student" || got.Admin {
+		t.Fatalf("Expected the token to authenticate the student, got %+v, %v", got, err)
+	}
+	if !got.HasRole(auth.RoleReviewer) || got.HasRole(auth.RoleApprover) {
+		t.Errorf("Expected the student to be a reviewer only, got %v", got.Roles)
+	}
+
+	req = httptest.NewRequest("GET", "/api/sessions", nil)
+	req.SetBasicAuth("student", "secret")
+	if got, err := service.Authenticate(req); err != nil || got.ID != "student" {
+		t.Errorf("Expected basic auth to authenticate the student, got %+v, %v", got, err)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	service := newLocalService(t)
+
+	var seen *auth.User
+	handler := service.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = auth.UserFromContext(r.Context())
+	}))
+
+	tests := []struct {
+		path   string
+		token  string
+		status int
+	}{
+		{"/api/sessions", "", http.StatusUnauthorized},
+		{"/api/sessions", "wrong", http.StatusUnauthorized},
+		{"/api/sessions", "api-token", http.StatusOK},
+		{"/static/uploads/page.jpg", "", http.StatusFound},
+		{"/?image=https://example.com/page.jpg", "", http.StatusFound},
+		{"/?nid=42", "", http.StatusFound},
+		{"/?collection=7", "", http.StatusFound},
+		{"/api/auth/me", "", http.StatusOK},
+		{"/healthcheck", "", http.StatusOK},
+		{"/script.js", "", http.StatusOK},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", tt.path, nil)
+		if tt.token != "" {
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != tt.status {
+			t.Errorf("%s: expected %d, got %d", tt.path, tt.status, rec.Code)
+		}
+	}
+
+	req := httptest.NewRequest("GET", "/api/sessions", nil)
+	req.Header.Set("Authorization", "Bearer api-token")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	if seen == nil || seen.ID != "student" {
+		t.Errorf("Expected the handler to see the student, got %+v", seen)
+	}
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/script.js", nil))
+	if seen != auth.Nobody || seen.Admin || seen.CanAccess("") {
+		t.Errorf("Expected an open request to carry Nobody, got %+v", seen)
+	}
+}
+
+func TestDisabled(t *testing.T) {
+	t.Setenv("AUTH_PROVIDERS", "")
+	service, err := auth.New()
+	if err != nil {
+		t.Fatalf("Error configuring auth: %v", err)
+	}
+
+	user, err := service.Authenticate(httptest.NewRequest("GET", "/api/sessions", nil))
+	if err != nil || !user.Anonymous() || !user.Admin {
+		t.Errorf("Expected every request to be the anonymous admin, got %+v, %v", user, err)
+	}
+
+	var seen *auth.User
+	handler := service.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = auth.UserFromContext(r.Context())
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/script.js", nil))
+	if !seen.Anonymous() {
+		t.Errorf("Expected the middleware to pass on the anonymous admin, got %+v", seen)
+	}
+
+	t.Setenv("AUTH_PROVIDERS", "local")
+	t.Setenv("AUTH_SECRET", "short")
+	if _, err := auth.New(); err == nil {
+		t.Error("Expected a short AUTH_SECRET to be refused")
+	}
+}
+
+// mockIdP is just enough of an OpenID provider for the authorization code flow
+type mockIdP struct {
+	*httptest.Server
+	key    *rsa.PrivateKey
+	nonces map[string]string
+	groups []string
+}
+
+func newMockIdP(t *testing.T) *mockIdP {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idp := &mockIdP{key: key, nonces: map[string]string{}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 idp.URL,
+			"authorization_endpoint": idp.URL + "/authorize",
+			"token_endpoint":         idp.URL + "/token",
+			"jwks_uri":               idp.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": "test",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/authorize", func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		idp.nonces["code-1"] = query.Get("nonce")
+		redirect, _ := url.Parse(query.Get("redirect_uri"))
+		redirect.RawQuery = url.Values{"code": {"code-1"}, "state": {query.Get("state")}}.Encode()
+		http.Redirect(w, r, redirect.String(), http.StatusFound)
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		claims := map[string]any{
+			"iss":                idp.URL,
+			"sub":                "user-123",
+			"aud":                "hocr-edit",
+			"exp":                time.Now().Add(time.Hour).Unix(),
+			"nonce":              idp.nonces[r.Form.Get("code")],
+			"preferred_username": "jdoe",
+			"name":               "Jo Doe",
+			"groups":             idp.groups,
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"access_token": "access",
+			"token_type":   "Bearer",
+			"id_token":     idp.sign(t, claims),
+		})
+	})
+	idp.Server = httptest.NewServer(mux)
+	t.Cleanup(idp.Close)
+	return idp
+}
+
+func (idp *mockIdP) sign(t *testing.T, claims map[string]any) string {
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "kid": "test", "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signingInput))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, idp.key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+// oidcLogin runs a browser through the login and returns the callback's result
+func oidcLogin(t *testing.T, service *auth.Service) (*auth.User, string, error) {
+	t.Helper()
+	start := httptest.NewRecorder()
+	loginURL, err := service.OIDCLoginURL(start, httptest.NewRequest("GET", "/api/auth/oidc/login", nil), "/?session=s1")
+	if err != nil {
+		t.Fatalf("Error starting login: %v", err)
+	}
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err := client.Get(loginURL)
+	if err != nil {
+		t.Fatalf("Error visiting the IdP: %v", err)
+	}
+	resp.Body.Close()
+
+	callback := httptest.NewRequest("GET", resp.Header.Get("Location"), nil)
+	for _, cookie := range start.Result().Cookies() {
+		callback.AddCookie(cookie)
+	}
+	return service.OIDCCallback(httptest.NewRecorder(), callback)
+}
+
+func TestOIDCLogin(t *testing.T) {
+	idp := newMockIdP(t)
+	idp.groups = []string{"staff", "hocr-admins"}
+
+	t.Setenv("AUTH_PROVIDERS", "oidc")
+	t.Setenv("AUTH_SECRET", testSecret)
+	t.Setenv("OIDC_ISSUER", idp.URL)
+	t.Setenv("OIDC_CLIENT_ID", "hocr-edit")
+	t.Setenv("OIDC_CLIENT_SECRET", "client-secret")
+	t.Setenv("OIDC_REDIRECT_URL", "http://hocr.example.edu/api/auth/oidc/callback")
+	t.Setenv("OIDC_ADMIN_GROUP", "hocr-admins")
+	service, err := auth.New()
+	if err != nil {
+		t.Fatalf("Error configuring auth: %v", err)
+	}
+
+	user, next, err := oidcLogin(t, service)
+	if err != nil {
+		t.Fatalf("Error completing login: %v", err)
+	}
+	if user.ID != "jdoe" || user.Name != "Jo Doe" || !user.Admin || user.Provider != auth.ProviderOIDC {
+		t.Errorf("Unexpected user %+v", user)
+	}
+	if next != "/?session=s1" {
+		t.Errorf("Expected to return to the session, got %s", next)
+	}
+
+	callback := httptest.NewRequest("GET", "/api/auth/oidc/callback?code=code-1&state=forged", nil)
+	if _, _, err := service.OIDCCallback(httptest.NewRecorder(), callback); err == nil {
+		t.Error("Expected a callback without the login cookie to fail")
+	}
+}
+
+func TestOIDCRejectsOtherClients(t *testing.T) {
+	idp := newMockIdP(t)
+
+	t.Setenv("AUTH_PROVIDERS", "oidc")
+	t.Setenv("AUTH_SECRET", testSecret)
+	t.Setenv("OIDC_ISSUER", idp.URL)
+	t.Setenv("OIDC_CLIENT_ID", "another-app")
+	t.Setenv("OIDC_REDIRECT_URL", "http://hocr.example.edu/api/auth/oidc/callback")
+	service, err := auth.New()
+	if err != nil {
+		t.Fatalf("Error configuring auth: %v", err)
+	}
+
+	if _, _, err := oidcLogin(t, service); err == nil || !strings.Contains(err.Error(), "not for this client") {
+		t.Errorf("Expected an ID token for another client to be rejected, got %v", err)
+	}
+}
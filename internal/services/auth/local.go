@@ -0,0 +1,110 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// localUser is an entry of AUTH_USERS_FILE. PasswordHash is a bcrypt hash, such as
+// htpasswd -nbB makes, Roles are any of reviewer and approver, and Tokens are the hex
+// SHA-256 hashes of the user's API tokens.
+type localUser struct {
+	Username     string   `json:"username"`
+	Name         string   `json:"name"`
+	PasswordHash string   `json:"password_hash"`
+	Admin        bool     `json:"admin"`
+	Roles        []string `json:"roles"`
+	Tokens       []string `json:"tokens"`
+}
+
+var dummyHash = sync.OnceValue(func() []byte {
+	hash, _ := bcrypt.GenerateFromPassword([]byte("not a password"), bcrypt.DefaultCost)
+	return hash
+})
+
+func (s *Service) loadUsers(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read AUTH_USERS_FILE: %w", err)
+	}
+
+	var users []localUser
+	if err := json.Unmarshal(data, &users); err != nil {
+		return fmt.Errorf("failed to parse AUTH_USERS_FILE: %w", err)
+	}
+
+	for _, user := range users {
+		if user.Username == "" {
+			return fmt.Errorf("AUTH_USERS_FILE has a user without a username")
+		}
+		if _, exists := s.users[user.Username]; exists {
+			return fmt.Errorf("AUTH_USERS_FILE lists %s more than once", user.Username)
+		}
+		for _, role := range user.Roles {
+			if !slices.Contains(Roles, role) {
+				return fmt.Errorf("AUTH_USERS_FILE gives %s the unknown role %q", user.Username, role)
+			}
+		}
+		s.users[user.Username] = user
+
+		for _, token := range user.Tokens {
+			token = strings.ToLower(token)
+			if len(token) != sha256.Size*2 {
+				return fmt.Errorf("token of %s in AUTH_USERS_FILE is not a SHA-256 hash", user.Username)
+			}
+			s.tokens[token] = user.Username
+		}
+	}
+
+	return nil
+}
+
+// Login checks the password of a local user
+func (s *Service) Login(username, password string) (*User, error) {
+	if !s.Enabled(ProviderLocal) {
+		return nil, ErrUnauthenticated
+	}
+
+	user, exists := s.users[username]
+	if !exists || user.PasswordHash == "" {
+		// compare anyway so unknown users take as long as wrong passwords
+		_ = bcrypt.CompareHashAndPassword(dummyHash(), []byte(password))
+		return nil, ErrUnauthenticated
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)); err != nil {
+		return nil, ErrUnauthenticated
+	}
+
+	return s.localUser(user, ProviderLocal), nil
+}
+
+func (s *Service) tokenUser(token string) (*User, error) {
+	sum := sha256.Sum256([]byte(token))
+	username, exists := s.tokens[hex.EncodeToString(sum[:])]
+	if !exists {
+		return nil, ErrUnauthenticated
+	}
+	return s.localUser(s.users[username], ProviderToken), nil
+}
+
+func (s *Service) localUser(user localUser, provider string) *User {
+	name := user.Name
+	if name == "" {
+		name = user.Username
+	}
+	return &User{
+		ID:       user.Username,
+		Name:     name,
+		Admin:    user.Admin || s.isAdmin(user.Username),
+		Roles:    s.userRoles(user.Username, user.Roles),
+		Provider: provider,
+	}
+}
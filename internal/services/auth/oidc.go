@@ -0,0 +1,384 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+const (
+	// oidcCookie carries the state, nonce and return path of a login through the IdP
+	oidcCookie = "hocr_oidc"
+	oidcTTL    = 10 * time.Minute
+	// clockSkew is how far the IdP's clock may be off when checking token expiry
+	clockSkew = time.Minute
+)
+
+// oidcProvider signs users in with the authorization code flow. The issuer's
+// configuration is discovered on first use, so the IdP does not have to be up
+// for the editor to start.
+type oidcProvider struct {
+	issuer      string
+	adminGroup  string
+	roleGroups  map[string]string
+	groupsClaim string
+	config      oauth2.Config
+	client      *http.Client
+
+	mu         sync.Mutex
+	discovered bool
+	jwksURI    string
+	keys       map[string]*rsa.PublicKey
+}
+
+// newOIDCProvider reads OIDC_ISSUER, OIDC_CLIENT_ID, OIDC_CLIENT_SECRET and
+// OIDC_REDIRECT_URL, which should point at /api/auth/oidc/callback. OIDC_SCOPES
+// overrides the requested scopes and members of OIDC_ADMIN_GROUP, found in the
+// OIDC_GROUPS_CLAIM claim of the ID token, are admins. Members of
+// OIDC_REVIEWER_GROUP and OIDC_APPROVER_GROUP get those roles.
+func newOIDCProvider() (*oidcProvider, error) {
+	p := &oidcProvider{
+		issuer:     strings.TrimSuffix(os.Getenv("OIDC_ISSUER"), "/"),
+		adminGroup: os.Getenv("OIDC_ADMIN_GROUP"),
+		roleGroups: map[string]string{
+			RoleReviewer: os.Getenv("OIDC_REVIEWER_GROUP"),
+			RoleApprover: os.Getenv("OIDC_APPROVER_GROUP"),
+		},
+		groupsClaim: os.Getenv("OIDC_GROUPS_CLAIM"),
+		client:      &http.Client{Timeout: 10 * time.Second},
+		config: oauth2.Config{
+			ClientID:     os.Getenv("OIDC_CLIENT_ID"),
+			ClientSecret: os.Getenv("OIDC_CLIENT_SECRET"),
+			RedirectURL:  os.Getenv("OIDC_REDIRECT_URL"),
+			Scopes:       strings.Fields(os.Getenv("OIDC_SCOPES")),
+		},
+	}
+	if p.groupsClaim == "" {
+		p.groupsClaim = "groups"
+	}
+	if len(p.config.Scopes) == 0 {
+		p.config.Scopes = []string{"openid", "profile", "email"}
+	}
+
+	switch {
+	case p.issuer == "":
+		return nil, fmt.Errorf("OIDC_ISSUER is required for oidc auth")
+	case p.config.ClientID == "":
+		return nil, fmt.Errorf("OIDC_CLIENT_ID is required for oidc auth")
+	case p.config.RedirectURL == "":
+		return nil, fmt.Errorf("OIDC_REDIRECT_URL is required for oidc auth")
+	}
+
+	return p, nil
+}
+
+// discover loads the endpoints of the issuer from its openid-configuration
+func (p *oidcProvider) discover(ctx context.Context) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.discovered {
+		return nil
+	}
+
+	var configuration struct {
+		Issuer                string `json:"issuer"`
+		AuthorizationEndpoint string `json:"authorization_endpoint"`
+		TokenEndpoint         string `json:"token_endpoint"`
+		JWKSURI               string `json:"jwks_uri"`
+	}
+	if err := p.getJSON(ctx, p.issuer+"/.well-known/openid-configuration", &configuration); err != nil {
+		return fmt.Errorf("failed to discover OIDC issuer: %w", err)
+	}
+	if strings.TrimSuffix(configuration.Issuer, "/") != p.issuer {
+		return fmt.Errorf("OIDC issuer %s calls itself %s", p.issuer, configuration.Issuer)
+	}
+
+	p.config.Endpoint = oauth2.Endpoint{
+		AuthURL:  configuration.AuthorizationEndpoint,
+		TokenURL: configuration.TokenEndpoint,
+	}
+	p.jwksURI = configuration.JWKSURI
+	p.discovered = true
+	return nil
+}
+
+// key returns the issuer's signing key with the given ID, fetching the key set
+// again when the ID is new, as it is after the IdP rotates its keys
+func (p *oidcProvider) key(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if key, ok := p.keys[kid]; ok {
+		return key, nil
+	}
+
+	var set struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	if err := p.getJSON(ctx, p.jwksURI, &set); err != nil {
+		return nil, fmt.Errorf("failed to fetch OIDC signing keys: %w", err)
+	}
+
+	keys := make(map[string]*rsa.PublicKey, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if jwk.Kty != "RSA" {
+			continue
+		}
+		n, errN := base64.RawURLEncoding.DecodeString(jwk.N)
+		e, errE := base64.RawURLEncoding.DecodeString(jwk.E)
+		if errN != nil || errE != nil {
+			continue
+		}
+		keys[jwk.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+	}
+	p.keys = keys
+
+	key, ok := p.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("OIDC issuer has no key %q", kid)
+	}
+	return key, nil
+}
+
+// idTokenClaims are the claims of an ID token that identify the user
+type idTokenClaims struct {
+	Issuer            string   `json:"iss"`
+	Subject           string   `json:"sub"`
+	Audience          audience `json:"aud"`
+	Expiry            int64    `json:"exp"`
+	Nonce             string   `json:"nonce"`
+	Name              string   `json:"name"`
+	PreferredUsername string   `json:"preferred_username"`
+	Email             string   `json:"email"`
+	// raw is the whole payload, for claims such as groups whose name is configured
+	raw []byte
+}
+
+// audience is the aud claim, which may be a single string or a list
+type audience []string
+
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = audience{single}
+		return nil
+	}
+	return json.Unmarshal(data, (*[]string)(a))
+}
+
+// verifyIDToken checks the RS256 signature, issuer, audience, expiry and nonce of an
+// ID token and returns its claims
+func (p *oidcProvider) verifyIDToken(ctx context.Context, raw, nonce string) (idTokenClaims, error) {
+	parts := strings.Split(raw, ".")
+	if len(parts) != 3 {
+		return idTokenClaims{}, fmt.Errorf("malformed ID token")
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return idTokenClaims{}, fmt.Errorf("malformed ID token header: %w", err)
+	}
+	if header.Alg != "RS256" {
+		return idTokenClaims{}, fmt.Errorf("unsupported ID token algorithm %q", header.Alg)
+	}
+
+	key, err := p.key(ctx, header.Kid)
+	if err != nil {
+		return idTokenClaims{}, err
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return idTokenClaims{}, fmt.Errorf("malformed ID token signature: %w", err)
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature); err != nil {
+		return idTokenClaims{}, fmt.Errorf("invalid ID token signature: %w", err)
+	}
+
+	var claims idTokenClaims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return idTokenClaims{}, fmt.Errorf("malformed ID token claims: %w", err)
+	}
+	claims.raw, _ = base64.RawURLEncoding.DecodeString(parts[1])
+
+	switch {
+	case strings.TrimSuffix(claims.Issuer, "/") != p.issuer:
+		return idTokenClaims{}, fmt.Errorf("ID token is from %s, not %s", claims.Issuer, p.issuer)
+	case !slices.Contains(claims.Audience, p.config.ClientID):
+		return idTokenClaims{}, fmt.Errorf("ID token is not for this client")
+	case time.Now().Add(-clockSkew).Unix() > claims.Expiry:
+		return idTokenClaims{}, fmt.Errorf("ID token has expired")
+	case claims.Nonce != nonce:
+		return idTokenClaims{}, fmt.Errorf("ID token nonce does not match")
+	case claims.Subject == "":
+		return idTokenClaims{}, fmt.Errorf("ID token has no subject")
+	}
+
+	return claims, nil
+}
+
+// groups reads the configured groups claim, which is missing for IdPs that do not send one
+func (p *oidcProvider) groups(claims idTokenClaims) []string {
+	var all map[string]json.RawMessage
+	if err := json.Unmarshal(claims.raw, &all); err != nil {
+		return nil
+	}
+	var groups []string
+	if err := json.Unmarshal(all[p.groupsClaim], &groups); err != nil {
+		return nil
+	}
+	return groups
+}
+
+func (p *oidcProvider) getJSON(ctx context.Context, url string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned HTTP %d", url, resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+func decodeSegment(segment string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// oidcLogin is what the oidc cookie remembers between leaving for the IdP and coming back
+type oidcLogin struct {
+	State string `json:"state"`
+	Nonce string `json:"nonce"`
+	Next  string `json:"next"`
+}
+
+// OIDCLoginURL starts a login through the IdP, returning the URL to send the browser
+// to. next is where the browser goes once the user is signed in.
+func (s *Service) OIDCLoginURL(w http.ResponseWriter, r *http.Request, next string) (string, error) {
+	if s.oidc == nil {
+		return "", fmt.Errorf("OIDC is not configured")
+	}
+	if err := s.oidc.discover(r.Context()); err != nil {
+		return "", err
+	}
+
+	login := oidcLogin{State: randomString(), Nonce: randomString(), Next: next}
+	value, err := s.sign(login, oidcTTL)
+	if err != nil {
+		return "", err
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcCookie,
+		Value:    value,
+		Path:     "/api/auth/oidc/",
+		MaxAge:   int(oidcTTL.Seconds()),
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	return s.oidc.config.AuthCodeURL(login.State, oauth2.SetAuthURLParam("nonce", login.Nonce)), nil
+}
+
+// OIDCCallback completes a login through the IdP, returning the user and where the
+// login asked to go next
+func (s *Service) OIDCCallback(w http.ResponseWriter, r *http.Request) (*User, string, error) {
+	if s.oidc == nil {
+		return nil, "", fmt.Errorf("OIDC is not configured")
+	}
+
+	cookie, err := r.Cookie(oidcCookie)
+	if err != nil {
+		return nil, "", fmt.Errorf("login expired, please sign in again")
+	}
+	http.SetCookie(w, &http.Cookie{Name: oidcCookie, Value: "", Path: "/api/auth/oidc/", MaxAge: -1, HttpOnly: true})
+
+	var login oidcLogin
+	if err := s.verify(cookie.Value, &login); err != nil {
+		return nil, "", fmt.Errorf("login expired, please sign in again")
+	}
+	if r.URL.Query().Get("state") != login.State {
+		return nil, "", fmt.Errorf("login state does not match")
+	}
+	if idpError := r.URL.Query().Get("error"); idpError != "" {
+		return nil, "", fmt.Errorf("identity provider refused the login: %s", idpError)
+	}
+	if err := s.oidc.discover(r.Context()); err != nil {
+		return nil, "", err
+	}
+
+	ctx := context.WithValue(r.Context(), oauth2.HTTPClient, s.oidc.client)
+	token, err := s.oidc.config.Exchange(ctx, r.URL.Query().Get("code"))
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to exchange authorization code: %w", err)
+	}
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return nil, "", fmt.Errorf("identity provider did not return an ID token")
+	}
+
+	claims, err := s.oidc.verifyIDToken(r.Context(), rawIDToken, login.Nonce)
+	if err != nil {
+		return nil, "", err
+	}
+
+	user := &User{ID: claims.PreferredUsername, Name: claims.Name, Provider: ProviderOIDC}
+	if user.ID == "" {
+		user.ID = claims.Email
+	}
+	if user.ID == "" {
+		user.ID = claims.Subject
+	}
+	if user.Name == "" {
+		user.Name = user.ID
+	}
+	groups := s.oidc.groups(claims)
+	user.Admin = s.isAdmin(user.ID) || (s.oidc.adminGroup != "" && slices.Contains(groups, s.oidc.adminGroup))
+
+	var roles []string
+	for role, group := range s.oidc.roleGroups {
+		if group != "" && slices.Contains(groups, group) {
+			roles = append(roles, role)
+		}
+	}
+	user.Roles = s.userRoles(user.ID, roles)
+
+	return user, login.Next, nil
+}
+
+func randomString() string {
+	b := make([]byte, 24)
+	_, _ = rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
@@ -0,0 +1,224 @@
+package drupal
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/lehigh-university-libraries/hocr-edit/internal/models"
+)
+
+const (
+	AuthNone   = "none"
+	AuthBasic  = "basic"
+	AuthJWT    = "jwt"
+	AuthCookie = "cookie"
+
+	// DefaultDestination is where the browser used to ask Drupal to write the file
+	DefaultDestination = "private://derivatives/hocr/gcloud/{{.Nid}}.hocr"
+)
+
+// ErrUntrustedURL is returned for upload URLs that are not on the Drupal site the
+// editor is configured to use, as the publisher sends its credentials with the upload
+var ErrUntrustedURL = errors.New("upload URL is not on the configured Drupal site")
+
+// Destination is the data available to the DRUPAL_HOCR_DESTINATION template
+type Destination struct {
+	Nid       string
+	SessionID string
+	ImageID   string
+}
+
+// Publisher uploads corrected hOCR to the Drupal media file endpoint of a node
+type Publisher struct {
+	client      *http.Client
+	site        *url.URL
+	auth        string
+	username    string
+	password    string
+	token       string
+	cookie      string
+	destination *template.Template
+	attempts    int
+	backoff     time.Duration
+}
+
+// NewPublisher reads its configuration from the environment:
+// DRUPAL_AUTH picks none, basic (DRUPAL_USERNAME/DRUPAL_PASSWORD), jwt (DRUPAL_JWT)
+// or cookie (DRUPAL_COOKIE); DRUPAL_HOCR_DESTINATION is a text/template for the
+// Content-Location URI; DRUPAL_PUBLISH_ATTEMPTS caps the number of tries. Uploads
+// only go to the scheme and host of DRUPAL_HOCR_URL.
+func NewPublisher() (*Publisher, error) {
+	auth := os.Getenv("DRUPAL_AUTH")
+	if auth == "" {
+		auth = AuthNone
+	}
+
+	p := &Publisher{
+		client: &http.Client{
+			Timeout: 60 * time.Second,
+			// a redirect could take the credentials somewhere else
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		auth:     auth,
+		username: os.Getenv("DRUPAL_USERNAME"),
+		password: os.Getenv("DRUPAL_PASSWORD"),
+		token:    os.Getenv("DRUPAL_JWT"),
+		cookie:   os.Getenv("DRUPAL_COOKIE"),
+		attempts: 3,
+		backoff:  time.Second,
+	}
+
+	switch auth {
+	case AuthNone:
+	case AuthBasic:
+		if p.username == "" {
+			return nil, fmt.Errorf("DRUPAL_USERNAME is required for basic auth")
+		}
+	case AuthJWT:
+		if p.token == "" {
+			return nil, fmt.Errorf("DRUPAL_JWT is required for jwt auth")
+		}
+	case AuthCookie:
+		if p.cookie == "" {
+			return nil, fmt.Errorf("DRUPAL_COOKIE is required for cookie auth")
+		}
+	default:
+		return nil, fmt.Errorf("unknown DRUPAL_AUTH %q", auth)
+	}
+
+	if attempts := os.Getenv("DRUPAL_PUBLISH_ATTEMPTS"); attempts != "" {
+		n, err := strconv.Atoi(attempts)
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("invalid DRUPAL_PUBLISH_ATTEMPTS %q", attempts)
+		}
+		p.attempts = n
+	}
+
+	if hocrURL := os.Getenv("DRUPAL_HOCR_URL"); hocrURL != "" {
+		// the URL is a template with a %s for the node ID
+		site, err := url.Parse(strings.ReplaceAll(hocrURL, "%s", "0"))
+		if err != nil || site.Host == "" {
+			return nil, fmt.Errorf("invalid DRUPAL_HOCR_URL %q", hocrURL)
+		}
+		p.site = site
+	}
+
+	destination := os.Getenv("DRUPAL_HOCR_DESTINATION")
+	if destination == "" {
+		destination = DefaultDestination
+	}
+	tmpl, err := template.New("destination").Option("missingkey=error").Parse(destination)
+	if err != nil {
+		return nil, fmt.Errorf("invalid DRUPAL_HOCR_DESTINATION: %w", err)
+	}
+	p.destination = tmpl
+
+	return p, nil
+}
+
+// Publish POSTs hocrXML to uploadURL, retrying network errors and 5xx/429 responses
+// with exponential backoff. The returned result is filled in even when publishing fails.
+func (p *Publisher) Publish(uploadURL string, dest Destination, hocrXML string) (models.PublishResult, error) {
+	result := models.PublishResult{PublishedAt: time.Now()}
+
+	var location bytes.Buffer
+	if err := p.destination.Execute(&location, dest); err != nil {
+		result.Error = err.Error()
+		return result, fmt.Errorf("failed to build destination URI: %w", err)
+	}
+	result.Destination = location.String()
+
+	if err := p.checkUploadURL(uploadURL); err != nil {
+		result.Error = err.Error()
+		return result, err
+	}
+
+	delay := p.backoff
+	var lastErr error
+	for attempt := 1; attempt <= p.attempts; attempt++ {
+		result.Attempts = attempt
+
+		statusCode, retry, err := p.post(uploadURL, result.Destination, hocrXML)
+		result.StatusCode = statusCode
+		if err == nil {
+			result.Success = true
+			result.Error = ""
+			result.PublishedAt = time.Now()
+			slog.Info("Published hOCR to Drupal", "nid", dest.Nid, "destination", result.Destination, "attempts", attempt)
+			return result, nil
+		}
+
+		lastErr = err
+		result.Error = err.Error()
+		if !retry || attempt == p.attempts {
+			break
+		}
+
+		slog.Warn("Drupal publish failed, retrying", "nid", dest.Nid, "attempt", attempt, "err", err)
+		time.Sleep(delay)
+		delay *= 2
+	}
+
+	result.PublishedAt = time.Now()
+	return result, lastErr
+}
+
+// checkUploadURL refuses upload URLs whose scheme or host differ from DRUPAL_HOCR_URL
+func (p *Publisher) checkUploadURL(uploadURL string) error {
+	if p.site == nil {
+		return fmt.Errorf("%w: DRUPAL_HOCR_URL is not set", ErrUntrustedURL)
+	}
+	u, err := url.Parse(uploadURL)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrUntrustedURL, err)
+	}
+	if !strings.EqualFold(u.Scheme, p.site.Scheme) || !strings.EqualFold(u.Host, p.site.Host) || u.User != nil {
+		return fmt.Errorf("%w: %s", ErrUntrustedURL, u.Redacted())
+	}
+	return nil
+}
+
+// post makes a single upload attempt and reports whether a failure is worth retrying
+func (p *Publisher) post(uploadURL, location, hocrXML string) (int, bool, error) {
+	req, err := http.NewRequest("POST", uploadURL, strings.NewReader(hocrXML))
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to create Drupal request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "text/vnd.hocr+html")
+	req.Header.Set("Content-Location", location)
+	switch p.auth {
+	case AuthBasic:
+		req.SetBasicAuth(p.username, p.password)
+	case AuthJWT:
+		req.Header.Set("Authorization", "Bearer "+p.token)
+	case AuthCookie:
+		req.Header.Set("Cookie", p.cookie)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return 0, true, fmt.Errorf("drupal request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp.StatusCode, false, nil
+	}
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	retry := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+	return resp.StatusCode, retry, fmt.Errorf("drupal returned HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+}
@@ -0,0 +1,104 @@
+package drupal
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPublishRetriesWithAuth(t *testing.T) {
+	t.Setenv("DRUPAL_AUTH", AuthJWT)
+	t.Setenv("DRUPAL_JWT", "secret")
+	t.Setenv("DRUPAL_HOCR_DESTINATION", "private://hocr/{{.SessionID}}/{{.Nid}}.hocr")
+
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			t.Errorf("Expected bearer token, got %q", r.Header.Get("Authorization"))
+		}
+		if r.Header.Get("Content-Location") != "private://hocr/s1/42.hocr" {
+			t.Errorf("Unexpected Content-Location %q", r.Header.Get("Content-Location"))
+		}
+		if requests == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+	t.Setenv("DRUPAL_HOCR_URL", server.URL+"/node/%s/hocr")
+
+	publisher, err := NewPublisher()
+	if err != nil {
+		t.Fatalf("Error creating publisher: %v", err)
+	}
+	publisher.backoff = 0
+
+	result, err := publisher.Publish(server.URL, Destination{Nid: "42", SessionID: "s1", ImageID: "img_1"}, "<html/>")
+	if err != nil {
+		t.Fatalf("Error publishing: %v", err)
+	}
+	if !result.Success || result.Attempts != 2 || result.StatusCode != http.StatusCreated {
+		t.Errorf("Expected success on the second attempt, got %+v", result)
+	}
+}
+
+func TestPublishDoesNotRetryClientErrors(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer server.Close()
+	t.Setenv("DRUPAL_HOCR_URL", server.URL+"/node/%s/hocr")
+
+	publisher, err := NewPublisher()
+	if err != nil {
+		t.Fatalf("Error creating publisher: %v", err)
+	}
+	publisher.backoff = 0
+
+	result, err := publisher.Publish(server.URL, Destination{Nid: "42"}, "<html/>")
+	if err == nil {
+		t.Fatal("Expected an error for a 403 response")
+	}
+	if requests != 1 || result.Success || result.StatusCode != http.StatusForbidden {
+		t.Errorf("Expected a single failed attempt, got %d requests and %+v", requests, result)
+	}
+	if result.Destination != "private://derivatives/hocr/gcloud/42.hocr" {
+		t.Errorf("Expected default destination, got %q", result.Destination)
+	}
+}
+
+func TestPublishRefusesOtherHosts(t *testing.T) {
+	t.Setenv("DRUPAL_AUTH", AuthBasic)
+	t.Setenv("DRUPAL_USERNAME", "editor")
+	t.Setenv("DRUPAL_HOCR_URL", "https://drupal.example.edu/node/%s/hocr")
+
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+	}))
+	defer server.Close()
+
+	publisher, err := NewPublisher()
+	if err != nil {
+		t.Fatalf("Error creating publisher: %v", err)
+	}
+
+	for _, uploadURL := range []string{
+		server.URL + "/node/42/media/file/7",
+		"http://drupal.example.edu/node/42/media/file/7",
+		"https://drupal.example.edu@169.254.169.254/latest",
+	} {
+		result, err := publisher.Publish(uploadURL, Destination{Nid: "42"}, "<html/>")
+		if !errors.Is(err, ErrUntrustedURL) || result.Success {
+			t.Errorf("Expected %s to be refused, got %v", uploadURL, err)
+		}
+	}
+	if requests != 0 {
+		t.Errorf("Expected no requests to leave, got %d", requests)
+	}
+}
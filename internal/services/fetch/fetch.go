@@ -0,0 +1,336 @@
+package fetch
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultMaxBytes     = 100 << 20
+	defaultTimeout      = 60 * time.Second
+	defaultMaxRedirects = 5
+)
+
+var (
+	// ErrBlocked is returned for URLs the allow and deny lists or the private
+	// address block do not let the fetcher reach
+	ErrBlocked = errors.New("fetching this URL is not allowed")
+	// ErrTooLarge is returned when a response is larger than FETCH_MAX_BYTES
+	ErrTooLarge = errors.New("response is too large")
+	// ErrContentType is returned when a response is not of a type the caller accepts
+	ErrContentType = errors.New("unexpected content type")
+)
+
+// reservedPrefixes are ranges that are not on the public internet and that
+// netip.Addr has no method for
+var reservedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("64:ff9b::/96"),
+}
+
+// rules is a list of host names and networks. A host entry starting with a dot
+// matches the domain and all of its subdomains.
+type rules struct {
+	hosts []string
+	nets  []netip.Prefix
+}
+
+func parseRules(value string) (rules, error) {
+	var r rules
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		switch {
+		case entry == "":
+			continue
+		case strings.Contains(entry, "/"):
+			prefix, err := netip.ParsePrefix(entry)
+			if err != nil {
+				return rules{}, fmt.Errorf("invalid CIDR %q: %w", entry, err)
+			}
+			r.nets = append(r.nets, prefix.Masked())
+		default:
+			if addr, err := netip.ParseAddr(entry); err == nil {
+				r.nets = append(r.nets, netip.PrefixFrom(addr, addr.BitLen()))
+				continue
+			}
+			r.hosts = append(r.hosts, entry)
+		}
+	}
+	return r, nil
+}
+
+func (r rules) empty() bool {
+	return len(r.hosts) == 0 && len(r.nets) == 0
+}
+
+func (r rules) matchHost(host string) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	return slices.ContainsFunc(r.hosts, func(pattern string) bool {
+		if domain, ok := strings.CutPrefix(pattern, "."); ok {
+			return host == domain || strings.HasSuffix(host, pattern)
+		}
+		return host == pattern
+	})
+}
+
+func (r rules) matchIP(ip netip.Addr) bool {
+	return slices.ContainsFunc(r.nets, func(prefix netip.Prefix) bool {
+		return prefix.Contains(ip)
+	})
+}
+
+// Fetcher downloads user supplied URLs. It only connects to addresses its rules
+// allow, checking every address a host name resolves to and every redirect, so
+// DNS and redirects cannot be used to reach internal services.
+type Fetcher struct {
+	client       *http.Client
+	resolver     *net.Resolver
+	dialer       *net.Dialer
+	allow        rules
+	deny         rules
+	trusted      []string
+	allowPrivate bool
+	maxBytes     int64
+	timeout      time.Duration
+	maxRedirects int
+}
+
+// New reads its configuration from the environment:
+// FETCH_ALLOW lists the hosts and CIDRs that may be fetched, and everything else is
+// refused when it is set; FETCH_DENY lists hosts and CIDRs that are always refused;
+// private, loopback and link-local addresses are refused unless FETCH_ALLOW_PRIVATE
+// is true or FETCH_ALLOW names them; FETCH_MAX_BYTES limits the size of a response
+// and FETCH_TIMEOUT the time a request may take. The hosts of the trusted URLs, the
+// services the editor is configured to use, may be fetched whatever the lists say.
+func New(trusted ...string) (*Fetcher, error) {
+	f := &Fetcher{
+		resolver:     net.DefaultResolver,
+		dialer:       &net.Dialer{Timeout: 10 * time.Second},
+		maxBytes:     defaultMaxBytes,
+		timeout:      defaultTimeout,
+		maxRedirects: defaultMaxRedirects,
+	}
+
+	var err error
+	if f.allow, err = parseRules(os.Getenv("FETCH_ALLOW")); err != nil {
+		return nil, fmt.Errorf("invalid FETCH_ALLOW: %w", err)
+	}
+	if f.deny, err = parseRules(os.Getenv("FETCH_DENY")); err != nil {
+		return nil, fmt.Errorf("invalid FETCH_DENY: %w", err)
+	}
+	if value := os.Getenv("FETCH_ALLOW_PRIVATE"); value != "" {
+		if f.allowPrivate, err = strconv.ParseBool(value); err != nil {
+			return nil, fmt.Errorf("invalid FETCH_ALLOW_PRIVATE: %w", err)
+		}
+	}
+	if value := os.Getenv("FETCH_MAX_BYTES"); value != "" {
+		if f.maxBytes, err = strconv.ParseInt(value, 10, 64); err != nil || f.maxBytes <= 0 {
+			return nil, fmt.Errorf("invalid FETCH_MAX_BYTES %q", value)
+		}
+	}
+	if value := os.Getenv("FETCH_TIMEOUT"); value != "" {
+		if f.timeout, err = time.ParseDuration(value); err != nil || f.timeout <= 0 {
+			return nil, fmt.Errorf("invalid FETCH_TIMEOUT %q", value)
+		}
+	}
+
+	for _, rawURL := range trusted {
+		if rawURL == "" {
+			continue
+		}
+		// the Drupal URLs are templates with a %s for the node ID
+		u, err := url.Parse(strings.ReplaceAll(rawURL, "%s", "0"))
+		if err != nil || u.Hostname() == "" {
+			slog.Warn("Unable to trust the host of a service URL", "url", rawURL, "err", err)
+			continue
+		}
+		f.trusted = append(f.trusted, strings.ToLower(u.Hostname()))
+	}
+
+	f.client = &http.Client{
+		Transport: &http.Transport{
+			// no proxy, which would connect to addresses on our behalf
+			Proxy:                 nil,
+			DialContext:           f.dialContext,
+			ForceAttemptHTTP2:     true,
+			MaxIdleConns:          20,
+			IdleConnTimeout:       90 * time.Second,
+			TLSHandshakeTimeout:   10 * time.Second,
+			ResponseHeaderTimeout: f.timeout,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= f.maxRedirects {
+				return fmt.Errorf("stopped after %d redirects", f.maxRedirects)
+			}
+			return f.checkURL(req.URL)
+		},
+	}
+
+	return f, nil
+}
+
+// Get downloads rawURL and returns its body and content type. When accept is not
+// empty, the content type must match one of its entries, where an entry ending in a
+// slash, such as "image/", matches every subtype.
+func (f *Fetcher) Get(ctx context.Context, rawURL string, accept ...string) ([]byte, string, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", rawURL, nil)
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid URL: %w", err)
+	}
+	return f.Do(req, accept...)
+}
+
+// Do sends req with the fetcher's rules, timeout and size limit and returns the
+// body and content type of a 200 response, checked against accept as for Get.
+// Responses that do not say what they are, or only say application/octet-stream,
+// are identified from their first bytes.
+func (f *Fetcher) Do(req *http.Request, accept ...string) ([]byte, string, error) {
+	if err := f.checkURL(req.URL); err != nil {
+		return nil, "", err
+	}
+
+	ctx, cancel := context.WithTimeout(req.Context(), f.timeout)
+	defer cancel()
+
+	resp, err := f.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	if resp.ContentLength > f.maxBytes {
+		return nil, "", fmt.Errorf("%w: %d bytes, the limit is %d", ErrTooLarge, resp.ContentLength, f.maxBytes)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, f.maxBytes+1))
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read response: %w", err)
+	}
+	if int64(len(data)) > f.maxBytes {
+		return nil, "", fmt.Errorf("%w: the limit is %d bytes", ErrTooLarge, f.maxBytes)
+	}
+
+	contentType := resp.Header.Get("Content-Type")
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	if mediaType == "" || mediaType == "application/octet-stream" || mediaType == "binary/octet-stream" {
+		contentType = DetectContentType(data)
+		mediaType, _, _ = mime.ParseMediaType(contentType)
+	}
+	if len(accept) > 0 && !slices.ContainsFunc(accept, func(accepted string) bool {
+		if strings.HasSuffix(accepted, "/") {
+			return strings.HasPrefix(mediaType, accepted)
+		}
+		return mediaType == accepted
+	}) {
+		return nil, "", fmt.Errorf("%w %q", ErrContentType, mediaType)
+	}
+
+	return data, contentType, nil
+}
+
+// DetectContentType is http.DetectContentType with the TIFF and JPEG 2000
+// signatures it does not know
+func DetectContentType(data []byte) string {
+	switch {
+	case bytes.HasPrefix(data, []byte("II*\x00")), bytes.HasPrefix(data, []byte("MM\x00*")):
+		return "image/tiff"
+	case bytes.HasPrefix(data, []byte("\x00\x00\x00\x0cjP  \r\n\x87\n")):
+		return "image/jp2"
+	}
+	return http.DetectContentType(data)
+}
+
+// checkURL refuses schemes other than HTTP and denied host names before connecting
+func (f *Fetcher) checkURL(u *url.URL) error {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("%w: unsupported scheme %q", ErrBlocked, u.Scheme)
+	}
+	if u.Hostname() == "" {
+		return fmt.Errorf("%w: URL has no host", ErrBlocked)
+	}
+	if f.deny.matchHost(u.Hostname()) {
+		return fmt.Errorf("%w: %s is denied", ErrBlocked, u.Hostname())
+	}
+	return nil
+}
+
+// checkIP decides whether the fetcher may connect to ip for host
+func (f *Fetcher) checkIP(host string, ip netip.Addr) error {
+	ip = ip.Unmap()
+	switch {
+	case f.deny.matchIP(ip):
+		return fmt.Errorf("%w: %s is denied", ErrBlocked, ip)
+	case slices.Contains(f.trusted, strings.ToLower(host)), f.allow.matchHost(host), f.allow.matchIP(ip):
+		return nil
+	case !f.allow.empty():
+		return fmt.Errorf("%w: %s is not in FETCH_ALLOW", ErrBlocked, host)
+	case !f.allowPrivate && isPrivate(ip):
+		return fmt.Errorf("%w: %s resolves to the private address %s", ErrBlocked, host, ip)
+	}
+	return nil
+}
+
+// dialContext resolves the host itself and connects to the first address it may,
+// so the address that is checked is the address that is used
+func (f *Fetcher) dialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	portNumber, err := strconv.ParseUint(port, 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("invalid port %q", port)
+	}
+
+	ips, err := f.resolver.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		return nil, err
+	}
+
+	var lastErr error
+	for _, ip := range ips {
+		if err := f.checkIP(host, ip); err != nil {
+			lastErr = err
+			continue
+		}
+		conn, err := f.dialer.DialContext(ctx, network, netip.AddrPortFrom(ip.Unmap(), uint16(portNumber)).String())
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		return conn, nil
+	}
+	if lastErr == nil {
+		lastErr = fmt.Errorf("no addresses found for %s", host)
+	}
+	return nil, lastErr
+}
+
+func isPrivate(ip netip.Addr) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() ||
+		slices.ContainsFunc(reservedPrefixes, func(prefix netip.Prefix) bool {
+			return prefix.Contains(ip)
+		})
+}
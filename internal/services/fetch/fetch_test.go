@@ -0,0 +1,140 @@
+package fetch_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lehigh-university-libraries/hocr-edit/internal/services/fetch"
+)
+
+var tiff = []byte("II*\x00\x08\x00\x00\x00rest of the image")
+
+func newServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/page.jpg", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/jpeg")
+		_, _ = w.Write([]byte("\xff\xd8\xff\xe0 a jpeg"))
+	})
+	mux.HandleFunc("/page.tif", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/octet-stream")
+		_, _ = w.Write(tiff)
+	})
+	mux.HandleFunc("/page.html", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte("<html>not an image</html>"))
+	})
+	mux.HandleFunc("/large", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/jpeg")
+		w.(http.Flusher).Flush() // no Content-Length, so the limit is found while reading
+		_, _ = w.Write([]byte(strings.Repeat("x", 100)))
+	})
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(time.Second):
+		case <-r.Context().Done():
+		}
+	})
+	mux.HandleFunc("/redirect", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, r.URL.Query().Get("to"), http.StatusFound)
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func newFetcher(t *testing.T, env map[string]string, trusted ...string) *fetch.Fetcher {
+	t.Helper()
+	for _, name := range []string{"FETCH_ALLOW", "FETCH_DENY", "FETCH_ALLOW_PRIVATE", "FETCH_MAX_BYTES", "FETCH_TIMEOUT"} {
+		t.Setenv(name, env[name])
+	}
+	fetcher, err := fetch.New(trusted...)
+	if err != nil {
+		t.Fatalf("Error configuring fetcher: %v", err)
+	}
+	return fetcher
+}
+
+func TestRules(t *testing.T) {
+	server := newServer(t)
+	port := server.URL[strings.LastIndex(server.URL, ":"):]
+
+	tests := []struct {
+		name    string
+		env     map[string]string
+		trusted string
+		url     string
+		err     error
+	}{
+		{"private addresses are blocked by default", nil, "", server.URL + "/page.jpg", fetch.ErrBlocked},
+		{"private addresses can be allowed", map[string]string{"FETCH_ALLOW_PRIVATE": "true"}, "", server.URL + "/page.jpg", nil},
+		{"allowed CIDR", map[string]string{"FETCH_ALLOW": "127.0.0.0/8"}, "", server.URL + "/page.jpg", nil},
+		{"allowed host", map[string]string{"FETCH_ALLOW": "localhost"}, "", "http://localhost" + port + "/page.jpg", nil},
+		{"not on the allow list", map[string]string{"FETCH_ALLOW": ".example.edu"}, "", server.URL + "/page.jpg", fetch.ErrBlocked},
+		{"denied CIDR", map[string]string{"FETCH_ALLOW_PRIVATE": "true", "FETCH_DENY": "127.0.0.1"}, "", server.URL + "/page.jpg", fetch.ErrBlocked},
+		{"denied host", map[string]string{"FETCH_ALLOW_PRIVATE": "true", "FETCH_DENY": "localhost"}, "", "http://localhost" + port + "/page.jpg", fetch.ErrBlocked},
+		{"trusted service", nil, server.URL + "/%s/houdini", server.URL + "/page.jpg", nil},
+		{"redirects are checked", nil, server.URL, server.URL + "/redirect?to=http://localhost" + port + "/page.jpg", fetch.ErrBlocked},
+		{"only http", map[string]string{"FETCH_ALLOW_PRIVATE": "true"}, "", "file:///etc/passwd", fetch.ErrBlocked},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var trusted []string
+			if tt.trusted != "" {
+				trusted = append(trusted, tt.trusted)
+			}
+			fetcher := newFetcher(t, tt.env, trusted...)
+			_, _, err := fetcher.Get(context.Background(), tt.url, "image/")
+			if tt.err == nil && err != nil {
+				t.Errorf("Expected %s to be fetched, got %v", tt.url, err)
+			}
+			if tt.err != nil && !errors.Is(err, tt.err) {
+				t.Errorf("Expected %v for %s, got %v", tt.err, tt.url, err)
+			}
+		})
+	}
+}
+
+func TestLimits(t *testing.T) {
+	server := newServer(t)
+	fetcher := newFetcher(t, map[string]string{"FETCH_ALLOW_PRIVATE": "true", "FETCH_MAX_BYTES": "64", "FETCH_TIMEOUT": "100ms"})
+
+	data, contentType, err := fetcher.Get(context.Background(), server.URL+"/page.tif", "image/", "application/pdf")
+	if err != nil {
+		t.Fatalf("Error fetching TIFF: %v", err)
+	}
+	if contentType != "image/tiff" || string(data) != string(tiff) {
+		t.Errorf("Expected the octet-stream to be identified as image/tiff, got %s", contentType)
+	}
+
+	if _, _, err := fetcher.Get(context.Background(), server.URL+"/page.html", "image/"); !errors.Is(err, fetch.ErrContentType) {
+		t.Errorf("Expected HTML to be refused as an image, got %v", err)
+	}
+	if _, _, err := fetcher.Get(context.Background(), server.URL+"/large", "image/"); !errors.Is(err, fetch.ErrTooLarge) {
+		t.Errorf("Expected a response over FETCH_MAX_BYTES to be refused, got %v", err)
+	}
+	if _, _, err := fetcher.Get(context.Background(), server.URL+"/slow"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected a slow response to time out, got %v", err)
+	}
+}
+
+func TestInvalidConfig(t *testing.T) {
+	for name, value := range map[string]string{
+		"FETCH_ALLOW":         "10.0.0.0/33",
+		"FETCH_DENY":          "::1/200",
+		"FETCH_ALLOW_PRIVATE": "maybe",
+		"FETCH_MAX_BYTES":     "-1",
+		"FETCH_TIMEOUT":       "soon",
+	} {
+		t.Setenv(name, value)
+		if _, err := fetch.New(); err == nil {
+			t.Errorf("Expected %s=%s to be refused", name, value)
+		}
+		t.Setenv(name, "")
+	}
+}
@@ -0,0 +1,210 @@
+package hocr
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/lehigh-university-libraries/hocr-edit/internal/models"
+)
+
+// CanonicalPage rebuilds a page from the structure the editor loaded and the words
+// as they were edited. Words are placed in their line by line_id and sorted left to
+// right; lines keep their place in the structure, and lines the structure does not
+// know about, such as newly drawn ones, follow top to bottom in an implicit area.
+// Bboxes are recomputed from the words and empty elements are dropped, so the same
+// edits always give the same page. A width and height of zero keep the bbox of the
+// structure.
+func CanonicalPage(structure models.HOCRPage, words []models.HOCRWord, width, height int) (models.HOCRPage, error) {
+	page := models.HOCRPage{
+		ID:         structure.ID,
+		BBox:       structure.BBox,
+		Image:      structure.Image,
+		PageNumber: structure.PageNumber,
+		Lang:       structure.Lang,
+	}
+	if page.ID == "" {
+		page.ID = "page_1"
+	}
+	if width > 0 && height > 0 {
+		page.BBox = models.BBox{X2: width, Y2: height}
+	}
+
+	if err := validateWords(words); err != nil {
+		return models.HOCRPage{}, err
+	}
+
+	lineWords := make(map[string][]models.HOCRWord)
+	for _, word := range words {
+		word.BBox = clampBBox(word.BBox, page.BBox)
+		word.Poly = canonicalPoly(word.Poly, word.BBox, page.BBox)
+		// character confidences that no longer line up with the text are dropped,
+		// while character boxes are kept and marked stale
+		if !hasCharConfidences(word) {
+			word.CharConfidences = nil
+		}
+		word.CharBoxes = slices.Clone(word.CharBoxes)
+		for i := range word.CharBoxes {
+			word.CharBoxes[i] = clampBBox(word.CharBoxes[i], page.BBox)
+		}
+		if len(word.CharBoxes) != utf8.RuneCountInString(word.Text) {
+			word.CharBoxesStale = len(word.CharBoxes) > 0
+		}
+		lineWords[word.LineID] = append(lineWords[word.LineID], word)
+	}
+	for _, wordsInLine := range lineWords {
+		sort.SliceStable(wordsInLine, func(i, j int) bool {
+			return compareBoxes(wordsInLine[i].BBox, wordsInLine[i].ID, wordsInLine[j].BBox, wordsInLine[j].ID, false)
+		})
+	}
+
+	placed := make(map[string]bool, len(lineWords))
+	for _, area := range structure.Areas {
+		canonicalArea := models.HOCRArea{ID: area.ID}
+		for _, paragraph := range area.Paragraphs {
+			canonicalParagraph := models.HOCRParagraph{ID: paragraph.ID, Lang: paragraph.Lang}
+			for _, line := range paragraph.Lines {
+				if placed[line.ID] || len(lineWords[line.ID]) == 0 {
+					continue
+				}
+				placed[line.ID] = true
+				canonicalParagraph.Lines = append(canonicalParagraph.Lines, canonicalLine(line, page.ID, lineWords[line.ID]))
+			}
+			if len(canonicalParagraph.Lines) > 0 {
+				canonicalParagraph.Poly, canonicalParagraph.BBox = canonicalRegion(paragraph.Poly, linesBBox(canonicalParagraph.Lines), page.BBox)
+				canonicalArea.Paragraphs = append(canonicalArea.Paragraphs, canonicalParagraph)
+			}
+		}
+		if len(canonicalArea.Paragraphs) > 0 {
+			canonicalArea.Poly, canonicalArea.BBox = canonicalRegion(area.Poly, paragraphsBBox(canonicalArea.Paragraphs), page.BBox)
+			page.Areas = append(page.Areas, canonicalArea)
+		}
+	}
+
+	var newLines []models.HOCRLine
+	for lineID, wordsInLine := range lineWords {
+		if !placed[lineID] {
+			newLines = append(newLines, canonicalLine(models.HOCRLine{ID: lineID}, page.ID, wordsInLine))
+		}
+	}
+	if len(newLines) > 0 {
+		sort.Slice(newLines, func(i, j int) bool {
+			return compareBoxes(newLines[i].BBox, newLines[i].ID, newLines[j].BBox, newLines[j].ID, true)
+		})
+		paragraph := models.HOCRParagraph{Lines: newLines, BBox: linesBBox(newLines)}
+		page.Areas = append(page.Areas, models.HOCRArea{Paragraphs: []models.HOCRParagraph{paragraph}, BBox: paragraph.BBox})
+	}
+
+	return page, nil
+}
+
+// CanonicalPages runs every page of a parsed document through CanonicalPage
+func CanonicalPages(pages []models.HOCRPage) ([]models.HOCRPage, error) {
+	canonical := make([]models.HOCRPage, 0, len(pages))
+	for _, page := range pages {
+		var words []models.HOCRWord
+		for _, line := range PageLines(page) {
+			for _, word := range line.Words {
+				word.LineID = line.ID
+				words = append(words, word)
+			}
+		}
+
+		canonicalPage, err := CanonicalPage(page, words, 0, 0)
+		if err != nil {
+			return nil, fmt.Errorf("page %s: %w", page.ID, err)
+		}
+		canonical = append(canonical, canonicalPage)
+	}
+	return canonical, nil
+}
+
+// validateWords rejects edits that would not make a usable hOCR document
+func validateWords(words []models.HOCRWord) error {
+	seen := make(map[string]bool, len(words))
+	for _, word := range words {
+		switch {
+		case word.ID == "":
+			return fmt.Errorf("word %q has no id", word.Text)
+		case seen[word.ID]:
+			return fmt.Errorf("word id %s is used more than once", word.ID)
+		case word.LineID == "":
+			return fmt.Errorf("word %s has no line_id", word.ID)
+		case strings.TrimSpace(word.Text) == "":
+			return fmt.Errorf("word %s has no text", word.ID)
+		case word.BBox.X2 < word.BBox.X1 || word.BBox.Y2 < word.BBox.Y1:
+			return fmt.Errorf("word %s has an inverted bbox", word.ID)
+		case word.Confidence < 0 || word.Confidence > 100:
+			return fmt.Errorf("word %s has confidence %.0f outside 0-100", word.ID, word.Confidence)
+		}
+		seen[word.ID] = true
+	}
+	return nil
+}
+
+// clampBBox keeps a bbox on the page, as OCR engines sometimes report boxes a few
+// pixels past the image edge
+func clampBBox(bbox, page models.BBox) models.BBox {
+	if page.X2 <= page.X1 || page.Y2 <= page.Y1 {
+		return bbox
+	}
+	return models.BBox{
+		X1: min(max(bbox.X1, page.X1), page.X2),
+		Y1: min(max(bbox.Y1, page.Y1), page.Y2),
+		X2: min(max(bbox.X2, page.X1), page.X2),
+		Y2: min(max(bbox.Y2, page.Y1), page.Y2),
+	}
+}
+
+// canonicalLine rebuilds a line as it was loaded around its edited words. It keeps
+// the loaded line's geometry and is outlined from the polys of its words, or failing
+// that keeps its own poly if the poly still lies within the new bbox.
+func canonicalLine(loaded models.HOCRLine, pageID string, words []models.HOCRWord) models.HOCRLine {
+	line := models.HOCRLine{ID: loaded.ID, PageID: pageID, Words: words}
+	boxes := make([]models.BBox, len(words))
+	for i, word := range words {
+		boxes[i] = word.BBox
+	}
+	line.BBox = unionBBoxes(boxes)
+	keepLineGeometry(&line, loaded)
+	line.Poly = linePoly(line)
+	if line.Poly == nil {
+		// the words are already on the page, so a poly within their bbox is too
+		line.Poly = canonicalPoly(loaded.Poly, line.BBox, models.BBox{})
+	}
+	return line
+}
+
+func linesBBox(lines []models.HOCRLine) models.BBox {
+	boxes := make([]models.BBox, len(lines))
+	for i, line := range lines {
+		boxes[i] = line.BBox
+	}
+	return unionBBoxes(boxes)
+}
+
+func paragraphsBBox(paragraphs []models.HOCRParagraph) models.BBox {
+	boxes := make([]models.BBox, len(paragraphs))
+	for i, paragraph := range paragraphs {
+		boxes[i] = paragraph.BBox
+	}
+	return unionBBoxes(boxes)
+}
+
+// compareBoxes orders boxes left to right, or top to bottom when vertical is set,
+// falling back to the other axis and then the ID so the order never depends on input order
+func compareBoxes(a models.BBox, aID string, b models.BBox, bID string, vertical bool) bool {
+	first, second := [2]int{a.X1, a.Y1}, [2]int{b.X1, b.Y1}
+	if vertical {
+		first, second = [2]int{a.Y1, a.X1}, [2]int{b.Y1, b.X1}
+	}
+	if first[0] != second[0] {
+		return first[0] < second[0]
+	}
+	if first[1] != second[1] {
+		return first[1] < second[1]
+	}
+	return aID < bID
+}
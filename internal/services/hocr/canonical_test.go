@@ -0,0 +1,150 @@
+package hocr_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/lehigh-university-libraries/hocr-edit/internal/models"
+	"github.com/lehigh-university-libraries/hocr-edit/internal/services/hocr"
+)
+
+func word(id, lineID, text string, x1, y1, x2, y2 int) models.HOCRWord {
+	return models.HOCRWord{ID: id, LineID: lineID, Text: text, Confidence: 90, BBox: models.BBox{X1: x1, Y1: y1, X2: x2, Y2: y2}}
+}
+
+var structure = models.HOCRPage{
+	ID: "page_1",
+	Areas: []models.HOCRArea{{
+		ID: "block_1",
+		Paragraphs: []models.HOCRParagraph{{
+			ID: "par_1",
+			Lines: []models.HOCRLine{
+				{ID: "line_1", BBox: models.BBox{X1: 0, Y1: 0, X2: 999, Y2: 999}},
+				{ID: "line_2"},
+			},
+		}},
+	}},
+}
+
+func TestCanonicalPage(t *testing.T) {
+	words := []models.HOCRWord{
+		word("word_3", "line_new", "drawn", 10, 300, 60, 320),
+		word("word_2", "line_1", "Sir", 70, 10, 100, 30),
+		word("word_1", "line_1", "Dear", 10, 12, 60, 32),
+	}
+
+	page, err := hocr.CanonicalPage(structure, words, 500, 400)
+	if err != nil {
+		t.Fatalf("Error building page: %v", err)
+	}
+
+	if page.BBox != (models.BBox{X2: 500, Y2: 400}) {
+		t.Errorf("Expected the page bbox from the image size, got %+v", page.BBox)
+	}
+	if len(page.Areas) != 2 {
+		t.Fatalf("Expected the structured area and one for the new line, got %d", len(page.Areas))
+	}
+
+	area := page.Areas[0]
+	if area.ID != "block_1" || len(area.Paragraphs[0].Lines) != 1 {
+		t.Fatalf("Expected line_2 without words to be dropped, got %+v", area)
+	}
+	line := area.Paragraphs[0].Lines[0]
+	if line.Words[0].Text != "Dear" || line.Words[1].Text != "Sir" {
+		t.Errorf("Expected words sorted left to right, got %+v", line.Words)
+	}
+	if line.BBox != (models.BBox{X1: 10, Y1: 10, X2: 100, Y2: 32}) {
+		t.Errorf("Expected the line bbox recomputed from its words, got %+v", line.BBox)
+	}
+	if area.BBox != line.BBox {
+		t.Errorf("Expected the area bbox recomputed from its lines, got %+v", area.BBox)
+	}
+
+	if page.Areas[1].ID != "" || page.Areas[1].Paragraphs[0].Lines[0].ID != "line_new" {
+		t.Errorf("Expected the new line in an implicit area, got %+v", page.Areas[1])
+	}
+}
+
+func TestCanonicalPageIsDeterministic(t *testing.T) {
+	words := []models.HOCRWord{
+		word("word_1", "line_a", "one", 10, 100, 40, 120),
+		word("word_2", "line_b", "two", 10, 10, 40, 30),
+		word("word_3", "line_b", "three", 50, 10, 90, 30),
+	}
+	reversed := []models.HOCRWord{words[2], words[1], words[0]}
+
+	first, err := hocr.CanonicalPage(models.HOCRPage{}, words, 500, 400)
+	if err != nil {
+		t.Fatalf("Error building page: %v", err)
+	}
+	second, err := hocr.CanonicalPage(models.HOCRPage{}, reversed, 500, 400)
+	if err != nil {
+		t.Fatalf("Error building page: %v", err)
+	}
+
+	a := hocr.NewConverter().ConvertHOCRPageToXML(first)
+	b := hocr.NewConverter().ConvertHOCRPageToXML(second)
+	if a != b {
+		t.Errorf("Expected identical hOCR regardless of word order\n%s\n%s", a, b)
+	}
+	if strings.Index(a, "line_b") > strings.Index(a, "line_a") {
+		t.Errorf("Expected new lines ordered top to bottom, got:\n%s", a)
+	}
+}
+
+func TestCanonicalPageValidation(t *testing.T) {
+	tests := map[string][]models.HOCRWord{
+		"duplicate id":  {word("w", "l", "a", 0, 0, 1, 1), word("w", "l", "b", 2, 0, 3, 1)},
+		"missing line":  {word("w", "", "a", 0, 0, 1, 1)},
+		"empty text":    {word("w", "l", " ", 0, 0, 1, 1)},
+		"inverted bbox": {word("w", "l", "a", 5, 0, 1, 1)},
+	}
+
+	for name, words := range tests {
+		if _, err := hocr.CanonicalPage(models.HOCRPage{}, words, 500, 400); err == nil {
+			t.Errorf("%s: expected a validation error", name)
+		}
+	}
+}
+
+func TestCanonicalPageClampsToPage(t *testing.T) {
+	page, err := hocr.CanonicalPage(models.HOCRPage{}, []models.HOCRWord{word("w", "l", "edge", -3, 10, 505, 30)}, 500, 400)
+	if err != nil {
+		t.Fatalf("Error building page: %v", err)
+	}
+
+	bbox := page.Areas[0].Paragraphs[0].Lines[0].Words[0].BBox
+	if bbox != (models.BBox{X1: 0, Y1: 10, X2: 500, Y2: 30}) {
+		t.Errorf("Expected the word clamped to the page, got %+v", bbox)
+	}
+}
+
+func TestCanonicalPageKeepsLineGeometry(t *testing.T) {
+	loaded := models.HOCRPage{ID: "page_1", Areas: []models.HOCRArea{{Paragraphs: []models.HOCRParagraph{{Lines: []models.HOCRLine{{
+		ID:       "line_1",
+		BBox:     models.BBox{X1: 100, Y1: 180, X2: 150, Y2: 206},
+		Baseline: &models.Baseline{Slope: -0.1, Offset: -5.5},
+		XSize:    22,
+	}}}}}}}
+
+	// the first letter was deleted, so the line now starts 10 pixels on and 2 higher
+	page, err := hocr.CanonicalPage(loaded, []models.HOCRWord{word("w", "line_1", "opes", 110, 180, 150, 204)}, 0, 0)
+	if err != nil {
+		t.Fatalf("Error building page: %v", err)
+	}
+
+	line := page.Areas[0].Paragraphs[0].Lines[0]
+	if line.XSize != 22 || line.Baseline == nil {
+		t.Fatalf("Expected the line to keep its geometry, got %+v", line)
+	}
+	if *line.Baseline != (models.Baseline{Slope: -0.1, Offset: -4.5}) {
+		t.Errorf("Expected the baseline to stay put on the image, got %+v", *line.Baseline)
+	}
+}
+
+func TestConverterOCRSystem(t *testing.T) {
+	xml := hocr.NewConverterForSystem("tesseract").ConvertHOCRPageToXML(models.HOCRPage{})
+	if !strings.Contains(xml, "<meta name='ocr-system' content='tesseract' />") {
+		t.Errorf("Expected tesseract as the ocr-system, got:\n%s", xml)
+	}
+}
@@ -3,24 +3,61 @@ package hocr
 import (
 	"fmt"
 	"html"
+	"math"
+	"strconv"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/lehigh-university-libraries/hocr-edit/internal/models"
 )
 
 type Converter struct {
-	lineCounter int
-	wordCounter int
+	ocrSystem        string
+	language         string
+	areaCounter      int
+	paragraphCounter int
+	lineCounter      int
+	wordCounter      int
 }
 
 func NewConverter() *Converter {
+	return NewConverterForSystem("google-cloud-vision")
+}
+
+// NewConverterForSystem writes documents whose ocr-system metadata names system
+func NewConverterForSystem(system string) *Converter {
 	return &Converter{
-		lineCounter: 1,
-		wordCounter: 1,
+		ocrSystem:        system,
+		areaCounter:      1,
+		paragraphCounter: 1,
+		lineCounter:      1,
+		wordCounter:      1,
 	}
 }
 
+// WithLanguage makes the converter tag documents as language, a BCP 47 tag, in place
+// of the languages of their pages, paragraphs and words. An empty language keeps them.
+func (h *Converter) WithLanguage(language string) *Converter {
+	h.language = language
+	return h
+}
+
 func (h *Converter) ConvertToHOCRLines(gcvResponse models.GCVResponse) ([]models.HOCRLine, error) {
+	pages, err := h.ConvertToHOCRPages(gcvResponse)
+	if err != nil {
+		return nil, err
+	}
+
+	var allLines []models.HOCRLine
+	for _, page := range pages {
+		allLines = append(allLines, PageLines(page)...)
+	}
+
+	return allLines, nil
+}
+
+// ConvertToHOCRPages keeps the GCV block and paragraph structure as ocr_carea and ocr_par
+func (h *Converter) ConvertToHOCRPages(gcvResponse models.GCVResponse) ([]models.HOCRPage, error) {
 	if len(gcvResponse.Responses) == 0 {
 		return nil, fmt.Errorf("no responses found in GCV data")
 	}
@@ -30,53 +67,260 @@ func (h *Converter) ConvertToHOCRLines(gcvResponse models.GCVResponse) ([]models
 		return nil, fmt.Errorf("no full text annotation found")
 	}
 
-	var allLines []models.HOCRLine
-
-	for _, page := range response.FullTextAnnotation.Pages {
-		pageLines := h.convertPageToLines(page)
-		allLines = append(allLines, pageLines...)
+	var pages []models.HOCRPage
+	for i, page := range response.FullTextAnnotation.Pages {
+		pages = append(pages, h.convertPage(page, i+1))
 	}
 
-	return allLines, nil
+	return pages, nil
+}
+
+// PageLines flattens a page into its lines in reading order
+func PageLines(page models.HOCRPage) []models.HOCRLine {
+	var lines []models.HOCRLine
+	for _, area := range page.Areas {
+		for _, paragraph := range area.Paragraphs {
+			lines = append(lines, paragraph.Lines...)
+		}
+	}
+	return lines
 }
 
 func (h *Converter) ConvertHOCRLinesToXML(lines []models.HOCRLine, pageWidth, pageHeight int) string {
 	var hocr strings.Builder
 
+	h.writeHeader(&hocr, h.language)
+
+	bbox := fmt.Sprintf("bbox 0 0 %d %d", pageWidth, pageHeight)
+	hocr.WriteString(fmt.Sprintf("<div class='ocr_page' id='page_1' title='%s'>\n", bbox))
+
+	for _, line := range lines {
+		hocr.WriteString(h.convertHOCRLineToXML(line, h.language))
+	}
+
+	hocr.WriteString("</div>\n")
+	h.writeFooter(&hocr)
+
+	return hocr.String()
+}
+
+// ConvertHOCRPageToXML writes a single page document
+func (h *Converter) ConvertHOCRPageToXML(page models.HOCRPage) string {
+	return h.ConvertHOCRPagesToXML([]models.HOCRPage{page})
+}
+
+// ConvertHOCRPagesToXML writes every page, with its ocr_carea and ocr_par elements, into one document.
+// Areas and paragraphs without an ID are implicit and only their lines are written.
+func (h *Converter) ConvertHOCRPagesToXML(pages []models.HOCRPage) string {
+	var hocr strings.Builder
+
+	language := h.documentLanguage(pages)
+	h.writeHeader(&hocr, language)
+
+	for i, page := range pages {
+		h.writePage(&hocr, page, i+1, language)
+	}
+
+	h.writeFooter(&hocr)
+
+	return hocr.String()
+}
+
+// documentLanguage is the language of the whole document: the converter's language,
+// or else the language every page shares
+func (h *Converter) documentLanguage(pages []models.HOCRPage) string {
+	if h.language != "" || len(pages) == 0 {
+		return h.language
+	}
+	for _, page := range pages[1:] {
+		if page.Lang != pages[0].Lang {
+			return ""
+		}
+	}
+	return pages[0].Lang
+}
+
+// langAttribute writes the lang of an element whose language differs from the
+// inherited language of the element containing it. With the converter's language
+// set, only the document is tagged.
+func (h *Converter) langAttribute(lang, inherited string) string {
+	if h.language != "" || lang == "" || lang == inherited {
+		return ""
+	}
+	return fmt.Sprintf(" lang='%s'", html.EscapeString(lang))
+}
+
+// elementLanguage is the language of an element with lang inside one of language inherited
+func elementLanguage(lang, inherited string) string {
+	if lang == "" {
+		return inherited
+	}
+	return lang
+}
+
+func (h *Converter) writePage(hocr *strings.Builder, page models.HOCRPage, pageNumber int, inherited string) {
+	pageID := page.ID
+	if pageID == "" {
+		pageID = fmt.Sprintf("page_%d", pageNumber)
+	}
+	hocr.WriteString(fmt.Sprintf("<div class='ocr_page' id='%s' title='%s'%s>\n", html.EscapeString(pageID), pageTitle(page), h.langAttribute(page.Lang, inherited)))
+	pageLanguage := elementLanguage(page.Lang, inherited)
+
+	for _, area := range page.Areas {
+		if area.ID != "" {
+			hocr.WriteString(fmt.Sprintf("<div class='ocr_carea' id='%s' title='%s'>\n", html.EscapeString(area.ID), formatShape(area.BBox, area.Poly)))
+		}
+
+		for _, paragraph := range area.Paragraphs {
+			// implicit paragraphs are not written, so neither is their language
+			language := pageLanguage
+			if paragraph.ID != "" {
+				hocr.WriteString(fmt.Sprintf("<p class='ocr_par' id='%s' title='%s'%s>\n", html.EscapeString(paragraph.ID), formatShape(paragraph.BBox, paragraph.Poly), h.langAttribute(paragraph.Lang, pageLanguage)))
+				language = elementLanguage(paragraph.Lang, pageLanguage)
+			}
+
+			for _, line := range paragraph.Lines {
+				hocr.WriteString(h.convertHOCRLineToXML(line, language))
+			}
+
+			if paragraph.ID != "" {
+				hocr.WriteString("</p>\n")
+			}
+		}
+
+		if area.ID != "" {
+			hocr.WriteString("</div>\n")
+		}
+	}
+
+	hocr.WriteString("</div>\n")
+}
+
+// pageTitle writes the image, bbox and ppageno properties of an ocr_page
+func pageTitle(page models.HOCRPage) string {
+	var properties []string
+	if page.Image != "" {
+		properties = append(properties, fmt.Sprintf("image \"%s\"", html.EscapeString(page.Image)))
+	}
+	properties = append(properties, formatBBox(page.BBox))
+	properties = append(properties, fmt.Sprintf("ppageno %d", page.PageNumber))
+	return strings.Join(properties, "; ")
+}
+
+// RenumberPages rewrites every element ID in place so IDs stay unique once
+// pages that were OCR'd separately are combined into one document.
+// Implicit areas and paragraphs keep their empty IDs.
+func RenumberPages(pages []models.HOCRPage) {
+	for p := range pages {
+		page := &pages[p]
+		page.ID = fmt.Sprintf("page_%d", p+1)
+		page.PageNumber = p
+
+		areaCounter, paragraphCounter, lineCounter, wordCounter := 1, 1, 1, 1
+		for a := range page.Areas {
+			area := &page.Areas[a]
+			if area.ID != "" {
+				area.ID = fmt.Sprintf("block_%d_%d", p+1, areaCounter)
+				areaCounter++
+			}
+
+			for r := range area.Paragraphs {
+				paragraph := &area.Paragraphs[r]
+				if paragraph.ID != "" {
+					paragraph.ID = fmt.Sprintf("par_%d_%d", p+1, paragraphCounter)
+					paragraphCounter++
+				}
+
+				for l := range paragraph.Lines {
+					line := &paragraph.Lines[l]
+					line.ID = fmt.Sprintf("line_%d_%d", p+1, lineCounter)
+					line.PageID = page.ID
+					lineCounter++
+
+					for w := range line.Words {
+						word := &line.Words[w]
+						word.ID = fmt.Sprintf("word_%d_%d", p+1, wordCounter)
+						word.LineID = line.ID
+						wordCounter++
+					}
+				}
+			}
+		}
+	}
+}
+
+func (h *Converter) writeHeader(hocr *strings.Builder, language string) {
 	hocr.WriteString("<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n")
 	hocr.WriteString("<!DOCTYPE html PUBLIC \"-//W3C//DTD XHTML 1.0 Transitional//EN\"\n")
 	hocr.WriteString("    \"http://www.w3.org/TR/xhtml1/DTD/xhtml1-transitional.dtd\">\n")
-	hocr.WriteString("<html xmlns=\"http://www.w3.org/1999/xhtml\" xml:lang=\"en\" lang=\"en\">\n")
+	if language != "" {
+		hocr.WriteString(fmt.Sprintf("<html xmlns=\"http://www.w3.org/1999/xhtml\" xml:lang=\"%[1]s\" lang=\"%[1]s\">\n", html.EscapeString(language)))
+	} else {
+		hocr.WriteString("<html xmlns=\"http://www.w3.org/1999/xhtml\">\n")
+	}
 	hocr.WriteString("<head>\n")
 	hocr.WriteString("<title></title>\n")
 	hocr.WriteString("<meta http-equiv=\"Content-Type\" content=\"text/html; charset=utf-8\" />\n")
-	hocr.WriteString("<meta name='ocr-system' content='google-cloud-vision' />\n")
+	hocr.WriteString(fmt.Sprintf("<meta name='ocr-system' content='%s' />\n", html.EscapeString(h.ocrSystem)))
 	hocr.WriteString("<meta name='ocr-capabilities' content='ocr_page ocr_carea ocr_par ocr_line ocrx_word' />\n")
 	hocr.WriteString("</head>\n")
 	hocr.WriteString("<body>\n")
+}
 
-	bbox := fmt.Sprintf("bbox 0 0 %d %d", pageWidth, pageHeight)
-	hocr.WriteString(fmt.Sprintf("<div class='ocr_page' id='page_1' title='%s'>\n", bbox))
-
-	for _, line := range lines {
-		hocr.WriteString(h.convertHOCRLineToXML(line))
-	}
-
-	hocr.WriteString("</div>\n")
+func (h *Converter) writeFooter(hocr *strings.Builder) {
 	hocr.WriteString("</body>\n")
 	hocr.WriteString("</html>\n")
+}
 
-	return hocr.String()
+func formatBBox(bbox models.BBox) string {
+	return fmt.Sprintf("bbox %d %d %d %d", bbox.X1, bbox.Y1, bbox.X2, bbox.Y2)
 }
 
-func (h *Converter) convertHOCRLineToXML(line models.HOCRLine) string {
-	bbox := fmt.Sprintf("bbox %d %d %d %d", line.BBox.X1, line.BBox.Y1, line.BBox.X2, line.BBox.Y2)
+// formatShape writes a bbox followed by the poly outlining the element, if it has one
+func formatShape(bbox models.BBox, poly []models.Point) string {
+	if len(poly) == 0 {
+		return formatBBox(bbox)
+	}
+	coordinates := make([]string, len(poly))
+	for i, p := range poly {
+		coordinates[i] = fmt.Sprintf("%d %d", p.X, p.Y)
+	}
+	return formatBBox(bbox) + "; poly " + strings.Join(coordinates, " ")
+}
 
+// lineTitle writes the bbox and poly of an ocr_line and the textangle, baseline and sizes
+// it has, in the order Tesseract writes them
+func lineTitle(line models.HOCRLine) string {
+	properties := []string{formatShape(line.BBox, line.Poly)}
+	if line.TextAngle != 0 {
+		properties = append(properties, "textangle "+formatNumber(line.TextAngle))
+	}
+	if line.Baseline != nil {
+		properties = append(properties, fmt.Sprintf("baseline %s %s", strconv.FormatFloat(math.Round(line.Baseline.Slope*1000)/1000, 'f', -1, 64), formatNumber(line.Baseline.Offset)))
+	}
+	if line.XSize > 0 {
+		properties = append(properties, "x_size "+formatNumber(line.XSize))
+	}
+	if line.XDescenders > 0 {
+		properties = append(properties, "x_descenders "+formatNumber(line.XDescenders))
+	}
+	if line.XAscenders > 0 {
+		properties = append(properties, "x_ascenders "+formatNumber(line.XAscenders))
+	}
+	return strings.Join(properties, "; ")
+}
+
+// formatNumber writes a measurement to two decimal places, without trailing zeros
+func formatNumber(value float64) string {
+	return strconv.FormatFloat(math.Round(value*100)/100, 'f', -1, 64)
+}
+
+func (h *Converter) convertHOCRLineToXML(line models.HOCRLine, language string) string {
 	var lineBuilder strings.Builder
-	lineBuilder.WriteString(fmt.Sprintf("<span class='ocr_line' id='%s' title='%s'>", line.ID, bbox))
+	lineBuilder.WriteString(fmt.Sprintf("<span class='ocr_line' id='%s' title='%s'>", html.EscapeString(line.ID), lineTitle(line)))
 
 	for _, word := range line.Words {
-		wordXML := h.convertHOCRWordToXML(word)
+		wordXML := h.convertHOCRWordToXML(word, language)
 		lineBuilder.WriteString(wordXML)
 	}
 
@@ -84,51 +328,130 @@ func (h *Converter) convertHOCRLineToXML(line models.HOCRLine) string {
 	return lineBuilder.String()
 }
 
-func (h *Converter) convertHOCRWordToXML(word models.HOCRWord) string {
-	bbox := fmt.Sprintf("bbox %d %d %d %d", word.BBox.X1, word.BBox.Y1, word.BBox.X2, word.BBox.Y2)
-	confidence := fmt.Sprintf("; x_wconf %.0f", word.Confidence)
-	title := bbox + confidence
+func (h *Converter) convertHOCRWordToXML(word models.HOCRWord, language string) string {
+	title := formatShape(word.BBox, word.Poly) + fmt.Sprintf("; x_wconf %.0f", word.Confidence)
+	if len(word.CharConfidences) > 0 {
+		confs := make([]string, len(word.CharConfidences))
+		for i, confidence := range word.CharConfidences {
+			confs[i] = fmt.Sprintf("%.0f", confidence)
+		}
+		title += "; x_confs " + strings.Join(confs, " ")
+	}
+	if len(word.CharBoxes) > 0 {
+		// stale boxes are kept under their own name, so tools reading x_bboxes do
+		// not pair them with the wrong characters
+		name := "x_bboxes"
+		if word.CharBoxesStale {
+			name = "x_stale_bboxes"
+		}
+		boxes := make([]string, len(word.CharBoxes))
+		for i, box := range word.CharBoxes {
+			boxes[i] = fmt.Sprintf("%d %d %d %d", box.X1, box.Y1, box.X2, box.Y2)
+		}
+		title += "; " + name + " " + strings.Join(boxes, " ")
+	}
 
-	return fmt.Sprintf("<span class='ocrx_word' id='%s' title='%s'>%s</span> ",
-		word.ID, title, html.EscapeString(word.Text))
+	return fmt.Sprintf("<span class='ocrx_word' id='%s' title='%s'%s>%s</span> ",
+		html.EscapeString(word.ID), title, h.langAttribute(word.Lang, language), html.EscapeString(word.Text))
 }
 
 func (h *Converter) ConvertToHOCR(gcvResponse models.GCVResponse) (string, error) {
-	lines, err := h.ConvertToHOCRLines(gcvResponse)
+	pages, err := h.ConvertToHOCRPages(gcvResponse)
 	if err != nil {
 		return "", err
 	}
 
-	if len(gcvResponse.Responses) == 0 || gcvResponse.Responses[0].FullTextAnnotation == nil || len(gcvResponse.Responses[0].FullTextAnnotation.Pages) == 0 {
+	if len(pages) == 0 {
 		return "", fmt.Errorf("no page data found")
 	}
 
-	page := gcvResponse.Responses[0].FullTextAnnotation.Pages[0]
-	return h.ConvertHOCRLinesToXML(lines, page.Width, page.Height), nil
+	return h.ConvertHOCRPagesToXML(pages), nil
 }
 
-func (h *Converter) convertPageToLines(page models.Page) []models.HOCRLine {
-	var allLines []models.HOCRLine
+func (h *Converter) convertPage(page models.Page, pageNumber int) models.HOCRPage {
+	hocrPage := models.HOCRPage{
+		ID:         fmt.Sprintf("page_%d", pageNumber),
+		BBox:       models.BBox{X1: 0, Y1: 0, X2: page.Width, Y2: page.Height},
+		PageNumber: pageNumber - 1,
+		Lang:       gcvLanguage(page.Property),
+	}
 
 	for _, block := range page.Blocks {
 		if block.BlockType == "TEXT" {
-			blockLines := h.convertBlockToLines(block)
-			allLines = append(allLines, blockLines...)
+			area := h.convertBlockToArea(block)
+			if len(area.Paragraphs) > 0 {
+				hocrPage.Areas = append(hocrPage.Areas, area)
+			}
 		}
 	}
 
-	return allLines
+	return hocrPage
 }
 
-func (h *Converter) convertBlockToLines(block models.Block) []models.HOCRLine {
-	var allLines []models.HOCRLine
+func (h *Converter) convertBlockToArea(block models.Block) models.HOCRArea {
+	area := models.HOCRArea{
+		ID: fmt.Sprintf("block_%d", h.areaCounter),
+	}
+	h.areaCounter++
 
+	var paragraphBoxes []models.BBox
 	for _, paragraph := range block.Paragraphs {
-		paragraphLines := h.convertParagraphToLines(paragraph)
-		allLines = append(allLines, paragraphLines...)
+		hocrParagraph := h.convertParagraph(paragraph)
+		if len(hocrParagraph.Lines) == 0 {
+			continue
+		}
+		if hocrParagraph.Lang == "" {
+			hocrParagraph.Lang = gcvLanguage(block.Property)
+		}
+		area.Paragraphs = append(area.Paragraphs, hocrParagraph)
+		paragraphBoxes = append(paragraphBoxes, hocrParagraph.BBox)
+	}
+
+	area.BBox = h.boundingPolyToBBoxStruct(block.BoundingBox)
+	area.Poly = gcvPoly(block.BoundingBox, area.BBox)
+	if len(block.BoundingBox.Vertices) == 0 {
+		area.BBox = unionBBoxes(paragraphBoxes)
+	}
+
+	return area
+}
+
+func (h *Converter) convertParagraph(paragraph models.Paragraph) models.HOCRParagraph {
+	hocrParagraph := models.HOCRParagraph{
+		ID:    fmt.Sprintf("par_%d", h.paragraphCounter),
+		Lang:  gcvLanguage(paragraph.Property),
+		Lines: h.convertParagraphToLines(paragraph),
+	}
+	h.paragraphCounter++
+
+	hocrParagraph.BBox = h.boundingPolyToBBoxStruct(paragraph.BoundingBox)
+	hocrParagraph.Poly = gcvPoly(paragraph.BoundingBox, hocrParagraph.BBox)
+	if len(paragraph.BoundingBox.Vertices) == 0 {
+		lineBoxes := make([]models.BBox, 0, len(hocrParagraph.Lines))
+		for _, line := range hocrParagraph.Lines {
+			lineBoxes = append(lineBoxes, line.BBox)
+		}
+		hocrParagraph.BBox = unionBBoxes(lineBoxes)
+	}
+
+	return hocrParagraph
+}
+
+// unionBBoxes returns the smallest box containing every box given
+func unionBBoxes(boxes []models.BBox) models.BBox {
+	if len(boxes) == 0 {
+		return models.BBox{X1: 0, Y1: 0, X2: 0, Y2: 0}
 	}
 
-	return allLines
+	union := boxes[0]
+	for _, box := range boxes[1:] {
+		union.X1 = min(union.X1, box.X1)
+		union.Y1 = min(union.Y1, box.Y1)
+		union.X2 = max(union.X2, box.X2)
+		union.Y2 = max(union.Y2, box.Y2)
+	}
+
+	return union
 }
 
 func (h *Converter) convertParagraphToLines(paragraph models.Paragraph) []models.HOCRLine {
@@ -154,6 +477,8 @@ func (h *Converter) convertParagraphToLines(paragraph models.Paragraph) []models
 			BBox:  lineBBox,
 			Words: hocrWords,
 		}
+		measureLine(&line, wordsGroup)
+		line.Poly = linePoly(line)
 
 		lines = append(lines, line)
 		h.lineCounter++
@@ -233,22 +558,79 @@ func (h *Converter) convertGCVWordToHOCRWord(gcvWord models.Word, lineID string)
 	}
 
 	bbox := h.boundingPolyToBBoxStruct(gcvWord.BoundingBox)
+	confidence, charConfidences := gcvConfidences(gcvWord)
 
-	confidence := 95.0
-	if gcvWord.Property != nil && len(gcvWord.Property.DetectedLanguages) > 0 {
-		confidence = gcvWord.Property.DetectedLanguages[0].Confidence * 100
+	// GCV symbols are characters; boxes are only kept when every symbol has one
+	var charBoxes []models.BBox
+	for _, symbol := range gcvWord.Symbols {
+		if len(symbol.BoundingBox.Vertices) == 0 {
+			charBoxes = nil
+			break
+		}
+		for range utf8.RuneCountInString(symbol.Text) {
+			charBoxes = append(charBoxes, h.boundingPolyToBBoxStruct(symbol.BoundingBox))
+		}
 	}
 
 	wordID := fmt.Sprintf("word_%d", h.wordCounter)
 	h.wordCounter++
 
 	return models.HOCRWord{
-		ID:         wordID,
-		Text:       text.String(),
-		BBox:       bbox,
-		Confidence: confidence,
-		LineID:     lineID,
+		ID:              wordID,
+		Text:            text.String(),
+		BBox:            bbox,
+		Poly:            gcvPoly(gcvWord.BoundingBox, bbox),
+		Confidence:      confidence,
+		CharConfidences: charConfidences,
+		CharBoxes:       charBoxes,
+		Lang:            gcvLanguage(gcvWord.Property),
+		LineID:          lineID,
+	}
+}
+
+// gcvLanguage is the language GCV is most confident an element is in, leaving out
+// the "und" it reports for text in no language it could determine
+func gcvLanguage(property *models.Property) string {
+	if property == nil {
+		return ""
+	}
+	language, confidence := "", -1.0
+	for _, detected := range property.DetectedLanguages {
+		if detected.LanguageCode != "" && detected.LanguageCode != "und" && detected.Confidence > confidence {
+			language, confidence = detected.LanguageCode, detected.Confidence
+		}
+	}
+	return language
+}
+
+// unknownConfidence is the x_wconf of words GCV reported no confidence for, such as
+// those in responses cached before confidences were kept
+const unknownConfidence = 95.0
+
+// gcvConfidences scales the confidences GCV reports from 0-1 to the 0-100 of hOCR.
+// A word without a confidence of its own takes the mean of its symbols'. Character
+// confidences are only returned when every symbol has one, so they line up with the text.
+func gcvConfidences(word models.Word) (float64, []float64) {
+	var charConfidences []float64
+	total := 0.0
+	for _, symbol := range word.Symbols {
+		if symbol.Confidence <= 0 {
+			charConfidences = nil
+			break
+		}
+		for range utf8.RuneCountInString(symbol.Text) {
+			charConfidences = append(charConfidences, symbol.Confidence*100)
+		}
+		total += symbol.Confidence * 100
+	}
+
+	switch {
+	case word.Confidence > 0:
+		return word.Confidence * 100, charConfidences
+	case charConfidences != nil:
+		return total / float64(len(word.Symbols)), charConfidences
 	}
+	return unknownConfidence, nil
 }
 
 func (h *Converter) boundingPolyToBBoxStruct(boundingPoly models.BoundingPoly) models.BBox {
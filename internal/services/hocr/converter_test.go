@@ -0,0 +1,358 @@
+package hocr_test
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/lehigh-university-libraries/hocr-edit/internal/models"
+	"github.com/lehigh-university-libraries/hocr-edit/internal/services/hocr"
+	"github.com/lehigh-university-libraries/hocr-edit/pkg/hocr/parser"
+)
+
+func gcvWord(confidence float64, symbols ...models.Symbol) models.Word {
+	return models.Word{
+		BoundingBox: models.BoundingPoly{Vertices: []models.Vertex{{X: 10, Y: 10}, {X: 90, Y: 10}, {X: 90, Y: 30}, {X: 10, Y: 30}}},
+		Symbols:     symbols,
+		Confidence:  confidence,
+	}
+}
+
+func TestConverterConfidences(t *testing.T) {
+	response := models.GCVResponse{Responses: []models.Response{{
+		FullTextAnnotation: &models.FullTextAnnotation{Pages: []models.Page{{
+			Width:  500,
+			Height: 400,
+			Blocks: []models.Block{{BlockType: "TEXT", Paragraphs: []models.Paragraph{{Words: []models.Word{
+				gcvWord(0.91, models.Symbol{Text: "O", Confidence: 0.9}, models.Symbol{Text: "k", Confidence: 0.92}),
+				gcvWord(0, models.Symbol{Text: "a", Confidence: 0.5}, models.Symbol{Text: "b", Confidence: 0.7}),
+				gcvWord(0, models.Symbol{Text: "x"}),
+			}}}}},
+		}}},
+	}}}
+
+	hocrXML, err := hocr.NewConverter().ConvertToHOCR(response)
+	if err != nil {
+		t.Fatalf("Error converting: %v", err)
+	}
+	if !strings.Contains(hocrXML, "x_wconf 91; x_confs 90 92") {
+		t.Errorf("Expected the word and symbol confidences, got:\n%s", hocrXML)
+	}
+
+	words, err := parser.ParseHOCRWords(hocrXML)
+	if err != nil {
+		t.Fatalf("Error parsing hOCR: %v", err)
+	}
+	tests := []struct {
+		confidence      float64
+		charConfidences []float64
+	}{
+		{91, []float64{90, 92}},
+		{60, []float64{50, 70}},
+		{95, nil},
+	}
+	for i, tt := range tests {
+		if words[i].Confidence != tt.confidence || !slices.Equal(words[i].CharConfidences, tt.charConfidences) {
+			t.Errorf("Expected word %d to have confidence %.0f and %v, got %.0f and %v", i, tt.confidence, tt.charConfidences, words[i].Confidence, words[i].CharConfidences)
+		}
+	}
+}
+
+func TestCharConfidencesFollowEdits(t *testing.T) {
+	pages := []models.HOCRPage{{ID: "page_1", Areas: []models.HOCRArea{{Paragraphs: []models.HOCRParagraph{{Lines: []models.HOCRLine{{
+		ID: "line_1",
+		Words: []models.HOCRWord{
+			{ID: "word_1", Text: "abc", BBox: models.BBox{X1: 0, Y1: 0, X2: 30, Y2: 10}, Confidence: 80, CharConfidences: []float64{70, 80, 90}},
+			{ID: "word_2", Text: "d", BBox: models.BBox{X1: 40, Y1: 0, X2: 50, Y2: 10}, Confidence: 60, CharConfidences: []float64{60}},
+		},
+	}}}}}}}}
+
+	words, err := hocr.SplitWord(pages, "word_1", []string{"a", "bc"})
+	if err != nil {
+		t.Fatalf("Error splitting: %v", err)
+	}
+	if !slices.Equal(words[0].CharConfidences, []float64{70}) || !slices.Equal(words[1].CharConfidences, []float64{80, 90}) {
+		t.Errorf("Expected the character confidences split with the text, got %v and %v", words[0].CharConfidences, words[1].CharConfidences)
+	}
+
+	merged, err := hocr.MergeWords(pages, words[1].ID, "word_2")
+	if err != nil {
+		t.Fatalf("Error merging: %v", err)
+	}
+	if !slices.Equal(merged.CharConfidences, []float64{80, 90, 60}) {
+		t.Errorf("Expected the character confidences joined, got %v", merged.CharConfidences)
+	}
+
+	text := "bed"
+	edited, err := hocr.EditWord(pages, merged.ID, hocr.WordEdit{Text: &text})
+	if err != nil {
+		t.Fatalf("Error editing: %v", err)
+	}
+	if edited.CharConfidences != nil {
+		t.Errorf("Expected new text to drop the character confidences, got %v", edited.CharConfidences)
+	}
+
+	line := &pages[0].Areas[0].Paragraphs[0].Lines[0]
+	line.Words[1].CharConfidences = []float64{80, 90, 60}
+	line.Words[1].CharBoxes = []models.BBox{{X2: 10, Y2: 10}, {X1: 10, X2: 20, Y2: 10}, {X1: 20, X2: 30, Y2: 10}}
+	editedLine, err := hocr.EditLine(pages, "line_1", "a bad")
+	if err != nil {
+		t.Fatalf("Error editing line: %v", err)
+	}
+	if !slices.Equal(editedLine.Words[0].CharConfidences, []float64{70}) || editedLine.Words[1].CharConfidences != nil {
+		t.Errorf("Expected only the changed word to drop its character confidences, got %v and %v", editedLine.Words[0].CharConfidences, editedLine.Words[1].CharConfidences)
+	}
+	if editedLine.Words[1].CharBoxesStale {
+		t.Error("Expected character boxes of the same length to stay current")
+	}
+	editedLine, err = hocr.EditLine(pages, "line_1", "a bads")
+	if err != nil {
+		t.Fatalf("Error editing line: %v", err)
+	}
+	if !editedLine.Words[1].CharBoxesStale {
+		t.Error("Expected a longer word to mark its character boxes stale")
+	}
+}
+
+func TestCharBoxes(t *testing.T) {
+	symbol := func(text string, x int) models.Symbol {
+		return models.Symbol{
+			Text:        text,
+			BoundingBox: models.BoundingPoly{Vertices: []models.Vertex{{X: x, Y: 10}, {X: x + 10, Y: 10}, {X: x + 10, Y: 30}, {X: x, Y: 30}}},
+		}
+	}
+	response := models.GCVResponse{Responses: []models.Response{{
+		FullTextAnnotation: &models.FullTextAnnotation{Pages: []models.Page{{
+			Width:  500,
+			Height: 400,
+			Blocks: []models.Block{{BlockType: "TEXT", Paragraphs: []models.Paragraph{{Words: []models.Word{
+				gcvWord(0.9, symbol("O", 10), symbol("k", 20)),
+			}}}}},
+		}}},
+	}}}
+
+	hocrXML, err := hocr.NewConverter().ConvertToHOCR(response)
+	if err != nil {
+		t.Fatalf("Error converting: %v", err)
+	}
+	if !strings.Contains(hocrXML, "x_bboxes 10 10 20 30 20 10 30 30") {
+		t.Fatalf("Expected the symbol boxes as x_bboxes, got:\n%s", hocrXML)
+	}
+
+	pages, err := parser.ParseHOCRPages(hocrXML)
+	if err != nil {
+		t.Fatalf("Error parsing hOCR: %v", err)
+	}
+	wordID := hocr.PageLines(pages[0])[0].Words[0].ID
+
+	text := "OK"
+	word, err := hocr.EditWord(pages, wordID, hocr.WordEdit{Text: &text})
+	if err != nil {
+		t.Fatalf("Error editing: %v", err)
+	}
+	if len(word.CharBoxes) != 2 || word.CharBoxesStale {
+		t.Errorf("Expected an edit of the same length to keep the boxes, got %+v (stale %t)", word.CharBoxes, word.CharBoxesStale)
+	}
+
+	text = "Okay"
+	word, err = hocr.EditWord(pages, wordID, hocr.WordEdit{Text: &text})
+	if err != nil {
+		t.Fatalf("Error editing: %v", err)
+	}
+	if len(word.CharBoxes) != 2 || !word.CharBoxesStale {
+		t.Errorf("Expected an edit of another length to mark the boxes stale, got %+v (stale %t)", word.CharBoxes, word.CharBoxesStale)
+	}
+	if xml := hocr.NewConverter().ConvertHOCRPagesToXML(pages); !strings.Contains(xml, "x_stale_bboxes 10 10 20 30 20 10 30 30") {
+		t.Errorf("Expected stale boxes written as x_stale_bboxes, got:\n%s", xml)
+	}
+}
+
+func TestConverterLanguages(t *testing.T) {
+	languages := func(codes ...string) *models.Property {
+		property := &models.Property{}
+		for i, code := range codes {
+			property.DetectedLanguages = append(property.DetectedLanguages, models.DetectedLanguage{LanguageCode: code, Confidence: 1 - float64(i)/10})
+		}
+		return property
+	}
+	german := gcvWord(0.9, models.Symbol{Text: "Herr"})
+	french := gcvWord(0.9, models.Symbol{Text: "Monsieur"})
+	french.Property = languages("fr", "de")
+	response := models.GCVResponse{Responses: []models.Response{{
+		FullTextAnnotation: &models.FullTextAnnotation{Pages: []models.Page{{
+			Property: languages("en"),
+			Width:    500,
+			Height:   400,
+			Blocks: []models.Block{{BlockType: "TEXT", Paragraphs: []models.Paragraph{{
+				Property: languages("de"),
+				Words:    []models.Word{german, french},
+			}}}},
+		}}},
+	}}}
+
+	hocrXML, err := hocr.NewConverter().ConvertToHOCR(response)
+	if err != nil {
+		t.Fatalf("Error converting: %v", err)
+	}
+	for _, expected := range []string{`xml:lang="en" lang="en"`, `class='ocr_par' id='par_1' title='bbox 10 10 90 30' lang='de'`, `lang='fr'>Monsieur`} {
+		if !strings.Contains(hocrXML, expected) {
+			t.Errorf("Expected %s, got:\n%s", expected, hocrXML)
+		}
+	}
+	if strings.Contains(hocrXML, `lang='de'>Herr`) {
+		t.Errorf("Expected words in the language of their paragraph not to be tagged, got:\n%s", hocrXML)
+	}
+
+	pages, err := parser.ParseHOCRPages(hocrXML)
+	if err != nil {
+		t.Fatalf("Error parsing hOCR: %v", err)
+	}
+	if pages[0].Lang != "en" || pages[0].Areas[0].Paragraphs[0].Lang != "de" {
+		t.Errorf("Expected the page and paragraph languages to be parsed, got %q and %q", pages[0].Lang, pages[0].Areas[0].Paragraphs[0].Lang)
+	}
+	if rewritten := hocr.NewConverter().ConvertHOCRPagesToXML(pages); rewritten != hocrXML {
+		t.Errorf("Expected the languages to round trip, got:\n%s", rewritten)
+	}
+
+	overridden := hocr.NewConverter().WithLanguage("la").ConvertHOCRPagesToXML(pages)
+	if !strings.Contains(overridden, `xml:lang="la" lang="la"`) || strings.Contains(overridden, "lang='") {
+		t.Errorf("Expected only the document tagged with the override, got:\n%s", overridden)
+	}
+}
+
+func TestConverterLineGeometry(t *testing.T) {
+	box := func(vertices ...int) models.BoundingPoly {
+		var poly models.BoundingPoly
+		for i := 0; i < len(vertices); i += 2 {
+			poly.Vertices = append(poly.Vertices, models.Vertex{X: vertices[i], Y: vertices[i+1]})
+		}
+		return poly
+	}
+	// a line rising one pixel in ten, with a descender on the p
+	var skewed []models.Symbol
+	for i, text := range []string{"H", "o", "p", "e", "s"} {
+		x, bottom := 100+i*10, 200-i
+		top := bottom - 10
+		if text == "H" {
+			top = bottom - 16
+		}
+		if text == "p" {
+			bottom += 6
+		}
+		skewed = append(skewed, models.Symbol{Text: text, BoundingBox: box(x, top, x+10, top, x+10, bottom, x, bottom)})
+	}
+	// marginalia reading bottom to top, so the tops of the letters face left
+	var turned []models.Symbol
+	for i, text := range []string{"n", "o", "t", "e"} {
+		y := 500 - i*10
+		turned = append(turned, models.Symbol{Text: text, BoundingBox: box(20, y, 20, y-10, 30, y-10, 30, y)})
+	}
+	response := models.GCVResponse{Responses: []models.Response{{
+		FullTextAnnotation: &models.FullTextAnnotation{Pages: []models.Page{{
+			Width:  600,
+			Height: 800,
+			Blocks: []models.Block{
+				{BlockType: "TEXT", Paragraphs: []models.Paragraph{{Words: []models.Word{{BoundingBox: box(100, 180, 150, 206, 100, 206, 150, 180), Symbols: skewed}}}}},
+				{BlockType: "TEXT", Paragraphs: []models.Paragraph{{Words: []models.Word{{BoundingBox: box(20, 500, 20, 460, 30, 460, 30, 500), Symbols: turned}}}}},
+			},
+		}}},
+	}}}
+
+	hocrXML, err := hocr.NewConverter().ConvertToHOCR(response)
+	if err != nil {
+		t.Fatalf("Error converting: %v", err)
+	}
+	for _, expected := range []string{
+		"title='bbox 100 180 150 206; baseline -0.1 -5.5; x_size 22; x_descenders 6; x_ascenders 6'",
+		"title='bbox 20 460 30 500; textangle 90; baseline 0 0; x_size 10'",
+	} {
+		if !strings.Contains(hocrXML, expected) {
+			t.Errorf("Expected %s, got:\n%s", expected, hocrXML)
+		}
+	}
+
+	pages, err := parser.ParseHOCRPages(hocrXML)
+	if err != nil {
+		t.Fatalf("Error parsing hOCR: %v", err)
+	}
+	canonical, err := hocr.CanonicalPages(pages)
+	if err != nil {
+		t.Fatalf("Error canonicalizing: %v", err)
+	}
+	if rewritten := hocr.NewConverter().ConvertHOCRPagesToXML(canonical); rewritten != hocrXML {
+		t.Errorf("Expected the line geometry to survive a save, got:\n%s", rewritten)
+	}
+}
+
+func TestConverterPolys(t *testing.T) {
+	box := func(vertices ...int) models.BoundingPoly {
+		var poly models.BoundingPoly
+		for i := 0; i < len(vertices); i += 2 {
+			poly.Vertices = append(poly.Vertices, models.Vertex{X: vertices[i], Y: vertices[i+1]})
+		}
+		return poly
+	}
+	response := models.GCVResponse{Responses: []models.Response{{
+		FullTextAnnotation: &models.FullTextAnnotation{Pages: []models.Page{{
+			Width:  500,
+			Height: 400,
+			Blocks: []models.Block{
+				{BlockType: "TEXT", Paragraphs: []models.Paragraph{{
+					BoundingBox: box(98, 108, 204, 86, 206, 112, 100, 134),
+					Words: []models.Word{
+						{BoundingBox: box(100, 110, 150, 100, 152, 120, 102, 130), Symbols: []models.Symbol{{Text: "Hello"}}},
+						{BoundingBox: box(160, 98, 200, 90, 202, 110, 162, 118), Symbols: []models.Symbol{{Text: "there"}}},
+					},
+				}}},
+				{BlockType: "TEXT", Paragraphs: []models.Paragraph{{Words: []models.Word{
+					{BoundingBox: box(40, 300, 90, 300, 90, 320, 40, 320), Symbols: []models.Symbol{{Text: "level"}}},
+				}}}},
+			},
+		}}},
+	}}}
+
+	hocrXML, err := hocr.NewConverter().ConvertToHOCR(response)
+	if err != nil {
+		t.Fatalf("Error converting: %v", err)
+	}
+	for _, expected := range []string{
+		"title='bbox 98 86 206 134; poly 98 108 204 86 206 112 100 134'",
+		"title='bbox 100 90 202 130; poly 100 110 200 90 202 110 102 130",
+		"title='bbox 100 100 152 130; poly 100 110 150 100 152 120 102 130; x_wconf 95'",
+		"title='bbox 40 300 90 320; x_wconf 95'",
+	} {
+		if !strings.Contains(hocrXML, expected) {
+			t.Errorf("Expected %s, got:\n%s", expected, hocrXML)
+		}
+	}
+
+	pages, err := parser.ParseHOCRPages(hocrXML)
+	if err != nil {
+		t.Fatalf("Error parsing hOCR: %v", err)
+	}
+	canonical, err := hocr.CanonicalPages(pages)
+	if err != nil {
+		t.Fatalf("Error canonicalizing: %v", err)
+	}
+	if rewritten := hocr.NewConverter().ConvertHOCRPagesToXML(canonical); rewritten != hocrXML {
+		t.Errorf("Expected the polys to survive a save, got:\n%s", rewritten)
+	}
+
+	words, err := hocr.SplitWord(pages, "word_1", []string{"He", "llo"})
+	if err != nil {
+		t.Fatalf("Error splitting: %v", err)
+	}
+	expected := []models.Point{{X: 100, Y: 110}, {X: 120, Y: 106}, {X: 122, Y: 126}, {X: 102, Y: 130}}
+	if !slices.Equal(words[0].Poly, expected) || words[0].BBox != (models.BBox{X1: 100, Y1: 106, X2: 122, Y2: 130}) {
+		t.Errorf("Expected the poly divided along its slant, got %v in %+v", words[0].Poly, words[0].BBox)
+	}
+
+	bbox := models.BBox{X1: 300, Y1: 300, X2: 340, Y2: 320}
+	edited, err := hocr.EditWord(pages, "word_2", hocr.WordEdit{BBox: &bbox})
+	if err != nil {
+		t.Fatalf("Error editing: %v", err)
+	}
+	if edited.Poly != nil {
+		t.Errorf("Expected a moved word to lose its poly, got %v", edited.Poly)
+	}
+}
@@ -0,0 +1,70 @@
+package hocr
+
+import (
+	"github.com/lehigh-university-libraries/hocr-edit/internal/models"
+	"github.com/lehigh-university-libraries/hocr-edit/pkg/metrics"
+)
+
+// DiffMove marks a word whose text is unchanged but whose bbox was moved
+const DiffMove = "move"
+
+// WordChange is one difference between two versions of a document. From is nil
+// for inserted words and To is nil for deleted ones.
+type WordChange struct {
+	Op   string           `json:"op"`
+	From *models.HOCRWord `json:"from,omitempty"`
+	To   *models.HOCRWord `json:"to,omitempty"`
+}
+
+// DiffSummary counts the changes of a diff by operation
+type DiffSummary struct {
+	Unchanged     int `json:"unchanged"`
+	Substitutions int `json:"substitutions"`
+	Deletions     int `json:"deletions"`
+	Insertions    int `json:"insertions"`
+	Moves         int `json:"moves"`
+}
+
+// DiffWords aligns the words of two versions of a document by their text, in
+// reading order, and returns what changed between them
+func DiffWords(from, to []models.HOCRWord) ([]WordChange, DiffSummary) {
+	fromTexts := make([]string, len(from))
+	for i, word := range from {
+		fromTexts[i] = word.Text
+	}
+	toTexts := make([]string, len(to))
+	for i, word := range to {
+		toTexts[i] = word.Text
+	}
+
+	changes := []WordChange{}
+	var summary DiffSummary
+	for _, alignment := range metrics.AlignWords(fromTexts, toTexts) {
+		change := WordChange{Op: alignment.Op}
+		if alignment.From >= 0 {
+			change.From = &from[alignment.From]
+		}
+		if alignment.To >= 0 {
+			change.To = &to[alignment.To]
+		}
+
+		switch alignment.Op {
+		case metrics.AlignEqual:
+			if change.From.BBox == change.To.BBox {
+				summary.Unchanged++
+				continue
+			}
+			change.Op = DiffMove
+			summary.Moves++
+		case metrics.AlignSubstitute:
+			summary.Substitutions++
+		case metrics.AlignDelete:
+			summary.Deletions++
+		case metrics.AlignInsert:
+			summary.Insertions++
+		}
+		changes = append(changes, change)
+	}
+
+	return changes, summary
+}
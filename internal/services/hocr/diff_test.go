@@ -0,0 +1,40 @@
+package hocr_test
+
+import (
+	"testing"
+
+	"github.com/lehigh-university-libraries/hocr-edit/internal/models"
+	"github.com/lehigh-university-libraries/hocr-edit/internal/services/hocr"
+)
+
+func TestDiffWords(t *testing.T) {
+	from := []models.HOCRWord{
+		word("w1", "l", "Dear", 10, 10, 50, 30),
+		word("w2", "l", "Sri", 60, 10, 90, 30),
+		word("w3", "l", "yours", 100, 10, 150, 30),
+	}
+	to := []models.HOCRWord{
+		word("w1", "l", "Dear", 12, 10, 50, 30),
+		word("w2", "l", "Sir", 60, 10, 90, 30),
+		word("w3", "l", "yours", 100, 10, 150, 30),
+		word("w4", "l", "truly", 160, 10, 200, 30),
+	}
+
+	changes, summary := hocr.DiffWords(from, to)
+
+	want := []string{hocr.DiffMove, "substitute", "insert"}
+	if len(changes) != len(want) {
+		t.Fatalf("Expected %d changes, got %+v", len(want), changes)
+	}
+	for i, op := range want {
+		if changes[i].Op != op {
+			t.Errorf("Change %d: expected %s, got %s", i, op, changes[i].Op)
+		}
+	}
+	if changes[2].From != nil || changes[2].To.Text != "truly" {
+		t.Errorf("Expected the insertion to have only a to word, got %+v", changes[2])
+	}
+	if summary != (hocr.DiffSummary{Unchanged: 1, Substitutions: 1, Insertions: 1, Moves: 1}) {
+		t.Errorf("Unexpected summary %+v", summary)
+	}
+}
@@ -0,0 +1,423 @@
+package hocr
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/lehigh-university-libraries/hocr-edit/internal/models"
+)
+
+// ErrNotFound is returned when an edit addresses a word or line the document does not have
+var ErrNotFound = errors.New("not found")
+
+// WordEdit changes the fields of a word that are set
+type WordEdit struct {
+	Text       *string      `json:"text"`
+	BBox       *models.BBox `json:"bbox"`
+	Confidence *float64     `json:"confidence"`
+}
+
+// The edit functions below change pages in place. They leave line and paragraph
+// bboxes and word order to CanonicalPages, which also validates the result.
+
+// EditWord applies edit to a word and returns the word as changed
+func EditWord(pages []models.HOCRPage, wordID string, edit WordEdit) (models.HOCRWord, error) {
+	word, err := findWord(pages, wordID)
+	if err != nil {
+		return models.HOCRWord{}, err
+	}
+
+	if edit.Text != nil {
+		setWordText(word, *edit.Text)
+	}
+	if edit.BBox != nil {
+		if *edit.BBox != word.BBox {
+			word.Poly = nil
+		}
+		word.BBox = *edit.BBox
+	}
+	if edit.Confidence != nil {
+		word.Confidence = *edit.Confidence
+	}
+
+	return *word, nil
+}
+
+// setWordText changes the text of a word. Character confidences belong to the old
+// text and are dropped, and character boxes are marked stale once they no longer
+// match the number of characters.
+func setWordText(word *models.HOCRWord, text string) {
+	if text != word.Text {
+		word.CharConfidences = nil
+	}
+	if utf8.RuneCountInString(text) != utf8.RuneCountInString(word.Text) && len(word.CharBoxes) > 0 {
+		word.CharBoxesStale = true
+	}
+	word.Text = text
+}
+
+// DeleteWord removes a word, and its line when it was the last word on it
+func DeleteWord(pages []models.HOCRPage, wordID string) error {
+	line, index, err := findWordInLine(pages, wordID)
+	if err != nil {
+		return err
+	}
+	line.Words = slices.Delete(line.Words, index, index+1)
+	return nil
+}
+
+// SplitWord replaces a word with one word per text, dividing its bbox in proportion
+// to their lengths. A word outlined by a four point poly has the poly divided along
+// its slant instead, and each word is bounded by its part. The first word keeps the
+// original ID.
+func SplitWord(pages []models.HOCRPage, wordID string, texts []string) ([]models.HOCRWord, error) {
+	if len(texts) < 2 {
+		return nil, fmt.Errorf("a split needs at least two texts")
+	}
+
+	line, index, err := findWordInLine(pages, wordID)
+	if err != nil {
+		return nil, err
+	}
+
+	original := line.Words[index]
+	boxes := spread(original.BBox, texts, false)
+	var polys [][]models.Point
+	if len(original.Poly) == 4 {
+		polys = spreadPoly(original.Poly, texts)
+	}
+	charConfidences, charBoxes := original.CharConfidences, original.CharBoxes
+	if !hasCharConfidences(original) || strings.Join(texts, "") != original.Text {
+		charConfidences = nil
+	}
+	if !hasCharBoxes(original) || strings.Join(texts, "") != original.Text {
+		charBoxes = nil
+	}
+	words := make([]models.HOCRWord, len(texts))
+	for i, text := range texts {
+		words[i] = original
+		words[i].Text = text
+		words[i].BBox, words[i].Poly = boxes[i], nil
+		if polys != nil {
+			words[i].BBox, words[i].Poly = polyBounds(polys[i]), polys[i]
+		}
+		words[i].CharConfidences, words[i].CharBoxes, words[i].CharBoxesStale = nil, nil, false
+		n := utf8.RuneCountInString(text)
+		if charConfidences != nil {
+			words[i].CharConfidences = slices.Clone(charConfidences[:n])
+			charConfidences = charConfidences[n:]
+		}
+		if charBoxes != nil {
+			words[i].CharBoxes = slices.Clone(charBoxes[:n])
+			charBoxes = charBoxes[n:]
+		}
+		if i > 0 {
+			words[i].ID = uniqueID(pages, "word", words[:i]...)
+		}
+	}
+
+	line.Words = slices.Replace(line.Words, index, index+1, words...)
+	return words, nil
+}
+
+// MergeWords joins a word with the next or previous word on its line. The merged
+// word keeps the ID of the leftmost, covers both bboxes and takes the lower confidence.
+// When either word has a poly the merged word is outlined from the left edge of the
+// first to the right edge of the second.
+func MergeWords(pages []models.HOCRPage, wordID, withID string) (models.HOCRWord, error) {
+	line, _, err := findWordInLine(pages, wordID)
+	if err != nil {
+		return models.HOCRWord{}, err
+	}
+	otherLine, _, err := findWordInLine(pages, withID)
+	if err != nil {
+		return models.HOCRWord{}, err
+	}
+	if otherLine != line {
+		return models.HOCRWord{}, fmt.Errorf("words %s and %s are on different lines", wordID, withID)
+	}
+
+	orderLineWords(line)
+	index, otherIndex := wordIndex(line, wordID), wordIndex(line, withID)
+	if index-otherIndex != 1 && otherIndex-index != 1 {
+		return models.HOCRWord{}, fmt.Errorf("words %s and %s are not next to each other", wordID, withID)
+	}
+
+	first, second := min(index, otherIndex), max(index, otherIndex)
+	merged := line.Words[first]
+	merged.CharConfidences = nil
+	if hasCharConfidences(line.Words[first]) && hasCharConfidences(line.Words[second]) {
+		merged.CharConfidences = slices.Concat(line.Words[first].CharConfidences, line.Words[second].CharConfidences)
+	}
+	merged.CharBoxes, merged.CharBoxesStale = nil, false
+	if len(line.Words[first].CharBoxes) > 0 && len(line.Words[second].CharBoxes) > 0 {
+		merged.CharBoxes = slices.Concat(line.Words[first].CharBoxes, line.Words[second].CharBoxes)
+		merged.CharBoxesStale = line.Words[first].CharBoxesStale || line.Words[second].CharBoxesStale
+	}
+	merged.Poly = nil
+	if len(line.Words[first].Poly) == 4 || len(line.Words[second].Poly) == 4 {
+		merged.Poly = joinOutlines(outline(line.Words[first]), outline(line.Words[second]))
+	}
+	merged.Text += line.Words[second].Text
+	merged.BBox = unionBBoxes([]models.BBox{merged.BBox, line.Words[second].BBox})
+	merged.Confidence = min(merged.Confidence, line.Words[second].Confidence)
+
+	line.Words[first] = merged
+	line.Words = slices.Delete(line.Words, second, second+1)
+	return merged, nil
+}
+
+// hasCharConfidences reports whether a word has one character confidence per character
+func hasCharConfidences(word models.HOCRWord) bool {
+	return len(word.CharConfidences) > 0 && len(word.CharConfidences) == utf8.RuneCountInString(word.Text)
+}
+
+// hasCharBoxes reports whether a word has current boxes for each of its characters
+func hasCharBoxes(word models.HOCRWord) bool {
+	return len(word.CharBoxes) > 0 && !word.CharBoxesStale && len(word.CharBoxes) == utf8.RuneCountInString(word.Text)
+}
+
+// InsertWord adds a word to a line and returns it with its new ID
+func InsertWord(pages []models.HOCRPage, lineID string, word models.HOCRWord) (models.HOCRWord, error) {
+	line, err := findLine(pages, lineID)
+	if err != nil {
+		return models.HOCRWord{}, err
+	}
+
+	word.ID = uniqueID(pages, "word")
+	word.LineID = line.ID
+	line.Words = append(line.Words, word)
+	return word, nil
+}
+
+// InsertLine adds a line of text spread across bbox. It goes after the line afterID
+// in the same paragraph, or in an area of its own at the end of the first page when
+// afterID is empty.
+func InsertLine(pages []models.HOCRPage, text string, bbox models.BBox, confidence float64, afterID string) (models.HOCRLine, error) {
+	if len(pages) == 0 {
+		return models.HOCRLine{}, fmt.Errorf("document has no pages")
+	}
+
+	line := models.HOCRLine{ID: uniqueID(pages, "line"), BBox: bbox}
+	line.Words = lineWords(pages, line.ID, text, bbox, confidence, nil)
+	if len(line.Words) == 0 {
+		return models.HOCRLine{}, fmt.Errorf("a line needs some text")
+	}
+
+	if afterID == "" {
+		line.PageID = pages[0].ID
+		pages[0].Areas = append(pages[0].Areas, models.HOCRArea{
+			Paragraphs: []models.HOCRParagraph{{Lines: []models.HOCRLine{line}}},
+		})
+		return line, nil
+	}
+
+	for p := range pages {
+		for a := range pages[p].Areas {
+			for r := range pages[p].Areas[a].Paragraphs {
+				paragraph := &pages[p].Areas[a].Paragraphs[r]
+				for l := range paragraph.Lines {
+					if paragraph.Lines[l].ID != afterID {
+						continue
+					}
+					line.PageID = pages[p].ID
+					paragraph.Lines = slices.Insert(paragraph.Lines, l+1, line)
+					return line, nil
+				}
+			}
+		}
+	}
+
+	return models.HOCRLine{}, fmt.Errorf("line %s: %w", afterID, ErrNotFound)
+}
+
+// EditLine replaces the text of a line. When the number of words is unchanged each
+// word keeps its bbox; otherwise the words are spread across the line's bbox.
+func EditLine(pages []models.HOCRPage, lineID, text string) (models.HOCRLine, error) {
+	line, err := findLine(pages, lineID)
+	if err != nil {
+		return models.HOCRLine{}, err
+	}
+
+	orderLineWords(line)
+	texts := strings.Fields(text)
+	if len(texts) == len(line.Words) {
+		for i := range line.Words {
+			setWordText(&line.Words[i], texts[i])
+		}
+		return *line, nil
+	}
+
+	confidence := 100.0
+	existingIDs := make([]string, len(line.Words))
+	for i, word := range line.Words {
+		existingIDs[i] = word.ID
+		confidence = min(confidence, word.Confidence)
+	}
+	line.Words = lineWords(pages, line.ID, text, line.BBox, confidence, existingIDs)
+	return *line, nil
+}
+
+// DeleteLine removes a line and all of its words
+func DeleteLine(pages []models.HOCRPage, lineID string) error {
+	for p := range pages {
+		for a := range pages[p].Areas {
+			for r := range pages[p].Areas[a].Paragraphs {
+				paragraph := &pages[p].Areas[a].Paragraphs[r]
+				for l := range paragraph.Lines {
+					if paragraph.Lines[l].ID == lineID {
+						paragraph.Lines = slices.Delete(paragraph.Lines, l, l+1)
+						return nil
+					}
+				}
+			}
+		}
+	}
+	return fmt.Errorf("line %s: %w", lineID, ErrNotFound)
+}
+
+// lineWords makes one word per whitespace separated text, reusing ids in order
+// before generating new ones
+func lineWords(pages []models.HOCRPage, lineID, text string, bbox models.BBox, confidence float64, ids []string) []models.HOCRWord {
+	texts := strings.Fields(text)
+	boxes := spread(bbox, texts, true)
+
+	words := make([]models.HOCRWord, len(texts))
+	for i, wordText := range texts {
+		words[i] = models.HOCRWord{Text: wordText, BBox: boxes[i], Confidence: confidence, LineID: lineID}
+		if i < len(ids) {
+			words[i].ID = ids[i]
+		}
+	}
+
+	// generate the missing IDs once the reused ones are out of the way
+	for i := range words {
+		if words[i].ID == "" {
+			words[i].ID = uniqueID(pages, "word", words...)
+		}
+	}
+	return words
+}
+
+// spread divides a bbox horizontally in proportion to the length of each text,
+// counting a one character gap between texts when spaced is set
+func spread(bbox models.BBox, texts []string, spaced bool) []models.BBox {
+	gap := 0
+	if spaced {
+		gap = 1
+	}
+
+	total := 0
+	for _, text := range texts {
+		total += utf8.RuneCountInString(text)
+	}
+	total += gap * max(len(texts)-1, 0)
+	if total == 0 {
+		total = 1
+	}
+
+	width := float64(bbox.X2 - bbox.X1)
+	boxes := make([]models.BBox, len(texts))
+	offset := 0
+	for i, text := range texts {
+		length := utf8.RuneCountInString(text)
+		boxes[i] = models.BBox{
+			X1: bbox.X1 + int(width*float64(offset)/float64(total)+0.5),
+			Y1: bbox.Y1,
+			X2: bbox.X1 + int(width*float64(offset+length)/float64(total)+0.5),
+			Y2: bbox.Y2,
+		}
+		offset += length + gap
+	}
+	return boxes
+}
+
+// uniqueID returns the first prefix_N not used by any element of pages or extra
+func uniqueID(pages []models.HOCRPage, prefix string, extra ...models.HOCRWord) string {
+	used := make(map[string]bool)
+	for _, page := range pages {
+		used[page.ID] = true
+		for _, area := range page.Areas {
+			used[area.ID] = true
+			for _, paragraph := range area.Paragraphs {
+				used[paragraph.ID] = true
+				for _, line := range paragraph.Lines {
+					used[line.ID] = true
+					for _, word := range line.Words {
+						used[word.ID] = true
+					}
+				}
+			}
+		}
+	}
+	for _, word := range extra {
+		used[word.ID] = true
+	}
+
+	for n := 1; ; n++ {
+		id := fmt.Sprintf("%s_%d", prefix, n)
+		if !used[id] {
+			return id
+		}
+	}
+}
+
+func findLine(pages []models.HOCRPage, lineID string) (*models.HOCRLine, error) {
+	for p := range pages {
+		for a := range pages[p].Areas {
+			for r := range pages[p].Areas[a].Paragraphs {
+				paragraph := &pages[p].Areas[a].Paragraphs[r]
+				for l := range paragraph.Lines {
+					if paragraph.Lines[l].ID == lineID {
+						return &paragraph.Lines[l], nil
+					}
+				}
+			}
+		}
+	}
+	return nil, fmt.Errorf("line %s: %w", lineID, ErrNotFound)
+}
+
+func findWordInLine(pages []models.HOCRPage, wordID string) (*models.HOCRLine, int, error) {
+	for p := range pages {
+		for a := range pages[p].Areas {
+			for r := range pages[p].Areas[a].Paragraphs {
+				paragraph := &pages[p].Areas[a].Paragraphs[r]
+				for l := range paragraph.Lines {
+					if index := wordIndex(&paragraph.Lines[l], wordID); index >= 0 {
+						return &paragraph.Lines[l], index, nil
+					}
+				}
+			}
+		}
+	}
+	return nil, -1, fmt.Errorf("word %s: %w", wordID, ErrNotFound)
+}
+
+func findWord(pages []models.HOCRPage, wordID string) (*models.HOCRWord, error) {
+	line, index, err := findWordInLine(pages, wordID)
+	if err != nil {
+		return nil, err
+	}
+	return &line.Words[index], nil
+}
+
+func wordIndex(line *models.HOCRLine, wordID string) int {
+	for i, word := range line.Words {
+		if word.ID == wordID {
+			return i
+		}
+	}
+	return -1
+}
+
+// orderLineWords sorts a line's words left to right, as CanonicalPage will
+func orderLineWords(line *models.HOCRLine) {
+	sort.SliceStable(line.Words, func(i, j int) bool {
+		return compareBoxes(line.Words[i].BBox, line.Words[i].ID, line.Words[j].BBox, line.Words[j].ID, false)
+	})
+}
@@ -0,0 +1,322 @@
+package hocr
+
+import (
+	"math"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/lehigh-university-libraries/hocr-edit/internal/models"
+)
+
+// xHeightLetters are lowercase letters without ascenders or descenders, so their
+// tops mark the x-height of a line
+const xHeightLetters = "acemnorsuvwxz"
+
+type point struct {
+	x, y float64
+}
+
+// glyph is a symbol, or a word GCV gave no symbol boxes for, turned upright
+type glyph struct {
+	text        string
+	x           float64
+	top, bottom float64
+}
+
+// measureLine works out the textangle, baseline and sizes of a line from the
+// vertices GCV reports for its words and symbols. GCV lists vertices clockwise from
+// the top left corner of the text as it is read, so the first two run along the
+// top of a word however it is turned. As in Tesseract, textangle is rounded to a
+// quarter turn and any remaining skew goes into the baseline slope.
+func measureLine(line *models.HOCRLine, words []models.Word) {
+	var shapes [][]models.Vertex
+	var texts []string
+	for _, word := range words {
+		unboxed := slices.ContainsFunc(word.Symbols, func(symbol models.Symbol) bool {
+			return len(symbol.BoundingBox.Vertices) != 4
+		})
+		if len(word.Symbols) > 0 && !unboxed {
+			for _, symbol := range word.Symbols {
+				shapes = append(shapes, symbol.BoundingBox.Vertices)
+				texts = append(texts, symbol.Text)
+			}
+		} else if len(word.BoundingBox.Vertices) == 4 {
+			shapes = append(shapes, word.BoundingBox.Vertices)
+			texts = append(texts, "")
+		}
+	}
+	if len(shapes) == 0 {
+		return
+	}
+
+	var dx, dy float64
+	for _, shape := range shapes {
+		dx += float64(shape[1].X - shape[0].X)
+		dy += float64(shape[1].Y - shape[0].Y)
+	}
+	// image y runs down, so a counterclockwise angle has a negative dy
+	quarter := (int(math.Round(math.Atan2(-dy, dx)*180/math.Pi/90)) + 4) % 4
+	line.TextAngle = float64(quarter * 90)
+
+	upright := uprightFunc(quarter)
+
+	glyphs := make([]glyph, len(shapes))
+	for i, shape := range shapes {
+		g := glyph{text: texts[i], top: math.Inf(1), bottom: math.Inf(-1)}
+		for _, vertex := range shape {
+			p := upright(float64(vertex.X), float64(vertex.Y))
+			g.x += p.x / 4
+			g.top = min(g.top, p.y)
+			g.bottom = max(g.bottom, p.y)
+		}
+		glyphs[i] = g
+	}
+
+	// fit the baseline to the bottoms of the glyphs with medians, so letters with
+	// descenders do not pull it down
+	var slopes []float64
+	for i := range glyphs {
+		for j := i + 1; j < len(glyphs); j++ {
+			if run := glyphs[j].x - glyphs[i].x; math.Abs(run) >= 1 {
+				slopes = append(slopes, (glyphs[j].bottom-glyphs[i].bottom)/run)
+			}
+		}
+	}
+	slope := median(slopes)
+	intercepts := make([]float64, len(glyphs))
+	for i, g := range glyphs {
+		intercepts[i] = g.bottom - slope*g.x
+	}
+	intercept := median(intercepts)
+
+	var ascent, descent float64
+	var xHeights []float64
+	for _, g := range glyphs {
+		baseline := slope*g.x + intercept
+		ascent = max(ascent, baseline-g.top)
+		descent = max(descent, g.bottom-baseline)
+		if len(g.text) == 1 && strings.Contains(xHeightLetters, g.text) {
+			xHeights = append(xHeights, baseline-g.top)
+		}
+	}
+
+	// the baseline is relative to the bottom left corner of the upright bbox
+	left, bottom := bottomLeft(line.BBox, upright)
+	line.Baseline = &models.Baseline{Slope: slope, Offset: slope*left + intercept - bottom}
+	line.XSize = ascent + descent
+	line.XDescenders = descent
+	if xHeight := median(xHeights); xHeight > 0 && ascent > xHeight {
+		line.XAscenders = ascent - xHeight
+	}
+}
+
+// keepLineGeometry copies the textangle, baseline and sizes of a line as it was
+// loaded onto the same line rebuilt from edited words. The baseline offset is moved
+// with the bbox so the baseline stays where it was on the image.
+func keepLineGeometry(line *models.HOCRLine, loaded models.HOCRLine) {
+	line.TextAngle = loaded.TextAngle
+	line.XSize = loaded.XSize
+	line.XDescenders = loaded.XDescenders
+	line.XAscenders = loaded.XAscenders
+	if loaded.Baseline == nil {
+		return
+	}
+
+	upright := uprightFunc(int(math.Round(loaded.TextAngle / 90)))
+	oldLeft, oldBottom := bottomLeft(loaded.BBox, upright)
+	newLeft, newBottom := bottomLeft(line.BBox, upright)
+	slope := loaded.Baseline.Slope
+	line.Baseline = &models.Baseline{
+		Slope:  slope,
+		Offset: oldBottom + loaded.Baseline.Offset + slope*(newLeft-oldLeft) - newBottom,
+	}
+}
+
+// uprightFunc turns points by a number of quarter turns so a line at that
+// textangle reads left to right, with y still running down
+func uprightFunc(quarter int) func(x, y float64) point {
+	quarter = (quarter%4 + 4) % 4
+	return func(x, y float64) point {
+		switch quarter {
+		case 1:
+			return point{-y, x}
+		case 2:
+			return point{-x, -y}
+		case 3:
+			return point{y, -x}
+		}
+		return point{x, y}
+	}
+}
+
+// bottomLeft is the bottom left corner of a bbox once it is turned upright
+func bottomLeft(bbox models.BBox, upright func(x, y float64) point) (left, bottom float64) {
+	left, bottom = math.Inf(1), math.Inf(-1)
+	for _, corner := range []point{
+		upright(float64(bbox.X1), float64(bbox.Y1)),
+		upright(float64(bbox.X2), float64(bbox.Y1)),
+		upright(float64(bbox.X1), float64(bbox.Y2)),
+		upright(float64(bbox.X2), float64(bbox.Y2)),
+	} {
+		left = min(left, corner.x)
+		bottom = max(bottom, corner.y)
+	}
+	return left, bottom
+}
+
+// median is the middle value of values, or 0 when there are none
+func median(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := slices.Clone(values)
+	slices.Sort(sorted)
+	middle := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[middle-1] + sorted[middle]) / 2
+	}
+	return sorted[middle]
+}
+
+// gcvPoly keeps the vertices of a GCV bounding poly as an hOCR poly, unless they
+// only trace the corners of bbox and so say no more than it does
+func gcvPoly(boundingPoly models.BoundingPoly, bbox models.BBox) []models.Point {
+	if len(boundingPoly.Vertices) < 3 {
+		return nil
+	}
+	poly := make([]models.Point, len(boundingPoly.Vertices))
+	for i, vertex := range boundingPoly.Vertices {
+		poly[i] = models.Point{X: vertex.X, Y: vertex.Y}
+	}
+	if tracesBBox(poly, bbox) {
+		return nil
+	}
+	return poly
+}
+
+// tracesBBox reports whether every point of a poly is a corner of bbox
+func tracesBBox(poly []models.Point, bbox models.BBox) bool {
+	for _, p := range poly {
+		if (p.X != bbox.X1 && p.X != bbox.X2) || (p.Y != bbox.Y1 && p.Y != bbox.Y2) {
+			return false
+		}
+	}
+	return true
+}
+
+// polyBounds is the bbox of a poly
+func polyBounds(poly []models.Point) models.BBox {
+	boxes := make([]models.BBox, len(poly))
+	for i, p := range poly {
+		boxes[i] = models.BBox{X1: p.X, Y1: p.Y, X2: p.X, Y2: p.Y}
+	}
+	return unionBBoxes(boxes)
+}
+
+// outline is the four corners of a word clockwise from the top left of its text:
+// its poly when it has a four point one, or else the corners of its bbox
+func outline(word models.HOCRWord) []models.Point {
+	if len(word.Poly) == 4 {
+		return word.Poly
+	}
+	return []models.Point{
+		{X: word.BBox.X1, Y: word.BBox.Y1},
+		{X: word.BBox.X2, Y: word.BBox.Y1},
+		{X: word.BBox.X2, Y: word.BBox.Y2},
+		{X: word.BBox.X1, Y: word.BBox.Y2},
+	}
+}
+
+// joinOutlines is the outline running from the left edge of first to the right
+// edge of last
+func joinOutlines(first, last []models.Point) []models.Point {
+	return []models.Point{first[0], last[1], last[2], first[3]}
+}
+
+// linePoly outlines a line from the left edge of its first word to the right edge
+// of its last, so a slanted line is not widened to the bbox of its slant. Lines
+// with a textangle are left to their bbox, as are lines whose words have no polys.
+func linePoly(line models.HOCRLine) []models.Point {
+	if line.TextAngle != 0 || !slices.ContainsFunc(line.Words, func(word models.HOCRWord) bool { return len(word.Poly) == 4 }) {
+		return nil
+	}
+	poly := joinOutlines(outline(line.Words[0]), outline(line.Words[len(line.Words)-1]))
+	if tracesBBox(poly, line.BBox) {
+		return nil
+	}
+	return poly
+}
+
+// spreadPoly divides a four point poly along its top and bottom edges in proportion
+// to the length of each text, as spread does a bbox
+func spreadPoly(poly []models.Point, texts []string) [][]models.Point {
+	total := 0
+	for _, text := range texts {
+		total += utf8.RuneCountInString(text)
+	}
+	total = max(total, 1)
+
+	along := func(from, to models.Point, fraction float64) models.Point {
+		return models.Point{
+			X: from.X + int(math.Round(float64(to.X-from.X)*fraction)),
+			Y: from.Y + int(math.Round(float64(to.Y-from.Y)*fraction)),
+		}
+	}
+	polys := make([][]models.Point, len(texts))
+	offset := 0
+	for i, text := range texts {
+		start := float64(offset) / float64(total)
+		offset += utf8.RuneCountInString(text)
+		end := float64(offset) / float64(total)
+		polys[i] = []models.Point{
+			along(poly[0], poly[1], start),
+			along(poly[0], poly[1], end),
+			along(poly[3], poly[2], end),
+			along(poly[3], poly[2], start),
+		}
+	}
+	return polys
+}
+
+// canonicalPoly clamps a poly to the page, and drops it when it reaches outside
+// bbox, as it then outlines where the element was before its bbox was edited
+func canonicalPoly(poly []models.Point, bbox, page models.BBox) []models.Point {
+	if len(poly) < 3 {
+		return nil
+	}
+	clamped := clampPoly(poly, page)
+	for _, p := range clamped {
+		if p.X < bbox.X1 || p.X > bbox.X2 || p.Y < bbox.Y1 || p.Y > bbox.Y2 {
+			return nil
+		}
+	}
+	if tracesBBox(clamped, bbox) {
+		return nil
+	}
+	return clamped
+}
+
+// canonicalRegion keeps the poly of an area or paragraph, clamped to the page, while
+// the bbox recomputed from its words stays within the poly's bounds, which are then
+// its bbox
+func canonicalRegion(poly []models.Point, bbox, page models.BBox) ([]models.Point, models.BBox) {
+	if len(poly) < 3 {
+		return nil, bbox
+	}
+	clamped := clampPoly(poly, page)
+	bounds := polyBounds(clamped)
+	if bbox.X1 < bounds.X1 || bbox.Y1 < bounds.Y1 || bbox.X2 > bounds.X2 || bbox.Y2 > bounds.Y2 || tracesBBox(clamped, bounds) {
+		return nil, bbox
+	}
+	return clamped, bounds
+}
+
+func clampPoly(poly []models.Point, page models.BBox) []models.Point {
+	clamped := make([]models.Point, len(poly))
+	for i, p := range poly {
+		corner := clampBBox(models.BBox{X1: p.X, Y1: p.Y, X2: p.X, Y2: p.Y}, page)
+		clamped[i] = models.Point{X: corner.X1, Y: corner.Y1}
+	}
+	return clamped
+}
@@ -0,0 +1,360 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+type Status string
+
+const (
+	StatusQueued    Status = "queued"
+	StatusRunning   Status = "running"
+	StatusSucceeded Status = "succeeded"
+	StatusFailed    Status = "failed"
+	StatusCanceled  Status = "canceled"
+)
+
+var (
+	// ErrQueueFull is returned by Submit when every slot in the queue is taken
+	ErrQueueFull = errors.New("job queue is full")
+	// ErrNotFound is returned by Cancel for jobs the queue does not know
+	ErrNotFound = errors.New("job not found")
+	// ErrFinished is returned by Cancel for jobs that have already stopped
+	ErrFinished = errors.New("job has already finished")
+)
+
+// Job is a snapshot of a unit of background work. Done and Total report
+// progress for jobs that handle more than one page or node.
+type Job struct {
+	ID         string     `json:"id"`
+	Type       string     `json:"type"`
+	SessionID  string     `json:"session_id"`
+	Status     Status     `json:"status"`
+	Attempts   int        `json:"attempts"`
+	Done       int        `json:"done"`
+	Total      int        `json:"total"`
+	Error      string     `json:"error,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+// Finished reports whether the job has stopped running for good
+func (j Job) Finished() bool {
+	return j.Status == StatusSucceeded || j.Status == StatusFailed || j.Status == StatusCanceled
+}
+
+// ProgressFunc lets a running job report how much of its work is done
+type ProgressFunc func(done, total int)
+
+// RunFunc does the work of a job. Returning an error retries the job until
+// the queue's attempts are used up.
+type RunFunc func(ctx context.Context, progress ProgressFunc) error
+
+type task struct {
+	id  string
+	ctx context.Context
+	run RunFunc
+}
+
+// Queue runs jobs on a fixed number of workers
+type Queue struct {
+	tasks       chan task
+	maxAttempts int
+	backoff     time.Duration
+	retention   time.Duration
+
+	mu          sync.RWMutex
+	jobs        map[string]*Job
+	cancels     map[string]context.CancelFunc
+	subscribers map[string]map[chan Job]struct{}
+	counter     int
+}
+
+// NewQueue starts workers goroutines pulling from a queue that holds up to capacity
+// waiting jobs. Failed jobs are retried up to maxAttempts times, doubling backoff each time.
+// Finished jobs are forgotten once they have been finished for retention.
+func NewQueue(workers, capacity, maxAttempts int, backoff, retention time.Duration) *Queue {
+	q := &Queue{
+		tasks:       make(chan task, capacity),
+		maxAttempts: max(maxAttempts, 1),
+		backoff:     backoff,
+		retention:   retention,
+		jobs:        make(map[string]*Job),
+		cancels:     make(map[string]context.CancelFunc),
+		subscribers: make(map[string]map[chan Job]struct{}),
+	}
+
+	for range max(workers, 1) {
+		go q.work()
+	}
+
+	return q
+}
+
+// Submit queues run and returns the job as it was queued
+func (q *Queue) Submit(jobType, sessionID string, run RunFunc) (Job, error) {
+	q.mu.Lock()
+	q.evict()
+	q.counter++
+	job := &Job{
+		ID:        fmt.Sprintf("job_%d_%d", time.Now().Unix(), q.counter),
+		Type:      jobType,
+		SessionID: sessionID,
+		Status:    StatusQueued,
+		CreatedAt: time.Now(),
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	q.jobs[job.ID] = job
+	q.cancels[job.ID] = cancel
+	snapshot := *job
+	q.mu.Unlock()
+
+	select {
+	case q.tasks <- task{id: job.ID, ctx: ctx, run: run}:
+	default:
+		q.mu.Lock()
+		delete(q.jobs, job.ID)
+		delete(q.cancels, job.ID)
+		q.mu.Unlock()
+		cancel()
+		return Job{}, ErrQueueFull
+	}
+
+	slog.Info("Job queued", "job_id", job.ID, "type", jobType, "session_id", sessionID)
+	return snapshot, nil
+}
+
+// evict forgets jobs that finished more than the retention ago. It is called with
+// the lock held, on every Submit, so the jobs map grows only with recent work.
+func (q *Queue) evict() {
+	cutoff := time.Now().Add(-q.retention)
+	for id, job := range q.jobs {
+		if job.Finished() && job.FinishedAt != nil && job.FinishedAt.Before(cutoff) {
+			delete(q.jobs, id)
+		}
+	}
+}
+
+// Get returns a snapshot of the job
+func (q *Queue) Get(jobID string) (Job, bool) {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	job, exists := q.jobs[jobID]
+	if !exists {
+		return Job{}, false
+	}
+	return *job, true
+}
+
+// Cancel stops a job. A queued job is canceled at once; a running job's context is
+// canceled and the job is reported canceled when its current attempt returns.
+func (q *Queue) Cancel(jobID string) (Job, error) {
+	q.mu.Lock()
+	job, exists := q.jobs[jobID]
+	if !exists {
+		q.mu.Unlock()
+		return Job{}, ErrNotFound
+	}
+	if job.Finished() {
+		snapshot := *job
+		q.mu.Unlock()
+		return snapshot, ErrFinished
+	}
+	cancel := q.cancels[jobID]
+	q.mu.Unlock()
+
+	cancel()
+	q.update(jobID, func(job *Job) {
+		if job.Status == StatusQueued {
+			markCanceled(job)
+		}
+	})
+
+	slog.Info("Job canceled", "job_id", jobID)
+	snapshot, _ := q.Get(jobID)
+	return snapshot, nil
+}
+
+func markCanceled(job *Job) {
+	now := time.Now()
+	job.Status = StatusCanceled
+	job.Error = context.Canceled.Error()
+	job.FinishedAt = &now
+}
+
+// Subscribe returns a channel that receives a snapshot every time the job changes.
+// The channel is closed once the job finishes; cancel stops delivery early.
+func (q *Queue) Subscribe(jobID string) (<-chan Job, func()) {
+	updates := make(chan Job, 16)
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	job, exists := q.jobs[jobID]
+	if !exists || job.Finished() {
+		close(updates)
+		return updates, func() {}
+	}
+
+	if q.subscribers[jobID] == nil {
+		q.subscribers[jobID] = make(map[chan Job]struct{})
+	}
+	q.subscribers[jobID][updates] = struct{}{}
+
+	cancel := func() {
+		q.mu.Lock()
+		defer q.mu.Unlock()
+		if _, ok := q.subscribers[jobID][updates]; ok {
+			delete(q.subscribers[jobID], updates)
+			close(updates)
+		}
+	}
+
+	return updates, cancel
+}
+
+func (q *Queue) work() {
+	for t := range q.tasks {
+		q.runTask(t)
+	}
+}
+
+func (q *Queue) runTask(t task) {
+	defer func() {
+		q.mu.Lock()
+		cancel := q.cancels[t.id]
+		delete(q.cancels, t.id)
+		q.mu.Unlock()
+		if cancel != nil {
+			cancel()
+		}
+	}()
+
+	if t.ctx.Err() != nil {
+		// canceled while it was waiting
+		return
+	}
+
+	progress := func(done, total int) {
+		q.update(t.id, func(job *Job) {
+			job.Done = done
+			job.Total = total
+		})
+	}
+
+	delay := q.backoff
+	var err error
+	for attempt := 1; attempt <= q.maxAttempts; attempt++ {
+		q.update(t.id, func(job *Job) {
+			now := time.Now()
+			job.Status = StatusRunning
+			job.Attempts = attempt
+			if job.StartedAt == nil {
+				job.StartedAt = &now
+			}
+		})
+
+		err = t.run(t.ctx, progress)
+		if err == nil || t.ctx.Err() != nil {
+			break
+		}
+
+		slog.Warn("Job attempt failed", "job_id", t.id, "attempt", attempt, "err", err)
+		q.update(t.id, func(job *Job) {
+			job.Error = err.Error()
+		})
+		if attempt < q.maxAttempts {
+			select {
+			case <-time.After(delay):
+			case <-t.ctx.Done():
+			}
+			delay *= 2
+		}
+	}
+
+	q.update(t.id, func(job *Job) {
+		if t.ctx.Err() != nil {
+			markCanceled(job)
+			return
+		}
+		now := time.Now()
+		job.FinishedAt = &now
+		if err != nil {
+			job.Status = StatusFailed
+			job.Error = err.Error()
+			return
+		}
+		job.Status = StatusSucceeded
+		job.Error = ""
+	})
+
+	if t.ctx.Err() != nil {
+		return
+	}
+	if err != nil {
+		slog.Error("Job failed", "job_id", t.id, "err", err)
+	} else {
+		slog.Info("Job finished", "job_id", t.id)
+	}
+}
+
+// update changes a job under the lock and fans the new snapshot out to subscribers
+func (q *Queue) update(jobID string, change func(job *Job)) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	job, exists := q.jobs[jobID]
+	if !exists {
+		return
+	}
+	change(job)
+
+	for updates := range q.subscribers[jobID] {
+		select {
+		case updates <- *job:
+		default:
+			// a slow subscriber only misses intermediate snapshots
+		}
+		if job.Finished() {
+			close(updates)
+		}
+	}
+	if job.Finished() {
+		delete(q.subscribers, jobID)
+	}
+}
+
+// New builds a queue sized by OCR_WORKERS (default 2), OCR_QUEUE_SIZE (default 100)
+// and OCR_JOB_ATTEMPTS (default 3), retrying after 2s, 4s, 8s... Finished jobs are
+// kept for OCR_JOB_RETENTION_HOURS (default 24).
+func New() *Queue {
+	return NewQueue(
+		envInt("OCR_WORKERS", 2),
+		envInt("OCR_QUEUE_SIZE", 100),
+		envInt("OCR_JOB_ATTEMPTS", 3),
+		2*time.Second,
+		time.Duration(envInt("OCR_JOB_RETENTION_HOURS", 24))*time.Hour,
+	)
+}
+
+func envInt(name string, fallback int) int {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 {
+		slog.Warn("Ignoring invalid environment variable", "name", name, "value", value)
+		return fallback
+	}
+	return n
+}
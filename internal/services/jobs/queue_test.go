@@ -0,0 +1,189 @@
+package jobs_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/lehigh-university-libraries/hocr-edit/internal/services/jobs"
+)
+
+func waitForJob(t *testing.T, q *jobs.Queue, jobID string) jobs.Job {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if job, _ := q.Get(jobID); job.Finished() {
+			return job
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("Job %s did not finish", jobID)
+	return jobs.Job{}
+}
+
+func TestQueueRetriesFailedJobs(t *testing.T) {
+	q := jobs.NewQueue(1, 10, 3, time.Millisecond, time.Hour)
+
+	calls := 0
+	job, err := q.Submit("test", "session_1", func(_ context.Context, progress jobs.ProgressFunc) error {
+		calls++
+		if calls < 3 {
+			return errors.New("vision unavailable")
+		}
+		progress(1, 1)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Error submitting job: %v", err)
+	}
+	if job.Status != jobs.StatusQueued {
+		t.Errorf("Expected queued job, got %s", job.Status)
+	}
+
+	job = waitForJob(t, q, job.ID)
+	if job.Status != jobs.StatusSucceeded || job.Attempts != 3 || job.Error != "" {
+		t.Errorf("Expected success on the third attempt, got %+v", job)
+	}
+	if job.Done != 1 || job.Total != 1 {
+		t.Errorf("Expected progress 1 of 1, got %d of %d", job.Done, job.Total)
+	}
+}
+
+func TestQueueReportsFailure(t *testing.T) {
+	q := jobs.NewQueue(1, 10, 2, time.Millisecond, time.Hour)
+
+	job, err := q.Submit("test", "session_1", func(context.Context, jobs.ProgressFunc) error {
+		return errors.New("bad image")
+	})
+	if err != nil {
+		t.Fatalf("Error submitting job: %v", err)
+	}
+
+	job = waitForJob(t, q, job.ID)
+	if job.Status != jobs.StatusFailed || job.Attempts != 2 || job.Error != "bad image" {
+		t.Errorf("Expected failure after two attempts, got %+v", job)
+	}
+}
+
+func TestSubscribeClosesWhenJobFinishes(t *testing.T) {
+	q := jobs.NewQueue(1, 10, 1, time.Millisecond, time.Hour)
+
+	release := make(chan struct{})
+	job, err := q.Submit("test", "session_1", func(context.Context, jobs.ProgressFunc) error {
+		<-release
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Error submitting job: %v", err)
+	}
+
+	updates, cancel := q.Subscribe(job.ID)
+	defer cancel()
+	close(release)
+
+	var last jobs.Job
+	for update := range updates {
+		last = update
+	}
+	if last.Status != jobs.StatusSucceeded {
+		t.Errorf("Expected the last update to be succeeded, got %s", last.Status)
+	}
+}
+
+func TestSubmitRejectsWhenFull(t *testing.T) {
+	q := jobs.NewQueue(1, 1, 1, time.Millisecond, time.Hour)
+
+	block := make(chan struct{})
+	defer close(block)
+	run := func(context.Context, jobs.ProgressFunc) error {
+		<-block
+		return nil
+	}
+
+	// the first job may already be on the worker, so keep submitting until the buffer is full
+	var err error
+	for range 3 {
+		if _, err = q.Submit("test", "session_1", run); err != nil {
+			break
+		}
+	}
+	if !errors.Is(err, jobs.ErrQueueFull) {
+		t.Errorf("Expected ErrQueueFull, got %v", err)
+	}
+}
+
+func TestQueueCancel(t *testing.T) {
+	q := jobs.NewQueue(1, 10, 3, time.Millisecond, time.Hour)
+
+	started := make(chan struct{})
+	running, err := q.Submit("test", "session_1", func(ctx context.Context, _ jobs.ProgressFunc) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	if err != nil {
+		t.Fatalf("Error submitting job: %v", err)
+	}
+	ran := false
+	waiting, err := q.Submit("test", "session_2", func(context.Context, jobs.ProgressFunc) error {
+		ran = true
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Error submitting job: %v", err)
+	}
+	<-started
+
+	job, err := q.Cancel(waiting.ID)
+	if err != nil || job.Status != jobs.StatusCanceled {
+		t.Errorf("Expected the waiting job to be canceled at once, got %s, %v", job.Status, err)
+	}
+
+	if _, err := q.Cancel(running.ID); err != nil {
+		t.Fatalf("Error canceling running job: %v", err)
+	}
+	job = waitForJob(t, q, running.ID)
+	if job.Status != jobs.StatusCanceled || job.Attempts != 1 {
+		t.Errorf("Expected the running job to stop after one attempt, got %+v", job)
+	}
+
+	if _, err := q.Cancel(running.ID); !errors.Is(err, jobs.ErrFinished) {
+		t.Errorf("Expected canceling a finished job to fail, got %v", err)
+	}
+	if _, err := q.Cancel("job_missing"); !errors.Is(err, jobs.ErrNotFound) {
+		t.Errorf("Expected canceling an unknown job to fail, got %v", err)
+	}
+
+	// the worker skips the canceled job instead of running it
+	third, _ := q.Submit("test", "session_3", func(context.Context, jobs.ProgressFunc) error { return nil })
+	waitForJob(t, q, third.ID)
+	if ran {
+		t.Error("Expected the canceled job not to run")
+	}
+}
+
+func TestQueueForgetsOldJobs(t *testing.T) {
+	q := jobs.NewQueue(1, 10, 1, time.Millisecond, 0)
+
+	first, err := q.Submit("test", "session_1", func(context.Context, jobs.ProgressFunc) error {
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Error submitting job: %v", err)
+	}
+	waitForJob(t, q, first.ID)
+
+	second, err := q.Submit("test", "session_2", func(context.Context, jobs.ProgressFunc) error {
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Error submitting job: %v", err)
+	}
+	if _, exists := q.Get(first.ID); exists {
+		t.Error("Expected the finished job to be forgotten")
+	}
+	if _, exists := q.Get(second.ID); !exists {
+		t.Error("Expected the new job to be kept")
+	}
+}
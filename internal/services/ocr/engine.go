@@ -0,0 +1,44 @@
+package ocr
+
+import (
+	"fmt"
+	"os"
+)
+
+const (
+	EngineGoogleCloudVision = "google_cloud_vision"
+	EngineTesseract         = "tesseract"
+)
+
+// Engine is an OCR backend that turns an image on disk into an hOCR document
+type Engine interface {
+	Name() string
+	Description() string
+	ProcessImage(imagePath string) (string, error)
+}
+
+// NewEngine returns the OCR engine registered under name
+func NewEngine(name string) (Engine, error) {
+	switch name {
+	case EngineGoogleCloudVision:
+		return NewGoogleCloudVision(), nil
+	case EngineTesseract:
+		return NewTesseract(), nil
+	}
+
+	return nil, fmt.Errorf("unknown OCR engine %q", name)
+}
+
+// EngineNames lists every engine NewEngine knows how to build
+func EngineNames() []string {
+	return []string{EngineGoogleCloudVision, EngineTesseract}
+}
+
+// DefaultEngineName is the engine used when a request does not ask for one.
+// It can be overridden with the OCR_ENGINE environment variable.
+func DefaultEngineName() string {
+	if name := os.Getenv("OCR_ENGINE"); name != "" {
+		return name
+	}
+	return EngineGoogleCloudVision
+}
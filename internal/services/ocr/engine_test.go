@@ -0,0 +1,107 @@
+package ocr_test
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/lehigh-university-libraries/hocr-edit/internal/services/ocr"
+)
+
+func TestNewEngine(t *testing.T) {
+	for _, name := range ocr.EngineNames() {
+		engine, err := ocr.NewEngine(name)
+		if err != nil {
+			t.Fatalf("Error building %s: %v", name, err)
+		}
+		if engine.Name() != name || engine.Description() == "" {
+			t.Errorf("Expected engine %s with a description, got %s: %q", name, engine.Name(), engine.Description())
+		}
+	}
+
+	for _, name := range []string{"", "openai", "Tesseract"} {
+		if _, err := ocr.NewEngine(name); err == nil {
+			t.Errorf("Expected an error for unknown engine %q", name)
+		}
+	}
+}
+
+func TestDefaultEngineName(t *testing.T) {
+	t.Setenv("OCR_ENGINE", "")
+	if name := ocr.DefaultEngineName(); name != ocr.EngineGoogleCloudVision {
+		t.Errorf("Expected %s by default, got %s", ocr.EngineGoogleCloudVision, name)
+	}
+
+	t.Setenv("OCR_ENGINE", ocr.EngineTesseract)
+	if name := ocr.DefaultEngineName(); name != ocr.EngineTesseract {
+		t.Errorf("Expected OCR_ENGINE to pick %s, got %s", ocr.EngineTesseract, name)
+	}
+	if !slices.Contains(ocr.EngineNames(), ocr.DefaultEngineName()) {
+		t.Errorf("Expected the default engine to be listed in %v", ocr.EngineNames())
+	}
+}
+
+// stubTesseract installs a script as TESSERACT_PATH that prints its arguments, fails
+// for images named broken and prints nothing for images named blank
+func stubTesseract(t *testing.T) {
+	t.Helper()
+	script := filepath.Join(t.TempDir(), "tesseract")
+	err := os.WriteFile(script, []byte(`#!/bin/sh
+case "$1" in
+*broken*) echo "Error in pixReadStream" >&2; exit 1 ;;
+*blank*) exit 0 ;;
+esac
+echo "$@"
+`), 0755)
+	if err != nil {
+		t.Fatalf("Error writing stub: %v", err)
+	}
+	t.Setenv("TESSERACT_PATH", script)
+}
+
+func TestTesseract(t *testing.T) {
+	stubTesseract(t)
+
+	tests := []struct {
+		name      string
+		languages string
+		want      string
+	}{
+		{"default languages", "", "page.jpg stdout hocr"},
+		{"TESSERACT_LANG", "eng+deu", "page.jpg stdout -l eng+deu hocr"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("TESSERACT_LANG", tt.languages)
+			got, err := ocr.NewTesseract().ProcessImage("page.jpg")
+			if err != nil {
+				t.Fatalf("Error running tesseract: %v", err)
+			}
+			if strings.TrimSpace(got) != tt.want {
+				t.Errorf("Expected arguments %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestTesseractErrors(t *testing.T) {
+	stubTesseract(t)
+	engine := ocr.NewTesseract()
+
+	_, err := engine.ProcessImage("broken.jpg")
+	if err == nil || !strings.Contains(err.Error(), "Error in pixReadStream") {
+		t.Errorf("Expected the failure with tesseract's stderr, got %v", err)
+	}
+
+	if _, err := engine.ProcessImage("blank.jpg"); err == nil {
+		t.Error("Expected an error when tesseract prints no hOCR")
+	}
+
+	t.Setenv("TESSERACT_PATH", filepath.Join(t.TempDir(), "missing"))
+	if _, err := ocr.NewTesseract().ProcessImage("page.jpg"); err == nil {
+		t.Error("Expected an error for a missing binary")
+	}
+}
@@ -2,20 +2,46 @@ package ocr
 
 import (
 	"context"
+	"fmt"
 	"os"
 
 	vision "cloud.google.com/go/vision/apiv1"
 	"cloud.google.com/go/vision/v2/apiv1/visionpb"
 	"github.com/lehigh-university-libraries/hocr-edit/internal/models"
+	"github.com/lehigh-university-libraries/hocr-edit/internal/services/hocr"
 )
 
-type Service struct{}
+// GoogleCloudVision runs DetectDocumentText and converts the result to hOCR
+type GoogleCloudVision struct{}
 
-func New() *Service {
-	return &Service{}
+func NewGoogleCloudVision() *GoogleCloudVision {
+	return &GoogleCloudVision{}
 }
 
-func (s *Service) ProcessImage(imagePath string) (models.GCVResponse, error) {
+func (g *GoogleCloudVision) Name() string {
+	return EngineGoogleCloudVision
+}
+
+func (g *GoogleCloudVision) Description() string {
+	return "Google Cloud Vision OCR with hOCR conversion"
+}
+
+func (g *GoogleCloudVision) ProcessImage(imagePath string) (string, error) {
+	gcvResponse, err := g.DetectDocumentText(imagePath)
+	if err != nil {
+		return "", err
+	}
+
+	converter := hocr.NewConverter()
+	hocrXML, err := converter.ConvertToHOCR(gcvResponse)
+	if err != nil {
+		return "", fmt.Errorf("failed to convert to hOCR: %w", err)
+	}
+
+	return hocrXML, nil
+}
+
+func (g *GoogleCloudVision) DetectDocumentText(imagePath string) (models.GCVResponse, error) {
 	ctx := context.Background()
 
 	client, err := vision.NewImageAnnotatorClient(ctx)
@@ -51,38 +77,37 @@ func convertVisionResponseToGCV(annotation *visionpb.TextAnnotation) models.GCVR
 	var pages []models.Page
 	for _, page := range annotation.Pages {
 		convertedPage := models.Page{
-			Width:  int(page.Width),
-			Height: int(page.Height),
+			Property: convertProperty(page.Property),
+			Width:    int(page.Width),
+			Height:   int(page.Height),
 		}
 
 		for _, block := range page.Blocks {
 			convertedBlock := models.Block{
+				Property:    convertProperty(block.Property),
 				BoundingBox: convertBoundingPoly(block.BoundingBox),
 				BlockType:   "TEXT",
 			}
 
 			for _, paragraph := range block.Paragraphs {
 				convertedParagraph := models.Paragraph{
+					Property:    convertProperty(paragraph.Property),
 					BoundingBox: convertBoundingPoly(paragraph.BoundingBox),
 				}
 
 				for _, word := range paragraph.Words {
 					convertedWord := models.Word{
+						Property:    convertProperty(word.Property),
 						BoundingBox: convertBoundingPoly(word.BoundingBox),
+						Confidence:  float64(word.Confidence),
 					}
 
 					for _, symbol := range word.Symbols {
 						convertedSymbol := models.Symbol{
+							Property:    convertProperty(symbol.Property),
 							BoundingBox: convertBoundingPoly(symbol.BoundingBox),
 							Text:        symbol.Text,
-						}
-
-						if symbol.Property != nil && symbol.Property.DetectedBreak != nil {
-							convertedSymbol.Property = &models.Property{
-								DetectedBreak: &models.DetectedBreak{
-									Type: symbol.Property.DetectedBreak.Type.String(),
-								},
-							}
+							Confidence:  float64(symbol.Confidence),
 						}
 
 						convertedWord.Symbols = append(convertedWord.Symbols, convertedSymbol)
@@ -112,6 +137,30 @@ func convertVisionResponseToGCV(annotation *visionpb.TextAnnotation) models.GCVR
 	}
 }
 
+func convertProperty(property *visionpb.TextAnnotation_TextProperty) *models.Property {
+	if property == nil {
+		return nil
+	}
+
+	converted := &models.Property{}
+	for _, language := range property.DetectedLanguages {
+		converted.DetectedLanguages = append(converted.DetectedLanguages, models.DetectedLanguage{
+			LanguageCode: language.LanguageCode,
+			Confidence:   float64(language.Confidence),
+		})
+	}
+	if property.DetectedBreak != nil {
+		converted.DetectedBreak = &models.DetectedBreak{
+			Type: property.DetectedBreak.Type.String(),
+		}
+	}
+
+	if converted.DetectedLanguages == nil && converted.DetectedBreak == nil {
+		return nil
+	}
+	return converted
+}
+
 func convertBoundingPoly(poly *visionpb.BoundingPoly) models.BoundingPoly {
 	if poly == nil {
 		return models.BoundingPoly{}
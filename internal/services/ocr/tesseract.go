@@ -0,0 +1,60 @@
+package ocr
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// Tesseract shells out to the local tesseract binary, which emits hOCR natively
+type Tesseract struct {
+	binary    string
+	languages string
+}
+
+// NewTesseract builds a Tesseract engine. TESSERACT_PATH overrides the binary
+// looked up on PATH and TESSERACT_LANG is passed through as -l (e.g. "eng+deu").
+func NewTesseract() *Tesseract {
+	binary := os.Getenv("TESSERACT_PATH")
+	if binary == "" {
+		binary = "tesseract"
+	}
+
+	return &Tesseract{
+		binary:    binary,
+		languages: os.Getenv("TESSERACT_LANG"),
+	}
+}
+
+func (t *Tesseract) Name() string {
+	return EngineTesseract
+}
+
+func (t *Tesseract) Description() string {
+	return "Tesseract OCR with native hOCR output"
+}
+
+func (t *Tesseract) ProcessImage(imagePath string) (string, error) {
+	args := []string{imagePath, "stdout"}
+	if t.languages != "" {
+		args = append(args, "-l", t.languages)
+	}
+	args = append(args, "hocr")
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(t.binary, args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("tesseract failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	if stdout.Len() == 0 {
+		return "", fmt.Errorf("tesseract returned no hOCR for %s", imagePath)
+	}
+
+	return stdout.String(), nil
+}
@@ -0,0 +1,151 @@
+package review
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/lehigh-university-libraries/hocr-edit/internal/models"
+	"github.com/lehigh-university-libraries/hocr-edit/internal/services/auth"
+)
+
+const (
+	ActionSubmit   = "submit"
+	ActionWithdraw = "withdraw"
+	ActionReview   = "review"
+	ActionApprove  = "approve"
+	ActionReject   = "reject"
+	ActionReopen   = "reopen"
+)
+
+var (
+	// ErrInvalidTransition is returned for an action the page's status does not allow
+	ErrInvalidTransition = errors.New("invalid review transition")
+	// ErrForbidden is returned when the user does not have the role an action needs
+	ErrForbidden = errors.New("not allowed")
+)
+
+type transition struct {
+	action string
+	from   string
+	to     string
+	role   string
+}
+
+// transitions is the workflow a corrected page goes through before it is accepted:
+// a transcriber submits it, a reviewer checks it and an approver signs it off, and
+// either of them can send it back with a comment.
+//
+//	draft     --submit-->   submitted   (transcriber)
+//	rejected  --submit-->   submitted   (transcriber)
+//	submitted --withdraw--> draft       (transcriber)
+//	submitted --review-->   reviewed    (reviewer)
+//	submitted --reject-->   rejected    (reviewer)
+//	reviewed  --approve-->  approved    (approver)
+//	reviewed  --reject-->   rejected    (approver)
+//	approved  --reopen-->   draft       (approver)
+var transitions = []transition{
+	{ActionSubmit, models.ReviewDraft, models.ReviewSubmitted, auth.RoleTranscriber},
+	{ActionSubmit, models.ReviewRejected, models.ReviewSubmitted, auth.RoleTranscriber},
+	{ActionWithdraw, models.ReviewSubmitted, models.ReviewDraft, auth.RoleTranscriber},
+	{ActionReview, models.ReviewSubmitted, models.ReviewReviewed, auth.RoleReviewer},
+	{ActionReject, models.ReviewSubmitted, models.ReviewRejected, auth.RoleReviewer},
+	{ActionApprove, models.ReviewReviewed, models.ReviewApproved, auth.RoleApprover},
+	{ActionReject, models.ReviewReviewed, models.ReviewRejected, auth.RoleApprover},
+	{ActionReopen, models.ReviewApproved, models.ReviewDraft, auth.RoleApprover},
+}
+
+// order ranks the statuses from least to most advanced, for the session status
+var order = []string{
+	models.ReviewRejected,
+	models.ReviewDraft,
+	models.ReviewSubmitted,
+	models.ReviewReviewed,
+	models.ReviewApproved,
+}
+
+// Status is the review status of a page, where no status is a draft
+func Status(image *models.ImageItem) string {
+	if image.Status == "" {
+		return models.ReviewDraft
+	}
+	return image.Status
+}
+
+// Apply moves a page through action on behalf of user and logs the transition.
+// Rejecting a page needs a comment, so the transcriber knows what to fix.
+func Apply(image *models.ImageItem, action string, user *auth.User, comment string) (models.ReviewEvent, error) {
+	from := Status(image)
+	index := slices.IndexFunc(transitions, func(t transition) bool {
+		return t.action == action && t.from == from
+	})
+	if index < 0 {
+		return models.ReviewEvent{}, fmt.Errorf("%w: cannot %s a page that is %s", ErrInvalidTransition, action, from)
+	}
+	t := transitions[index]
+
+	if !user.HasRole(t.role) {
+		return models.ReviewEvent{}, fmt.Errorf("%w: %s a %s page needs the %s role", ErrForbidden, action, from, t.role)
+	}
+	if action == ActionReject && comment == "" {
+		return models.ReviewEvent{}, fmt.Errorf("%w: a comment is required to reject a page", ErrInvalidTransition)
+	}
+
+	event := models.ReviewEvent{
+		Action:  action,
+		From:    from,
+		To:      t.to,
+		User:    user.ID,
+		Comment: comment,
+		At:      time.Now(),
+	}
+	image.Status = t.to
+	image.Reviews = append(image.Reviews, event)
+	return event, nil
+}
+
+// CanEdit reports whether user may change the hOCR of a page in its current status.
+// Transcribers edit drafts and rejected pages, reviewers and approvers may fix pages
+// while they are checking them, and approved pages have to be reopened first.
+func CanEdit(image *models.ImageItem, user *auth.User) bool {
+	switch Status(image) {
+	case models.ReviewDraft, models.ReviewRejected:
+		return true
+	case models.ReviewSubmitted:
+		return user.HasRole(auth.RoleReviewer)
+	case models.ReviewReviewed:
+		return user.HasRole(auth.RoleApprover)
+	}
+	return false
+}
+
+// Awaiting returns the role that acts next on a page in status: transcribers on
+// drafts and rejected pages, reviewers on submitted and approvers on reviewed pages.
+// Approved pages await no one.
+func Awaiting(status string) string {
+	switch status {
+	case "", models.ReviewDraft, models.ReviewRejected:
+		return auth.RoleTranscriber
+	case models.ReviewSubmitted:
+		return auth.RoleReviewer
+	case models.ReviewReviewed:
+		return auth.RoleApprover
+	}
+	return ""
+}
+
+// SessionStatus is the status of the least advanced page of a session, so a
+// session is approved once all of its pages are and rejected while any page is
+func SessionStatus(images []models.ImageItem) string {
+	if len(images) == 0 {
+		return models.ReviewDraft
+	}
+	status := models.ReviewApproved
+	for i := range images {
+		if slices.Index(order, Status(&images[i])) < slices.Index(order, status) {
+			status = Status(&images[i])
+		}
+	}
+	return status
+}
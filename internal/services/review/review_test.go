@@ -0,0 +1,99 @@
+package review_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/lehigh-university-libraries/hocr-edit/internal/models"
+	"github.com/lehigh-university-libraries/hocr-edit/internal/services/auth"
+	"github.com/lehigh-university-libraries/hocr-edit/internal/services/review"
+)
+
+var (
+	transcriber = &auth.User{ID: "jo"}
+	reviewer    = &auth.User{ID: "rey", Roles: []string{auth.RoleReviewer}}
+	approver    = &auth.User{ID: "abe", Roles: []string{auth.RoleApprover}}
+)
+
+func TestApply(t *testing.T) {
+	image := &models.ImageItem{ID: "img_1"}
+
+	steps := []struct {
+		action  string
+		user    *auth.User
+		comment string
+		status  string
+		err     error
+	}{
+		{review.ActionReview, reviewer, "", models.ReviewDraft, review.ErrInvalidTransition},
+		{review.ActionSubmit, transcriber, "", models.ReviewSubmitted, nil},
+		{review.ActionReview, transcriber, "", models.ReviewSubmitted, review.ErrForbidden},
+		{review.ActionReject, reviewer, "", models.ReviewSubmitted, review.ErrInvalidTransition},
+		{review.ActionReject, reviewer, "line 3 is missing", models.ReviewRejected, nil},
+		{review.ActionSubmit, transcriber, "", models.ReviewSubmitted, nil},
+		{review.ActionReview, reviewer, "", models.ReviewReviewed, nil},
+		{review.ActionApprove, reviewer, "", models.ReviewReviewed, review.ErrForbidden},
+		{review.ActionApprove, approver, "", models.ReviewApproved, nil},
+		{review.ActionSubmit, transcriber, "", models.ReviewApproved, review.ErrInvalidTransition},
+		{review.ActionReopen, approver, "", models.ReviewDraft, nil},
+	}
+	for i, step := range steps {
+		_, err := review.Apply(image, step.action, step.user, step.comment)
+		if !errors.Is(err, step.err) {
+			t.Errorf("step %d: %s by %s: expected error %v, got %v", i, step.action, step.user.ID, step.err, err)
+		}
+		if got := review.Status(image); got != step.status {
+			t.Errorf("step %d: expected status %s, got %s", i, step.status, got)
+		}
+	}
+
+	if len(image.Reviews) != 6 {
+		t.Fatalf("Expected 6 review events, got %d", len(image.Reviews))
+	}
+	if event := image.Reviews[1]; event.User != "rey" || event.Comment != "line 3 is missing" || event.To != models.ReviewRejected {
+		t.Errorf("Unexpected rejection %+v", event)
+	}
+}
+
+func TestCanEdit(t *testing.T) {
+	tests := []struct {
+		status string
+		user   *auth.User
+		want   bool
+	}{
+		{"", transcriber, true},
+		{models.ReviewRejected, transcriber, true},
+		{models.ReviewSubmitted, transcriber, false},
+		{models.ReviewSubmitted, reviewer, true},
+		{models.ReviewReviewed, reviewer, false},
+		{models.ReviewReviewed, approver, true},
+		{models.ReviewApproved, approver, false},
+	}
+	for _, tt := range tests {
+		if got := review.CanEdit(&models.ImageItem{Status: tt.status}, tt.user); got != tt.want {
+			t.Errorf("CanEdit(%q, %s) = %v, expected %v", tt.status, tt.user.ID, got, tt.want)
+		}
+	}
+}
+
+func TestSessionStatus(t *testing.T) {
+	tests := []struct {
+		statuses []string
+		want     string
+	}{
+		{nil, models.ReviewDraft},
+		{[]string{models.ReviewApproved, models.ReviewApproved}, models.ReviewApproved},
+		{[]string{models.ReviewApproved, models.ReviewSubmitted, models.ReviewReviewed}, models.ReviewSubmitted},
+		{[]string{models.ReviewApproved, ""}, models.ReviewDraft},
+		{[]string{models.ReviewDraft, models.ReviewRejected}, models.ReviewRejected},
+	}
+	for _, tt := range tests {
+		images := make([]models.ImageItem, len(tt.statuses))
+		for i, status := range tt.statuses {
+			images[i].Status = status
+		}
+		if got := review.SessionStatus(images); got != tt.want {
+			t.Errorf("SessionStatus(%v) = %s, expected %s", tt.statuses, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/lehigh-university-libraries/hocr-edit/internal/models"
+	bolt "go.etcd.io/bbolt"
+)
+
+var sessionsBucket = []byte("sessions")
+
+// BoltStore keeps sessions as JSON in an embedded bbolt database so they survive restarts.
+// Every Get decodes a fresh copy, so changes to a session must be written back with Set.
+type BoltStore struct {
+	db *bolt.DB
+}
+
+// NewBolt opens, or creates, the database at path
+func NewBolt(path string) (*BoltStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create session store directory: %w", err)
+	}
+
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open session store: %w", err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(sessionsBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create sessions bucket: %w", err)
+	}
+
+	slog.Info("Session store opened", "path", path)
+	return &BoltStore{db: db}, nil
+}
+
+func (s *BoltStore) Get(sessionID string) (*models.CorrectionSession, bool) {
+	var session *models.CorrectionSession
+	err := s.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(sessionsBucket).Get([]byte(sessionID))
+		if data == nil {
+			return nil
+		}
+
+		session = &models.CorrectionSession{}
+		return json.Unmarshal(data, session)
+	})
+	if err != nil {
+		slog.Error("Unable to read session", "session_id", sessionID, "err", err)
+		return nil, false
+	}
+
+	return session, session != nil
+}
+
+func (s *BoltStore) Set(sessionID string, session *models.CorrectionSession) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(sessionsBucket)
+		version, err := storedVersion(bucket.Get([]byte(sessionID)))
+		if err != nil {
+			return err
+		}
+
+		session.Version = version + 1
+		return putSession(bucket, sessionID, session)
+	})
+}
+
+// Update runs change inside a bbolt write transaction, which only one writer holds at a time
+func (s *BoltStore) Update(sessionID string, change func(session *models.CorrectionSession) error) (*models.CorrectionSession, error) {
+	var session *models.CorrectionSession
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(sessionsBucket)
+		data := bucket.Get([]byte(sessionID))
+		if data == nil {
+			return ErrNotFound
+		}
+
+		session = &models.CorrectionSession{}
+		if err := json.Unmarshal(data, session); err != nil {
+			return fmt.Errorf("failed to decode session: %w", err)
+		}
+
+		if err := change(session); err != nil {
+			return err
+		}
+
+		session.Version++
+		return putSession(bucket, sessionID, session)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return session, nil
+}
+
+func putSession(bucket *bolt.Bucket, sessionID string, session *models.CorrectionSession) error {
+	data, err := json.Marshal(session)
+	if err != nil {
+		return fmt.Errorf("failed to encode session: %w", err)
+	}
+	return bucket.Put([]byte(sessionID), data)
+}
+
+// storedVersion reads just the version of an encoded session, which is 0 for none
+func storedVersion(data []byte) (int, error) {
+	if data == nil {
+		return 0, nil
+	}
+
+	var stored struct {
+		Version int `json:"version"`
+	}
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return 0, fmt.Errorf("failed to decode session: %w", err)
+	}
+	return stored.Version, nil
+}
+
+func (s *BoltStore) GetAll() map[string]*models.CorrectionSession {
+	result := make(map[string]*models.CorrectionSession)
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(sessionsBucket).ForEach(func(k, v []byte) error {
+			var session models.CorrectionSession
+			if err := json.Unmarshal(v, &session); err != nil {
+				slog.Warn("Skipping unreadable session", "session_id", string(k), "err", err)
+				return nil
+			}
+			result[string(k)] = &session
+			return nil
+		})
+	})
+	if err != nil {
+		slog.Error("Unable to list sessions", "err", err)
+	}
+
+	return result
+}
+
+func (s *BoltStore) Delete(sessionID string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(sessionsBucket).Delete([]byte(sessionID))
+	})
+}
+
+func (s *BoltStore) Close() error {
+	return s.db.Close()
+}
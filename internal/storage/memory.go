@@ -0,0 +1,114 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sync"
+
+	"github.com/lehigh-university-libraries/hocr-edit/internal/models"
+)
+
+// MemoryStore keeps sessions in a map, so they are lost when the process exits.
+// Sessions are held encoded, as in BoltStore, so that no caller shares a session
+// with the store or with another caller.
+type MemoryStore struct {
+	sessions map[string][]byte
+	mu       sync.RWMutex
+}
+
+func NewMemory() *MemoryStore {
+	return &MemoryStore{
+		sessions: make(map[string][]byte),
+	}
+}
+
+func (s *MemoryStore) Get(sessionID string) (*models.CorrectionSession, bool) {
+	s.mu.RLock()
+	data, exists := s.sessions[sessionID]
+	s.mu.RUnlock()
+	if !exists {
+		return nil, false
+	}
+
+	session := &models.CorrectionSession{}
+	if err := json.Unmarshal(data, session); err != nil {
+		slog.Error("Unable to read session", "session_id", sessionID, "err", err)
+		return nil, false
+	}
+	return session, true
+}
+
+func (s *MemoryStore) Set(sessionID string, session *models.CorrectionSession) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	version, err := storedVersion(s.sessions[sessionID])
+	if err != nil {
+		return err
+	}
+
+	session.Version = version + 1
+	return s.put(sessionID, session)
+}
+
+func (s *MemoryStore) Update(sessionID string, change func(session *models.CorrectionSession) error) (*models.CorrectionSession, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, exists := s.sessions[sessionID]
+	if !exists {
+		return nil, ErrNotFound
+	}
+
+	session := &models.CorrectionSession{}
+	if err := json.Unmarshal(data, session); err != nil {
+		return nil, fmt.Errorf("failed to decode session: %w", err)
+	}
+
+	if err := change(session); err != nil {
+		return nil, err
+	}
+
+	session.Version++
+	if err := s.put(sessionID, session); err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+func (s *MemoryStore) put(sessionID string, session *models.CorrectionSession) error {
+	data, err := json.Marshal(session)
+	if err != nil {
+		return fmt.Errorf("failed to encode session: %w", err)
+	}
+	s.sessions[sessionID] = data
+	return nil
+}
+
+func (s *MemoryStore) GetAll() map[string]*models.CorrectionSession {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make(map[string]*models.CorrectionSession, len(s.sessions))
+	for k, data := range s.sessions {
+		var session models.CorrectionSession
+		if err := json.Unmarshal(data, &session); err != nil {
+			slog.Warn("Skipping unreadable session", "session_id", k, "err", err)
+			continue
+		}
+		result[k] = &session
+	}
+	return result
+}
+
+func (s *MemoryStore) Delete(sessionID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.sessions, sessionID)
+	return nil
+}
+
+func (s *MemoryStore) Close() error {
+	return nil
+}
@@ -1,48 +1,148 @@
 package storage
 
 import (
-	"sync"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
 
 	"github.com/lehigh-university-libraries/hocr-edit/internal/models"
 )
 
-type SessionStore struct {
-	sessions map[string]*models.CorrectionSession
-	mu       sync.RWMutex
+const (
+	BackendMemory = "memory"
+	BackendBolt   = "bolt"
+)
+
+var (
+	// ErrNotFound is returned by Update when the session does not exist
+	ErrNotFound = errors.New("session not found")
+	// ErrConflict is returned when a write expected an older version of a session
+	ErrConflict = errors.New("session was changed by someone else")
+)
+
+// SessionStore persists correction sessions by ID. Sessions returned by Get and
+// GetAll are copies, so changing one has no effect until it is written back. Every
+// write increments the session's Version.
+type SessionStore interface {
+	Get(sessionID string) (*models.CorrectionSession, bool)
+	// Set stores session as it is, setting its Version to one past the stored one
+	Set(sessionID string, session *models.CorrectionSession) error
+	// Update applies change to the stored session and writes it back atomically, so
+	// concurrent updates cannot overwrite each other. Nothing is written when change
+	// returns an error, which Update passes on. The updated session is returned.
+	Update(sessionID string, change func(session *models.CorrectionSession) error) (*models.CorrectionSession, error)
+	GetAll() map[string]*models.CorrectionSession
+	Delete(sessionID string) error
+	Close() error
 }
 
-func New() *SessionStore {
-	return &SessionStore{
-		sessions: make(map[string]*models.CorrectionSession),
+// CheckVersion returns ErrConflict unless session is at the expected version. It is
+// meant to be called from the change function given to Update.
+func CheckVersion(session *models.CorrectionSession, expected int) error {
+	if session.Version != expected {
+		return fmt.Errorf("%w: expected version %d, found %d", ErrConflict, expected, session.Version)
 	}
+	return nil
 }
 
-func (s *SessionStore) Get(sessionID string) (*models.CorrectionSession, bool) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-	session, exists := s.sessions[sessionID]
-	return session, exists
-}
+// New builds the store selected by SESSION_STORE (memory or bolt, defaulting to memory).
+// The bolt database lives at SESSION_STORE_PATH, and any session JSON found in
+// SESSION_IMPORT_DIR is migrated into the store before it is returned.
+func New() (SessionStore, error) {
+	backend := os.Getenv("SESSION_STORE")
+	if backend == "" {
+		backend = BackendMemory
+	}
+
+	var store SessionStore
+	switch backend {
+	case BackendMemory:
+		store = NewMemory()
+	case BackendBolt:
+		path := os.Getenv("SESSION_STORE_PATH")
+		if path == "" {
+			path = filepath.Join("data", "sessions.db")
+		}
+
+		boltStore, err := NewBolt(path)
+		if err != nil {
+			return nil, err
+		}
+		store = boltStore
+	default:
+		return nil, fmt.Errorf("unknown session store %q", backend)
+	}
+
+	if dir := os.Getenv("SESSION_IMPORT_DIR"); dir != "" {
+		imported, err := ImportDir(store, dir)
+		if err != nil {
+			store.Close()
+			return nil, err
+		}
+		slog.Info("Imported sessions", "dir", dir, "sessions", imported)
+	}
 
-func (s *SessionStore) Set(sessionID string, session *models.CorrectionSession) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	s.sessions[sessionID] = session
+	return store, nil
 }
 
-func (s *SessionStore) GetAll() map[string]*models.CorrectionSession {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
+// ImportDir migrates CorrectionSession JSON files into store. Each .json file may
+// hold a single session or a list of sessions, as returned by GET /api/sessions.
+// Sessions that already exist in the store are left untouched.
+func ImportDir(store SessionStore, dir string) (int, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read session import directory: %w", err)
+	}
+
+	imported := 0
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.EqualFold(filepath.Ext(entry.Name()), ".json") {
+			continue
+		}
+
+		path := filepath.Join(dir, entry.Name())
+		sessions, err := readSessionsFile(path)
+		if err != nil {
+			return imported, err
+		}
 
-	result := make(map[string]*models.CorrectionSession, len(s.sessions))
-	for k, v := range s.sessions {
-		result[k] = v
+		for _, session := range sessions {
+			if session.ID == "" {
+				slog.Warn("Skipping session without an ID", "file", path)
+				continue
+			}
+			if _, exists := store.Get(session.ID); exists {
+				continue
+			}
+			if err := store.Set(session.ID, session); err != nil {
+				return imported, fmt.Errorf("failed to import session %s: %w", session.ID, err)
+			}
+			imported++
+		}
 	}
-	return result
+
+	return imported, nil
 }
 
-func (s *SessionStore) Delete(sessionID string) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	delete(s.sessions, sessionID)
+func readSessionsFile(path string) ([]*models.CorrectionSession, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	var sessions []*models.CorrectionSession
+	if err := json.Unmarshal(data, &sessions); err == nil {
+		return sessions, nil
+	}
+
+	var session models.CorrectionSession
+	if err := json.Unmarshal(data, &session); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	return []*models.CorrectionSession{&session}, nil
 }
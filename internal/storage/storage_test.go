@@ -0,0 +1,179 @@
+package storage_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lehigh-university-libraries/hocr-edit/internal/models"
+	"github.com/lehigh-university-libraries/hocr-edit/internal/storage"
+)
+
+func TestBoltStoreSurvivesReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sessions.db")
+
+	store, err := storage.NewBolt(path)
+	if err != nil {
+		t.Fatalf("Error opening store: %v", err)
+	}
+
+	session := &models.CorrectionSession{
+		ID:        "letter_1",
+		CreatedAt: time.Now().UTC().Truncate(time.Second),
+		Images: []models.ImageItem{
+			{ID: "img_1", OriginalHOCR: "<html/>", CorrectedHOCR: "<html>fixed</html>", Completed: true},
+		},
+	}
+	if err := store.Set(session.ID, session); err != nil {
+		t.Fatalf("Error saving session: %v", err)
+	}
+	if err := store.Close(); err != nil {
+		t.Fatalf("Error closing store: %v", err)
+	}
+
+	store, err = storage.NewBolt(path)
+	if err != nil {
+		t.Fatalf("Error reopening store: %v", err)
+	}
+	defer store.Close()
+
+	got, exists := store.Get("letter_1")
+	if !exists {
+		t.Fatal("Expected session to survive reopening the store")
+	}
+	if got.Images[0].CorrectedHOCR != "<html>fixed</html>" || !got.Images[0].Completed {
+		t.Errorf("Expected corrected image to be kept, got %+v", got.Images[0])
+	}
+	if !got.CreatedAt.Equal(session.CreatedAt) {
+		t.Errorf("Expected created at %v, got %v", session.CreatedAt, got.CreatedAt)
+	}
+
+	if err := store.Delete("letter_1"); err != nil {
+		t.Fatalf("Error deleting session: %v", err)
+	}
+	if len(store.GetAll()) != 0 {
+		t.Errorf("Expected no sessions after delete, got %d", len(store.GetAll()))
+	}
+}
+
+func TestImportDir(t *testing.T) {
+	dir := t.TempDir()
+	single := `{"id": "single", "images": [{"id": "img_1"}]}`
+	list := `[{"id": "first"}, {"id": "existing"}]`
+	if err := os.WriteFile(filepath.Join(dir, "single.json"), []byte(single), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "list.json"), []byte(list), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("not a session"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	store := storage.NewMemory()
+	existing := &models.CorrectionSession{ID: "existing", Current: 3}
+	if err := store.Set(existing.ID, existing); err != nil {
+		t.Fatal(err)
+	}
+
+	imported, err := storage.ImportDir(store, dir)
+	if err != nil {
+		t.Fatalf("Error importing sessions: %v", err)
+	}
+	if imported != 2 {
+		t.Errorf("Expected 2 imported sessions, got %d", imported)
+	}
+
+	if session, _ := store.Get("existing"); session.Current != 3 {
+		t.Errorf("Expected existing session to be left untouched, got current %d", session.Current)
+	}
+	if session, exists := store.Get("single"); !exists || len(session.Images) != 1 {
+		t.Errorf("Expected single session with one image, got %+v", session)
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	bolt, err := storage.NewBolt(filepath.Join(t.TempDir(), "sessions.db"))
+	if err != nil {
+		t.Fatalf("Error opening store: %v", err)
+	}
+	defer bolt.Close()
+
+	stores := map[string]storage.SessionStore{"memory": storage.NewMemory(), "bolt": bolt}
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			session := &models.CorrectionSession{ID: "s1"}
+			if err := store.Set("s1", session); err != nil {
+				t.Fatalf("Error saving session: %v", err)
+			}
+			if session.Version != 1 {
+				t.Errorf("Expected Set to make version 1, got %d", session.Version)
+			}
+
+			got, _ := store.Get("s1")
+			got.Current = 5
+			if stored, _ := store.Get("s1"); stored.Current != 0 {
+				t.Error("Expected changes to a session from Get not to reach the store")
+			}
+
+			updated, err := store.Update("s1", func(session *models.CorrectionSession) error {
+				if err := storage.CheckVersion(session, 1); err != nil {
+					return err
+				}
+				session.Current = 2
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("Error updating session: %v", err)
+			}
+			if updated.Version != 2 || updated.Current != 2 {
+				t.Errorf("Expected version 2 with the change, got %+v", updated)
+			}
+
+			_, err = store.Update("s1", func(session *models.CorrectionSession) error {
+				session.Current = 9
+				return storage.CheckVersion(session, 1)
+			})
+			if !errors.Is(err, storage.ErrConflict) {
+				t.Errorf("Expected a conflict for a stale version, got %v", err)
+			}
+			if stored, _ := store.Get("s1"); stored.Current != 2 || stored.Version != 2 {
+				t.Errorf("Expected a failed update to leave the session unchanged, got %+v", stored)
+			}
+
+			if _, err := store.Update("missing", func(*models.CorrectionSession) error { return nil }); !errors.Is(err, storage.ErrNotFound) {
+				t.Errorf("Expected ErrNotFound for a missing session, got %v", err)
+			}
+		})
+	}
+}
+
+func TestConcurrentUpdates(t *testing.T) {
+	store := storage.NewMemory()
+	if err := store.Set("s1", &models.CorrectionSession{ID: "s1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for range 50 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := store.Update("s1", func(session *models.CorrectionSession) error {
+				session.Current++
+				return nil
+			})
+			if err != nil {
+				t.Errorf("Error updating session: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if session, _ := store.Get("s1"); session.Current != 50 || session.Version != 51 {
+		t.Errorf("Expected every update to apply, got current %d at version %d", session.Current, session.Version)
+	}
+}
@@ -1,9 +1,11 @@
 package utils
 
 import (
+	"bytes"
 	"crypto/md5"
 	"encoding/hex"
 	"encoding/json"
+	"fmt"
 	"io"
 	"log/slog"
 	"net/http"
@@ -35,6 +37,7 @@ func CalculateDataMD5(data []byte) string {
 }
 
 func RespondWithError(w http.ResponseWriter, message string, statusCode int) {
+	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(statusCode)
 	response := map[string]string{
 		"error": message,
@@ -63,3 +66,66 @@ func GetImageDimensions(imagePath string) (int, int) {
 
 	return 1000, 1400
 }
+
+// CountPages returns the number of pages or frames ImageMagick finds in a file
+func CountPages(filePath string) int {
+	cmd := exec.Command("identify", "-format", "%n\n", filePath)
+	output, err := cmd.Output()
+	if err != nil {
+		slog.Warn("Failed to count pages", "error", err)
+		return 1
+	}
+
+	parts := strings.Fields(string(output))
+	if len(parts) > 0 {
+		if pages, err := strconv.Atoi(parts[0]); err == nil && pages > 0 {
+			return pages
+		}
+	}
+
+	return 1
+}
+
+// SplitPages rasterizes every page of a multi-page TIFF or PDF to its own JPEG,
+// written as <outputPrefix>_page_<n>.jpg with n starting at 1
+func SplitPages(filePath, outputPrefix string) ([]string, error) {
+	cmd := exec.Command("convert",
+		"-density", "300",
+		filePath,
+		"-background", "white",
+		"-alpha", "remove",
+		"-scene", "1",
+		outputPrefix+"_page_%d.jpg",
+	)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("failed to split pages: %w: %s", err, strings.TrimSpace(string(output)))
+	}
+
+	var pages []string
+	for n := 1; ; n++ {
+		pagePath := fmt.Sprintf("%s_page_%d.jpg", outputPrefix, n)
+		if _, err := os.Stat(pagePath); err != nil {
+			break
+		}
+		pages = append(pages, pagePath)
+	}
+
+	if len(pages) == 0 {
+		return nil, fmt.Errorf("no pages found in %s", filePath)
+	}
+
+	return pages, nil
+}
+
+// ConvertToJPEG uses ImageMagick to re-encode the first page of an image as JPEG,
+// for formats such as WebP and JP2 that the Go image packages cannot decode
+func ConvertToJPEG(filePath string) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("convert", filePath+"[0]", "-background", "white", "-alpha", "remove", "jpg:-")
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("failed to convert %s to JPEG: %w: %s", filePath, err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.Bytes(), nil
+}
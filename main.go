@@ -7,6 +7,9 @@ import (
 
 	"github.com/joho/godotenv"
 	"github.com/lehigh-university-libraries/hocr-edit/internal/handlers"
+	"github.com/lehigh-university-libraries/hocr-edit/internal/services/auth"
+	"github.com/lehigh-university-libraries/hocr-edit/internal/services/fetch"
+	"github.com/lehigh-university-libraries/hocr-edit/internal/storage"
 	"github.com/lehigh-university-libraries/hocr-edit/internal/utils"
 )
 
@@ -16,26 +19,28 @@ func main() {
 		slog.Warn("Error loading .env file", "err", err)
 	}
 
-	handler := handlers.New()
-
-	// Set up routes
-	http.HandleFunc("/api/sessions", handler.HandleSessions)
-	http.HandleFunc("/api/sessions/", handler.HandleSessionDetail)
-	http.HandleFunc("/api/upload", handler.HandleUpload)
-	http.HandleFunc("/api/hocr/parse", handler.HandleHOCRParse)
-	http.HandleFunc("/api/hocr/update", handler.HandleHOCRUpdate)
-	http.HandleFunc("/", handler.HandleStatic)
-	http.HandleFunc("/healthcheck", func(w http.ResponseWriter, r *http.Request) {
-		_, err := w.Write([]byte("OK"))
-		if err != nil {
-			slog.Error("Unable to write healthcheck", "err", err)
-			os.Exit(1)
-		}
-	})
+	sessionStore, err := storage.New()
+	if err != nil {
+		utils.ExitOnError("Unable to open session store", err)
+	}
+	defer sessionStore.Close()
+
+	authService, err := auth.New()
+	if err != nil {
+		utils.ExitOnError("Unable to configure authentication", err)
+	}
+
+	fetcher, err := fetch.New(os.Getenv("HOUDINI_URL"), os.Getenv("DRUPAL_HOCR_URL"), os.Getenv("DRUPAL_COLLECTION_URL"))
+	if err != nil {
+		utils.ExitOnError("Unable to configure URL fetching", err)
+	}
+
+	handler := handlers.New(sessionStore, authService, fetcher)
+
 	addr := ":8888"
 	slog.Info("hOCR Editor interface available", "addr", addr)
 
-	if err := http.ListenAndServe(addr, nil); err != nil {
+	if err := http.ListenAndServe(addr, authService.Middleware(handler.Routes())); err != nil {
 		utils.ExitOnError("Server failed to start", err)
 	}
 }
@@ -0,0 +1,146 @@
+package alto_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/lehigh-university-libraries/hocr-edit/pkg/hocr/alto"
+)
+
+const testALTO = `<?xml version="1.0" encoding="UTF-8"?>
+<alto xmlns="http://www.loc.gov/standards/alto/ns-v4#">
+<Description>
+<MeasurementUnit>pixel</MeasurementUnit>
+<sourceImageInformation><fileName>letter.jpg</fileName></sourceImageInformation>
+</Description>
+<Layout>
+<Page ID="p1" PHYSICAL_IMG_NR="1" WIDTH="1120" HEIGHT="1368">
+<PrintSpace HPOS="0" VPOS="0" WIDTH="1120" HEIGHT="1368">
+<TextBlock ID="tb_header" HPOS="599" VPOS="41" WIDTH="75" HEIGHT="28">
+<TextLine ID="tl_1" HPOS="599" VPOS="41" WIDTH="75" HEIGHT="28">
+<String ID="s_1" HPOS="599" VPOS="41" WIDTH="75" HEIGHT="28" WC="0.91" CONTENT="ALS"/>
+</TextLine>
+</TextBlock>
+<Illustration ID="ill_1" HPOS="0" VPOS="0" WIDTH="10" HEIGHT="10"/>
+<ComposedBlock ID="cb_1" HPOS="161" VPOS="80" WIDTH="274" HEIGHT="49">
+<TextBlock ID="tb_1" HPOS="161" VPOS="80" WIDTH="274" HEIGHT="49">
+<TextLine ID="tl_2" HPOS="161" VPOS="80" WIDTH="274" HEIGHT="49">
+<String ID="s_2" HPOS="161" VPOS="84" WIDTH="139" HEIGHT="45" WC="0.5" CONTENT="Dear"/>
+<SP WIDTH="24"/>
+<String HPOS="324.4" VPOS="80" WIDTH="93" HEIGHT="43" CONTENT="Sir &amp; Madam"/>
+</TextLine>
+</TextBlock>
+</ComposedBlock>
+</PrintSpace>
+</Page>
+</Layout>
+</alto>`
+
+func TestParse(t *testing.T) {
+	pages, err := alto.Parse(testALTO)
+	if err != nil {
+		t.Fatalf("Error parsing ALTO: %v", err)
+	}
+
+	if len(pages) != 1 {
+		t.Fatalf("Expected 1 page, got %d", len(pages))
+	}
+	page := pages[0]
+	if page.ID != "p1" || page.Image != "letter.jpg" || page.BBox.X2 != 1120 || page.BBox.Y2 != 1368 {
+		t.Errorf("Unexpected page %+v", page)
+	}
+
+	if len(page.Areas) != 2 {
+		t.Fatalf("Expected 2 areas, got %d", len(page.Areas))
+	}
+	if page.Areas[0].ID != "" || page.Areas[0].Paragraphs[0].ID != "tb_header" {
+		t.Errorf("Expected a loose TextBlock to become a paragraph in an implicit area, got %+v", page.Areas[0])
+	}
+	if page.Areas[1].ID != "cb_1" || page.Areas[1].Paragraphs[0].ID != "tb_1" {
+		t.Errorf("Expected ComposedBlock cb_1 holding TextBlock tb_1, got %+v", page.Areas[1])
+	}
+
+	line := page.Areas[1].Paragraphs[0].Lines[0]
+	if line.ID != "tl_2" || line.PageID != "p1" || len(line.Words) != 2 {
+		t.Fatalf("Unexpected line %+v", line)
+	}
+
+	dear := line.Words[0]
+	if dear.Text != "Dear" || dear.Confidence != 50 || dear.LineID != "tl_2" {
+		t.Errorf("Unexpected word %+v", dear)
+	}
+	if dear.BBox.X1 != 161 || dear.BBox.Y1 != 84 || dear.BBox.X2 != 300 || dear.BBox.Y2 != 129 {
+		t.Errorf("Expected bbox [161 84 300 129], got %+v", dear.BBox)
+	}
+
+	sir := line.Words[1]
+	if sir.Text != "Sir & Madam" || sir.ID == "" || sir.BBox.X1 != 324 || sir.BBox.X2 != 417 {
+		t.Errorf("Unexpected word %+v", sir)
+	}
+}
+
+func TestParseRejectsOtherUnits(t *testing.T) {
+	doc := strings.Replace(testALTO, "<MeasurementUnit>pixel", "<MeasurementUnit>mm10", 1)
+	if _, err := alto.Parse(doc); err == nil {
+		t.Error("Expected an error for mm10 measurement units")
+	}
+}
+
+func TestWriteRoundTrip(t *testing.T) {
+	pages, err := alto.Parse(testALTO)
+	if err != nil {
+		t.Fatalf("Error parsing ALTO: %v", err)
+	}
+
+	written := alto.Write(pages)
+	if !strings.Contains(written, `<String ID="s_1" HPOS="599" VPOS="41" WIDTH="75" HEIGHT="28" WC="0.91" CONTENT="ALS"/>`) {
+		t.Errorf("Expected String element for ALS, got:\n%s", written)
+	}
+	if !strings.Contains(written, `CONTENT="Sir &amp; Madam"`) {
+		t.Errorf("Expected CONTENT to be escaped, got:\n%s", written)
+	}
+	if !strings.Contains(written, `HEIGHT="43" CONTENT="Sir &amp; Madam"`) {
+		t.Errorf("Expected no WC for a word without a confidence, got:\n%s", written)
+	}
+
+	reparsed, err := alto.Parse(written)
+	if err != nil {
+		t.Fatalf("Error parsing written ALTO: %v", err)
+	}
+	if !reflect.DeepEqual(pages, reparsed) {
+		t.Errorf("Expected round trip to preserve pages\nbefore: %+v\nafter:  %+v", pages, reparsed)
+	}
+}
+
+func TestShapes(t *testing.T) {
+	doc := strings.Replace(testALTO, `<String ID="s_2" HPOS="161" VPOS="84" WIDTH="139" HEIGHT="45" WC="0.5" CONTENT="Dear"/>`,
+		`<String ID="s_2" HPOS="161" VPOS="84" WIDTH="139" HEIGHT="45" WC="0.5" CONTENT="Dear"><Shape><Polygon POINTS="161 94 300 84 300 119 161 129"/></Shape></String>`, 1)
+	doc = strings.Replace(doc, `<ComposedBlock ID="cb_1" HPOS="161" VPOS="80" WIDTH="274" HEIGHT="49">`,
+		`<ComposedBlock ID="cb_1" HPOS="161" VPOS="80" WIDTH="274" HEIGHT="49"><Shape><Polygon POINTS="161,90 435,80 435,119 161,129"/></Shape>`, 1)
+
+	pages, err := alto.Parse(doc)
+	if err != nil {
+		t.Fatalf("Error parsing ALTO: %v", err)
+	}
+	area := pages[0].Areas[1]
+	if len(area.Poly) != 4 || area.Poly[1].X != 435 {
+		t.Errorf("Expected the ComposedBlock polygon, got %v", area.Poly)
+	}
+	dear := area.Paragraphs[0].Lines[0].Words[0]
+	if len(dear.Poly) != 4 || dear.Poly[0].Y != 94 {
+		t.Errorf("Expected the String polygon in the older space separated form, got %v", dear.Poly)
+	}
+
+	written := alto.Write(pages)
+	if !strings.Contains(written, `CONTENT="Dear"><Shape><Polygon POINTS="161,94 300,84 300,119 161,129"/></Shape></String>`) {
+		t.Errorf("Expected the String shape written in ALTO 4 form, got:\n%s", written)
+	}
+	reparsed, err := alto.Parse(written)
+	if err != nil {
+		t.Fatalf("Error parsing written ALTO: %v", err)
+	}
+	if !reflect.DeepEqual(pages, reparsed) {
+		t.Errorf("Expected round trip to preserve shapes\nbefore: %+v\nafter:  %+v", pages, reparsed)
+	}
+}
@@ -0,0 +1,308 @@
+// Package alto reads and writes ALTO v4 XML using the same page, area,
+// paragraph, line and word structures as the hOCR parser.
+package alto
+
+import (
+	"encoding/xml"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/lehigh-university-libraries/hocr-edit/internal/models"
+)
+
+type document struct {
+	Description struct {
+		MeasurementUnit        string `xml:"MeasurementUnit"`
+		SourceImageInformation struct {
+			FileName string `xml:"fileName"`
+		} `xml:"sourceImageInformation"`
+	} `xml:"Description"`
+	Layout struct {
+		Pages []page `xml:"Page"`
+	} `xml:"Layout"`
+}
+
+type page struct {
+	ID            string  `xml:"ID,attr"`
+	PhysicalImgNr int     `xml:"PHYSICAL_IMG_NR,attr"`
+	Width         float64 `xml:"WIDTH,attr"`
+	Height        float64 `xml:"HEIGHT,attr"`
+	PrintSpace    blocks  `xml:"PrintSpace"`
+}
+
+// blocks keeps the TextBlock and ComposedBlock children of an element in
+// document order, which is the reading order ALTO producers use
+type blocks struct {
+	Items []block
+	Shape shape
+}
+
+type block struct {
+	ID       string
+	Poly     []models.Point
+	Composed []textBlock
+	Text     *textBlock
+}
+
+// shape is the Shape of an element. Only polygons are read, as the other shapes
+// are rarely used for text.
+type shape struct {
+	Polygon struct {
+		Points string `xml:"POINTS,attr"`
+	} `xml:"Polygon"`
+}
+
+type textBlock struct {
+	ID     string     `xml:"ID,attr"`
+	HPos   float64    `xml:"HPOS,attr"`
+	VPos   float64    `xml:"VPOS,attr"`
+	Width  float64    `xml:"WIDTH,attr"`
+	Height float64    `xml:"HEIGHT,attr"`
+	Shape  shape      `xml:"Shape"`
+	Lines  []textLine `xml:"TextLine"`
+}
+
+type textLine struct {
+	ID      string       `xml:"ID,attr"`
+	HPos    float64      `xml:"HPOS,attr"`
+	VPos    float64      `xml:"VPOS,attr"`
+	Width   float64      `xml:"WIDTH,attr"`
+	Height  float64      `xml:"HEIGHT,attr"`
+	Shape   shape        `xml:"Shape"`
+	Strings []textString `xml:"String"`
+}
+
+type textString struct {
+	ID         string   `xml:"ID,attr"`
+	Content    string   `xml:"CONTENT,attr"`
+	Confidence *float64 `xml:"WC,attr"`
+	HPos       float64  `xml:"HPOS,attr"`
+	VPos       float64  `xml:"VPOS,attr"`
+	Width      float64  `xml:"WIDTH,attr"`
+	Height     float64  `xml:"HEIGHT,attr"`
+	Shape      shape    `xml:"Shape"`
+}
+
+func (b *blocks) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	for {
+		token, err := d.Token()
+		if err != nil {
+			return err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "TextBlock":
+				var tb textBlock
+				if err := d.DecodeElement(&tb, &t); err != nil {
+					return err
+				}
+				b.Items = append(b.Items, block{ID: tb.ID, Text: &tb})
+			case "ComposedBlock":
+				var nested blocks
+				if err := d.DecodeElement(&nested, &t); err != nil {
+					return err
+				}
+				composed := block{ID: attrValue(t, "ID"), Poly: parsePolygon(nested.Shape.Polygon.Points)}
+				for _, item := range nested.Items {
+					if item.Text != nil {
+						composed.Composed = append(composed.Composed, *item.Text)
+					}
+					composed.Composed = append(composed.Composed, item.Composed...)
+				}
+				b.Items = append(b.Items, composed)
+			case "Shape":
+				if err := d.DecodeElement(&b.Shape, &t); err != nil {
+					return err
+				}
+			default:
+				if err := d.Skip(); err != nil {
+					return err
+				}
+			}
+		case xml.EndElement:
+			return nil
+		}
+	}
+}
+
+func attrValue(element xml.StartElement, name string) string {
+	for _, attr := range element.Attr {
+		if attr.Name.Local == name {
+			return attr.Value
+		}
+	}
+	return ""
+}
+
+// Parse reads an ALTO document into pages. A ComposedBlock becomes an ocr_carea
+// holding one ocr_par per TextBlock; a TextBlock directly on the PrintSpace becomes
+// an ocr_par in an implicit area. WC is scaled from 0-1 to the 0-100 x_wconf range,
+// and Shape polygons are kept as the poly of their element.
+// Only pixel measurement units are supported.
+func Parse(altoXML string) ([]models.HOCRPage, error) {
+	var doc document
+	if err := xml.Unmarshal([]byte(altoXML), &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse ALTO: %w", err)
+	}
+
+	unit := strings.TrimSpace(doc.Description.MeasurementUnit)
+	if unit != "" && unit != "pixel" {
+		return nil, fmt.Errorf("unsupported ALTO measurement unit %q", unit)
+	}
+
+	var pages []models.HOCRPage
+	counters := &idCounters{}
+	for i, altoPage := range doc.Layout.Pages {
+		pageNumber := i + 1
+		hocrPage := models.HOCRPage{
+			ID:         altoPage.ID,
+			BBox:       models.BBox{X2: round(altoPage.Width), Y2: round(altoPage.Height)},
+			PageNumber: i,
+		}
+		if hocrPage.ID == "" {
+			hocrPage.ID = fmt.Sprintf("page_%d", pageNumber)
+		}
+		if altoPage.PhysicalImgNr > 0 {
+			hocrPage.PageNumber = altoPage.PhysicalImgNr - 1
+		}
+		if len(doc.Layout.Pages) == 1 {
+			hocrPage.Image = doc.Description.SourceImageInformation.FileName
+		}
+
+		for _, item := range altoPage.PrintSpace.Items {
+			var area models.HOCRArea
+			if item.Text != nil {
+				area.Paragraphs = []models.HOCRParagraph{counters.paragraph(*item.Text, hocrPage.ID)}
+			} else {
+				area.ID, area.Poly = item.ID, item.Poly
+				if area.ID == "" {
+					area.ID = counters.next("block")
+				}
+				for _, tb := range item.Composed {
+					area.Paragraphs = append(area.Paragraphs, counters.paragraph(tb, hocrPage.ID))
+				}
+			}
+
+			boxes := make([]models.BBox, 0, len(area.Paragraphs))
+			for _, paragraph := range area.Paragraphs {
+				boxes = append(boxes, paragraph.BBox)
+			}
+			area.BBox = unionBBoxes(boxes)
+			hocrPage.Areas = append(hocrPage.Areas, area)
+		}
+
+		pages = append(pages, hocrPage)
+	}
+
+	return pages, nil
+}
+
+// idCounters generates IDs for ALTO elements that do not carry one
+type idCounters struct {
+	counts map[string]int
+}
+
+func (c *idCounters) next(prefix string) string {
+	if c.counts == nil {
+		c.counts = make(map[string]int)
+	}
+	c.counts[prefix]++
+	return fmt.Sprintf("%s_%d", prefix, c.counts[prefix])
+}
+
+func (c *idCounters) paragraph(tb textBlock, pageID string) models.HOCRParagraph {
+	paragraph := models.HOCRParagraph{
+		ID:   tb.ID,
+		BBox: toBBox(tb.HPos, tb.VPos, tb.Width, tb.Height),
+		Poly: parsePolygon(tb.Shape.Polygon.Points),
+	}
+	if paragraph.ID == "" {
+		paragraph.ID = c.next("par")
+	}
+
+	for _, altoLine := range tb.Lines {
+		line := models.HOCRLine{
+			ID:     altoLine.ID,
+			PageID: pageID,
+			BBox:   toBBox(altoLine.HPos, altoLine.VPos, altoLine.Width, altoLine.Height),
+			Poly:   parsePolygon(altoLine.Shape.Polygon.Points),
+		}
+		if line.ID == "" {
+			line.ID = c.next("line")
+		}
+
+		for _, altoString := range altoLine.Strings {
+			word := models.HOCRWord{
+				ID:     altoString.ID,
+				Text:   altoString.Content,
+				BBox:   toBBox(altoString.HPos, altoString.VPos, altoString.Width, altoString.Height),
+				Poly:   parsePolygon(altoString.Shape.Polygon.Points),
+				LineID: line.ID,
+			}
+			if word.ID == "" {
+				word.ID = c.next("word")
+			}
+			if altoString.Confidence != nil {
+				word.Confidence = math.Round(*altoString.Confidence * 100)
+			}
+			line.Words = append(line.Words, word)
+		}
+
+		paragraph.Lines = append(paragraph.Lines, line)
+	}
+
+	return paragraph
+}
+
+func toBBox(hpos, vpos, width, height float64) models.BBox {
+	return models.BBox{
+		X1: round(hpos),
+		Y1: round(vpos),
+		X2: round(hpos + width),
+		Y2: round(vpos + height),
+	}
+}
+
+// parsePolygon reads Polygon POINTS, written "x,y x,y ..." in ALTO 4 and
+// "x y x y ..." before it. A polygon of fewer than three points or with a malformed
+// coordinate is ignored.
+func parsePolygon(points string) []models.Point {
+	fields := strings.Fields(strings.ReplaceAll(points, ",", " "))
+	if len(fields) < 6 || len(fields)%2 != 0 {
+		return nil
+	}
+
+	poly := make([]models.Point, 0, len(fields)/2)
+	for i := 0; i < len(fields); i += 2 {
+		x, xErr := strconv.ParseFloat(fields[i], 64)
+		y, yErr := strconv.ParseFloat(fields[i+1], 64)
+		if xErr != nil || yErr != nil {
+			return nil
+		}
+		poly = append(poly, models.Point{X: round(x), Y: round(y)})
+	}
+	return poly
+}
+
+func round(value float64) int {
+	return int(math.Round(value))
+}
+
+func unionBBoxes(boxes []models.BBox) models.BBox {
+	if len(boxes) == 0 {
+		return models.BBox{}
+	}
+
+	union := boxes[0]
+	for _, box := range boxes[1:] {
+		union.X1 = min(union.X1, box.X1)
+		union.Y1 = min(union.Y1, box.Y1)
+		union.X2 = max(union.X2, box.X2)
+		union.Y2 = max(union.Y2, box.Y2)
+	}
+	return union
+}
@@ -0,0 +1,139 @@
+package alto
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strings"
+
+	"github.com/lehigh-university-libraries/hocr-edit/internal/models"
+)
+
+const namespace = "http://www.loc.gov/standards/alto/ns-v4#"
+
+// Write serializes pages as an ALTO v4 document in pixel units. Areas with an ID
+// become ComposedBlocks and every paragraph a TextBlock. x_wconf is scaled to a
+// 0-1 WC, which is left out for words without a confidence, and polys are written
+// as Shape polygons. IDs are expected to be unique across pages, as after
+// hocr.RenumberPages.
+func Write(pages []models.HOCRPage) string {
+	var alto strings.Builder
+
+	alto.WriteString("<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n")
+	alto.WriteString(fmt.Sprintf("<alto xmlns=\"%s\" xmlns:xsi=\"http://www.w3.org/2001/XMLSchema-instance\" ", namespace))
+	alto.WriteString("xsi:schemaLocation=\"http://www.loc.gov/standards/alto/ns-v4# http://www.loc.gov/alto/v4/alto-4-4.xsd\">\n")
+	alto.WriteString("<Description>\n")
+	alto.WriteString("<MeasurementUnit>pixel</MeasurementUnit>\n")
+	if len(pages) > 0 && pages[0].Image != "" {
+		alto.WriteString(fmt.Sprintf("<sourceImageInformation><fileName>%s</fileName></sourceImageInformation>\n", escape(pages[0].Image)))
+	}
+	alto.WriteString("<Processing ID=\"processing_1\"><processingSoftware><softwareName>hocr-edit</softwareName></processingSoftware></Processing>\n")
+	alto.WriteString("</Description>\n")
+	alto.WriteString("<Layout>\n")
+
+	for i, page := range pages {
+		writePage(&alto, page, i+1)
+	}
+
+	alto.WriteString("</Layout>\n")
+	alto.WriteString("</alto>\n")
+
+	return alto.String()
+}
+
+func writePage(alto *strings.Builder, page models.HOCRPage, pageNumber int) {
+	pageID := page.ID
+	if pageID == "" {
+		pageID = fmt.Sprintf("page_%d", pageNumber)
+	}
+
+	alto.WriteString(fmt.Sprintf("<Page ID=\"%s\" PHYSICAL_IMG_NR=\"%d\" %s>\n", escape(pageID), page.PageNumber+1, dimensions(page.BBox)))
+	alto.WriteString(fmt.Sprintf("<PrintSpace %s>\n", position(page.BBox)))
+
+	blockCounter := 1
+	for _, area := range page.Areas {
+		if area.ID != "" {
+			alto.WriteString(fmt.Sprintf("<ComposedBlock ID=\"%s\" %s>\n", escape(area.ID), position(area.BBox)))
+			if len(area.Poly) > 0 {
+				alto.WriteString(shapeElement(area.Poly) + "\n")
+			}
+		}
+
+		for _, paragraph := range area.Paragraphs {
+			blockID := paragraph.ID
+			if blockID == "" {
+				blockID = fmt.Sprintf("%s_block_%d", pageID, blockCounter)
+			}
+			blockCounter++
+			writeTextBlock(alto, blockID, paragraph)
+		}
+
+		if area.ID != "" {
+			alto.WriteString("</ComposedBlock>\n")
+		}
+	}
+
+	alto.WriteString("</PrintSpace>\n")
+	alto.WriteString("</Page>\n")
+}
+
+func writeTextBlock(alto *strings.Builder, blockID string, paragraph models.HOCRParagraph) {
+	alto.WriteString(fmt.Sprintf("<TextBlock ID=\"%s\" %s>\n", escape(blockID), position(paragraph.BBox)))
+	if len(paragraph.Poly) > 0 {
+		alto.WriteString(shapeElement(paragraph.Poly) + "\n")
+	}
+
+	for _, line := range paragraph.Lines {
+		alto.WriteString(fmt.Sprintf("<TextLine ID=\"%s\" %s>%s", escape(line.ID), position(line.BBox), shapeElement(line.Poly)))
+		for i, word := range line.Words {
+			if i > 0 {
+				alto.WriteString("<SP/>")
+			}
+			alto.WriteString(fmt.Sprintf("<String ID=\"%s\" %s%s CONTENT=\"%s\"",
+				escape(word.ID), position(word.BBox), wordConfidence(word.Confidence), escape(word.Text)))
+			if len(word.Poly) > 0 {
+				alto.WriteString(">" + shapeElement(word.Poly) + "</String>")
+			} else {
+				alto.WriteString("/>")
+			}
+		}
+		alto.WriteString("</TextLine>\n")
+	}
+
+	alto.WriteString("</TextBlock>\n")
+}
+
+// shapeElement writes a poly as a Shape polygon, or nothing for an element without one
+func shapeElement(poly []models.Point) string {
+	if len(poly) == 0 {
+		return ""
+	}
+	points := make([]string, len(poly))
+	for i, p := range poly {
+		points[i] = fmt.Sprintf("%d,%d", p.X, p.Y)
+	}
+	return fmt.Sprintf("<Shape><Polygon POINTS=\"%s\"/></Shape>", strings.Join(points, " "))
+}
+
+func position(bbox models.BBox) string {
+	return fmt.Sprintf("HPOS=\"%d\" VPOS=\"%d\" %s", bbox.X1, bbox.Y1, dimensions(bbox))
+}
+
+func dimensions(bbox models.BBox) string {
+	return fmt.Sprintf("WIDTH=\"%d\" HEIGHT=\"%d\"", bbox.X2-bbox.X1, bbox.Y2-bbox.Y1)
+}
+
+// wordConfidence writes a confidence as a 0-1 WC attribute. A WC of 0 means the
+// word is certainly wrong, so it is left out for words without a confidence.
+func wordConfidence(confidence float64) string {
+	if confidence == 0 {
+		return ""
+	}
+	return fmt.Sprintf(" WC=\"%.2f\"", confidence/100)
+}
+
+func escape(text string) string {
+	var escaped strings.Builder
+	// EscapeText only fails when the writer does
+	_ = xml.EscapeText(&escaped, []byte(text))
+	return escaped.String()
+}
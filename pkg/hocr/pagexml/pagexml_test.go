@@ -0,0 +1,139 @@
+package pagexml_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/lehigh-university-libraries/hocr-edit/pkg/hocr/pagexml"
+)
+
+const testPAGE = `<?xml version="1.0" encoding="UTF-8"?>
+<PcGts xmlns="http://schema.primaresearch.org/PAGE/gts/pagecontent/2013-07-15">
+<Metadata><Creator>Transkribus</Creator></Metadata>
+<Page imageFilename="letter.jpg" imageWidth="1120" imageHeight="1368">
+<ReadingOrder>
+<OrderedGroup id="ro_1">
+<RegionRefIndexed index="1" regionRef="r_body"/>
+<RegionRefIndexed index="0" regionRef="r_header"/>
+</OrderedGroup>
+</ReadingOrder>
+<TextRegion id="r_body">
+<Coords points="161,80 435,80 435,129 161,129"/>
+<TextLine id="l_2">
+<Coords points="161,84 417,80 417,123 161,129"/>
+<Word id="w_2">
+<Coords points="161,84 300,84 300,129 161,129"/>
+<TextEquiv conf="0.5"><Unicode>Dear</Unicode></TextEquiv>
+</Word>
+<Word id="w_3">
+<Coords points="324,80 417,80 417,123 324,123"/>
+<TextEquiv><Unicode>Sir &amp; Madam</Unicode></TextEquiv>
+</Word>
+<TextEquiv><Unicode>Dear Sir &amp; Madam</Unicode></TextEquiv>
+</TextLine>
+</TextRegion>
+<TextRegion id="r_header">
+<Coords points="600,40 700,40 700,70 600,70"/>
+<TextLine id="l_1">
+<Coords points="600,40 700,40 700,70 600,70"/>
+<TextEquiv conf="0.91"><Unicode>ALS 1862</Unicode></TextEquiv>
+</TextLine>
+</TextRegion>
+</Page>
+</PcGts>`
+
+func TestParse(t *testing.T) {
+	page, err := pagexml.Parse(testPAGE)
+	if err != nil {
+		t.Fatalf("Error parsing PAGE XML: %v", err)
+	}
+
+	if page.Image != "letter.jpg" || page.BBox.X2 != 1120 || page.BBox.Y2 != 1368 {
+		t.Errorf("Unexpected page %+v", page)
+	}
+
+	if len(page.Areas) != 2 {
+		t.Fatalf("Expected 2 areas, got %d", len(page.Areas))
+	}
+	if page.Areas[0].Paragraphs[0].ID != "r_header" || page.Areas[1].Paragraphs[0].ID != "r_body" {
+		t.Errorf("Expected regions in reading order, got %s then %s",
+			page.Areas[0].Paragraphs[0].ID, page.Areas[1].Paragraphs[0].ID)
+	}
+
+	body := page.Areas[1].Paragraphs[0].Lines[0]
+	if body.BBox.X1 != 161 || body.BBox.Y1 != 80 || body.BBox.X2 != 417 || body.BBox.Y2 != 129 {
+		t.Errorf("Expected line polygon reduced to [161 80 417 129], got %+v", body.BBox)
+	}
+	if len(body.Words) != 2 || body.Words[0].Text != "Dear" || body.Words[0].Confidence != 50 || body.Words[1].Text != "Sir & Madam" {
+		t.Errorf("Unexpected words %+v", body.Words)
+	}
+
+	header := page.Areas[0].Paragraphs[0].Lines[0]
+	if len(header.Words) != 2 {
+		t.Fatalf("Expected the line transcription split into 2 words, got %+v", header.Words)
+	}
+	als, year := header.Words[0], header.Words[1]
+	if als.Text != "ALS" || als.Confidence != 91 || als.LineID != "l_1" {
+		t.Errorf("Unexpected word %+v", als)
+	}
+	// 8 characters over 100 pixels: "ALS" takes 3, the space 1 and "1862" 4
+	if als.BBox.X1 != 600 || als.BBox.X2 != 638 || year.BBox.X1 != 650 || year.BBox.X2 != 700 {
+		t.Errorf("Expected proportional word boxes, got %+v and %+v", als.BBox, year.BBox)
+	}
+}
+
+func TestParseRejectsBadPoints(t *testing.T) {
+	doc := strings.Replace(testPAGE, `points="600,40 700,40 700,70 600,70"/>
+<TextLine`, `points="600;40"/>
+<TextLine`, 1)
+	if _, err := pagexml.Parse(doc); err == nil {
+		t.Error("Expected an error for malformed Coords points")
+	}
+}
+
+func TestWriteRoundTrip(t *testing.T) {
+	page, err := pagexml.Parse(testPAGE)
+	if err != nil {
+		t.Fatalf("Error parsing PAGE XML: %v", err)
+	}
+
+	written := pagexml.Write(page)
+	if !strings.Contains(written, `<RegionRefIndexed index="0" regionRef="r_header"/>`) {
+		t.Errorf("Expected r_header first in the reading order, got:\n%s", written)
+	}
+	if !strings.Contains(written, `<Word id="w_2"><Coords points="161,84 300,84 300,129 161,129"/><TextEquiv conf="0.50"><Unicode>Dear</Unicode></TextEquiv></Word>`) {
+		t.Errorf("Expected Word element for Dear, got:\n%s", written)
+	}
+	if !strings.Contains(written, `<TextEquiv><Unicode>Sir &amp; Madam</Unicode></TextEquiv></Word>`) {
+		t.Errorf("Expected no conf for a word without a confidence, got:\n%s", written)
+	}
+
+	reparsed, err := pagexml.Parse(written)
+	if err != nil {
+		t.Fatalf("Error parsing written PAGE XML: %v", err)
+	}
+	if !reflect.DeepEqual(page, reparsed) {
+		t.Errorf("Expected round trip to preserve the page\nbefore: %+v\nafter:  %+v", page, reparsed)
+	}
+}
+
+func TestPolygons(t *testing.T) {
+	page, err := pagexml.Parse(testPAGE)
+	if err != nil {
+		t.Fatalf("Error parsing PAGE XML: %v", err)
+	}
+
+	body := page.Areas[1].Paragraphs[0]
+	if body.Poly != nil {
+		t.Errorf("Expected rectangular Coords to leave no poly, got %v", body.Poly)
+	}
+	if line := body.Lines[0]; len(line.Poly) != 4 || line.Poly[1].Y != 80 || line.BBox.Y1 != 80 {
+		t.Errorf("Expected the slanted line kept as a poly, got %v in %+v", line.Poly, line.BBox)
+	}
+
+	if written := pagexml.Write(page); !strings.Contains(written, `<TextLine id="l_2">
+<Coords points="161,84 417,80 417,123 161,129"/>`) {
+		t.Errorf("Expected the poly written as the line's Coords, got:\n%s", written)
+	}
+}
@@ -0,0 +1,293 @@
+// Package pagexml reads and writes PRImA PAGE XML, as exchanged by Transkribus and
+// eScriptorium, using the same page, area, paragraph, line and word structures as
+// the hOCR parser.
+package pagexml
+
+import (
+	"encoding/xml"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/lehigh-university-libraries/hocr-edit/internal/models"
+)
+
+type document struct {
+	Page struct {
+		ImageFilename string `xml:"imageFilename,attr"`
+		ImageWidth    int    `xml:"imageWidth,attr"`
+		ImageHeight   int    `xml:"imageHeight,attr"`
+		ReadingOrder  struct {
+			OrderedGroup struct {
+				Refs []regionRef `xml:"RegionRefIndexed"`
+			} `xml:"OrderedGroup"`
+		} `xml:"ReadingOrder"`
+		TextRegions []textRegion `xml:"TextRegion"`
+	} `xml:"Page"`
+}
+
+type regionRef struct {
+	Index     int    `xml:"index,attr"`
+	RegionRef string `xml:"regionRef,attr"`
+}
+
+type coords struct {
+	Points string `xml:"points,attr"`
+}
+
+type textEquiv struct {
+	Conf    *float64 `xml:"conf,attr"`
+	Unicode string   `xml:"Unicode"`
+}
+
+type textRegion struct {
+	ID        string      `xml:"id,attr"`
+	Coords    coords      `xml:"Coords"`
+	TextLines []textLine  `xml:"TextLine"`
+	TextEquiv []textEquiv `xml:"TextEquiv"`
+}
+
+type textLine struct {
+	ID        string      `xml:"id,attr"`
+	Coords    coords      `xml:"Coords"`
+	Words     []word      `xml:"Word"`
+	TextEquiv []textEquiv `xml:"TextEquiv"`
+}
+
+type word struct {
+	ID        string      `xml:"id,attr"`
+	Coords    coords      `xml:"Coords"`
+	TextEquiv []textEquiv `xml:"TextEquiv"`
+}
+
+// Parse reads a PAGE document into a single page. Each TextRegion becomes an ocr_par
+// in an implicit area, in ReadingOrder when the document has one. Coords give the
+// bboxes and polys, and TextEquiv conf is scaled to the 0-100 x_wconf range. Lines
+// without Word elements are split on whitespace into words sized by their length.
+func Parse(pageXML string) (models.HOCRPage, error) {
+	var doc document
+	if err := xml.Unmarshal([]byte(pageXML), &doc); err != nil {
+		return models.HOCRPage{}, fmt.Errorf("failed to parse PAGE XML: %w", err)
+	}
+
+	page := models.HOCRPage{
+		ID:    "page_1",
+		BBox:  models.BBox{X2: doc.Page.ImageWidth, Y2: doc.Page.ImageHeight},
+		Image: doc.Page.ImageFilename,
+	}
+
+	regions, err := readingOrder(doc.Page.TextRegions, doc.Page.ReadingOrder.OrderedGroup.Refs)
+	if err != nil {
+		return models.HOCRPage{}, err
+	}
+
+	counters := &idCounters{}
+	for _, region := range regions {
+		paragraph, err := counters.paragraph(region, page.ID)
+		if err != nil {
+			return models.HOCRPage{}, err
+		}
+		page.Areas = append(page.Areas, models.HOCRArea{
+			BBox:       paragraph.BBox,
+			Paragraphs: []models.HOCRParagraph{paragraph},
+		})
+	}
+
+	return page, nil
+}
+
+// readingOrder sorts the regions by their RegionRefIndexed index. Regions the
+// reading order does not mention follow in document order.
+func readingOrder(regions []textRegion, refs []regionRef) ([]textRegion, error) {
+	if len(refs) == 0 {
+		return regions, nil
+	}
+
+	sort.SliceStable(refs, func(i, j int) bool {
+		return refs[i].Index < refs[j].Index
+	})
+
+	byID := make(map[string]textRegion, len(regions))
+	for _, region := range regions {
+		byID[region.ID] = region
+	}
+
+	ordered := make([]textRegion, 0, len(regions))
+	used := make(map[string]bool, len(regions))
+	for _, ref := range refs {
+		region, exists := byID[ref.RegionRef]
+		if !exists {
+			// the reading order also lists non-text regions such as images
+			continue
+		}
+		if used[ref.RegionRef] {
+			return nil, fmt.Errorf("region %q appears twice in the reading order", ref.RegionRef)
+		}
+		used[ref.RegionRef] = true
+		ordered = append(ordered, region)
+	}
+
+	for _, region := range regions {
+		if !used[region.ID] {
+			ordered = append(ordered, region)
+		}
+	}
+
+	return ordered, nil
+}
+
+// idCounters generates IDs for PAGE elements that do not carry one
+type idCounters struct {
+	counts map[string]int
+}
+
+func (c *idCounters) next(prefix string) string {
+	if c.counts == nil {
+		c.counts = make(map[string]int)
+	}
+	c.counts[prefix]++
+	return fmt.Sprintf("%s_%d", prefix, c.counts[prefix])
+}
+
+func (c *idCounters) paragraph(region textRegion, pageID string) (models.HOCRParagraph, error) {
+	paragraph := models.HOCRParagraph{ID: region.ID}
+	if paragraph.ID == "" {
+		paragraph.ID = c.next("par")
+	}
+
+	var err error
+	paragraph.BBox, paragraph.Poly, err = parsePoints(region.Coords.Points)
+	if err != nil {
+		return models.HOCRParagraph{}, fmt.Errorf("region %s: %w", paragraph.ID, err)
+	}
+
+	for _, pageLine := range region.TextLines {
+		line := models.HOCRLine{ID: pageLine.ID, PageID: pageID}
+		if line.ID == "" {
+			line.ID = c.next("line")
+		}
+
+		line.BBox, line.Poly, err = parsePoints(pageLine.Coords.Points)
+		if err != nil {
+			return models.HOCRParagraph{}, fmt.Errorf("line %s: %w", line.ID, err)
+		}
+
+		if len(pageLine.Words) > 0 {
+			for _, pageWord := range pageLine.Words {
+				hocrWord := models.HOCRWord{ID: pageWord.ID, LineID: line.ID}
+				if hocrWord.ID == "" {
+					hocrWord.ID = c.next("word")
+				}
+				hocrWord.BBox, hocrWord.Poly, err = parsePoints(pageWord.Coords.Points)
+				if err != nil {
+					return models.HOCRParagraph{}, fmt.Errorf("word %s: %w", hocrWord.ID, err)
+				}
+				hocrWord.Text, hocrWord.Confidence = text(pageWord.TextEquiv)
+				line.Words = append(line.Words, hocrWord)
+			}
+		} else {
+			lineText, confidence := text(pageLine.TextEquiv)
+			line.Words = c.splitLine(line, lineText, confidence)
+		}
+
+		paragraph.Lines = append(paragraph.Lines, line)
+	}
+
+	return paragraph, nil
+}
+
+// splitLine estimates word boxes for a line that only has a transcription
+func (c *idCounters) splitLine(line models.HOCRLine, lineText string, confidence float64) []models.HOCRWord {
+	texts := strings.Fields(lineText)
+	if len(texts) == 0 {
+		return nil
+	}
+
+	// spaces between words count as one character each
+	characters := len(texts) - 1
+	for _, t := range texts {
+		characters += utf8.RuneCountInString(t)
+	}
+	width := float64(line.BBox.X2 - line.BBox.X1)
+
+	words := make([]models.HOCRWord, 0, len(texts))
+	offset := 0
+	for _, t := range texts {
+		length := utf8.RuneCountInString(t)
+		words = append(words, models.HOCRWord{
+			ID:   c.next("word"),
+			Text: t,
+			BBox: models.BBox{
+				X1: line.BBox.X1 + round(width*float64(offset)/float64(characters)),
+				Y1: line.BBox.Y1,
+				X2: line.BBox.X1 + round(width*float64(offset+length)/float64(characters)),
+				Y2: line.BBox.Y2,
+			},
+			Confidence: confidence,
+			LineID:     line.ID,
+		})
+		offset += length + 1
+	}
+
+	return words
+}
+
+// text returns the first transcription and its confidence on the 0-100 scale
+func text(equivs []textEquiv) (string, float64) {
+	if len(equivs) == 0 {
+		return "", 0
+	}
+
+	var confidence float64
+	if equivs[0].Conf != nil {
+		confidence = math.Round(*equivs[0].Conf * 100)
+	}
+	return equivs[0].Unicode, confidence
+}
+
+// parsePoints returns the bounding box of a "x1,y1 x2,y2 ..." polygon, and the
+// polygon itself when it is more than the corners of the box
+func parsePoints(points string) (models.BBox, []models.Point, error) {
+	pairs := strings.Fields(points)
+	if len(pairs) == 0 {
+		return models.BBox{}, nil, nil
+	}
+
+	bbox := models.BBox{X1: math.MaxInt, Y1: math.MaxInt, X2: math.MinInt, Y2: math.MinInt}
+	poly := make([]models.Point, 0, len(pairs))
+	for _, pair := range pairs {
+		xs, ys, ok := strings.Cut(pair, ",")
+		if !ok {
+			return models.BBox{}, nil, fmt.Errorf("invalid point %q", pair)
+		}
+		x, err := strconv.ParseFloat(xs, 64)
+		if err != nil {
+			return models.BBox{}, nil, fmt.Errorf("invalid point %q: %w", pair, err)
+		}
+		y, err := strconv.ParseFloat(ys, 64)
+		if err != nil {
+			return models.BBox{}, nil, fmt.Errorf("invalid point %q: %w", pair, err)
+		}
+
+		point := models.Point{X: round(x), Y: round(y)}
+		poly = append(poly, point)
+		bbox.X1 = min(bbox.X1, point.X)
+		bbox.Y1 = min(bbox.Y1, point.Y)
+		bbox.X2 = max(bbox.X2, point.X)
+		bbox.Y2 = max(bbox.Y2, point.Y)
+	}
+
+	for _, point := range poly {
+		if (point.X != bbox.X1 && point.X != bbox.X2) || (point.Y != bbox.Y1 && point.Y != bbox.Y2) {
+			return bbox, poly, nil
+		}
+	}
+	return bbox, nil, nil
+}
+
+func round(value float64) int {
+	return int(math.Round(value))
+}
@@ -0,0 +1,119 @@
+package pagexml
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lehigh-university-libraries/hocr-edit/internal/models"
+)
+
+const namespace = "http://schema.primaresearch.org/PAGE/gts/pagecontent/2019-07-15"
+
+// Write serializes a page as a PAGE 2019 document. Every paragraph becomes a
+// TextRegion listed in that order in the ReadingOrder. Polys are written as Coords,
+// or bounding boxes as four point Coords where there is no poly. x_wconf is scaled
+// to a 0-1 conf, which is left out for words without a confidence.
+func Write(page models.HOCRPage) string {
+	var pageXML strings.Builder
+	now := time.Now().UTC().Format("2006-01-02T15:04:05")
+
+	pageXML.WriteString("<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n")
+	pageXML.WriteString(fmt.Sprintf("<PcGts xmlns=\"%s\" xmlns:xsi=\"http://www.w3.org/2001/XMLSchema-instance\" ", namespace))
+	pageXML.WriteString(fmt.Sprintf("xsi:schemaLocation=\"%s %s/pagecontent.xsd\">\n", namespace, namespace))
+	pageXML.WriteString("<Metadata>\n")
+	pageXML.WriteString("<Creator>hocr-edit</Creator>\n")
+	pageXML.WriteString(fmt.Sprintf("<Created>%s</Created>\n", now))
+	pageXML.WriteString(fmt.Sprintf("<LastChange>%s</LastChange>\n", now))
+	pageXML.WriteString("</Metadata>\n")
+	pageXML.WriteString(fmt.Sprintf("<Page imageFilename=\"%s\" imageWidth=\"%d\" imageHeight=\"%d\">\n",
+		escape(page.Image), page.BBox.X2-page.BBox.X1, page.BBox.Y2-page.BBox.Y1))
+
+	var paragraphs []models.HOCRParagraph
+	for _, area := range page.Areas {
+		paragraphs = append(paragraphs, area.Paragraphs...)
+	}
+
+	regionIDs := make([]string, len(paragraphs))
+	for i, paragraph := range paragraphs {
+		regionIDs[i] = paragraph.ID
+		if regionIDs[i] == "" {
+			regionIDs[i] = fmt.Sprintf("region_%d", i+1)
+		}
+	}
+
+	if len(regionIDs) > 0 {
+		pageXML.WriteString("<ReadingOrder>\n<OrderedGroup id=\"ro_1\">\n")
+		for i, regionID := range regionIDs {
+			pageXML.WriteString(fmt.Sprintf("<RegionRefIndexed index=\"%d\" regionRef=\"%s\"/>\n", i, escape(regionID)))
+		}
+		pageXML.WriteString("</OrderedGroup>\n</ReadingOrder>\n")
+	}
+
+	for i, paragraph := range paragraphs {
+		writeTextRegion(&pageXML, regionIDs[i], paragraph)
+	}
+
+	pageXML.WriteString("</Page>\n")
+	pageXML.WriteString("</PcGts>\n")
+
+	return pageXML.String()
+}
+
+func writeTextRegion(pageXML *strings.Builder, regionID string, paragraph models.HOCRParagraph) {
+	pageXML.WriteString(fmt.Sprintf("<TextRegion id=\"%s\" type=\"paragraph\">\n", escape(regionID)))
+	pageXML.WriteString(fmt.Sprintf("<Coords points=\"%s\"/>\n", points(paragraph.BBox, paragraph.Poly)))
+
+	lineTexts := make([]string, 0, len(paragraph.Lines))
+	for _, line := range paragraph.Lines {
+		pageXML.WriteString(fmt.Sprintf("<TextLine id=\"%s\">\n", escape(line.ID)))
+		pageXML.WriteString(fmt.Sprintf("<Coords points=\"%s\"/>\n", points(line.BBox, line.Poly)))
+
+		wordTexts := make([]string, 0, len(line.Words))
+		for _, word := range line.Words {
+			pageXML.WriteString(fmt.Sprintf("<Word id=\"%s\">", escape(word.ID)))
+			pageXML.WriteString(fmt.Sprintf("<Coords points=\"%s\"/>", points(word.BBox, word.Poly)))
+			pageXML.WriteString(fmt.Sprintf("<TextEquiv%s><Unicode>%s</Unicode></TextEquiv>", conf(word.Confidence), escape(word.Text)))
+			pageXML.WriteString("</Word>\n")
+			wordTexts = append(wordTexts, word.Text)
+		}
+
+		lineText := strings.Join(wordTexts, " ")
+		pageXML.WriteString(fmt.Sprintf("<TextEquiv><Unicode>%s</Unicode></TextEquiv>\n", escape(lineText)))
+		pageXML.WriteString("</TextLine>\n")
+		lineTexts = append(lineTexts, lineText)
+	}
+
+	pageXML.WriteString(fmt.Sprintf("<TextEquiv><Unicode>%s</Unicode></TextEquiv>\n", escape(strings.Join(lineTexts, "\n"))))
+	pageXML.WriteString("</TextRegion>\n")
+}
+
+// conf writes a confidence as a 0-1 conf attribute, leaving it out for the zero
+// confidence of a word without one, as the reader gives those
+func conf(confidence float64) string {
+	if confidence == 0 {
+		return ""
+	}
+	return fmt.Sprintf(" conf=\"%.2f\"", confidence/100)
+}
+
+// points writes a poly, or else a bounding box as the clockwise polygon PAGE expects
+func points(bbox models.BBox, poly []models.Point) string {
+	if len(poly) > 0 {
+		pairs := make([]string, len(poly))
+		for i, p := range poly {
+			pairs[i] = fmt.Sprintf("%d,%d", p.X, p.Y)
+		}
+		return strings.Join(pairs, " ")
+	}
+	return fmt.Sprintf("%d,%d %d,%d %d,%d %d,%d",
+		bbox.X1, bbox.Y1, bbox.X2, bbox.Y1, bbox.X2, bbox.Y2, bbox.X1, bbox.Y2)
+}
+
+func escape(text string) string {
+	var escaped strings.Builder
+	// EscapeText only fails when the writer does
+	_ = xml.EscapeText(&escaped, []byte(text))
+	return escaped.String()
+}
@@ -6,6 +6,7 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/lehigh-university-libraries/hocr-edit/internal/models"
 )
@@ -18,16 +19,19 @@ type XMLElement struct {
 }
 
 func ParseHOCRLines(hocrXML string) ([]models.HOCRLine, error) {
-	var doc XMLElement
-
-	decoder := xml.NewDecoder(strings.NewReader(hocrXML))
-	if err := decoder.Decode(&doc); err != nil {
-		return nil, fmt.Errorf("failed to parse XML: %w", err)
+	pages, err := ParseHOCRPages(hocrXML)
+	if err != nil {
+		return nil, err
 	}
 
 	var lines []models.HOCRLine
-
-	traverseLinesElements(doc, &lines)
+	for _, page := range pages {
+		for _, area := range page.Areas {
+			for _, paragraph := range area.Paragraphs {
+				lines = append(lines, paragraph.Lines...)
+			}
+		}
+	}
 
 	return lines, nil
 }
@@ -47,6 +51,281 @@ func ParseHOCRWords(hocrXML string) ([]models.HOCRWord, error) {
 	return words, nil
 }
 
+// ParseHOCRPages parses the document into its page/area/paragraph/line/word tree.
+// Lines that are not wrapped in an ocr_par or ocr_carea are collected into
+// implicit paragraphs and areas with an empty ID so that no words are lost.
+func ParseHOCRPages(hocrXML string) ([]models.HOCRPage, error) {
+	var doc XMLElement
+
+	decoder := xml.NewDecoder(strings.NewReader(hocrXML))
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("failed to parse XML: %w", err)
+	}
+
+	var pages []models.HOCRPage
+	collectPages(doc, &pages)
+
+	if len(pages) == 0 {
+		page := models.HOCRPage{ID: "page_1", Areas: collectAreas(doc)}
+		if len(page.Areas) > 0 {
+			page.BBox = unionAreaBoxes(page.Areas)
+			pages = append(pages, page)
+		}
+	}
+
+	// pages take the language of the document unless they have their own
+	for i := range pages {
+		if pages[i].Lang == "" {
+			pages[i].Lang = elementLang(doc)
+		}
+	}
+
+	return pages, nil
+}
+
+func collectPages(element XMLElement, pages *[]models.HOCRPage) {
+	if hasClass(element, "ocr_page") {
+		title := elementTitle(element)
+		properties := titleProperties(title)
+
+		page := models.HOCRPage{
+			ID:    elementID(element),
+			Image: strings.Trim(properties["image"], `"'`),
+			Lang:  elementLang(element),
+		}
+		page.BBox, _ = parseBBox(title)
+		if ppageno, err := strconv.Atoi(properties["ppageno"]); err == nil {
+			page.PageNumber = ppageno
+		} else {
+			page.PageNumber = len(*pages)
+		}
+
+		page.Areas = collectAreas(element)
+		for a := range page.Areas {
+			for p := range page.Areas[a].Paragraphs {
+				for l := range page.Areas[a].Paragraphs[p].Lines {
+					page.Areas[a].Paragraphs[p].Lines[l].PageID = page.ID
+				}
+			}
+		}
+
+		*pages = append(*pages, page)
+		return
+	}
+
+	for _, child := range element.Children {
+		collectPages(child, pages)
+	}
+}
+
+func collectAreas(element XMLElement) []models.HOCRArea {
+	var areas []models.HOCRArea
+	var stray []XMLElement
+
+	flush := func() {
+		if len(stray) > 0 {
+			area := models.HOCRArea{Paragraphs: collectParagraphs(XMLElement{Children: stray})}
+			area.BBox = unionParagraphBoxes(area.Paragraphs)
+			areas = append(areas, area)
+			stray = nil
+		}
+	}
+
+	for _, child := range element.Children {
+		switch {
+		case hasClass(child, "ocr_carea"):
+			flush()
+			area := models.HOCRArea{ID: elementID(child), Paragraphs: collectParagraphs(child)}
+			area.BBox, _ = parseBBox(elementTitle(child))
+			area.Poly = parsePoly(elementTitle(child))
+			areas = append(areas, area)
+		case hasClass(child, "ocr_par") || isLineElement(child):
+			stray = append(stray, child)
+		default:
+			nested := collectAreas(child)
+			if len(nested) > 0 {
+				flush()
+				areas = append(areas, nested...)
+			}
+		}
+	}
+	flush()
+
+	return areas
+}
+
+func collectParagraphs(element XMLElement) []models.HOCRParagraph {
+	var paragraphs []models.HOCRParagraph
+	var implicit *models.HOCRParagraph
+
+	flush := func() {
+		if implicit != nil {
+			implicit.BBox = unionLineBoxes(implicit.Lines)
+			paragraphs = append(paragraphs, *implicit)
+			implicit = nil
+		}
+	}
+
+	for _, child := range element.Children {
+		switch {
+		case hasClass(child, "ocr_par"):
+			flush()
+			paragraph := models.HOCRParagraph{ID: elementID(child), Lang: elementLang(child)}
+			paragraph.BBox, _ = parseBBox(elementTitle(child))
+			paragraph.Poly = parsePoly(elementTitle(child))
+			traverseLinesElements(child, &paragraph.Lines)
+			paragraphs = append(paragraphs, paragraph)
+		case isLineElement(child):
+			if implicit == nil {
+				implicit = &models.HOCRParagraph{}
+			}
+			traverseLinesElements(child, &implicit.Lines)
+		default:
+			nested := collectParagraphs(child)
+			if len(nested) > 0 {
+				flush()
+				paragraphs = append(paragraphs, nested...)
+			}
+		}
+	}
+	flush()
+
+	return paragraphs
+}
+
+func unionAreaBoxes(areas []models.HOCRArea) models.BBox {
+	boxes := make([]models.BBox, 0, len(areas))
+	for _, area := range areas {
+		boxes = append(boxes, area.BBox)
+	}
+	return unionBBoxes(boxes)
+}
+
+func unionParagraphBoxes(paragraphs []models.HOCRParagraph) models.BBox {
+	boxes := make([]models.BBox, 0, len(paragraphs))
+	for _, paragraph := range paragraphs {
+		boxes = append(boxes, paragraph.BBox)
+	}
+	return unionBBoxes(boxes)
+}
+
+func unionLineBoxes(lines []models.HOCRLine) models.BBox {
+	boxes := make([]models.BBox, 0, len(lines))
+	for _, line := range lines {
+		boxes = append(boxes, line.BBox)
+	}
+	return unionBBoxes(boxes)
+}
+
+func unionBBoxes(boxes []models.BBox) models.BBox {
+	if len(boxes) == 0 {
+		return models.BBox{}
+	}
+
+	union := boxes[0]
+	for _, box := range boxes[1:] {
+		union.X1 = min(union.X1, box.X1)
+		union.Y1 = min(union.Y1, box.Y1)
+		union.X2 = max(union.X2, box.X2)
+		union.Y2 = max(union.Y2, box.Y2)
+	}
+	return union
+}
+
+func hasClass(element XMLElement, class string) bool {
+	for _, attr := range element.Attrs {
+		if attr.Name.Local != "class" {
+			continue
+		}
+		for _, c := range strings.Fields(attr.Value) {
+			if c == class {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func elementID(element XMLElement) string {
+	for _, attr := range element.Attrs {
+		if attr.Name.Local == "id" {
+			return attr.Value
+		}
+	}
+	return ""
+}
+
+// elementLang reads the lang or xml:lang attribute of an element
+func elementLang(element XMLElement) string {
+	for _, attr := range element.Attrs {
+		if attr.Name.Local == "lang" {
+			return attr.Value
+		}
+	}
+	return ""
+}
+
+func elementTitle(element XMLElement) string {
+	for _, attr := range element.Attrs {
+		if attr.Name.Local == "title" {
+			return attr.Value
+		}
+	}
+	return ""
+}
+
+func parseBBox(title string) (models.BBox, bool) {
+	var bbox models.BBox
+	matches := bboxRegex.FindStringSubmatch(title)
+	if len(matches) != 5 {
+		return bbox, false
+	}
+
+	// the regex only matches digits so these conversions cannot fail
+	bbox.X1, _ = strconv.Atoi(matches[1])
+	bbox.Y1, _ = strconv.Atoi(matches[2])
+	bbox.X2, _ = strconv.Atoi(matches[3])
+	bbox.Y2, _ = strconv.Atoi(matches[4])
+	return bbox, true
+}
+
+var bboxRegex = regexp.MustCompile(`bbox\s+(\d+)\s+(\d+)\s+(\d+)\s+(\d+)`)
+
+// titleProperties splits an hOCR title into its semicolon separated properties,
+// keyed by property name. Semicolons inside double quotes (e.g. in an image
+// path) do not split. Values are the raw text after the name.
+func titleProperties(title string) map[string]string {
+	properties := make(map[string]string)
+
+	var parts []string
+	var current strings.Builder
+	inQuotes := false
+	for _, r := range title {
+		switch {
+		case r == '"':
+			inQuotes = !inQuotes
+			current.WriteRune(r)
+		case r == ';' && !inQuotes:
+			parts = append(parts, current.String())
+			current.Reset()
+		default:
+			current.WriteRune(r)
+		}
+	}
+	parts = append(parts, current.String())
+
+	for _, part := range parts {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, value, _ := strings.Cut(part, " ")
+		properties[name] = strings.TrimSpace(value)
+	}
+
+	return properties
+}
+
 func traverseLinesElements(element XMLElement, lines *[]models.HOCRLine) {
 	if isLineElement(element) {
 		line, err := parseLineElement(element)
@@ -86,9 +365,18 @@ func traverseElementsWithLineContext(element XMLElement, words *[]models.HOCRWor
 	}
 }
 
+// lineClasses are the hOCR classes that hold a single line of words.
+// Tesseract emits the caption, header and textfloat variants alongside ocr_line.
+var lineClasses = map[string]bool{
+	"ocr_line":      true,
+	"ocr_caption":   true,
+	"ocr_header":    true,
+	"ocr_textfloat": true,
+}
+
 func isLineElement(element XMLElement) bool {
-	for _, attr := range element.Attrs {
-		if attr.Name.Local == "class" && strings.Contains(attr.Value, "ocr_line") {
+	for class := range lineClasses {
+		if hasClass(element, class) {
 			return true
 		}
 	}
@@ -157,6 +445,28 @@ func parseLineTitleAttribute(title string, line *models.HOCRLine) error {
 		}
 	}
 
+	// the other properties are optional, so a malformed one is skipped rather than
+	// losing the line
+	line.Poly = parsePoly(title)
+	properties := titleProperties(title)
+	if fields := strings.Fields(properties["baseline"]); len(fields) == 2 {
+		slope, slopeErr := strconv.ParseFloat(fields[0], 64)
+		offset, offsetErr := strconv.ParseFloat(fields[1], 64)
+		if slopeErr == nil && offsetErr == nil {
+			line.Baseline = &models.Baseline{Slope: slope, Offset: offset}
+		}
+	}
+	for name, value := range map[string]*float64{
+		"textangle":    &line.TextAngle,
+		"x_size":       &line.XSize,
+		"x_descenders": &line.XDescenders,
+		"x_ascenders":  &line.XAscenders,
+	} {
+		if number, err := strconv.ParseFloat(properties[name], 64); err == nil {
+			*value = number
+		}
+	}
+
 	return nil
 }
 
@@ -167,6 +477,8 @@ func parseWordElement(element XMLElement) (models.HOCRWord, error) {
 		switch attr.Name.Local {
 		case "id":
 			word.ID = attr.Value
+		case "lang":
+			word.Lang = attr.Value
 		case "title":
 			if err := parseTitleAttribute(attr.Value, &word); err != nil {
 				return word, fmt.Errorf("failed to parse title attribute: %w", err)
@@ -175,10 +487,115 @@ func parseWordElement(element XMLElement) (models.HOCRWord, error) {
 	}
 
 	word.Text = strings.TrimSpace(element.Content)
+	word.Poly = parsePoly(elementTitle(element))
+
+	properties := titleProperties(elementTitle(element))
+	for _, name := range []string{"x_bboxes", "x_stale_bboxes"} {
+		if value, ok := properties[name]; ok {
+			boxes, err := parseCharBoxes(value)
+			if err != nil {
+				return word, fmt.Errorf("invalid %s: %w", name, err)
+			}
+			word.CharBoxes = boxes
+			word.CharBoxesStale = name == "x_stale_bboxes"
+			break
+		}
+	}
+
+	if err := parseCharElements(element, &word); err != nil {
+		return word, err
+	}
 
 	return word, nil
 }
 
+// parseCharElements reads words written with one ocrx_cinfo span per character, as
+// Tesseract does, taking the text, boxes and confidences from the spans
+func parseCharElements(element XMLElement, word *models.HOCRWord) error {
+	var text strings.Builder
+	var boxes []models.BBox
+	var confidences []float64
+	for _, child := range element.Children {
+		if !hasClass(child, "ocrx_cinfo") {
+			continue
+		}
+
+		properties := titleProperties(elementTitle(child))
+		box, ok := parseBBox(elementTitle(child))
+		if value, exists := properties["x_bboxes"]; exists {
+			parsed, err := parseCharBoxes(value)
+			if err != nil || len(parsed) != 1 {
+				return fmt.Errorf("invalid ocrx_cinfo x_bboxes %q", value)
+			}
+			box, ok = parsed[0], true
+		}
+		if ok {
+			boxes = append(boxes, box)
+		}
+		if confidence, err := strconv.ParseFloat(properties["x_conf"], 64); err == nil {
+			confidences = append(confidences, confidence)
+		}
+		text.WriteString(child.Content)
+	}
+	if text.Len() == 0 {
+		return nil
+	}
+
+	// boxes and confidences are only kept when every character has one
+	word.Text = strings.TrimSpace(word.Text + text.String())
+	characters := utf8.RuneCountInString(word.Text)
+	if len(boxes) == characters {
+		word.CharBoxes = boxes
+	}
+	if len(confidences) == characters {
+		word.CharConfidences = confidences
+	}
+	return nil
+}
+
+// parseCharBoxes reads an x_bboxes value, four coordinates per character
+func parseCharBoxes(value string) ([]models.BBox, error) {
+	fields := strings.Fields(value)
+	if len(fields)%4 != 0 {
+		return nil, fmt.Errorf("%d coordinates is not four per character", len(fields))
+	}
+
+	coordinates := make([]int, len(fields))
+	for i, field := range fields {
+		var err error
+		if coordinates[i], err = strconv.Atoi(field); err != nil {
+			return nil, err
+		}
+	}
+
+	boxes := make([]models.BBox, 0, len(fields)/4)
+	for i := 0; i < len(coordinates); i += 4 {
+		boxes = append(boxes, models.BBox{X1: coordinates[i], Y1: coordinates[i+1], X2: coordinates[i+2], Y2: coordinates[i+3]})
+	}
+	return boxes, nil
+}
+
+// parsePoly reads the poly property of a title, x and y for each point. A poly of
+// fewer than three points or with a malformed coordinate is ignored, leaving the
+// element to its bbox.
+func parsePoly(title string) []models.Point {
+	fields := strings.Fields(titleProperties(title)["poly"])
+	if len(fields) < 6 || len(fields)%2 != 0 {
+		return nil
+	}
+
+	poly := make([]models.Point, 0, len(fields)/2)
+	for i := 0; i < len(fields); i += 2 {
+		x, xErr := strconv.Atoi(fields[i])
+		y, yErr := strconv.Atoi(fields[i+1])
+		if xErr != nil || yErr != nil {
+			return nil
+		}
+		poly = append(poly, models.Point{X: x, Y: y})
+	}
+	return poly
+}
+
 func parseTitleAttribute(title string, word *models.HOCRWord) error {
 	bboxRegex := regexp.MustCompile(`bbox\s+(\d+)\s+(\d+)\s+(\d+)\s+(\d+)`)
 	if matches := bboxRegex.FindStringSubmatch(title); len(matches) == 5 {
@@ -205,5 +622,16 @@ func parseTitleAttribute(title string, word *models.HOCRWord) error {
 		}
 	}
 
+	confsRegex := regexp.MustCompile(`x_confs((?:\s+\d+(?:\.\d+)?)+)`)
+	if matches := confsRegex.FindStringSubmatch(title); len(matches) == 2 {
+		for _, field := range strings.Fields(matches[1]) {
+			confidence, err := strconv.ParseFloat(field, 64)
+			if err != nil {
+				return fmt.Errorf("invalid character confidence: %w", err)
+			}
+			word.CharConfidences = append(word.CharConfidences, confidence)
+		}
+	}
+
 	return nil
 }
@@ -3,6 +3,7 @@ package parser_test
 import (
 	"testing"
 
+	"github.com/lehigh-university-libraries/hocr-edit/internal/models"
 	"github.com/lehigh-university-libraries/hocr-edit/pkg/hocr/parser"
 )
 
@@ -126,3 +127,266 @@ func TestParseHOCRLines(t *testing.T) {
 		t.Errorf("Expected first word in first line to have LineID 'line_1', got '%s'", lines[0].Words[0].LineID)
 	}
 }
+
+func TestParseHOCRLinesTesseractLineClasses(t *testing.T) {
+	// Tesseract emits headers and captions with their own line classes
+	testXML := `<?xml version="1.0" encoding="UTF-8"?>
+<html xmlns="http://www.w3.org/1999/xhtml" xml:lang="en" lang="en">
+<head><title></title></head>
+<body>
+<div class='ocr_page' id='page_1' title='image "scan.png"; bbox 0 0 800 600; ppageno 0'>
+<div class='ocr_carea' id='block_1_1' title="bbox 10 10 400 80">
+<p class='ocr_par' id='par_1_1' lang='eng' title="bbox 10 10 400 80">
+<span class='ocr_header' id='line_1_1' title="bbox 10 10 400 40; baseline 0 -5; x_size 30">
+<span class='ocrx_word' id='word_1_1' title='bbox 10 10 120 40; x_wconf 91'>Chapter</span>
+</span>
+<span class='ocr_caption' id='line_1_2' title="bbox 10 50 200 80; baseline 0 -4; x_size 25">
+<span class='ocrx_word' id='word_1_2' title='bbox 10 50 200 80; x_wconf 88'>Figure</span>
+</span>
+</p>
+</div>
+</div>
+</body>
+</html>`
+
+	lines, err := parser.ParseHOCRLines(testXML)
+	if err != nil {
+		t.Fatalf("Error parsing hOCR lines: %v", err)
+	}
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 lines, got %d", len(lines))
+	}
+	if lines[0].ID != "line_1_1" || lines[1].ID != "line_1_2" {
+		t.Errorf("Expected lines line_1_1 and line_1_2, got %s and %s", lines[0].ID, lines[1].ID)
+	}
+
+	words, err := parser.ParseHOCRWords(testXML)
+	if err != nil {
+		t.Fatalf("Error parsing hOCR: %v", err)
+	}
+	if len(words) != 2 {
+		t.Fatalf("Expected 2 words, got %d", len(words))
+	}
+	if words[1].LineID != "line_1_2" {
+		t.Errorf("Expected second word LineID to be 'line_1_2', got '%s'", words[1].LineID)
+	}
+}
+
+func TestParseHOCRPages(t *testing.T) {
+	testXML := `<?xml version="1.0" encoding="UTF-8"?>
+<html xmlns="http://www.w3.org/1999/xhtml" xml:lang="en" lang="en">
+<head><title></title></head>
+<body>
+<div class='ocr_page' id='page_1' title='bbox 0 0 1120 1368'>
+<div class='ocr_carea' id='block_1' title='bbox 150 70 450 140'>
+<p class='ocr_par' id='par_1' title='bbox 155 75 440 135'>
+<span class='ocr_line' id='line_1' title='bbox 161 80 435 129'>
+<span class='ocrx_word' id='word_1' title='bbox 161 84 300 129; x_wconf 95'>Dear</span>
+<span class='ocrx_word' id='word_2' title='bbox 324 80 417 123; x_wconf 95'>Sir</span>
+</span>
+</p>
+</div>
+<span class='ocr_line' id='line_2' title='bbox 599 41 674 69'>
+<span class='ocrx_word' id='word_3' title='bbox 599 41 674 69; x_wconf 95'>ALS</span>
+</span>
+<span class='ocr_line' id='line_3' title='bbox 599 80 700 100'>
+<span class='ocrx_word' id='word_4' title='bbox 599 80 700 100; x_wconf 95'>1862</span>
+</span>
+</div>
+</body>
+</html>`
+
+	pages, err := parser.ParseHOCRPages(testXML)
+	if err != nil {
+		t.Fatalf("Error parsing hOCR pages: %v", err)
+	}
+	if len(pages) != 1 {
+		t.Fatalf("Expected 1 page, got %d", len(pages))
+	}
+
+	page := pages[0]
+	if page.ID != "page_1" || page.BBox.X2 != 1120 || page.BBox.Y2 != 1368 {
+		t.Errorf("Unexpected page %s with bbox %+v", page.ID, page.BBox)
+	}
+	if len(page.Areas) != 2 {
+		t.Fatalf("Expected 2 areas, got %d", len(page.Areas))
+	}
+
+	area := page.Areas[0]
+	if area.ID != "block_1" || area.BBox.X1 != 150 || area.BBox.Y2 != 140 {
+		t.Errorf("Unexpected area %s with bbox %+v", area.ID, area.BBox)
+	}
+	if len(area.Paragraphs) != 1 || area.Paragraphs[0].ID != "par_1" {
+		t.Fatalf("Expected paragraph par_1 in block_1, got %+v", area.Paragraphs)
+	}
+	if area.Paragraphs[0].BBox.X1 != 155 {
+		t.Errorf("Expected paragraph bbox to start at 155, got %d", area.Paragraphs[0].BBox.X1)
+	}
+	if len(area.Paragraphs[0].Lines) != 1 || len(area.Paragraphs[0].Lines[0].Words) != 2 {
+		t.Errorf("Expected one line with two words in par_1")
+	}
+
+	// Stray lines are grouped into one implicit area and paragraph
+	implicit := page.Areas[1]
+	if implicit.ID != "" || len(implicit.Paragraphs) != 1 {
+		t.Fatalf("Expected one implicit area with one paragraph, got %+v", implicit)
+	}
+	if implicit.Paragraphs[0].ID != "" || len(implicit.Paragraphs[0].Lines) != 2 {
+		t.Errorf("Expected implicit paragraph with 2 lines, got %+v", implicit.Paragraphs[0])
+	}
+	if implicit.BBox.X1 != 599 || implicit.BBox.Y1 != 41 || implicit.BBox.X2 != 700 || implicit.BBox.Y2 != 100 {
+		t.Errorf("Expected implicit area bbox [599, 41, 700, 100], got %+v", implicit.BBox)
+	}
+}
+
+func TestParseHOCRPagesMultiPage(t *testing.T) {
+	testXML := `<?xml version="1.0" encoding="UTF-8"?>
+<html xmlns="http://www.w3.org/1999/xhtml" xml:lang="en" lang="en">
+<head><title></title></head>
+<body>
+<div class='ocr_page' id='page_1' title='image "scans/letter; part 1.jpg"; bbox 0 0 1000 1400; ppageno 0'>
+<span class='ocr_line' id='line_1_1' title='bbox 10 10 100 40'>
+<span class='ocrx_word' id='word_1_1' title='bbox 10 10 100 40; x_wconf 90'>First</span>
+</span>
+</div>
+<div class='ocr_page' id='page_2' title='image "scans/letter-2.jpg"; bbox 0 0 1100 1500; ppageno 1'>
+<span class='ocr_line' id='line_2_1' title='bbox 20 20 200 60'>
+<span class='ocrx_word' id='word_2_1' title='bbox 20 20 200 60; x_wconf 80'>Second</span>
+</span>
+</div>
+</body>
+</html>`
+
+	pages, err := parser.ParseHOCRPages(testXML)
+	if err != nil {
+		t.Fatalf("Error parsing hOCR pages: %v", err)
+	}
+	if len(pages) != 2 {
+		t.Fatalf("Expected 2 pages, got %d", len(pages))
+	}
+	if pages[0].Image != "scans/letter; part 1.jpg" || pages[0].PageNumber != 0 {
+		t.Errorf("Unexpected first page image %q ppageno %d", pages[0].Image, pages[0].PageNumber)
+	}
+	if pages[1].Image != "scans/letter-2.jpg" || pages[1].PageNumber != 1 || pages[1].BBox.X2 != 1100 {
+		t.Errorf("Unexpected second page image %q ppageno %d bbox %+v", pages[1].Image, pages[1].PageNumber, pages[1].BBox)
+	}
+
+	lines, err := parser.ParseHOCRLines(testXML)
+	if err != nil {
+		t.Fatalf("Error parsing hOCR lines: %v", err)
+	}
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 lines, got %d", len(lines))
+	}
+	if lines[0].PageID != "page_1" || lines[1].PageID != "page_2" {
+		t.Errorf("Expected lines on page_1 and page_2, got %s and %s", lines[0].PageID, lines[1].PageID)
+	}
+}
+
+func TestParseHOCRCharBoxes(t *testing.T) {
+	testXML := `<html xmlns="http://www.w3.org/1999/xhtml"><body>
+<div class='ocr_page' id='page_1' title='bbox 0 0 500 400'>
+<span class='ocr_line' id='line_1' title='bbox 10 10 200 30'>
+<span class='ocrx_word' id='word_1' title='bbox 10 10 30 30; x_wconf 90; x_bboxes 10 10 20 30 20 10 30 30'>ab</span>
+<span class='ocrx_word' id='word_2' title='bbox 40 10 60 30; x_wconf 90; x_stale_bboxes 40 10 60 30'>cd</span>
+<span class='ocrx_word' id='word_3' title='bbox 70 10 90 30; x_wconf 80'><span class='ocrx_cinfo' title='x_bboxes 70 10 80 30; x_conf 70'>e</span><span class='ocrx_cinfo' title='x_bboxes 80 10 90 30; x_conf 90'>f</span></span>
+</span>
+</div>
+</body></html>`
+
+	words, err := parser.ParseHOCRWords(testXML)
+	if err != nil {
+		t.Fatalf("Error parsing hOCR: %v", err)
+	}
+	if len(words) != 3 {
+		t.Fatalf("Expected 3 words, got %d", len(words))
+	}
+
+	if len(words[0].CharBoxes) != 2 || words[0].CharBoxes[1] != (models.BBox{X1: 20, Y1: 10, X2: 30, Y2: 30}) || words[0].CharBoxesStale {
+		t.Errorf("Expected two current character boxes, got %+v (stale %t)", words[0].CharBoxes, words[0].CharBoxesStale)
+	}
+	if len(words[1].CharBoxes) != 1 || !words[1].CharBoxesStale {
+		t.Errorf("Expected one stale character box, got %+v (stale %t)", words[1].CharBoxes, words[1].CharBoxesStale)
+	}
+	if words[2].Text != "ef" || len(words[2].CharBoxes) != 2 || words[2].CharBoxes[0].X2 != 80 {
+		t.Errorf("Expected the text and boxes of the ocrx_cinfo spans, got %q %+v", words[2].Text, words[2].CharBoxes)
+	}
+	if len(words[2].CharConfidences) != 2 || words[2].CharConfidences[1] != 90 {
+		t.Errorf("Expected the confidences of the ocrx_cinfo spans, got %v", words[2].CharConfidences)
+	}
+}
+
+func TestParseHOCRLineGeometry(t *testing.T) {
+	testXML := `<html xmlns="http://www.w3.org/1999/xhtml"><body>
+<div class='ocr_page' id='page_1' title='bbox 0 0 600 800'>
+<span class='ocr_line' id='line_1' title='bbox 36 92 580 122; baseline 0.015 -8; x_size 30; x_descenders 7; x_ascenders 8'>
+<span class='ocrx_word' id='word_1' title='bbox 36 92 120 122; x_wconf 90'>Skewed</span>
+</span>
+<span class='ocr_caption' id='line_2' title='bbox 10 200 40 700; textangle 90; baseline 0 -6; x_size 25.5; x_descenders 5.25; x_ascenders 6'>
+<span class='ocrx_word' id='word_2' title='bbox 10 600 40 700; x_wconf 90'>Margin</span>
+</span>
+</div>
+</body></html>`
+
+	lines, err := parser.ParseHOCRLines(testXML)
+	if err != nil {
+		t.Fatalf("Error parsing hOCR lines: %v", err)
+	}
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 lines, got %d", len(lines))
+	}
+
+	skewed := lines[0]
+	if skewed.TextAngle != 0 || skewed.Baseline == nil || *skewed.Baseline != (models.Baseline{Slope: 0.015, Offset: -8}) {
+		t.Errorf("Expected a baseline of 0.015 -8 and no textangle, got %v and %+v", skewed.TextAngle, skewed.Baseline)
+	}
+	if skewed.XSize != 30 || skewed.XDescenders != 7 || skewed.XAscenders != 8 {
+		t.Errorf("Expected sizes 30, 7 and 8, got %v, %v and %v", skewed.XSize, skewed.XDescenders, skewed.XAscenders)
+	}
+
+	margin := lines[1]
+	if margin.TextAngle != 90 || margin.Baseline == nil || margin.Baseline.Offset != -6 {
+		t.Errorf("Expected a textangle of 90 with its baseline, got %v and %+v", margin.TextAngle, margin.Baseline)
+	}
+	if margin.XSize != 25.5 || margin.XDescenders != 5.25 {
+		t.Errorf("Expected fractional sizes, got %v and %v", margin.XSize, margin.XDescenders)
+	}
+}
+
+func TestParseHOCRPolys(t *testing.T) {
+	testXML := `<html xmlns="http://www.w3.org/1999/xhtml"><body>
+<div class='ocr_page' id='page_1' title='bbox 0 0 500 400'>
+<div class='ocr_carea' id='block_1' title='bbox 98 86 206 134; poly 98 108 204 86 206 112 100 134'>
+<p class='ocr_par' id='par_1' title='bbox 98 86 206 134; poly 98 108 204 86 206 112'>
+<span class='ocr_line' id='line_1' title='bbox 100 90 202 130; poly 100 110 200 90 202 110 102 130; baseline -0.2 -10'>
+<span class='ocrx_word' id='word_1' title='bbox 100 100 152 130; poly 100 110 150 100 152 120 102 130; x_wconf 95'>Hello</span>
+<span class='ocrx_word' id='word_2' title='bbox 160 90 202 118; poly 160 98 200 90 202; x_wconf 95'>there</span>
+</span>
+</p>
+</div>
+</div>
+</body></html>`
+
+	pages, err := parser.ParseHOCRPages(testXML)
+	if err != nil {
+		t.Fatalf("Error parsing hOCR: %v", err)
+	}
+
+	area := pages[0].Areas[0]
+	if len(area.Poly) != 4 || area.Poly[3] != (models.Point{X: 100, Y: 134}) {
+		t.Errorf("Expected the area poly, got %v", area.Poly)
+	}
+	if len(area.Paragraphs[0].Poly) != 3 {
+		t.Errorf("Expected a three point paragraph poly, got %v", area.Paragraphs[0].Poly)
+	}
+	line := area.Paragraphs[0].Lines[0]
+	if len(line.Poly) != 4 || line.Baseline == nil {
+		t.Errorf("Expected the line poly alongside its baseline, got %v and %+v", line.Poly, line.Baseline)
+	}
+	if len(line.Words[0].Poly) != 4 || line.Words[0].Confidence != 95 {
+		t.Errorf("Expected the word poly alongside its confidence, got %+v", line.Words[0])
+	}
+	if line.Words[1].Poly != nil {
+		t.Errorf("Expected a poly with an odd number of coordinates to be ignored, got %v", line.Words[1].Poly)
+	}
+}
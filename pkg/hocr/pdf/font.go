@@ -0,0 +1,131 @@
+package pdf
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+)
+
+// glyphWidth is the advance of every glyph of the text layer's font per point of
+// font size. One width for every character lets word widths be computed from the
+// number of characters alone.
+const glyphWidth = 0.5
+
+// glyphlessFont is a TrueType font whose glyphs all draw nothing, like the one
+// tesseract embeds for its text layer. Glyph 0 is .notdef and glyph 1 is the glyph
+// every character is mapped to by the CIDToGIDMap.
+var glyphlessFont = buildGlyphlessFont()
+
+// fontTable is one table of a TrueType font
+type fontTable struct {
+	tag  string
+	data []byte
+}
+
+func buildGlyphlessFont() []byte {
+	be := binary.BigEndian
+	u16 := func(values ...uint16) []byte {
+		b := make([]byte, 2*len(values))
+		for i, v := range values {
+			be.PutUint16(b[2*i:], v)
+		}
+		return b
+	}
+	u32 := func(v uint32) []byte {
+		return be.AppendUint32(nil, v)
+	}
+	join := func(parts ...[]byte) []byte {
+		var b []byte
+		for _, part := range parts {
+			b = append(b, part...)
+		}
+		return b
+	}
+
+	const unitsPerEm = 1000
+	advance := uint16(glyphWidth * unitsPerEm)
+
+	// tables in tag order, as the table directory must be
+	tables := []fontTable{
+		// a format 4 subtable with only the closing segment: no character has a glyph
+		{"cmap", join(u16(0, 1, 3, 1), u32(12), u16(4, 24, 0, 2, 2, 0, 0, 0xFFFF, 0, 0xFFFF, 1, 0))},
+		// both glyphs are empty, so there are no outlines
+		{"glyf", nil},
+		{"head", join(u32(0x00010000), u32(0x00010000), u32(0), u32(0x5F0F3CF5),
+			u16(0x000B, unitsPerEm), make([]byte, 16),
+			u16(0, 0, advance, unitsPerEm, 0, 3, 2, 0, 0))},
+		{"hhea", join(u32(0x00010000),
+			u16(unitsPerEm, 0, 0, advance, 0, 0, advance, 1, 0, 0, 0, 0, 0, 0, 0, 2))},
+		{"hmtx", u16(advance, 0, advance, 0)},
+		{"loca", u16(0, 0, 0)},
+		{"maxp", join(u32(0x00010000), u16(2, 0, 0, 0, 0, 2, 0, 0, 0, 0, 0, 0, 0, 0))},
+		{"name", u16(0, 0, 6)},
+		{"post", join(u32(0x00030000), u32(0), u16(0, 0), u32(1), make([]byte, 16))},
+	}
+
+	// the table directory, with its binary search fields for 9 tables: 8 is the
+	// largest power of two not above 9
+	headerSize := 12 + 16*len(tables)
+	font := join(u32(0x00010000), u16(uint16(len(tables)), 8*16, 3, uint16(len(tables))*16-8*16))
+	offset := headerSize
+	var body []byte
+	headOffset := 0
+	for _, table := range tables {
+		padded := append(table.data, make([]byte, (4-len(table.data)%4)%4)...)
+		font = append(font, table.tag...)
+		font = append(font, u32(checksum(padded))...)
+		font = append(font, u32(uint32(offset))...)
+		font = append(font, u32(uint32(len(table.data)))...)
+		if table.tag == "head" {
+			headOffset = offset
+		}
+		body = append(body, padded...)
+		offset += len(padded)
+	}
+	font = append(font, body...)
+
+	// checkSumAdjustment makes the whole font sum to the magic number
+	be.PutUint32(font[headOffset+8:], 0xB1B0AFBA-checksum(font))
+	return font
+}
+
+// checksum is the TrueType table checksum, the sum of the data as big endian uint32s
+func checksum(data []byte) uint32 {
+	var sum uint32
+	for i := 0; i < len(data); i += 4 {
+		var word [4]byte
+		copy(word[:], data[i:])
+		sum += binary.BigEndian.Uint32(word[:])
+	}
+	return sum
+}
+
+// cidToGIDMap maps every two byte character code to glyph 1
+func cidToGIDMap() []byte {
+	mapping := make([]byte, 2*65536)
+	for i := 1; i < len(mapping); i += 2 {
+		mapping[i] = 1
+	}
+	return mapping
+}
+
+// toUnicode is a CMap taking each two byte character code back to the UTF-16 code
+// unit it was written from. Each range keeps to one high byte, as bfrange requires.
+func toUnicode() []byte {
+	var cmap strings.Builder
+	cmap.WriteString("/CIDInit /ProcSet findresource begin\n12 dict begin\nbegincmap\n" +
+		"/CIDSystemInfo << /Registry (Adobe) /Ordering (UCS) /Supplement 0 >> def\n" +
+		"/CMapName /Adobe-Identity-UCS def\n/CMapType 2 def\n" +
+		"1 begincodespacerange\n<0000> <FFFF>\nendcodespacerange\n")
+	// a bfrange section holds at most 100 ranges
+	for start := 0; start < 256; start += 100 {
+		end := min(start+100, 256)
+		cmap.WriteString(fmt.Sprintf("%d beginbfrange\n", end-start))
+		for high := start; high < end; high++ {
+			cmap.WriteString(fmt.Sprintf("<%02X00> <%02XFF> <%02X00>\n", high, high, high))
+		}
+		cmap.WriteString("endbfrange\n")
+	}
+	cmap.WriteString("endcmap\nCMapName currentdict /CMapResource defineresource pop\nend\nend\n")
+	return []byte(cmap.String())
+}
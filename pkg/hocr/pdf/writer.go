@@ -0,0 +1,305 @@
+// Package pdf builds searchable PDFs in the manner of hocr-pdf: each page image is
+// drawn full page with the hOCR words laid over it as invisible text. As in
+// tesseract's PDFs the text is set in a glyphless font, one two byte code per UTF-16
+// code unit, with a ToUnicode CMap so viewers can extract text in any script.
+package pdf
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif"
+	"image/jpeg"
+	_ "image/png"
+	"io"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/lehigh-university-libraries/hocr-edit/internal/models"
+)
+
+// DPI is the resolution assumed for page images when sizing pages
+const DPI = 300
+
+// Page is one page image, as JPEG, PNG or GIF data, with the hOCR to lay over it
+type Page struct {
+	Image []byte
+	HOCR  models.HOCRPage
+}
+
+// Write renders pages as a PDF. hOCR coordinates are scaled from the page bbox to
+// the image size, so hOCR made from a differently sized rendition still lines up.
+func Write(w io.Writer, pages []Page) error {
+	if len(pages) == 0 {
+		return fmt.Errorf("no pages to write")
+	}
+
+	doc := &document{w: bufio.NewWriter(w)}
+	doc.write("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+
+	// objects 1-8 are the catalog, page tree and the parts of the font; each page
+	// adds three more
+	pageIDs := make([]int, len(pages))
+	for i := range pages {
+		pageIDs[i] = 9 + i*3
+	}
+
+	doc.object(1, "<< /Type /Catalog /Pages 2 0 R >>")
+
+	kids := make([]string, len(pageIDs))
+	for i, id := range pageIDs {
+		kids[i] = fmt.Sprintf("%d 0 R", id)
+	}
+	doc.object(2, fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(pages)))
+	if err := doc.font(); err != nil {
+		return err
+	}
+
+	for i, page := range pages {
+		if err := doc.page(pageIDs[i], page); err != nil {
+			return fmt.Errorf("page %d: %w", i+1, err)
+		}
+	}
+
+	doc.trailer()
+	if doc.err != nil {
+		return doc.err
+	}
+	return doc.w.Flush()
+}
+
+type document struct {
+	w       *bufio.Writer
+	offset  int
+	offsets []int
+	err     error
+}
+
+func (d *document) write(s string) {
+	d.writeBytes([]byte(s))
+}
+
+func (d *document) writeBytes(b []byte) {
+	if d.err != nil {
+		return
+	}
+	n, err := d.w.Write(b)
+	d.offset += n
+	d.err = err
+}
+
+func (d *document) startObject(id int) {
+	for len(d.offsets) < id {
+		d.offsets = append(d.offsets, 0)
+	}
+	d.offsets[id-1] = d.offset
+	d.write(fmt.Sprintf("%d 0 obj\n", id))
+}
+
+func (d *document) object(id int, body string) {
+	d.startObject(id)
+	d.write(body)
+	d.write("\nendobj\n")
+}
+
+func (d *document) stream(id int, dict string, data []byte) {
+	d.startObject(id)
+	d.write(fmt.Sprintf("<< %s /Length %d >>\nstream\n", dict, len(data)))
+	d.writeBytes(data)
+	d.write("\nendstream\nendobj\n")
+}
+
+// font writes the glyphless font as objects 3-8: the Type0 font pages refer to, its
+// CID font, descriptor, TrueType program, CIDToGIDMap and ToUnicode CMap
+func (d *document) font() error {
+	program, err := compress(glyphlessFont)
+	if err != nil {
+		return err
+	}
+	gids, err := compress(cidToGIDMap())
+	if err != nil {
+		return err
+	}
+	cmap, err := compress(toUnicode())
+	if err != nil {
+		return err
+	}
+
+	d.object(3, "<< /Type /Font /Subtype /Type0 /BaseFont /GlyphLessFont /Encoding /Identity-H "+
+		"/DescendantFonts [4 0 R] /ToUnicode 8 0 R >>")
+	d.object(4, fmt.Sprintf("<< /Type /Font /Subtype /CIDFontType2 /BaseFont /GlyphLessFont "+
+		"/CIDSystemInfo << /Registry (Adobe) /Ordering (Identity) /Supplement 0 >> "+
+		"/FontDescriptor 5 0 R /CIDToGIDMap 7 0 R /DW %s >>", number(glyphWidth*1000)))
+	d.object(5, fmt.Sprintf("<< /Type /FontDescriptor /FontName /GlyphLessFont /Flags 5 "+
+		"/FontBBox [0 0 %s 1000] /ItalicAngle 0 /Ascent 1000 /Descent 0 /CapHeight 1000 /StemV 80 "+
+		"/FontFile2 6 0 R >>", number(glyphWidth*1000)))
+	d.stream(6, fmt.Sprintf("/Length1 %d /Filter /FlateDecode", len(glyphlessFont)), program)
+	d.stream(7, "/Filter /FlateDecode", gids)
+	d.stream(8, "/Filter /FlateDecode", cmap)
+	return d.err
+}
+
+func (d *document) trailer() {
+	xref := d.offset
+	d.write(fmt.Sprintf("xref\n0 %d\n", len(d.offsets)+1))
+	d.write("0000000000 65535 f \n")
+	for _, offset := range d.offsets {
+		d.write(fmt.Sprintf("%010d 00000 n \n", offset))
+	}
+	d.write(fmt.Sprintf("trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(d.offsets)+1, xref))
+}
+
+func (d *document) page(id int, page Page) error {
+	img, err := pageImage(page.Image)
+	if err != nil {
+		return err
+	}
+
+	width := float64(img.width) * 72 / DPI
+	height := float64(img.height) * 72 / DPI
+
+	content, err := compress(pageContent(page.HOCR, img, width, height))
+	if err != nil {
+		return err
+	}
+
+	d.object(id, fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %s %s] "+
+		"/Resources << /Font << /F1 3 0 R >> /XObject << /Im1 %d 0 R >> >> /Contents %d 0 R >>",
+		number(width), number(height), id+1, id+2))
+	d.stream(id+1, fmt.Sprintf("/Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace /%s /BitsPerComponent 8 /Filter /%s",
+		img.width, img.height, img.colorSpace, img.filter), img.data)
+	d.stream(id+2, "/Filter /FlateDecode", content)
+
+	return d.err
+}
+
+// pageContent draws the image over the whole page, then writes every word in
+// render mode 3 (invisible), stretched horizontally to fill its bbox
+func pageContent(hocrPage models.HOCRPage, img pdfImage, width, height float64) []byte {
+	var content strings.Builder
+	content.WriteString(fmt.Sprintf("q %s 0 0 %s 0 0 cm /Im1 Do Q\n", number(width), number(height)))
+
+	pageWidth := hocrPage.BBox.X2 - hocrPage.BBox.X1
+	pageHeight := hocrPage.BBox.Y2 - hocrPage.BBox.Y1
+	if pageWidth <= 0 || pageHeight <= 0 {
+		pageWidth, pageHeight = img.width, img.height
+	}
+	scaleX := width / float64(pageWidth)
+	scaleY := height / float64(pageHeight)
+
+	content.WriteString("BT 3 Tr\n")
+	for _, area := range hocrPage.Areas {
+		for _, paragraph := range area.Paragraphs {
+			for _, line := range paragraph.Lines {
+				fontSize := float64(line.BBox.Y2-line.BBox.Y1) * scaleY
+				for i, word := range line.Words {
+					text := utf16.Encode([]rune(word.Text))
+					if len(text) == 0 || fontSize <= 0 {
+						continue
+					}
+
+					wordWidth := float64(word.BBox.X2-word.BBox.X1) * scaleX
+					stretch := 100 * wordWidth / (glyphWidth * fontSize * float64(len(text)))
+					if i < len(line.Words)-1 {
+						// a trailing space lets text extraction separate the words
+						text = append(text, ' ')
+					}
+
+					x := float64(word.BBox.X1-hocrPage.BBox.X1) * scaleX
+					y := height - float64(line.BBox.Y2-hocrPage.BBox.Y1)*scaleY
+					content.WriteString(fmt.Sprintf("/F1 %s Tf %s Tz 1 0 0 1 %s %s Tm <%s> Tj\n",
+						number(fontSize), number(stretch), number(x), number(y), encode(text)))
+				}
+			}
+		}
+	}
+	content.WriteString("ET\n")
+
+	return []byte(content.String())
+}
+
+type pdfImage struct {
+	width, height int
+	colorSpace    string
+	filter        string
+	data          []byte
+}
+
+// pageImage embeds JPEGs as they are and recompresses anything else losslessly
+func pageImage(data []byte) (pdfImage, error) {
+	config, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return pdfImage{}, fmt.Errorf("failed to read image: %w", err)
+	}
+
+	if format == "jpeg" && config.ColorModel != color.CMYKModel {
+		colorSpace := "DeviceRGB"
+		if config.ColorModel == color.GrayModel {
+			colorSpace = "DeviceGray"
+		}
+		return pdfImage{width: config.Width, height: config.Height, colorSpace: colorSpace, filter: "DCTDecode", data: data}, nil
+	}
+
+	var decoded image.Image
+	if format == "jpeg" {
+		decoded, err = jpeg.Decode(bytes.NewReader(data))
+	} else {
+		decoded, _, err = image.Decode(bytes.NewReader(data))
+	}
+	if err != nil {
+		return pdfImage{}, fmt.Errorf("failed to decode image: %w", err)
+	}
+
+	bounds := decoded.Bounds()
+	pixels := make([]byte, 0, bounds.Dx()*bounds.Dy()*3)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			// transparent pixels are drawn on white, as a browser would show them
+			c := color.NRGBAModel.Convert(decoded.At(x, y)).(color.NRGBA)
+			alpha := uint32(c.A)
+			pixels = append(pixels,
+				byte((uint32(c.R)*alpha+255*(255-alpha))/255),
+				byte((uint32(c.G)*alpha+255*(255-alpha))/255),
+				byte((uint32(c.B)*alpha+255*(255-alpha))/255))
+		}
+	}
+
+	compressed, err := compress(pixels)
+	if err != nil {
+		return pdfImage{}, err
+	}
+
+	return pdfImage{width: bounds.Dx(), height: bounds.Dy(), colorSpace: "DeviceRGB", filter: "FlateDecode", data: compressed}, nil
+}
+
+func compress(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+	if _, err := zw.Write(data); err != nil {
+		return nil, fmt.Errorf("failed to compress stream: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		return nil, fmt.Errorf("failed to compress stream: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// encode writes UTF-16 code units as the hex string of their two byte character
+// codes, which Identity-H takes as CIDs and the ToUnicode CMap maps back to themselves
+func encode(text []uint16) string {
+	encoded := make([]byte, 0, 2*len(text))
+	for _, unit := range text {
+		encoded = append(encoded, byte(unit>>8), byte(unit))
+	}
+	return hex.EncodeToString(encoded)
+}
+
+// number formats a coordinate with at most two decimals and no trailing zeros
+func number(value float64) string {
+	formatted := strings.TrimRight(fmt.Sprintf("%.2f", value), "0")
+	return strings.TrimSuffix(formatted, ".")
+}
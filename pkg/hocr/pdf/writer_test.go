@@ -0,0 +1,211 @@
+package pdf_test
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"encoding/hex"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"unicode/utf16"
+
+	"github.com/lehigh-university-libraries/hocr-edit/internal/models"
+	"github.com/lehigh-university-libraries/hocr-edit/pkg/hocr/pdf"
+)
+
+func testImage(t *testing.T, encode func(io.Writer, image.Image) error) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, 600, 300))
+	for x := range 600 {
+		img.Set(x, 150, color.Black)
+	}
+	var buf bytes.Buffer
+	if err := encode(&buf, img); err != nil {
+		t.Fatalf("Error encoding test image: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func testPage(words ...string) models.HOCRPage {
+	line := models.HOCRLine{ID: "line_1", BBox: models.BBox{X1: 0, Y1: 100, X2: 600, Y2: 160}}
+	for i, text := range words {
+		line.Words = append(line.Words, models.HOCRWord{
+			ID:   "word_" + strconv.Itoa(i+1),
+			Text: text,
+			BBox: models.BBox{X1: i * 200, Y1: 100, X2: i*200 + 150, Y2: 160},
+		})
+	}
+	return models.HOCRPage{
+		BBox:  models.BBox{X2: 600, Y2: 300},
+		Areas: []models.HOCRArea{{Paragraphs: []models.HOCRParagraph{{Lines: []models.HOCRLine{line}}}}},
+	}
+}
+
+func TestWrite(t *testing.T) {
+	jpegData := testImage(t, func(w io.Writer, img image.Image) error { return jpeg.Encode(w, img, nil) })
+	pngData := testImage(t, png.Encode)
+
+	var out bytes.Buffer
+	err := pdf.Write(&out, []pdf.Page{
+		{Image: jpegData, HOCR: testPage("Dear", "Sir")},
+		{Image: pngData, HOCR: testPage("Café", "日本")},
+	})
+	if err != nil {
+		t.Fatalf("Error writing PDF: %v", err)
+	}
+	doc := out.String()
+
+	if !strings.HasPrefix(doc, "%PDF-1.4") || !strings.HasSuffix(doc, "%%EOF\n") {
+		t.Fatalf("Expected a PDF header and trailer, got:\n%s", doc)
+	}
+	if !strings.Contains(doc, "/Count 2") {
+		t.Error("Expected two pages in the page tree")
+	}
+	if !strings.Contains(doc, "/MediaBox [0 0 144 72]") {
+		t.Error("Expected a 600x300 pixel image to give a 144x72 point page at 300 DPI")
+	}
+	if !strings.Contains(doc, "/Filter /DCTDecode") || !strings.Contains(doc, "/Filter /FlateDecode") {
+		t.Error("Expected the JPEG embedded as is and the PNG recompressed")
+	}
+
+	// every xref entry must point at the start of its object
+	xref := regexp.MustCompile(`(\d{10}) 00000 n `).FindAllStringSubmatch(doc, -1)
+	if len(xref) != 14 {
+		t.Fatalf("Expected 14 objects, got %d", len(xref))
+	}
+	for i, entry := range xref {
+		offset, _ := strconv.Atoi(entry[1])
+		if !strings.HasPrefix(doc[offset:], strconv.Itoa(i+1)+" 0 obj") {
+			t.Errorf("Expected xref entry %d to point at its object", i+1)
+		}
+	}
+
+	text := pageText(t, doc)
+	if !strings.Contains(text, "Dear Sir") {
+		t.Errorf("Expected invisible text for the first page, got %q", text)
+	}
+	if !strings.Contains(text, "Café 日本") {
+		t.Errorf("Expected the second page's text to extract as written, got %q", text)
+	}
+}
+
+func TestWriteNonLatinText(t *testing.T) {
+	pngData := testImage(t, png.Encode)
+	words := []string{"Ελληνικά", "русский", "العربية", "עברית", "中文", "𝔊𝔬𝔱𝔥𝔦𝔠"}
+
+	var out bytes.Buffer
+	if err := pdf.Write(&out, []pdf.Page{{Image: pngData, HOCR: testPage(words...)}}); err != nil {
+		t.Fatalf("Error writing PDF: %v", err)
+	}
+	doc := out.String()
+
+	if !strings.Contains(doc, "/Subtype /Type0") || !strings.Contains(doc, "/Encoding /Identity-H") {
+		t.Error("Expected the text to be set in a Type0 font with Identity-H encoding")
+	}
+	if text := strings.TrimSpace(pageText(t, doc)); text != strings.Join(words, " ") {
+		t.Errorf("Expected %q to be extracted, got %q", strings.Join(words, " "), text)
+	}
+
+	// the embedded TrueType program must be whole and sum to the checksum magic
+	match := regexp.MustCompile(`/Length1 (\d+) /Filter /FlateDecode /Length (\d+) >>\nstream\n`).FindStringSubmatchIndex(doc)
+	if match == nil {
+		t.Fatal("Expected an embedded font program")
+	}
+	length1, _ := strconv.Atoi(doc[match[2]:match[3]])
+	length, _ := strconv.Atoi(doc[match[4]:match[5]])
+	font := inflate(t, doc[match[1]:match[1]+length])
+	if len(font) != length1 || len(font)%4 != 0 {
+		t.Fatalf("Expected a %d byte font, got %d bytes", length1, len(font))
+	}
+	var sum uint32
+	for i := 0; i < len(font); i += 4 {
+		sum += binary.BigEndian.Uint32(font[i:])
+	}
+	if sum != 0xB1B0AFBA {
+		t.Errorf("Expected the font checksum to be 0xB1B0AFBA, got %#x", sum)
+	}
+}
+
+func TestWriteRejectsBadImage(t *testing.T) {
+	var out bytes.Buffer
+	if err := pdf.Write(&out, []pdf.Page{{Image: []byte("not an image"), HOCR: testPage("x")}}); err == nil {
+		t.Error("Expected an error for undecodable image data")
+	}
+}
+
+// pageText extracts the text shown with Tj as a viewer would, mapping each two byte
+// code through the ToUnicode CMap
+func pageText(t *testing.T, doc string) string {
+	t.Helper()
+	var contents [][]byte
+	toUnicode := map[uint16]uint16{}
+	ranges := regexp.MustCompile(`<([0-9A-F]{4})> <([0-9A-F]{4})> <([0-9A-F]{4})>`)
+	for _, stream := range flateStreams(t, doc) {
+		switch {
+		case bytes.Contains(stream, []byte("begincmap")):
+			for _, r := range ranges.FindAllSubmatch(stream, -1) {
+				from, _ := strconv.ParseUint(string(r[1]), 16, 16)
+				to, _ := strconv.ParseUint(string(r[2]), 16, 16)
+				dst, _ := strconv.ParseUint(string(r[3]), 16, 16)
+				for code := from; code <= to; code++ {
+					toUnicode[uint16(code)] = uint16(dst + code - from)
+				}
+			}
+		case bytes.Contains(stream, []byte(" Tj")):
+			contents = append(contents, stream)
+		}
+	}
+
+	var text strings.Builder
+	for _, content := range contents {
+		if !bytes.Contains(content, []byte("3 Tr")) {
+			t.Error("Expected text in invisible render mode")
+		}
+		for _, shown := range regexp.MustCompile(`<([0-9a-f]+)> Tj`).FindAllSubmatch(content, -1) {
+			codes, _ := hex.DecodeString(string(shown[1]))
+			units := make([]uint16, 0, len(codes)/2)
+			for i := 0; i+1 < len(codes); i += 2 {
+				code := binary.BigEndian.Uint16(codes[i:])
+				unit, ok := toUnicode[code]
+				if !ok {
+					t.Fatalf("Expected code %04x to be in the ToUnicode CMap", code)
+				}
+				units = append(units, unit)
+			}
+			text.WriteString(string(utf16.Decode(units)))
+		}
+		text.WriteString("\n")
+	}
+	return text.String()
+}
+
+// flateStreams inflates every stream of the document that has a FlateDecode filter
+func flateStreams(t *testing.T, doc string) [][]byte {
+	t.Helper()
+	var streams [][]byte
+	for _, match := range regexp.MustCompile(`/Filter /FlateDecode /Length (\d+) >>\nstream\n`).FindAllStringSubmatchIndex(doc, -1) {
+		length, _ := strconv.Atoi(doc[match[2]:match[3]])
+		streams = append(streams, inflate(t, doc[match[1]:match[1]+length]))
+	}
+	return streams
+}
+
+func inflate(t *testing.T, data string) []byte {
+	t.Helper()
+	r, err := zlib.NewReader(strings.NewReader(data))
+	if err != nil {
+		t.Fatalf("Error inflating stream: %v", err)
+	}
+	inflated, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("Error inflating stream: %v", err)
+	}
+	return inflated
+}
@@ -1,8 +1,9 @@
 package metrics
 
 import (
-	"regexp"
+	"slices"
 	"strings"
+	"unicode/utf8"
 )
 
 type EvalResult struct {
@@ -12,6 +13,7 @@ type EvalResult struct {
 	Public                bool    `json:"public"`
 	OpenAIResponse        string  `json:"openai_response"`
 	CharacterSimilarity   float64 `json:"character_similarity"`
+	CharacterErrorRate    float64 `json:"character_error_rate"`
 	WordSimilarity        float64 `json:"word_similarity"`
 	WordAccuracy          float64 `json:"word_accuracy"`
 	WordErrorRate         float64 `json:"word_error_rate"`
@@ -23,10 +25,30 @@ type EvalResult struct {
 	Insertions            int     `json:"insertions"`
 }
 
+// CalculateAccuracyMetrics compares the texts under the default profile,
+// which ignores case and differences in whitespace
 func CalculateAccuracyMetrics(original, transcribed string) EvalResult {
 	origNorm := normalizeText(original)
 	transNorm := normalizeText(transcribed)
+	return calculateAccuracyMetrics(origNorm, transNorm)
+}
+
+// CalculateAccuracyMetricsWithProfile compares the texts after normalizing both with profile
+func CalculateAccuracyMetricsWithProfile(original, transcribed, profile string) (EvalResult, error) {
+	origNorm, err := Normalize(original, profile)
+	if err != nil {
+		return EvalResult{}, err
+	}
+	transNorm, err := Normalize(transcribed, profile)
+	if err != nil {
+		return EvalResult{}, err
+	}
+	return calculateAccuracyMetrics(origNorm, transNorm), nil
+}
+
+func calculateAccuracyMetrics(origNorm, transNorm string) EvalResult {
 	charSim := calculateSimilarity(origNorm, transNorm)
+	cer := calculateCharacterErrorRate(origNorm, transNorm)
 	origWords := strings.Fields(origNorm)
 	transWords := strings.Fields(transNorm)
 	wordSim := calculateSimilarity(strings.Join(origWords, " "), strings.Join(transWords, " "))
@@ -36,6 +58,7 @@ func CalculateAccuracyMetrics(original, transcribed string) EvalResult {
 
 	return EvalResult{
 		CharacterSimilarity:   charSim,
+		CharacterErrorRate:    cer,
 		WordSimilarity:        wordSim,
 		WordAccuracy:          wordAcc,
 		WordErrorRate:         wer,
@@ -49,13 +72,15 @@ func CalculateAccuracyMetrics(original, transcribed string) EvalResult {
 }
 
 func normalizeText(text string) string {
-	re := regexp.MustCompile(`\s+`)
-	text = re.ReplaceAllString(strings.TrimSpace(text), " ")
+	text = whitespaceRegex.ReplaceAllString(strings.TrimSpace(text), " ")
 	return strings.ToLower(text)
 }
 
+// levenshteinDistance counts edits between runes rather than bytes, so a single
+// wrong accented letter or ligature is one error regardless of its UTF-8 length
 func levenshteinDistance(s1, s2 string) int {
-	len1, len2 := len(s1), len(s2)
+	r1, r2 := []rune(s1), []rune(s2)
+	len1, len2 := len(r1), len(r2)
 	if len1 == 0 {
 		return len2
 	}
@@ -63,36 +88,34 @@ func levenshteinDistance(s1, s2 string) int {
 		return len1
 	}
 
-	matrix := make([][]int, len1+1)
-	for i := range matrix {
-		matrix[i] = make([]int, len2+1)
-	}
-
-	for i := 0; i <= len1; i++ {
-		matrix[i][0] = i
-	}
+	// Only the previous row of the matrix is needed, which keeps memory
+	// linear for full page transcriptions
+	previous := make([]int, len2+1)
+	current := make([]int, len2+1)
 	for j := 0; j <= len2; j++ {
-		matrix[0][j] = j
+		previous[j] = j
 	}
 
 	for i := 1; i <= len1; i++ {
+		current[0] = i
 		for j := 1; j <= len2; j++ {
 			cost := 0
-			if s1[i-1] != s2[j-1] {
+			if r1[i-1] != r2[j-1] {
 				cost = 1
 			}
-			matrix[i][j] = min(
-				min(matrix[i-1][j]+1, matrix[i][j-1]+1),
-				matrix[i-1][j-1]+cost,
+			current[j] = min(
+				min(previous[j]+1, current[j-1]+1),
+				previous[j-1]+cost,
 			)
 		}
+		previous, current = current, previous
 	}
 
-	return matrix[len1][len2]
+	return previous[len2]
 }
 
 func calculateSimilarity(s1, s2 string) float64 {
-	maxLen := max(len(s1), len(s2))
+	maxLen := max(utf8.RuneCountInString(s1), utf8.RuneCountInString(s2))
 	if maxLen == 0 {
 		return 1.0
 	}
@@ -100,7 +123,38 @@ func calculateSimilarity(s1, s2 string) float64 {
 	return 1.0 - float64(distance)/float64(maxLen)
 }
 
-func calculateWordLevelMetrics(orig, trans []string) (float64, int, int, int, int) {
+// calculateCharacterErrorRate is the character edit distance divided by the
+// number of characters in the reference text, as reported by HTR benchmarks.
+// It can exceed 1 when the transcription is much longer than the reference.
+func calculateCharacterErrorRate(reference, hypothesis string) float64 {
+	referenceLen := utf8.RuneCountInString(reference)
+	if referenceLen == 0 {
+		if hypothesis == "" {
+			return 0.0
+		}
+		return 1.0
+	}
+	return float64(levenshteinDistance(reference, hypothesis)) / float64(referenceLen)
+}
+
+// Alignment operations reported by AlignWords
+const (
+	AlignEqual      = "equal"
+	AlignSubstitute = "substitute"
+	AlignDelete     = "delete"
+	AlignInsert     = "insert"
+)
+
+// Alignment pairs a word of the original with a word of the transcription. From
+// is -1 for insertions and To is -1 for deletions.
+type Alignment struct {
+	Op   string `json:"op"`
+	From int    `json:"from"`
+	To   int    `json:"to"`
+}
+
+// AlignWords returns the minimum edit alignment of two word sequences, in order
+func AlignWords(orig, trans []string) []Alignment {
 	m, n := len(orig), len(trans)
 	dp := make([][]int, m+1)
 	for i := range dp {
@@ -128,30 +182,50 @@ func calculateWordLevelMetrics(orig, trans []string) (float64, int, int, int, in
 	}
 
 	i, j := m, n
-	substitutions, deletions, insertions, correct := 0, 0, 0, 0
+	var alignments []Alignment
 
 	for i > 0 || j > 0 {
 		if i > 0 && j > 0 && orig[i-1] == trans[j-1] {
-			correct++
+			alignments = append(alignments, Alignment{Op: AlignEqual, From: i - 1, To: j - 1})
 			i--
 			j--
 		} else if i > 0 && j > 0 && dp[i][j] == dp[i-1][j-1]+1 {
-			substitutions++
+			alignments = append(alignments, Alignment{Op: AlignSubstitute, From: i - 1, To: j - 1})
 			i--
 			j--
 		} else if i > 0 && dp[i][j] == dp[i-1][j]+1 {
-			deletions++
+			alignments = append(alignments, Alignment{Op: AlignDelete, From: i - 1, To: -1})
 			i--
 		} else if j > 0 && dp[i][j] == dp[i][j-1]+1 {
-			insertions++
+			alignments = append(alignments, Alignment{Op: AlignInsert, From: -1, To: j - 1})
 			j--
 		}
 	}
 
+	slices.Reverse(alignments)
+	return alignments
+}
+
+func calculateWordLevelMetrics(orig, trans []string) (float64, int, int, int, int) {
+	substitutions, deletions, insertions, correct := 0, 0, 0, 0
+
+	for _, alignment := range AlignWords(orig, trans) {
+		switch alignment.Op {
+		case AlignEqual:
+			correct++
+		case AlignSubstitute:
+			substitutions++
+		case AlignDelete:
+			deletions++
+		case AlignInsert:
+			insertions++
+		}
+	}
+
 	totalEdits := substitutions + deletions + insertions
 	wer := 0.0
-	if m > 0 {
-		wer = float64(totalEdits) / float64(m)
+	if len(orig) > 0 {
+		wer = float64(totalEdits) / float64(len(orig))
 	}
 	wordAccuracy := 1.0 - wer
 
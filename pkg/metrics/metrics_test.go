@@ -0,0 +1,118 @@
+package metrics_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/lehigh-university-libraries/hocr-edit/pkg/metrics"
+)
+
+func almostEqual(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestCalculateAccuracyMetricsCountsRunes(t *testing.T) {
+	tests := []struct {
+		name        string
+		original    string
+		transcribed string
+		similarity  float64
+		cer         float64
+	}{
+		{"identical", "Dear Sir", "dear sir", 1.0, 0.0},
+		{"accented letter", "café", "cafe", 0.75, 0.25},
+		{"fraktur ligature", "ﬅraße", "ſtraße", 4.0 / 6.0, 2.0 / 5.0},
+		{"missing character", "abcd", "abc", 0.75, 0.25},
+		{"inserted characters", "ab", "abcd", 0.5, 1.0},
+		{"empty", "", "", 1.0, 0.0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := metrics.CalculateAccuracyMetrics(tt.original, tt.transcribed)
+			if !almostEqual(result.CharacterSimilarity, tt.similarity) {
+				t.Errorf("Expected character similarity %.3f, got %.3f", tt.similarity, result.CharacterSimilarity)
+			}
+			if !almostEqual(result.CharacterErrorRate, tt.cer) {
+				t.Errorf("Expected character error rate %.3f, got %.3f", tt.cer, result.CharacterErrorRate)
+			}
+		})
+	}
+}
+
+func TestCalculateAccuracyMetricsWords(t *testing.T) {
+	result := metrics.CalculateAccuracyMetrics("the quick brown fox", "the quack brown")
+
+	if result.TotalWordsOriginal != 4 || result.TotalWordsTranscribed != 3 {
+		t.Errorf("Expected 4 original and 3 transcribed words, got %d and %d", result.TotalWordsOriginal, result.TotalWordsTranscribed)
+	}
+	if result.CorrectWords != 2 || result.Substitutions != 1 || result.Deletions != 1 || result.Insertions != 0 {
+		t.Errorf("Unexpected alignment: %d correct, %d substitutions, %d deletions, %d insertions",
+			result.CorrectWords, result.Substitutions, result.Deletions, result.Insertions)
+	}
+	if !almostEqual(result.WordErrorRate, 0.5) {
+		t.Errorf("Expected word error rate 0.5, got %.3f", result.WordErrorRate)
+	}
+}
+
+func TestCalculateAccuracyMetricsWithProfile(t *testing.T) {
+	tests := []struct {
+		profile     string
+		original    string
+		transcribed string
+		cer         float64
+	}{
+		{metrics.ProfileStrict, "Dear Sir", "dear sir", 2.0 / 8.0},
+		{metrics.ProfileCaseInsensitive, "Dear Sir", "dear sir", 0.0},
+		{metrics.ProfilePunctuationStripped, "Dear Sir,", "Dear Sir", 0.0},
+		{metrics.ProfileNFC, "café", "café", 0.0},
+		{metrics.ProfileNFKC, "ﬁle", "file", 0.0},
+		{metrics.ProfileDiacriticFolded, "Übermäßig", "Ubermaßig", 0.0},
+		{metrics.ProfileHistorical, "Preſident", "President", 0.0},
+		{metrics.ProfileStrict, "Preſident", "President", 1.0 / 9.0},
+		{"historical+case_insensitive", "Preſident", "president", 0.0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.profile, func(t *testing.T) {
+			result, err := metrics.CalculateAccuracyMetricsWithProfile(tt.original, tt.transcribed, tt.profile)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !almostEqual(result.CharacterErrorRate, tt.cer) {
+				t.Errorf("Expected character error rate %.3f, got %.3f", tt.cer, result.CharacterErrorRate)
+			}
+		})
+	}
+
+	if _, err := metrics.CalculateAccuracyMetricsWithProfile("a", "b", "nonsense"); err == nil {
+		t.Error("Expected an error for an unknown profile")
+	}
+}
+
+func TestAlignWords(t *testing.T) {
+	alignments := metrics.AlignWords(
+		[]string{"Dear", "Sri", "wrote", "it"},
+		[]string{"Dear", "Sir", "wrote", "again"},
+	)
+	alignments = append(alignments, metrics.AlignWords([]string{"and", "yours", "truly"}, []string{"yours", "truly", "Jo"})...)
+
+	want := []metrics.Alignment{
+		{Op: metrics.AlignEqual, From: 0, To: 0},
+		{Op: metrics.AlignSubstitute, From: 1, To: 1},
+		{Op: metrics.AlignEqual, From: 2, To: 2},
+		{Op: metrics.AlignSubstitute, From: 3, To: 3},
+		{Op: metrics.AlignDelete, From: 0, To: -1},
+		{Op: metrics.AlignEqual, From: 1, To: 0},
+		{Op: metrics.AlignEqual, From: 2, To: 1},
+		{Op: metrics.AlignInsert, From: -1, To: 2},
+	}
+	if len(alignments) != len(want) {
+		t.Fatalf("Expected %d alignments, got %+v", len(want), alignments)
+	}
+	for i := range want {
+		if alignments[i] != want[i] {
+			t.Errorf("Alignment %d: expected %+v, got %+v", i, want[i], alignments[i])
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package metrics
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Normalization profiles accepted by CalculateAccuracyMetricsWithProfile.
+// Profiles can be combined with "+", e.g. "nfc+case_insensitive".
+const (
+	// ProfileDefault lowercases and collapses whitespace, matching CalculateAccuracyMetrics
+	ProfileDefault = "default"
+	// ProfileStrict only collapses whitespace, for case-sensitive diplomatic accuracy
+	ProfileStrict              = "strict"
+	ProfileCaseInsensitive     = "case_insensitive"
+	ProfilePunctuationStripped = "punctuation_stripped"
+	ProfileNFC                 = "nfc"
+	ProfileNFKC                = "nfkc"
+	ProfileDiacriticFolded     = "diacritic_folded"
+	// ProfileHistorical folds historical letter forms such as long s (ſ) to their modern equivalent
+	ProfileHistorical = "historical"
+)
+
+var whitespaceRegex = regexp.MustCompile(`\s+`)
+
+var historicalReplacer = strings.NewReplacer(
+	"ſ", "s",
+	"ẛ", "s",
+	"ꝛ", "r",
+)
+
+var normalizers = map[string]func(string) string{
+	ProfileDefault:         strings.ToLower,
+	ProfileStrict:          func(text string) string { return text },
+	ProfileCaseInsensitive: strings.ToLower,
+	ProfilePunctuationStripped: func(text string) string {
+		return strings.Map(func(r rune) rune {
+			if unicode.IsPunct(r) {
+				return -1
+			}
+			return r
+		}, text)
+	},
+	ProfileNFC:  norm.NFC.String,
+	ProfileNFKC: norm.NFKC.String,
+	ProfileDiacriticFolded: func(text string) string {
+		folded, _, err := transform.String(transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC), text)
+		if err != nil {
+			return text
+		}
+		return folded
+	},
+	ProfileHistorical: historicalReplacer.Replace,
+}
+
+// ProfileNames lists the normalization profiles that can be requested
+func ProfileNames() []string {
+	names := make([]string, 0, len(normalizers))
+	for name := range normalizers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Normalize applies a profile, or "+" separated combination of profiles, to text.
+// Whitespace is always trimmed and collapsed to single spaces.
+func Normalize(text, profile string) (string, error) {
+	if profile == "" {
+		profile = ProfileDefault
+	}
+
+	for _, name := range strings.Split(profile, "+") {
+		normalizer, ok := normalizers[strings.TrimSpace(name)]
+		if !ok {
+			return "", fmt.Errorf("unknown normalization profile %q", name)
+		}
+		text = normalizer(text)
+	}
+
+	return whitespaceRegex.ReplaceAllString(strings.TrimSpace(text), " "), nil
+}
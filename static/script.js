@@ -18,7 +18,11 @@ let currentDrawingBox = null;
 let pendingAnnotation = null;
 
 // Load sessions and check URL parameters on page load
-document.addEventListener('DOMContentLoaded', function() {
+document.addEventListener('DOMContentLoaded', async function() {
+    if (!await checkAuth()) {
+        return;
+    }
+
     // Check for session parameter first
     const urlParams = new URLSearchParams(window.location.search);
     const sessionParam = urlParams.get('session');
@@ -30,8 +34,61 @@ document.addEventListener('DOMContentLoaded', function() {
         // Load sessions list as usual
         loadSessions();
     }
+    loadEngines();
 });
 
+// checkAuth shows the login form when the server requires a user that is not signed in
+async function checkAuth() {
+    const response = await fetch('api/auth/me');
+    const data = await response.json();
+    if (response.ok) {
+        if (data.providers.length > 0) {
+            document.getElementById('user-name').textContent = data.user.name;
+            document.getElementById('user-info').classList.remove('hidden');
+        }
+        return true;
+    }
+
+    const next = new URLSearchParams(window.location.search).get('next') || '';
+    document.getElementById('upload-section').classList.add('hidden');
+    document.getElementById('login-section').classList.remove('hidden');
+    if (data.providers.includes('local')) {
+        document.getElementById('login-form').classList.remove('hidden');
+    }
+    if (data.providers.includes('oidc')) {
+        const link = document.getElementById('oidc-login');
+        link.href = 'api/auth/oidc/login?next=' + encodeURIComponent(next);
+        link.classList.remove('hidden');
+    }
+    return false;
+}
+
+async function login(event) {
+    event.preventDefault();
+    const response = await fetch('api/auth/login', {
+        method: 'POST',
+        headers: {'Content-Type': 'application/json'},
+        body: JSON.stringify({
+            username: document.getElementById('login-username').value,
+            password: document.getElementById('login-password').value
+        })
+    });
+    if (!response.ok) {
+        const data = await response.json();
+        document.getElementById('login-error').textContent = data.error;
+        return;
+    }
+
+    // only follow paths on this server
+    const next = new URLSearchParams(window.location.search).get('next');
+    window.location.href = next && next.startsWith('/') && !next.startsWith('//') ? next : window.location.pathname;
+}
+
+async function logout() {
+    await fetch('api/auth/logout', {method: 'POST'});
+    window.location.href = window.location.pathname;
+}
+
 // Global keyboard event listener for navigation
 document.addEventListener('keydown', function(e) {
     // Only handle navigation when correction interface is visible
@@ -522,6 +579,26 @@ async function loadSessions() {
     }
 }
 
+async function loadEngines() {
+    const select = document.getElementById('engine-select');
+    if (!select) return;
+
+    try {
+        const response = await fetch('api/engines');
+        const engines = await response.json();
+        select.innerHTML = engines.map(engine =>
+            `<option value="${engine.name}" ${engine.default ? 'selected' : ''}>${escapeHTML(engine.description)}</option>`
+        ).join('');
+    } catch (error) {
+        console.error('Error loading OCR engines:', error);
+    }
+}
+
+function getSelectedEngine() {
+    const select = document.getElementById('engine-select');
+    return select ? select.value : '';
+}
+
 function displaySessions(sessions) {
     const container = document.getElementById('sessions-list');
     if (sessions.length === 0) {
@@ -532,7 +609,9 @@ function displaySessions(sessions) {
     const html = sessions.map(session => 
         `<div style="border: 1px solid #333; padding: 15px; margin: 10px 0; border-radius: 8px; background: #111;">
         <h4>Session: ${session.id}</h4>
-        <p>Images: ${session.images.length} | Completed: ${session.images.filter(img => img.completed).length}</p>
+        <p>Images: ${session.images.length} | Completed: ${session.images.filter(img => img.completed).length} | Review: ${session.status || 'draft'}</p>
+        ${session.batch ? `<p>Batch: ${session.batch.status} | Nodes: ${session.batch.processed} of ${session.batch.nids.length} | Skipped: ${session.batch.skipped.length} | Failed: ${Object.keys(session.batch.failed).length}</p>` : ''}
+        ${session.job_error ? `<p>OCR failed: ${escapeHTML(session.job_error)}</p>` : ''}
         <p>Created: ${new Date(session.created_at).toLocaleString()}</p>
         <button class="btn btn-primary" onclick="loadSession('${session.id}')">Continue</button>
         </div>`
@@ -548,6 +627,8 @@ async function handleUpload() {
         return;
     }
 
+    const engine = getSelectedEngine();
+
     // Show upload progress
     const uploadArea = document.getElementById('upload-area');
     uploadArea.innerHTML = '<h3>Processing files...</h3><p>Please wait while files are uploaded and processed with OCR.</p>';
@@ -556,6 +637,16 @@ async function handleUpload() {
     for (let file of files) {
         formData.append('files', file);
     }
+    formData.append('engine', engine);
+
+    const altoInput = document.getElementById('alto-input');
+    if (altoInput && altoInput.files.length > 0) {
+        formData.append('alto', altoInput.files[0]);
+    }
+    const pageXMLInput = document.getElementById('pagexml-input');
+    if (pageXMLInput && pageXMLInput.files.length > 0) {
+        formData.append('pagexml', pageXMLInput.files[0]);
+    }
 
     try {
         const response = await fetch('api/upload', {
@@ -601,6 +692,8 @@ async function handleUrlUpload() {
         return;
     }
 
+    const engine = getSelectedEngine();
+
     // Show upload progress
     const uploadArea = document.getElementById('upload-area');
     uploadArea.innerHTML = '<h3>Processing image URL...</h3><p>Please wait while the image is downloaded and processed with OCR.</p>';
@@ -612,7 +705,8 @@ async function handleUrlUpload() {
                 'Content-Type': 'application/json',
             },
             body: JSON.stringify({
-                image_url: imageUrl
+                image_url: imageUrl,
+                engine: engine
             })
         });
 
@@ -643,10 +737,22 @@ function resetUploadArea() {
         <h3>Start New hOCR Correction Session</h3>
         <p>Upload images or provide an image URL - they'll be processed with hOCR-capable OCR</p>
 
+        <!-- OCR Engine -->
+        <div class="upload-method">
+            <h4>OCR Engine</h4>
+            <select id="engine-select" style="margin: 10px 0; padding: 8px; border: 1px solid #333; background: #111; color: #fff; border-radius: 4px;"></select>
+        </div>
+
         <!-- File Upload -->
         <div class="upload-method">
             <h4>Upload from Computer</h4>
-            <input type="file" id="file-input" accept=".jpg,.jpeg,.png,.gif,.csv" multiple style="margin: 10px 0;">
+            <input type="file" id="file-input" accept=".jpg,.jpeg,.png,.gif,.tif,.tiff,.pdf,.csv" multiple style="margin: 10px 0;">
+            <br>
+            <label for="alto-input" style="font-size: 0.9em; color: #aaa;">Existing ALTO XML (optional, skips OCR):</label>
+            <input type="file" id="alto-input" accept=".xml" style="margin: 10px 0;">
+            <br>
+            <label for="pagexml-input" style="font-size: 0.9em; color: #aaa;">Existing PAGE XML, e.g. from Transkribus (optional, skips OCR):</label>
+            <input type="file" id="pagexml-input" accept=".xml" style="margin: 10px 0;">
             <br>
             <button class="btn btn-primary" onclick="handleUpload()">Upload & Process</button>
         </div>
@@ -659,6 +765,7 @@ function resetUploadArea() {
             <button class="btn btn-primary" onclick="handleUrlUpload()">Process URL</button>
         </div>
     `;
+    loadEngines();
 }
 
 async function saveToIslandora() {
@@ -677,9 +784,7 @@ async function saveToIslandora() {
         return;
     }
 
-    // Get current HOCR data
-    const hocrData = getCurrentHOCR();
-    if (!hocrData) {
+    if (!hocrData || !hocrData.words) {
         alert('No HOCR data to save');
         return;
     }
@@ -691,23 +796,23 @@ async function saveToIslandora() {
     button.disabled = true;
 
     try {
-        const drupalUploadURL = currentSession.images[0].drupal_upload_url;
-        const response = await fetch(drupalUploadURL, {
-            method: 'POST',
-            headers: {
-                'Content-Type': 'text/vnd.hocr+html',
-                'Content-Location': 'private://derivatives/hocr/gcloud/' + currentSession.images[0].drupal_nid + '.hocr'
-            },
-            body: hocrData
+        const image = currentSession.images[currentImageIndex];
+
+        // Store the latest edits first so the server publishes what is on screen
+        await saveCurrentHOCR();
+
+        const response = await fetch('api/sessions/' + currentSession.id + '/images/' + image.id + '/publish', {
+            method: 'POST'
         });
+        const result = await response.json();
 
         if (!response.ok) {
-            const errorText = await response.text();
-            throw new Error(`Upload failed: HTTP ${response.status} - ${errorText}`);
+            throw new Error(result.error || `Upload failed: HTTP ${result.status_code}`);
         }
 
+        image.last_publish = result;
         alert('Successfully saved to Islandora!');
-        console.log('Islandora upload successful:', `HTTP ${response.status}`);
+        console.log('Islandora upload successful:', result.destination);
 
     } catch (error) {
         console.error('Islandora upload error:', error);
@@ -719,16 +824,6 @@ async function saveToIslandora() {
     }
 }
 
-function getCurrentHOCR() {
-    // Generate HOCR from current session data
-    if (!currentSession || !currentSession.images || currentSession.images.length === 0) {
-        return null;
-    }
-
-    const currentImage = currentSession.images[currentImageIndex];
-    return currentImage.corrected_hocr || currentImage.original_hocr;
-}
-
 function checkForDrupalSession() {
     // Show/hide the Islandora button based on session type
     const button = document.getElementById('save-islandora-btn');
@@ -765,6 +860,16 @@ function showCorrectionInterface() {
 }
 
 async function loadCurrentImage() {
+    if (isBatchRunning() && currentImageIndex >= currentSession.images.length) {
+        waitForBatch();
+        return;
+    }
+
+    if (currentSession && !currentSession.batch && currentSession.job_id && currentSession.images.length === 0) {
+        waitForJob(currentSession.job_id);
+        return;
+    }
+
     if (!currentSession || currentImageIndex >= currentSession.images.length) {
         finishSession();
         return;
@@ -797,6 +902,61 @@ async function loadCurrentImage() {
     img.src = image.image_url || '/static/uploads/' + image.image_path;
 }
 
+// Follow an OCR job over server-sent events and reload the session once it finishes
+function waitForJob(jobId) {
+    const progressText = document.getElementById('progress-text');
+    progressText.textContent = 'Waiting for OCR...';
+
+    const events = new EventSource('api/jobs/' + jobId + '/events');
+    events.onmessage = async function(event) {
+        const job = JSON.parse(event.data);
+        progressText.textContent = job.total
+            ? `OCR ${job.status}: ${job.done} of ${job.total}`
+            : `OCR ${job.status}` + (job.attempts > 1 ? ` (attempt ${job.attempts})` : '');
+
+        if (job.status === 'succeeded') {
+            events.close();
+            const response = await fetch('api/sessions/' + currentSession.id);
+            currentSession = await response.json();
+            loadCurrentImage();
+        } else if (job.status === 'failed') {
+            events.close();
+            alert('OCR failed: ' + job.error);
+        }
+    };
+    events.onerror = function() {
+        events.close();
+        progressText.textContent = 'Lost track of the OCR job, reload to check again';
+    };
+}
+
+function isBatchRunning() {
+    return currentSession && currentSession.batch && !currentSession.batch.finished_at;
+}
+
+// Poll a batch session until the page we are waiting for has been OCR'd
+async function waitForBatch() {
+    try {
+        const response = await fetch('api/sessions/' + currentSession.id + '/batch');
+        const progress = await response.json();
+
+        document.getElementById('progress-text').textContent =
+            `Processing Drupal nodes: ${progress.processed} of ${progress.total}` +
+            ` (${progress.skipped.length} skipped, ${Object.keys(progress.failed).length} failed)`;
+
+        if (progress.images > currentSession.images.length || progress.finished_at) {
+            const sessionResponse = await fetch('api/sessions/' + currentSession.id);
+            currentSession = await sessionResponse.json();
+            loadCurrentImage();
+            return;
+        }
+    } catch (error) {
+        console.error('Error checking batch progress:', error);
+    }
+
+    setTimeout(waitForBatch, 3000);
+}
+
 async function parseAndDisplayHOCR(hocrXML) {
     try {
         const response = await fetch('api/hocr/parse', {
@@ -864,6 +1024,13 @@ function renderHOCROverlay() {
         lineBox.style.width = ((lineBBox.x2 - lineBBox.x1) * scaleX) + 'px';
         lineBox.style.height = ((lineBBox.y2 - lineBBox.y1) * scaleY) + 'px';
 
+        // Clip slanted lines to their outline, so they do not cover their neighbours
+        const outline = lineOutline(line);
+        if (outline) {
+            lineBox.style.clipPath = 'polygon(' + outline.map(point =>
+                `${(point.x - lineBBox.x1) * scaleX}px ${(point.y - lineBBox.y1) * scaleY}px`).join(', ') + ')';
+        }
+
         // Apply confidence-based styling
         const avgConf = line.avgConfidence;
         if (avgConf < 60) {
@@ -885,6 +1052,40 @@ function renderHOCROverlay() {
     });
 }
 
+// The outline of a line from the left edge of its first word to the right edge of
+// its last, as the server writes the poly of a line. Lines whose words have no polys
+// and turned lines are left to their bbox.
+function lineOutline(line) {
+    if (!line.words.some(word => word.poly && word.poly.length === 4) || lineTextAngle(line.id) !== 0) {
+        return null;
+    }
+
+    const wordOutline = word => word.poly && word.poly.length === 4 ? word.poly : [
+        { x: word.bbox[0], y: word.bbox[1] },
+        { x: word.bbox[2], y: word.bbox[1] },
+        { x: word.bbox[2], y: word.bbox[3] },
+        { x: word.bbox[0], y: word.bbox[3] }
+    ];
+    const first = wordOutline(line.words[0]);
+    const last = wordOutline(line.words[line.words.length - 1]);
+    return [first[0], last[1], last[2], first[3]];
+}
+
+// The textangle of a line in the structure the words were parsed from
+function lineTextAngle(lineId) {
+    for (const page of hocrData.pages || []) {
+        for (const area of page.areas || []) {
+            for (const paragraph of area.paragraphs || []) {
+                const line = (paragraph.lines || []).find(candidate => candidate.id === lineId);
+                if (line) {
+                    return line.textangle || 0;
+                }
+            }
+        }
+    }
+    return 0;
+}
+
 function calculateLineBoundingBox(words) {
     if (!words || words.length === 0) {
         return { x1: 0, y1: 0, x2: 0, y2: 0 };
@@ -1143,12 +1344,13 @@ function updateLineText() {
     for (let i = 0; i < Math.max(words.length, currentLineWords.length); i++) {
         if (i < words.length && i < currentLineWords.length) {
             // Update existing word
-            currentLineWords[i].text = words[i];
+            setWordText(currentLineWords[i], words[i]);
         } else if (i < words.length) {
             // Need to create a new word - for now, just extend the last word's text
             // This is a simplified approach - in reality you'd need to handle word boundaries
             if (currentLineWords.length > 0) {
-                currentLineWords[currentLineWords.length - 1].text += ' ' + words.slice(currentLineWords.length).join(' ');
+                const lastWord = currentLineWords[currentLineWords.length - 1];
+                setWordText(lastWord, lastWord.text + ' ' + words.slice(currentLineWords.length).join(' '));
                 break;
             }
         } else {
@@ -1162,7 +1364,7 @@ function updateLineText() {
     currentLineWords.forEach(word => {
         const globalWord = hocrData.words.find(w => w.id === word.id);
         if (globalWord) {
-            globalWord.text = word.text;
+            setWordText(globalWord, word.text);
         }
     });
 
@@ -1177,12 +1379,25 @@ function updateLineText() {
     updateMetrics();
 }
 
+// Change a word's text, dropping the OCR's per-character confidences once they no
+// longer describe it. Character boxes survive edits that keep the length and are
+// marked stale by ones that do not.
+function setWordText(word, text) {
+    if (word.text !== text) {
+        delete word.char_confidences;
+    }
+    if (word.char_boxes && [...text].length !== [...word.text].length) {
+        word.char_boxes_stale = true;
+    }
+    word.text = text;
+}
+
 function updateWordText(wordId, newText) {
     if (!hocrData || !hocrData.words) return;
 
     const word = hocrData.words.find(w => w.id === wordId);
     if (word) {
-        word.text = newText;
+        setWordText(word, newText);
 
         // Update the main word editor if this is the selected word
         if (wordId === selectedWordId) {
@@ -1222,7 +1437,7 @@ function updateSelectedWord() {
     const word = hocrData.words.find(w => w.id === selectedWordId);
 
     if (word) {
-        word.text = newText;
+        setWordText(word, newText);
         updateHOCRSource();
         updateMetrics();
     }
@@ -1261,85 +1476,79 @@ function deleteSelectedLine() {
     }
 }
 
-function updateHOCRSource() {
-    if (!hocrData) return;
+let hocrRenderTimer = null;
 
-    // Generate hOCR XML from current data
-    const hocr = generateHOCRXML(hocrData);
+function updateHOCRSource() {
+    if (!hocrData || !currentSession || !currentSession.images[currentImageIndex]) return;
 
-    // Update session data
-    if (currentSession && currentSession.images[currentImageIndex]) {
-        currentSession.images[currentImageIndex].corrected_hocr = hocr;
-    }
+    // Keep the local copy in step with the edits; a burst of edits makes one request
+    const image = currentSession.images[currentImageIndex];
+    const payload = hocrEditPayload();
+    clearTimeout(hocrRenderTimer);
+    hocrRenderTimer = setTimeout(async () => {
+        try {
+            image.corrected_hocr = await renderHOCR(payload);
+        } catch (error) {
+            console.error('Error rendering hOCR:', error);
+        }
+    }, 300);
 }
 
-// Remove updateHOCRFromSource as we no longer have the textarea
-
-function generateHOCRXML(data) {
-    // Basic hOCR XML generation
-    let xml = '<?xml version="1.0" encoding="UTF-8"?>\n';
-    xml += '<!DOCTYPE html PUBLIC "-//W3C//DTD XHTML 1.0 Transitional//EN" "http://www.w3.org/TR/xhtml1/DTD/xhtml1-transitional.dtd">\n';
-    xml += '<html xmlns="http://www.w3.org/1999/xhtml" xml:lang="en" lang="en">\n';
-    xml += '<head>\n<title></title>\n</head>\n<body>\n';
-
-    xml += '<div class="ocr_page" id="page_1" title="bbox 0 0 ' + (currentSession.images[currentImageIndex].image_width || 1000) + ' ' + (currentSession.images[currentImageIndex].image_height || 1000) + '">\n';
-
-    // Group words by line, sorting them first
-    const sortedWords = [...data.words].sort((a, b) => {
-        // Sort by Y position first (top to bottom)
-        const yDiff = a.bbox[1] - b.bbox[1];
-        if (Math.abs(yDiff) > 10) { // Allow some tolerance for same line
-            return yDiff;
-        }
-        // If roughly same Y, sort by X position (left to right)
-        return a.bbox[0] - b.bbox[0];
-    });
+// The editor's words and the structure they were parsed from, which the server
+// turns into the one canonical hOCR document
+function hocrEditPayload() {
+    return {
+        session_id: currentSession.id,
+        image_id: currentSession.images[currentImageIndex].id,
+        words: hocrData.words.map(word => ({
+            id: word.id,
+            text: word.text,
+            confidence: word.confidence,
+            char_confidences: word.char_confidences,
+            char_boxes: word.char_boxes,
+            char_boxes_stale: word.char_boxes_stale,
+            lang: word.lang,
+            line_id: word.line_id,
+            bbox: { x1: word.bbox[0], y1: word.bbox[1], x2: word.bbox[2], y2: word.bbox[3] },
+            poly: word.poly
+        })),
+        pages: hocrData.pages || []
+    };
+}
 
-    const lineGroups = {};
-    sortedWords.forEach(word => {
-        if (!lineGroups[word.line_id]) {
-            lineGroups[word.line_id] = [];
-        }
-        lineGroups[word.line_id].push(word);
+async function renderHOCR(payload) {
+    const response = await fetch('api/hocr/render', {
+        method: 'POST',
+        headers: { 'Content-Type': 'application/json' },
+        body: JSON.stringify(payload)
     });
+    if (!response.ok) {
+        const result = await response.json().catch(() => ({}));
+        throw new Error(result.error || `HTTP ${response.status}`);
+    }
+    return response.text();
+}
 
-    // Generate XML for each line
-    Object.keys(lineGroups).forEach(lineId => {
-        const words = lineGroups[lineId];
-        if (words.length === 0) return;
-
-        // Sort words within line by X position
-        words.sort((a, b) => a.bbox[0] - b.bbox[0]);
-
-        // Calculate line bbox
-        const lineBbox = words.reduce((bbox, word) => {
-            return [
-                Math.min(bbox[0], word.bbox[0]),
-                Math.min(bbox[1], word.bbox[1]),
-                Math.max(bbox[2], word.bbox[2]),
-                Math.max(bbox[3], word.bbox[3])
-            ];
-        }, [Infinity, Infinity, -Infinity, -Infinity]);
-
-        xml += '  <span class="ocr_line" id="' + lineId + '" title="bbox ' + lineBbox.join(' ') + '">\n';
-
-        words.forEach(word => {
-            xml += '    <span class="ocrx_word" id="' + word.id + '" title="bbox ' + word.bbox.join(' ') + '; x_wconf ' + word.confidence + '">' + escapeXML(word.text) + '</span>\n';
-        });
-
-        xml += '  </span>\n';
+// Store the current image's edits as its corrected hOCR and keep the server's copy
+async function saveCurrentHOCR() {
+    const image = currentSession.images[currentImageIndex];
+    const response = await fetch('api/hocr/update', {
+        method: 'POST',
+        headers: { 'Content-Type': 'application/json', 'If-Match': sessionETag() },
+        body: JSON.stringify(hocrEditPayload())
     });
+    const result = await response.json();
+    if (response.status === 409) {
+        throw new Error('This session was changed by someone else since you opened it. Reload it to see their changes before saving again.');
+    }
+    if (!response.ok) {
+        throw new Error(result.error || `Save failed: HTTP ${response.status}`);
+    }
 
-    xml += '</div>\n</body>\n</html>';
-    return xml;
-}
-
-function escapeXML(text) {
-    return text.replace(/&/g, '&amp;')
-                  .replace(/</g, '&lt;')
-                  .replace(/>/g, '&gt;')
-                  .replace(/"/g, '&quot;')
-                  .replace(/'/g, '&#39;');
+    image.corrected_hocr = result.hocr;
+    image.completed = true;
+    currentSession.version = result.version;
+    return result.hocr;
 }
 
 function toggleLowConfidence() {
@@ -1358,17 +1567,58 @@ function updateProgress() {
     const current = currentImageIndex + 1;
     const percentage = (current / total) * 100;
 
-    document.getElementById('progress-text').textContent = `Image ${current} of ${total}`;
+    const image = currentSession.images[currentImageIndex];
+    let progressText = `Image ${current} of ${total}`;
+    if (image && image.page_count) {
+        progressText += ` (page ${image.page_number} of ${image.page_count})`;
+    }
+
+    document.getElementById('progress-text').textContent = progressText;
     document.getElementById('progress-bar').style.width = percentage + '%';
+    document.getElementById('review-status').textContent = image ? ` | ${image.status || 'draft'}` : '';
+}
+
+// reviewPage moves the current page through the review workflow with the chosen action
+async function reviewPage(select) {
+    const action = select.value;
+    select.value = '';
+    if (!action) return;
+
+    let comment = '';
+    if (action === 'reject') {
+        comment = prompt('What needs to be fixed?');
+        if (!comment) return;
+    }
+
+    const image = currentSession.images[currentImageIndex];
+    const response = await fetch(`api/sessions/${currentSession.id}/images/${image.id}/review`, {
+        method: 'POST',
+        headers: {'Content-Type': 'application/json', 'If-Match': sessionETag()},
+        body: JSON.stringify({action: action, comment: comment})
+    });
+    const data = await response.json();
+    if (!response.ok) {
+        alert('Unable to ' + action + ' page: ' + data.error);
+        if (response.status === 409) {
+            loadSession(currentSession.id);
+        }
+        return;
+    }
+
+    image.status = data.pages[image.id].to;
+    currentSession.status = data.status;
+    currentSession.version = data.version;
+    updateProgress();
 }
 
 async function updateMetrics() {
     if (!hocrData || !currentSession) return;
 
     const originalText = extractTextFromHOCR(currentSession.images[currentImageIndex].original_hocr);
-    const correctedText = extractTextFromHOCR(generateHOCRXML(hocrData));
 
     try {
+        const correctedText = extractTextFromHOCR(await renderHOCR(hocrEditPayload()));
+
         const response = await fetch('api/sessions/' + currentSession.id + '/metrics', {
             method: 'POST',
             headers: { 'Content-Type': 'application/json' },
@@ -1380,6 +1630,7 @@ async function updateMetrics() {
         const metrics = await response.json();
 
         document.getElementById('char-similarity').textContent = metrics.character_similarity.toFixed(3);
+        document.getElementById('char-error-rate').textContent = metrics.character_error_rate.toFixed(3);
         document.getElementById('word-accuracy').textContent = metrics.word_accuracy.toFixed(3);
         document.getElementById('word-error-rate').textContent = metrics.word_error_rate.toFixed(3);
         document.getElementById('total-words').textContent = hocrData.words.length;
@@ -1410,9 +1661,14 @@ function extractTextFromHOCR(hocrXML) {
 }
 
 async function saveAndNext() {
-    const hocrXML = generateHOCRXML(hocrData);
-    currentSession.images[currentImageIndex].corrected_hocr = hocrXML;
-    currentSession.images[currentImageIndex].completed = true;
+    clearTimeout(hocrRenderTimer);
+    try {
+        await saveCurrentHOCR();
+    } catch (error) {
+        console.error('Error saving hOCR:', error);
+        alert('Unable to save hOCR: ' + error.message);
+        return;
+    }
 
     // Save to backend
     await saveSession();
@@ -1445,13 +1701,26 @@ function previousImage() {
     }
 }
 
+// The ETag of the session as last loaded or saved, so the server can refuse stale writes
+function sessionETag() {
+    return '"' + (currentSession.version || 0) + '"';
+}
+
 async function saveSession() {
     try {
-        await fetch('api/sessions/' + currentSession.id, {
+        const response = await fetch('api/sessions/' + currentSession.id, {
             method: 'PUT',
-            headers: { 'Content-Type': 'application/json' },
+            headers: { 'Content-Type': 'application/json', 'If-Match': sessionETag() },
             body: JSON.stringify(currentSession)
         });
+        if (response.status === 409) {
+            alert('This session was changed by someone else since you opened it. It will be reloaded with their changes.');
+            await loadSession(currentSession.id);
+            return;
+        }
+        if (response.ok) {
+            currentSession.version = (await response.json()).version;
+        }
     } catch (error) {
         console.error('Error saving session:', error);
     }
@@ -1468,6 +1737,30 @@ window.addEventListener('resize', () => {
     setTimeout(renderHOCROverlay, 100);
 });
 
+// Open the combined multi-page hOCR for the whole session
+function exportSessionHOCR() {
+    if (!currentSession) return;
+    window.open('api/sessions/' + encodeURIComponent(currentSession.id) + '/hocr', '_blank');
+}
+
+// Download the whole session as ALTO XML
+function exportSessionALTO() {
+    if (!currentSession) return;
+    window.open('api/sessions/' + encodeURIComponent(currentSession.id) + '/alto', '_blank');
+}
+
+// Download the session as PAGE XML, zipped when it has more than one page
+function exportSessionPageXML() {
+    if (!currentSession) return;
+    window.open('api/sessions/' + encodeURIComponent(currentSession.id) + '/pagexml', '_blank');
+}
+
+// Download every image in the session as one PDF with an invisible text layer
+function exportSessionPDF() {
+    if (!currentSession) return;
+    window.open('api/sessions/' + encodeURIComponent(currentSession.id) + '/export.pdf', '_blank');
+}
+
 // Download formatted hOCR function
 async function downloadFormattedHocr() {
     if (!hocrData || !hocrData.words || hocrData.words.length === 0) {
@@ -1475,9 +1768,10 @@ async function downloadFormattedHocr() {
         return;
     }
 
+    let hocrXML = null;
     try {
-        // Generate the well-formatted hOCR XML
-        const hocrXML = generateHOCRXML(hocrData);
+        // The server writes the well-formatted hOCR
+        hocrXML = await renderHOCR(hocrEditPayload());
 
         // Copy to clipboard
         await navigator.clipboard.writeText(hocrXML);
@@ -1499,7 +1793,9 @@ async function downloadFormattedHocr() {
 
         // Fallback: create download link
         try {
-            const hocrXML = generateHOCRXML(hocrData);
+            if (!hocrXML) {
+                throw error;
+            }
             const blob = new Blob([hocrXML], { type: 'text/xml' });
             const url = URL.createObjectURL(blob);
             const a = document.createElement('a');